@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"inis/app/model"
+	"strings"
+	"time"
+)
+
+// ApiKeys - API Key 管理 - 用户管理自己的 API Key，管理员可以为其他用户（如应用帐号）签发
+type ApiKeys struct {
+	// 继承
+	base
+}
+
+// IGET - GET请求本体
+func (this *ApiKeys) IGET(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"all": this.all,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPOST - POST请求本体
+func (this *ApiKeys) IPOST(ctx *gin.Context) {
+
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"create": this.create,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPUT - PUT请求本体
+func (this *ApiKeys) IPUT(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IDEL - DELETE请求本体
+func (this *ApiKeys) IDEL(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"remove": this.remove,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// INDEX - GET请求本体
+func (this *ApiKeys) INDEX(ctx *gin.Context) {
+	this.json(ctx, nil, facade.Lang(ctx, "没什么用！"), 202)
+}
+
+// owner - 操作的用户 - 管理员可以通过 uid 参数指定其他用户，API Key 不能管理 API Key
+/**
+ * @return uid 用户ID，为0时已输出错误
+ */
+func (this *ApiKeys) owner(ctx *gin.Context, params map[string]any) (uid int) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return 0
+	}
+
+	if _, ok := ctx.Get("api_key"); ok {
+		this.json(ctx, nil, facade.Lang(ctx, "API Key 不能管理 API Key！"), 403)
+		return 0
+	}
+
+	uid = user.Id
+	if !utils.Is.Empty(params["uid"]) && cast.ToInt(params["uid"]) != user.Id {
+		if !model.PermissionsAdmin(user.Id) {
+			this.json(ctx, nil, facade.Lang(ctx, "无权限！"), 403)
+			return 0
+		}
+		uid = cast.ToInt(params["uid"])
+	}
+
+	return uid
+}
+
+// all 用户的 API Key
+func (this *ApiKeys) all(ctx *gin.Context) {
+
+	uid := this.owner(ctx, this.params(ctx))
+	if uid == 0 {
+		return
+	}
+
+	list := model.ApiKeysList(uid)
+	if len(list) == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "无数据！"), 204)
+		return
+	}
+
+	this.json(ctx, list, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// create 签发 API Key - 密钥只在创建时返回一次
+func (this *ApiKeys) create(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx, map[string]any{
+		"expire": 0,
+	})
+
+	uid := this.owner(ctx, params)
+	if uid == 0 {
+		return
+	}
+
+	name := strings.TrimSpace(cast.ToString(params["name"]))
+	if utils.Is.Empty(name) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "name"), 400)
+		return
+	}
+
+	if len([]rune(name)) > 64 {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 长度不能超过 %d！", "name", 64), 400)
+		return
+	}
+
+	// 必须明确指定权限范围，需要全部权限时使用 *
+	scopes := model.PermissionsNormalize(params["scopes"])
+	if len(scopes) == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "scopes"), 400)
+		return
+	}
+
+	expire := cast.ToInt64(params["expire"])
+	if expire < 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 格式不正确！", "expire"), 400)
+		return
+	}
+
+	if uid != this.meta.user(ctx).Id && utils.Is.Empty(facade.DB.Model(&model.Users{}).Find(uid)) {
+		this.json(ctx, nil, facade.Lang(ctx, "用户不存在！"), 400)
+		return
+	}
+
+	item, secret, err := model.ApiKeysCreate(uid, name, scopes, time.Duration(expire)*time.Second)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	this.json(ctx, gin.H{
+		"item":   item,
+		"secret": secret,
+	}, facade.Lang(ctx, "创建成功！请妥善保存密钥，关闭后将无法再次查看！"), 200)
+}
+
+// remove 吊销 API Key - 管理员可以吊销任意用户的
+func (this *ApiKeys) remove(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	uid := this.owner(ctx, params)
+	if uid == 0 {
+		return
+	}
+
+	// id 数组 - 参数归一化
+	ids := utils.Unity.Ids(params["ids"])
+
+	if utils.Is.Empty(ids) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "ids"), 400)
+		return
+	}
+
+	var owner any = uid
+	if model.PermissionsAdmin(this.meta.user(ctx).Id) {
+		owner = nil
+	}
+
+	count := model.ApiKeysRevoke(owner, cast.ToIntSlice(ids))
+	if count == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "无可操作数据！"), 204)
+		return
+	}
+
+	this.json(ctx, gin.H{"ids": ids, "count": count}, facade.Lang(ctx, "删除成功！"), 200)
+}
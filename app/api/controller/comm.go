@@ -1,6 +1,10 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cast"
@@ -8,6 +12,7 @@ import (
 	"inis/app/facade"
 	"inis/app/model"
 	"inis/app/validator"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -23,7 +28,11 @@ func (this *Comm) IGET(ctx *gin.Context) {
 	// 转小写
 	method := strings.ToLower(ctx.Param("method"))
 
-	allow := map[string]any{}
+	allow := map[string]any{
+		"oauth-providers": this.oauthProviders,
+		"oauth-authorize": this.oauthAuthorize,
+		"oauth-callback":  this.oauthCallback,
+	}
 	err := this.call(allow, method, ctx)
 
 	if err != nil {
@@ -43,7 +52,10 @@ func (this *Comm) IPOST(ctx *gin.Context) {
 		"register":      this.register,
 		"social-login":  this.socialLogin,
 		"check-token":   this.checkToken,
+		"refresh":       this.refresh,
 		"reset-passowd": this.resetPassword,
+		"unlock":        this.unlock,
+		"verify-2fa":    this.verify2fa,
 	}
 	err := this.call(allow, method, ctx)
 
@@ -131,48 +143,44 @@ func (this *Comm) login(ctx *gin.Context) {
 	// 查询用户是否存在
 	item := facade.DB.Model(&table).Or([]any{
 		[]any{"email", "=", params["account"]},
-		[]any{"phone", "=", params["account"]},
+		model.UsersSocial("phone", params["account"]),
 		[]any{"account", "=", params["account"]},
 	}).Where("source", params["source"]).Find()
 
-	if utils.Is.Empty(item) {
-		this.json(ctx, nil, facade.Lang(ctx, "账户不存在！"), 400)
-		return
-	}
+	// 失败计数 - 用户存在时按用户计数（帐号、邮箱、手机号共用），否则按提交的帐号计数
+	throttle := this.throttle(params["source"], params["account"], table.Id)
 
-	if utils.Is.Empty(table.Password) {
-		this.json(ctx, nil, facade.Lang(ctx, "该帐号未设置密码，请切换登录方式！"), 400)
+	// 校验密码之前先预占一次尝试 - 并发请求不能都通过检查
+	if wait, lock := facade.Throttle.Acquire(throttle, ctx.ClientIP()); wait > 0 {
+		this.json(ctx, gin.H{"wait": wait, "lock": lock}, facade.Lang(ctx, utils.Ternary(lock,
+			"帐号已临时锁定，请 %d 秒后再试，或通过验证码解锁！",
+			"尝试次数过多，请 %d 秒后再试！",
+		), wait), 429)
 		return
 	}
 
-	// 密码校验
-	if utils.Password.Verify(table.Password, params["password"]) == false {
-		this.json(ctx, nil, facade.Lang(ctx, "密码错误！"), 400)
+	// 帐号不存在、未设置密码时用假哈希校验一次 - 响应时间一致，避免判断帐号是否存在
+	exist := !utils.Is.Empty(item) && !utils.Is.Empty(table.Password)
+	hash  := utils.Ternary(exist, table.Password, facade.Password.Dummy())
+
+	// 帐号不存在、未设置密码、密码错误 - 统一提示，避免通过提示判断帐号是否存在
+	if !facade.Password.Verify(hash, params["password"]) || !exist {
+		this.json(ctx, gin.H{"lock": facade.Throttle.Locked(throttle)}, facade.Lang(ctx, "帐号或密码错误！"), 400)
 		return
 	}
 
-	jwt := facade.Jwt().Create(facade.H{
-		"uid":  table.Id,
-		"hash": facade.Hash.Sum32(table.Password),
-	})
-
-	// 删除 item 中的密码
-	delete(item, "password")
-	// 更新用户登录时间
-	item["login_time"] = time.Now().Unix()
-	facade.DB.Model(&table).Where("id", table.Id).Update(map[string]any{
-		"login_time": item["login_time"],
-	})
+	facade.Throttle.Release(throttle, ctx.ClientIP())
 
-	result := map[string]any{
-		"user":  item,
-		"token": jwt.Text,
+	// 旧算法或旧参数的哈希 - 用明文重新生成，不更换安全戳
+	if facade.Password.NeedsRehash(table.Password) {
+		if hash := facade.Password.Create(params["password"]); !utils.Is.Empty(hash) {
+			facade.DB.Drive().Model(&model.Users{}).Where("id = ?", table.Id).UpdateColumn("password", hash)
+		}
 	}
 
-	// 往客户端写入cookie - 存储登录token
-	setToken(ctx, jwt.Text)
-
-	this.json(ctx, result, facade.Lang(ctx, "登录成功！"), 200)
+	// 开启了两步验证时返回挑战令牌，否则签发令牌
+	result, msg, code := this.signin(ctx, table, item)
+	this.json(ctx, result, facade.Lang(ctx, msg), code)
 }
 
 // 注册
@@ -214,39 +222,22 @@ func (this *Comm) register(ctx *gin.Context) {
 	}
 
 	// 判断是否已经注册
-	ok := facade.DB.Model(&table).Where([]any{
+	exist := facade.DB.Model(&table).Where([]any{
 		[]any{"source", "=", params["source"]},
-		[]any{social, "=", params["social"]},
+		model.UsersSocial(social, params["social"]),
 	}).Exist()
-	// 已注册
-	if ok {
-		switch social {
-		case "email":
-			this.json(ctx, nil, facade.Lang(ctx, "该邮箱已经注册！"), 400)
-			return
-		case "phone":
-			this.json(ctx, nil, facade.Lang(ctx, "该手机号已经注册！"), 400)
-			return
-		}
-	}
-
-	if !utils.Is.Empty(params["account"]) {
-		// 判断账号是否已经注册
-		ok := facade.DB.Model(&table).Where([]any{
-			[]any{"source", "=", params["source"]},
-			[]any{"account", "=", params["account"]},
-		}).Exist()
-		if ok {
-			this.json(ctx, nil, facade.Lang(ctx, "该帐号已经注册！"), 400)
-			return
-		}
-	}
 
-	cacheName := fmt.Sprintf("%v-%v", social, params["social"])
+	cacheName := fmt.Sprintf("register[%v:%v]", social, params["social"])
 
 	// 验证码为空 - 发送验证码
 	if utils.Is.Empty(params["code"]) {
 
+		// 已注册时不发送，提示与发送成功一致 - 避免通过注册接口判断邮箱、手机号是否已注册
+		if exist {
+			this.json(ctx, nil, facade.Lang(ctx, "验证码发送成功！"), 201)
+			return
+		}
+
 		drive := utils.Ternary(social == "email", "email", "sms")
 		sms   := facade.NewSMS(drive).VerifyCode(params["social"])
 		if sms.Error != nil {
@@ -264,21 +255,36 @@ func (this *Comm) register(ctx *gin.Context) {
 		return
 	}
 
-	// 获取缓存里面的验证码
-	cacheCode := facade.Cache.Get(cacheName)
+	// 校验验证码 - 已注册时没有发送过验证码，同样提示验证码错误
+	if !this.verifyCode(ctx, cacheName, params["code"]) {
+		return
+	}
 
-	if cast.ToString(params["code"]) != cacheCode {
-		this.json(ctx, nil, facade.Lang(ctx, "验证码错误！"), 400)
+	// 以下提示只有收到验证码的人才能看到
+	if exist {
+		this.json(ctx, nil, facade.Lang(ctx, utils.Ternary(social == "email", "该邮箱已经注册！", "该手机号已经注册！")), 400)
 		return
 	}
 
+	if !utils.Is.Empty(params["account"]) {
+		// 判断账号是否已经注册
+		ok := facade.DB.Model(&table).Where([]any{
+			[]any{"source", "=", params["source"]},
+			[]any{"account", "=", params["account"]},
+		}).Exist()
+		if ok {
+			this.json(ctx, nil, facade.Lang(ctx, "该帐号已经注册！"), 400)
+			return
+		}
+	}
+
 	// 允许存储的字段
 	allow := []any{"account", "password", "email", "phone", "nickname", "avatar", "description", "source"}
 	// 动态给结构体赋值
 	for key, val := range params {
 		// 加密密码
 		if key == "password" {
-			val = utils.Password.Create(params["password"])
+			val = facade.Password.Create(params["password"])
 		}
 		// 防止恶意传入字段
 		if utils.In.Array(key, allow) {
@@ -300,21 +306,17 @@ func (this *Comm) register(ctx *gin.Context) {
 	// 删除验证码
 	facade.Cache.Del(cacheName)
 
-	jwt := facade.Jwt().Create(facade.H{
-		"uid":  table.Id,
-		"hash": facade.Hash.Sum32(table.Password),
-	})
+	// 签发令牌并写入cookie
+	result, err := this.token(ctx, table.Id, "", "")
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
 
 	// 删除密码
-	table.Password = ""
+	table.Password, table.Stamp = "", ""
 
-	result := map[string]any{
-		"user":  table,
-		"token": jwt.Text,
-	}
-
-	// 往客户端写入cookie - 存储登录token
-	setToken(ctx, jwt.Text)
+	result["user"] = table
 
 	this.json(ctx, result, facade.Lang(ctx, "注册成功！"), 200)
 }
@@ -353,7 +355,7 @@ func (this *Comm) socialLogin(ctx *gin.Context) {
 	// 判断是否已经注册
 	ok := facade.DB.Model(&table).Where([]any{
 		[]any{"source", "=", params["source"]},
-		[]any{social, "=", params["social"]},
+		model.UsersSocial(social, params["social"]),
 	}).Exist()
 	// 未注册 - 自动注册
 	if !ok {
@@ -396,11 +398,8 @@ func (this *Comm) socialLogin(ctx *gin.Context) {
 		return
 	}
 
-	// 获取缓存里面的验证码
-	cacheCode := facade.Cache.Get(cacheName)
-
-	if cast.ToString(params["code"]) != cacheCode {
-		this.json(ctx, nil, facade.Lang(ctx, "验证码错误！"), 400)
+	// 校验验证码
+	if !this.verifyCode(ctx, cacheName, params["code"]) {
 		return
 	}
 
@@ -408,30 +407,11 @@ func (this *Comm) socialLogin(ctx *gin.Context) {
 	facade.Cache.Del(cacheName)
 
 	// 查询用户
-	item := facade.DB.Model(&table).Where(social, params["social"]).Find()
-
-	jwt := facade.Jwt().Create(facade.H{
-		"uid":  table.Id,
-		"hash": facade.Hash.Sum32(table.Password),
-	})
-
-	// 删除密码
-	delete(item, "password")
-	// 更新用户登录时间
-	item["login_time"] = time.Now().Unix()
-	facade.DB.Model(&table).Where("id", table.Id).Update(map[string]any{
-		"login_time": item["login_time"],
-	})
-
-	result := map[string]any{
-		"user":  item,
-		"token": jwt.Text,
-	}
+	item := facade.DB.Model(&table).Where(model.UsersSocial(social, params["social"])).Find()
 
-	// 往客户端写入cookie - 存储登录token
-	setToken(ctx, jwt.Text)
-
-	this.json(ctx, result, facade.Lang(ctx, "登录成功！"), 200)
+	// 开启了两步验证时返回挑战令牌，否则签发令牌
+	result, msg, code := this.signin(ctx, table, item)
+	this.json(ctx, result, facade.Lang(ctx, msg), code)
 }
 
 // 忘记密码
@@ -468,32 +448,11 @@ func (this *Comm) resetPassword(ctx *gin.Context) {
 
 	var user map[string]any
 
-	// 账号优先
+	// 账号优先 - 未注册时同样交给 password 处理，提示与已注册一致
 	if !utils.Is.Empty(params["account"]) {
-
-		// 判断账号是否已经注册
 		user = facade.DB.Model(&table).Where("source", params["source"]).Where("account", params["account"]).Find()
-		if utils.Is.Empty(user) {
-			this.json(ctx, nil, facade.Lang(ctx, "该账号未注册！"), 400)
-			return
-		}
-
-		// 找回密码
-		this.password(ctx, user)
-		return
-	}
-
-	// 判断是否已经注册
-	user = facade.DB.Model(&table).Where("source", params["source"]).Where(social, params["social"]).Find()
-	if utils.Is.Empty(user) {
-		switch social {
-		case "email":
-			this.json(ctx, nil, facade.Lang(ctx, "该邮箱未注册！"), 400)
-			return
-		case "phone":
-			this.json(ctx, nil, facade.Lang(ctx, "该手机号未注册！"), 400)
-			return
-		}
+	} else {
+		user = facade.DB.Model(&table).Where("source", params["source"]).Where(model.UsersSocial(social, params["social"])).Find()
 	}
 
 	// 找回密码
@@ -508,61 +467,29 @@ func (this *Comm) password(ctx *gin.Context, user map[string]any) {
 
 	drives := cast.ToStringMap(facade.SMSToml.Get("drive"))
 
-	// 驱动、社交、驱动模式
-	var drive, social, mode string
-
-	// 邮箱驱动 - 次之
-	if !utils.Is.Empty(drives["email"]) && !utils.Is.Empty(user["email"]) {
-		mode   = "email"
-		drive  = cast.ToString(drives["email"])
-		social = cast.ToString(user["email"])
-	}
-
-	// SMS驱动 - 优先 - 覆盖
-	if !utils.Is.Empty(drives["sms"]) && !utils.Is.Empty(user["phone"]) {
-		mode   = "sms"
-		drive  = cast.ToString(drives["sms"])
-		social = cast.ToString(user["phone"])
-	}
+	// 只发送到帐号绑定的手机号或邮箱 - 提交的 social 只用于查找帐号
+	_, drive, social := this.sender(user)
 
-	// 如果提交了 social
-	if !utils.Is.Empty(params["social"]) {
-		var unknown string
-		unknown = utils.Ternary(utils.Is.Email(params["social"]), "email", mode)
-		unknown = utils.Ternary(utils.Is.Phone(params["social"]), "sms", mode)
-		// 如果驱动存在，且提交的 social 也存在
-		if !utils.Is.Empty(drives[mode]) && !utils.Is.Empty(unknown) {
-			mode   = unknown
-			social = cast.ToString(params["social"])
-			drive  = cast.ToString(drives[mode])
-		}
+	// 既没开启邮箱驱动，也没开启SMS驱动
+	if utils.Is.Empty(drives["email"]) && utils.Is.Empty(drives["sms"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "请联系管理员重置密码！"), 400)
+		return
 	}
 
-	// 都不满足
+	// 帐号不存在，或没有可用的发送方式 - 提示与发送成功、验证码错误一致，避免通过提示判断帐号是否存在
 	if utils.Is.Empty(drive) {
 
-		// 既没开启邮箱驱动，也没开启SMS驱动
-		if utils.Is.Empty(drives["email"]) && utils.Is.Empty(drives["sms"]) {
-			this.json(ctx, nil, facade.Lang(ctx, "请联系管理员重置密码！"), 400)
-			return
-		}
-
-		if !utils.Is.Empty(user["phone"]) {
-			this.json(ctx, nil, facade.Lang(ctx, "管理员未开启短信服务，无法发送验证码！"), 400)
+		if utils.Is.Empty(params["code"]) {
+			this.json(ctx, nil, facade.Lang(ctx, "如果帐号存在且绑定了手机或邮箱，验证码已发送，请注意查收！"), 201)
 			return
 		}
 
-		if !utils.Is.Empty(user["email"]) {
-			this.json(ctx, nil, facade.Lang(ctx, "管理员未开启邮箱服务，无法发送验证码！"), 400)
-			return
-		}
-
-		this.json(ctx, nil, facade.Lang(ctx, "请联系管理员重置密码！"), 400)
+		this.verifyCode(ctx, fmt.Sprintf("reset[%v:%v:%v]", params["source"], params["account"], params["social"]), params["code"])
 		return
 	}
 
-	// 缓存名称
-	cacheName := fmt.Sprintf("%v-%v", drive, social)
+	// 缓存名称 - 按用户区分，验证码只能重置这个帐号的密码
+	cacheName := fmt.Sprintf("reset[%v]", user["id"])
 
 	// 验证码为空 - 发送验证码
 	if utils.Is.Empty(params["code"]) {
@@ -575,8 +502,7 @@ func (this *Comm) password(ctx *gin.Context, user map[string]any) {
 		// 缓存验证码 - 5分钟
 		go facade.Cache.Set(cacheName, sms.VerifyCode, 5 * time.Minute)
 
-		msg := fmt.Sprintf("验证码发送至您的%v：%s，请注意查收！", utils.Ternary(mode == "email", "邮箱", "手机"), social)
-		this.json(ctx, nil, facade.Lang(ctx, msg), 201)
+		this.json(ctx, nil, facade.Lang(ctx, "如果帐号存在且绑定了手机或邮箱，验证码已发送，请注意查收！"), 201)
 		return
 	}
 
@@ -585,19 +511,19 @@ func (this *Comm) password(ctx *gin.Context, user map[string]any) {
 		return
 	}
 
-	// 获取缓存里面的验证码
-	cacheCode := facade.Cache.Get(cacheName)
-
-	if cast.ToString(params["code"]) != cast.ToString(cacheCode) {
-		this.json(ctx, nil, facade.Lang(ctx, "验证码错误！"), 400)
+	// 校验验证码
+	if !this.verifyCode(ctx, cacheName, params["code"]) {
 		return
 	}
 
 	// 加密密码
-	password := utils.Password.Create(params["password"])
+	password := facade.Password.Create(params["password"])
 
-	// 更新密码
-	tx := facade.DB.Model(&model.Users{}).Where("id", user["id"]).UpdateColumn("password", password)
+	// 更新密码并更换安全戳 - 已签发的访问令牌立即失效
+	tx := facade.DB.Drive().Model(&model.Users{}).Where("id = ?", user["id"]).UpdateColumns(map[string]any{
+		"password": password,
+		"stamp":    model.UsersStampNew(),
+	})
 	if tx.Error != nil {
 		this.json(ctx, nil, tx.Error.Error(), 400)
 		return
@@ -605,10 +531,140 @@ func (this *Comm) password(ctx *gin.Context, user map[string]any) {
 
 	// 删除验证码
 	go facade.Cache.Del(cacheName)
+	facade.Cache.Del(fmt.Sprintf("user[%v]", user["id"]))
+	// 吊销全部会话 - 所有设备需要重新登录
+	model.SessionsRevokeUser(user["id"], "")
 
 	this.json(ctx, nil, facade.Lang(ctx, "密码重置成功！"), 200)
 }
 
+// sender - 发送验证码的方式 - 短信优先，其次是邮箱
+/**
+ * @param user 用户
+ * @return mode 驱动模式（sms、email），drive 驱动，social 手机号或邮箱 - 都不可用时为空
+ */
+func (this *Comm) sender(user map[string]any) (mode, drive, social string) {
+
+	drives := cast.ToStringMap(facade.SMSToml.Get("drive"))
+
+	// 邮箱驱动 - 次之
+	if !utils.Is.Empty(drives["email"]) && !utils.Is.Empty(user["email"]) {
+		mode   = "email"
+		drive  = cast.ToString(drives["email"])
+		social = cast.ToString(user["email"])
+	}
+
+	// SMS驱动 - 优先 - 覆盖
+	if !utils.Is.Empty(drives["sms"]) && !utils.Is.Empty(user["phone"]) {
+		mode   = "sms"
+		drive  = cast.ToString(drives["sms"])
+		social = cast.ToString(user["phone"])
+	}
+
+	return mode, drive, social
+}
+
+// throttle - 登录失败计数的标识
+/**
+ * @param source 注册来源
+ * @param account 提交的帐号（或邮箱、手机号）
+ * @param uid 用户ID - 0 表示帐号不存在
+ */
+func (this *Comm) throttle(source, account any, uid int) string {
+	if uid != 0 {
+		return fmt.Sprintf("uid:%d", uid)
+	}
+	return fmt.Sprintf("%v:%v", source, account)
+}
+
+// verifyCode - 校验验证码 - 按验证码和IP预占尝试次数，错误过多时作废验证码，防止暴力破解
+/**
+ * @param cacheName 验证码的缓存名称
+ * @param code 提交的验证码
+ * @return bool 校验通过 - 不通过时已输出错误
+ */
+func (this *Comm) verifyCode(ctx *gin.Context, cacheName string, code any) bool {
+
+	throttle := "code:" + cacheName
+
+	if wait, _ := facade.Throttle.Acquire(throttle, ctx.ClientIP()); wait > 0 {
+		this.json(ctx, gin.H{"wait": wait}, facade.Lang(ctx, "尝试次数过多，请 %d 秒后再试！", wait), 429)
+		return false
+	}
+
+	cacheCode := facade.Cache.Get(cacheName)
+	if utils.Is.Empty(cacheCode) || cast.ToString(code) != cast.ToString(cacheCode) {
+		// 达到锁定次数 - 验证码作废，需要重新发送
+		if facade.Throttle.Locked(throttle) {
+			facade.Cache.Del(cacheName)
+		}
+		this.json(ctx, nil, facade.Lang(ctx, "验证码错误！"), 400)
+		return false
+	}
+
+	facade.Throttle.Release(throttle, ctx.ClientIP())
+
+	return true
+}
+
+// unlock 解锁帐号 - 不带 code 时发送验证码到绑定的手机或邮箱，带 code 时校验并解锁
+func (this *Comm) unlock(ctx *gin.Context) {
+
+	// 表数据结构体
+	table := model.Users{}
+	// 请求参数
+	params := this.params(ctx, map[string]any{
+		"source": "default",
+	})
+
+	if utils.Is.Empty(params["account"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "请提交帐号（或邮箱和手机号）！"), 400)
+		return
+	}
+
+	user := facade.DB.Model(&table).Or([]any{
+		[]any{"email", "=", params["account"]},
+		model.UsersSocial("phone", params["account"]),
+		[]any{"account", "=", params["account"]},
+	}).Where("source", params["source"]).Find()
+
+	_, drive, social := this.sender(user)
+	cacheName := fmt.Sprintf("unlock[%d]", table.Id)
+
+	// 发送验证码 - 帐号不存在或没有可用的发送方式时同样提示成功，避免通过提示判断帐号是否存在
+	if utils.Is.Empty(params["code"]) {
+
+		if !utils.Is.Empty(user) && !utils.Is.Empty(drive) {
+			sms := facade.NewSMS(drive).VerifyCode(social)
+			if sms.Error != nil {
+				facade.Log.Error(map[string]any{
+					"error":     sms.Error,
+					"func_name": utils.Caller().FuncName,
+					"file_name": utils.Caller().FileName,
+					"file_line": utils.Caller().Line,
+				}, "解锁验证码发送失败")
+			} else {
+				// 缓存验证码 - 5分钟
+				go facade.Cache.Set(cacheName, sms.VerifyCode, 5 * time.Minute)
+			}
+		}
+
+		this.json(ctx, nil, facade.Lang(ctx, "如果帐号存在且绑定了手机或邮箱，验证码已发送，请注意查收！"), 201)
+		return
+	}
+
+	// 帐号不存在时 cacheName 为 unlock[0]，不会有验证码
+	if !this.verifyCode(ctx, cacheName, params["code"]) {
+		return
+	}
+
+	// 删除验证码
+	go facade.Cache.Del(cacheName)
+	facade.Throttle.Reset(this.throttle(params["source"], params["account"], table.Id))
+
+	this.json(ctx, nil, facade.Lang(ctx, "解锁成功！"), 200)
+}
+
 // 校验token
 func (this *Comm) checkToken(ctx *gin.Context) {
 
@@ -635,6 +691,12 @@ func (this *Comm) checkToken(ctx *gin.Context) {
 		return
 	}
 
+	// 会话已被吊销或不存在
+	if session, _ := model.SessionsFind(jwt.Jti); utils.Is.Empty(jwt.Jti) || !session.Active() {
+		this.json(ctx, nil, facade.Lang(ctx, "登录已过期，请重新登录！"), 401)
+		return
+	}
+
 	// 表数据结构体
 	table := model.Users{}
 	// 查询用户
@@ -644,46 +706,182 @@ func (this *Comm) checkToken(ctx *gin.Context) {
 		return
 	}
 
-	// token 有效时长
-	valid := jwt.Valid
-
+	// 续期 - 访问令牌有效期很短，只能通过刷新令牌换取新的
 	if cast.ToBool(params["renew"]) {
-		jwt = facade.Jwt().Create(facade.H{
-			"uid":  table.Id,
-			"hash": facade.Hash.Sum32(table.Password),
-		})
-		valid = cast.ToInt64(utils.Calc(facade.AppToml.Get("jwt.expire", "7200")))
-		// 往客户端写入cookie - 存储登录token
-		setToken(ctx, jwt.Text)
+		this.refresh(ctx)
+		return
 	}
 
 	delete(item, "password")
+	delete(item, "stamp")
 
 	this.json(ctx, gin.H{
 		"user":       item,
-		"token":      jwt.Text,
-		"valid_time": valid,
+		"token":      token,
+		"valid_time": jwt.Valid,
 	}, facade.Lang(ctx, facade.Lang(ctx, "合法的token！")), 200)
 }
 
+// 刷新令牌 - 换取新的访问令牌，刷新令牌同时轮换
+func (this *Comm) refresh(ctx *gin.Context) {
+
+	params := this.params(ctx)
+
+	token := cast.ToString(params["refresh_token"])
+	if utils.Is.Empty(token) {
+		token, _ = ctx.Cookie(refreshName())
+	}
+
+	if utils.Is.Empty(token) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "refresh_token"), 412)
+		return
+	}
+
+	expire := time.Duration(facade.JwtRefreshExpire()) * time.Second
+	item, next, err := model.TokensRotate(token, ctx.ClientIP(), ctx.Request.UserAgent(), expire)
+	if err != nil {
+		clearToken(ctx)
+		this.json(ctx, nil, facade.Lang(ctx, err.Error()), 401)
+		return
+	}
+
+	// 会话已被吊销（其他设备上退出或被强制下线）或不存在（没有会话的旧令牌） - 需要重新登录
+	session, _ := model.SessionsFind(item.Family)
+	if !session.Active() {
+		model.SessionsRevoke(item.Family)
+		clearToken(ctx)
+		this.json(ctx, nil, facade.Lang(ctx, "登录已过期，请重新登录！"), 401)
+		return
+	}
+	model.SessionsTouch(session, ctx.ClientIP(), time.Now().Add(expire).Unix())
+
+	// 表数据结构体
+	table := model.Users{}
+	// 查询用户
+	user := facade.DB.Model(&table).Where("id", item.Uid).Find()
+	if utils.Is.Empty(user) {
+		model.TokensRevokeFamily(item.Family)
+		clearToken(ctx)
+		this.json(ctx, nil, facade.Lang(ctx, "用户不存在！"), 401)
+		return
+	}
+
+	result, err := this.token(ctx, table.Id, next, item.Family)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	delete(user, "password")
+	delete(user, "stamp")
+	result["user"] = user
+
+	this.json(ctx, result, facade.Lang(ctx, "刷新成功！"), 200)
+}
+
 // 退出登录
 func (this *Comm) logout(ctx *gin.Context) {
-	ctx.SetCookie(cast.ToString(facade.AppToml.Get("app.token_name", "INIS_LOGIN_TOKEN")), "", -1, "/", "", false, false)
+
+	// 吊销刷新令牌 - 本次登录轮换出的令牌全部失效
+	token := cast.ToString(this.param(ctx, "refresh_token"))
+	if utils.Is.Empty(token) {
+		token, _ = ctx.Cookie(refreshName())
+	}
+	if !utils.Is.Empty(token) {
+		model.TokensRevoke(token)
+	}
+
+	// 按访问令牌中的会话ID吊销 - 客户端只保存了访问令牌时
+	access := ctx.Request.Header.Get("Authorization")
+	if utils.Is.Empty(access) {
+		access, _ = ctx.Cookie(cast.ToString(facade.AppToml.Get("app.token_name", "INIS_LOGIN_TOKEN")))
+	}
+	if jwt := facade.Jwt().Parse(access); jwt.Error == nil && !utils.Is.Empty(jwt.Jti) {
+		model.SessionsRevoke(jwt.Jti)
+	}
+
+	clearToken(ctx)
 	this.json(ctx, nil, facade.Lang(ctx, "退出成功！"), 200)
 }
 
+// token - 签发访问令牌和刷新令牌，并写入cookie
+/**
+ * @param uid 用户ID
+ * @param refresh 已轮换出的刷新令牌 - 为空时签发新的（登录）
+ * @param jti 会话ID - 为空时创建新的会话（登录）
+ */
+func (this *Comm) token(ctx *gin.Context, uid int, refresh, jti string) (result gin.H, err error) {
+
+	access := facade.JwtAccessExpire()
+	expire := facade.JwtRefreshExpire()
+
+	// 登录 - 创建会话
+	if utils.Is.Empty(jti) {
+		jti, err = model.SessionsCreate(uid, ctx.ClientIP(), ctx.Request.UserAgent(), time.Duration(expire)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	jwt := facade.Jwt(facade.JwtRequest{Expire: access, Jti: jti}).Create(facade.H{
+		"uid":   uid,
+		"stamp": model.UsersStamp(uid),
+		"type":  "access",
+	})
+	if jwt.Error != nil {
+		return nil, jwt.Error
+	}
+
+	if utils.Is.Empty(refresh) {
+		refresh, err = model.TokensIssue(uid, jti, ctx.ClientIP(), ctx.Request.UserAgent(), time.Duration(expire)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 往客户端写入cookie - 存储登录token
+	setToken(ctx, jwt.Text, access)
+	setRefresh(ctx, refresh, expire)
+
+	return gin.H{
+		"token":          jwt.Text,
+		"valid_time":     access,
+		"refresh_token":  refresh,
+		"refresh_expire": expire,
+	}, nil
+}
+
 // 设置登录token到客户的cookie中
-func setToken(ctx *gin.Context, token any) {
+func setToken(ctx *gin.Context, token any, expire int64) {
+
+	tokenName := cast.ToString(facade.AppToml.Get("app.token_name", "INIS_LOGIN_TOKEN"))
+
+	ctx.SetCookie(tokenName, cast.ToString(token), int(expire), "/", cookieHost(ctx), false, false)
+}
+
+// 设置刷新令牌到客户的cookie中 - 只在 comm 接口下发送，前端脚本无法读取
+func setRefresh(ctx *gin.Context, token string, expire int64) {
+	ctx.SetCookie(refreshName(), token, int(expire), "/api/comm/", cookieHost(ctx), false, true)
+}
 
+// 清除登录token和刷新令牌的cookie
+func clearToken(ctx *gin.Context) {
+	ctx.SetCookie(cast.ToString(facade.AppToml.Get("app.token_name", "INIS_LOGIN_TOKEN")), "", -1, "/", cookieHost(ctx), false, false)
+	ctx.SetCookie(refreshName(), "", -1, "/api/comm/", cookieHost(ctx), false, true)
+}
+
+// 刷新令牌的cookie名称
+func refreshName() string {
+	return cast.ToString(facade.AppToml.Get("app.token_name", "INIS_LOGIN_TOKEN")) + "_REFRESH"
+}
+
+// cookie 的域名 - 去掉端口
+func cookieHost(ctx *gin.Context) string {
 	host := ctx.Request.Host
 	if strings.Contains(host, ":") {
 		host = strings.Split(host, ":")[0]
 	}
-
-	expire := cast.ToInt(facade.AppToml.Get("jwt.expire", "7200"))
-	tokenName := cast.ToString(facade.AppToml.Get("app.token_name", "INIS_LOGIN_TOKEN"))
-
-	ctx.SetCookie(tokenName, cast.ToString(token), expire, "/", host, false, false)
+	return host
 }
 
 // 获取注册配置
@@ -692,3 +890,369 @@ func (this *Comm) signInConfig() (result map[string]any) {
 		"value": 1,
 	}
 }
+
+// signin - 登录成功 - 开启了两步验证时返回挑战令牌，由 comm/verify-2fa 完成登录
+/**
+ * @param table 用户
+ * @param item 用户（map）
+ * @return result 响应数据，msg 提示信息，code 状态码 - 由调用方输出或跳转
+ */
+func (this *Comm) signin(ctx *gin.Context, table model.Users, item map[string]any) (result gin.H, msg string, code int) {
+
+	if model.TotpsEnabled(table.Id) {
+
+		challenge := utils.Rand.String(32, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		if !facade.CacheSet(fmt.Sprintf("2fa[%s]", challenge), table.Id, model.TotpsExpire) {
+			return nil, "两步验证初始化失败！", 500
+		}
+
+		return gin.H{
+			"challenge": challenge,
+			"type":      "totp",
+			"expire":    int64(model.TotpsExpire.Seconds()),
+		}, "请输入两步验证码！", 202
+	}
+
+	return this.issue(ctx, table, item)
+}
+
+// issue - 签发令牌、写入cookie并更新登录时间
+func (this *Comm) issue(ctx *gin.Context, table model.Users, item map[string]any) (result gin.H, msg string, code int) {
+
+	// 签发令牌并写入cookie
+	result, err := this.token(ctx, table.Id, "", "")
+	if err != nil {
+		return nil, err.Error(), 500
+	}
+
+	// 删除 item 中的密码
+	delete(item, "password")
+	delete(item, "stamp")
+	// 更新用户登录时间
+	item["login_time"] = time.Now().Unix()
+	facade.DB.Model(&table).Where("id", table.Id).Update(map[string]any{
+		"login_time": item["login_time"],
+	})
+
+	result["user"] = item
+
+	return result, "登录成功！", 200
+}
+
+// verify2fa 两步验证 - 使用登录返回的挑战令牌和验证码（或恢复码）完成登录
+func (this *Comm) verify2fa(ctx *gin.Context) {
+
+	// 请求参数
+	params := this.params(ctx)
+
+	if utils.Is.Empty(params["challenge"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "challenge"), 400)
+		return
+	}
+
+	if utils.Is.Empty(params["code"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "code"), 400)
+		return
+	}
+
+	cacheName := fmt.Sprintf("2fa[%v]", params["challenge"])
+	uid, ok := facade.CacheGet[int](cacheName)
+	if !ok || uid == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "两步验证已过期，请重新登录！"), 401)
+		return
+	}
+
+	// 验证码错误计入该用户的失败次数，帐号被锁定后挑战令牌作废
+	throttle := this.throttle(nil, nil, uid)
+	if wait, lock := facade.Throttle.Acquire(throttle, ctx.ClientIP()); wait > 0 {
+		if lock {
+			go facade.Cache.Del(cacheName)
+		}
+		this.json(ctx, gin.H{"wait": wait, "lock": lock}, facade.Lang(ctx, "尝试次数过多，请 %d 秒后再试！", wait), 429)
+		return
+	}
+
+	totp, exist := model.TotpsFind(uid)
+	if !exist {
+		go facade.Cache.Del(cacheName)
+		this.json(ctx, nil, facade.Lang(ctx, "两步验证已过期，请重新登录！"), 401)
+		return
+	}
+
+	recovery, pass := totp.Verify(cast.ToString(params["code"]))
+	if !pass {
+		if facade.Throttle.Locked(throttle) {
+			go facade.Cache.Del(cacheName)
+		}
+		this.json(ctx, nil, facade.Lang(ctx, "验证码错误！"), 400)
+		return
+	}
+
+	// 挑战令牌只能使用一次
+	facade.Cache.Del(cacheName)
+	facade.Throttle.Release(throttle, ctx.ClientIP())
+
+	// 表数据结构体
+	table := model.Users{}
+	item  := facade.DB.Model(&table).Where("id", uid).Find()
+	if utils.Is.Empty(item) {
+		this.json(ctx, nil, facade.Lang(ctx, "用户不存在！"), 204)
+		return
+	}
+
+	// 使用了恢复码 - 提示剩余数量
+	if recovery {
+		item["recovery_remaining"] = totp.Remaining()
+	}
+
+	result, msg, code := this.issue(ctx, table, item)
+	this.json(ctx, result, facade.Lang(ctx, msg), code)
+}
+
+// oauthState - 第三方登录的授权状态 - 缓存在 oauth[state] 中，回调时使用一次后删除
+type oauthState struct {
+	Provider string `json:"provider"`
+	// PKCE 的 code_verifier
+	Verifier string `json:"verifier"`
+	// OIDC 的 nonce
+	Nonce    string `json:"nonce"`
+	// 完成后跳转的前端路径 - 为空时回调直接返回JSON
+	Redirect string `json:"redirect"`
+	// 绑定到的用户 - 为0时为登录
+	Uid      int    `json:"uid"`
+}
+
+// oauthExpire - 授权状态的有效期
+const oauthExpire = 10 * time.Minute
+
+// oauthProviders 已开启的第三方登录平台
+func (this *Comm) oauthProviders(ctx *gin.Context) {
+
+	list := make([]gin.H, 0)
+	for _, item := range facade.OAuthProviders() {
+		list = append(list, gin.H{"name": item.Name, "kind": item.Kind})
+	}
+
+	this.json(ctx, list, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// oauthAuthorize 跳转到第三方授权页面 - bind 为真时将第三方帐号绑定到当前用户，mode=json 时返回授权地址
+func (this *Comm) oauthAuthorize(ctx *gin.Context) {
+
+	// 请求参数
+	params := this.params(ctx)
+
+	provider, err := facade.NewOAuth(cast.ToString(params["provider"]))
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, err.Error()), 400)
+		return
+	}
+
+	// 只允许站内路径，防止登录后被跳转到其他网站
+	redirect := cast.ToString(params["redirect"])
+	if !utils.Is.Empty(redirect) && (!strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\")) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 只能是站内路径！", "redirect"), 400)
+		return
+	}
+
+	state := oauthState{
+		Provider: provider.Name,
+		Verifier: facade.OAuthRandom(),
+		Nonce:    facade.OAuthRandom(),
+		Redirect: redirect,
+	}
+
+	if cast.ToBool(params["bind"]) {
+		if state.Uid = this.meta.user(ctx).Id; state.Uid == 0 {
+			this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+			return
+		}
+	}
+
+	key := facade.OAuthRandom()
+	if !facade.CacheSet(fmt.Sprintf("oauth[%s]", key), state, oauthExpire) {
+		this.json(ctx, nil, facade.Lang(ctx, "第三方登录初始化失败！"), 500)
+		return
+	}
+
+	address, err := provider.AuthorizeURL(this.oauthRedirect(ctx), key, state.Verifier, state.Nonce)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	// 授权状态绑定到发起授权的浏览器 - mode=json 时前端需携带 cookie 请求
+	ctx.SetCookie(oauthCookie(), oauthBinding(key), int(oauthExpire.Seconds()), "/api/comm/", cookieHost(ctx), ctx.Request.TLS != nil, true)
+
+	if params["mode"] == "json" {
+		this.json(ctx, gin.H{"url": address, "expire": int64(oauthExpire.Seconds())}, facade.Lang(ctx, "数据请求成功！"), 200)
+		return
+	}
+
+	ctx.Redirect(302, address)
+}
+
+// oauthCallback 第三方授权回调 - 绑定，或登录（未绑定时按已验证的邮箱关联或自动注册）
+func (this *Comm) oauthCallback(ctx *gin.Context) {
+
+	// 请求参数
+	params := this.params(ctx)
+
+	// 只接受发起授权的浏览器的回调 - 防止把自己的授权链接发给他人完成（绑定到攻击者帐号、登录 CSRF）
+	binding, _ := ctx.Cookie(oauthCookie())
+	ctx.SetCookie(oauthCookie(), "", -1, "/api/comm/", cookieHost(ctx), ctx.Request.TLS != nil, true)
+	if utils.Is.Empty(params["state"]) || subtle.ConstantTimeCompare([]byte(binding), []byte(oauthBinding(cast.ToString(params["state"])))) != 1 {
+		this.json(ctx, nil, facade.Lang(ctx, "授权状态校验失败，请在同一浏览器中重新登录！"), 400)
+		return
+	}
+
+	// 授权状态只能使用一次
+	cacheName := fmt.Sprintf("oauth[%v]", params["state"])
+	state, ok := facade.CacheGet[oauthState](cacheName)
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "授权已过期，请重新登录！"), 400)
+		return
+	}
+	facade.Cache.Del(cacheName)
+
+	// 用户取消了授权
+	if !utils.Is.Empty(params["error"]) || utils.Is.Empty(params["code"]) {
+		this.oauthFinish(ctx, state, nil, "授权已取消！", 400)
+		return
+	}
+
+	provider, err := facade.NewOAuth(state.Provider)
+	if err != nil {
+		this.oauthFinish(ctx, state, nil, err.Error(), 400)
+		return
+	}
+
+	token, err := provider.Exchange(cast.ToString(params["code"]), state.Verifier, this.oauthRedirect(ctx))
+	if err != nil {
+		this.oauthFinish(ctx, state, nil, err.Error(), 400)
+		return
+	}
+
+	profile, err := provider.Profile(token, state.Nonce)
+	if err != nil {
+		this.oauthFinish(ctx, state, nil, err.Error(), 400)
+		return
+	}
+
+	// 绑定
+	if state.Uid != 0 {
+		identity, err := model.IdentitiesBind(state.Uid, profile)
+		if err != nil {
+			this.oauthFinish(ctx, state, nil, err.Error(), 400)
+			return
+		}
+		this.oauthFinish(ctx, state, gin.H{"identity": identity}, "绑定成功！", 200)
+		return
+	}
+
+	uid := 0
+	if identity, exist := model.IdentitiesFind(profile.Provider, profile.Subject); exist {
+		uid = identity.Uid
+	} else if uid, err = this.oauthUser(profile); err != nil {
+		this.oauthFinish(ctx, state, nil, err.Error(), 400)
+		return
+	}
+
+	// 表数据结构体
+	table := model.Users{}
+	item  := facade.DB.Model(&table).Where("id", uid).Find()
+	if utils.Is.Empty(item) {
+		this.oauthFinish(ctx, state, nil, "用户不存在！", 400)
+		return
+	}
+
+	// 更新绑定的资料
+	if _, err = model.IdentitiesBind(uid, profile); err != nil {
+		this.oauthFinish(ctx, state, nil, err.Error(), 400)
+		return
+	}
+
+	// 开启了两步验证时返回挑战令牌，否则签发令牌
+	result, msg, code := this.signin(ctx, table, item)
+	this.oauthFinish(ctx, state, result, msg, code)
+}
+
+// oauthUser - 未绑定的第三方帐号 - 开启了 link_email 时关联邮箱相同（且已由平台验证）的唯一用户，否则自动注册
+func (this *Comm) oauthUser(profile *facade.OAuthProfile) (uid int, err error) {
+
+	email := utils.Ternary(profile.EmailVerified, profile.Email, "")
+
+	var ids []int
+	if !utils.Is.Empty(email) {
+		facade.DB.Drive().Model(&model.Users{}).Where("email = ?", email).Limit(2).Pluck("id", &ids)
+	}
+
+	if len(ids) == 1 && cast.ToBool(facade.OAuthToml.Get("link_email")) {
+		return ids[0], nil
+	}
+
+	if !cast.ToBool(this.signInConfig()["value"]) {
+		return 0, errors.New("请联系管理员为您手动开通账号！")
+	}
+
+	nickname := []rune(profile.Nickname)
+	if len(nickname) > 32 {
+		nickname = nickname[:32]
+	}
+
+	table := model.Users{
+		Nickname: utils.Ternary(len(nickname) == 0, "会员"+utils.Rand.String(4, "0123456789"), string(nickname)),
+		Avatar:   profile.Avatar,
+		// 邮箱已被其他用户使用时不写入，避免邮箱登录时无法区分
+		Email:    utils.Ternary(len(ids) == 0, email, ""),
+		Source:   profile.Provider,
+	}
+
+	tx := facade.DB.Model(&table).Create(&table)
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+
+	return table.Id, nil
+}
+
+// oauthCookie - 授权状态绑定的cookie名称
+func oauthCookie() string {
+	return cast.ToString(facade.AppToml.Get("app.token_name", "INIS_LOGIN_TOKEN")) + "_OAUTH"
+}
+
+// oauthBinding - cookie 中保存的授权状态哈希
+func oauthBinding(state string) string {
+	sum := sha256.Sum256([]byte("oauth-state:" + state))
+	return hex.EncodeToString(sum[:])
+}
+
+// oauthRedirect - 回调地址 - oauth.redirect 为空时使用当前请求的域名
+func (this *Comm) oauthRedirect(ctx *gin.Context) string {
+
+	domain := cast.ToString(facade.OAuthToml.Get("redirect"))
+	if utils.Is.Empty(domain) {
+		scheme := utils.Ternary(ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https", "https", "http")
+		domain  = scheme + "://" + ctx.Request.Host
+	}
+
+	return strings.TrimSuffix(domain, "/") + "/api/comm/oauth-callback"
+}
+
+// oauthFinish - 输出回调结果 - 授权时指定了 redirect 则跳转到前端，结果放在 code、msg、challenge 参数中，令牌已写入cookie
+func (this *Comm) oauthFinish(ctx *gin.Context, state oauthState, result gin.H, msg string, code int) {
+
+	if utils.Is.Empty(state.Redirect) {
+		this.json(ctx, result, facade.Lang(ctx, msg), code)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("code", cast.ToString(code))
+	query.Set("msg", facade.Lang(ctx, msg))
+	if challenge, ok := result["challenge"]; ok {
+		query.Set("challenge", cast.ToString(challenge))
+	}
+
+	ctx.Redirect(302, state.Redirect+utils.Ternary(strings.Contains(state.Redirect, "?"), "&", "?")+query.Encode())
+}
@@ -2,17 +2,22 @@ package controller
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/spf13/cast"
 	"github.com/unti-io/go-utils/utils"
-	"image"
 	"inis/app/facade"
+	"inis/app/model"
+	"io"
 	"mime/multipart"
-	"regexp"
+	"net/http"
+	"path"
 	"strings"
+	"time"
 )
 
 type File struct {
@@ -20,6 +25,12 @@ type File struct {
 	base
 }
 
+// fileImage - 上传时可以添加水印的图片类型
+var fileImage = []any{"jpg", "jpeg", "png", "gif", "bmp", "tif", "tiff", "webp"}
+
+// fileFormOverhead - 简单上传时表单中文件以外的部分（分隔符、字段）允许的大小
+const fileFormOverhead = 1 << 20
+
 // IGET - GET请求本体
 func (this *File) IGET(ctx *gin.Context) {
 	// 转小写
@@ -28,6 +39,11 @@ func (this *File) IGET(ctx *gin.Context) {
 	allow := map[string]any{
 		"rand": this.rand,
 		"to-base64": this.toBase64,
+		"info": this.info,
+		"list": this.list,
+		"download": this.download,
+		"sign": this.sign,
+		"chunk": this.chunkStatus,
 	}
 	err := this.call(allow, method, ctx)
 
@@ -45,6 +61,8 @@ func (this *File) IPOST(ctx *gin.Context) {
 
 	allow := map[string]any{
 		"upload": this.upload,
+		"init": this.initUpload,
+		"complete": this.complete,
 	}
 	err := this.call(allow, method, ctx)
 
@@ -59,7 +77,9 @@ func (this *File) IPUT(ctx *gin.Context) {
 	// 转小写
 	method := strings.ToLower(ctx.Param("method"))
 
-	allow := map[string]any{}
+	allow := map[string]any{
+		"chunk": this.chunk,
+	}
 	err := this.call(allow, method, ctx)
 
 	if err != nil {
@@ -73,7 +93,10 @@ func (this *File) IDEL(ctx *gin.Context) {
 	// 转小写
 	method := strings.ToLower(ctx.Param("method"))
 
-	allow := map[string]any{}
+	allow := map[string]any{
+		"delete": this.delete,
+		"chunk": this.abort,
+	}
 	err := this.call(allow, method, ctx)
 
 	if err != nil {
@@ -90,11 +113,18 @@ func (this *File) INDEX(ctx *gin.Context) {
 // upload - 简单文件上传
 func (this *File) upload(ctx *gin.Context) {
 
-	params := this.params(ctx)
+	// 上传策略 - 解析表单之前按策略限制请求体大小，超出时不再继续读取
+	policy := facade.UploadPolicy(this.role(ctx))
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, policy.MaxSize+fileFormOverhead)
 
 	// 上传文件
 	file, err := ctx.FormFile("file")
 	if err != nil {
+		var large *http.MaxBytesError
+		if errors.As(err, &large) {
+			this.json(ctx, nil, facade.Lang(ctx, "文件过大！"), 400)
+			return
+		}
 		this.json(ctx, nil, err.Error(), 400)
 		return
 	}
@@ -112,21 +142,785 @@ func (this *File) upload(ctx *gin.Context) {
 		}
 	}(Byte)
 
-	// 文件后缀
-	suffix := file.Filename[strings.LastIndex(file.Filename, "."):]
-	params["suffix"] = suffix
+	// 检查大小和扩展名
+	ext, err := policy.Allow(file.Filename, file.Size)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(Byte, policy.MaxSize+1))
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+	if int64(len(body)) > policy.MaxSize {
+		this.json(ctx, nil, facade.Lang(ctx, "文件过大！"), 400)
+		return
+	}
+
+	// 文件内容必须与扩展名一致
+	if _, err = policy.Sniff(ext, body); err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+
+	// 图片去除元数据并重新编码
+	if body, err = policy.Sanitize(ext, body); err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+
+	driver := cast.ToString(facade.StorageToml.Get("default"))
+	store  := facade.StorageDriver(driver)
+	key    := store.Path() + "." + ext
+
+	// 上传时添加水印
+	if facade.Watermark.Enable(facade.WatermarkModeUpload) && !facade.Watermark.Exclude(key) {
+		if body, err = this.watermark(ext, body); err != nil {
+			this.json(ctx, nil, err.Error(), 400)
+			return
+		}
+	}
+
+	size := int64(len(body))
+
+	// 计算哈希和类型后回到文件开头
+	hash, mime, err := this.digest(bytes.NewReader(body))
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+
+	// 当前用户上传过相同内容的文件 - 直接返回已有的地址
+	if exist, ok := this.exist(ctx, hash); ok {
+		this.record(ctx, model.Files{
+			Name: file.Filename, Size: size, Mime: mime, Sha256: hash,
+			Driver: exist.Driver, Key: exist.Key, Url: exist.Url,
+		})
+		this.json(ctx, map[string]any{
+			"path": exist.Url,
+		}, facade.Lang(ctx, "上传成功！"), 200)
+		return
+	}
+
+	item := store.Upload(key, bytes.NewReader(body))
+	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	this.record(ctx, model.Files{
+		Name: file.Filename, Size: size, Mime: mime, Sha256: hash,
+		Driver: driver, Key: strings.TrimPrefix(item.Path, "/"), Url: item.Domain + item.Path,
+	})
+
+	this.json(ctx, map[string]any{
+		"path": item.Domain + item.Path,
+	}, facade.Lang(ctx, "上传成功！"), 200)
+}
+
+// watermark - 上传的图片添加水印 - 非图片原样返回
+func (this *File) watermark(ext string, body []byte) ([]byte, error) {
+
+	if !utils.In.Array(ext, fileImage) {
+		return body, nil
+	}
+
+	options := facade.ImageOriginal(ext)
+
+	// 无法按原格式编码（如不支持 webp 编码）时不添加，避免内容与扩展名不符
+	if options.Mime() != facade.ImageMime(ext) {
+		return body, nil
+	}
+	options.Watermark = facade.Watermark.Position()
+
+	return options.Process(body)
+}
+
+// rewrite - 上传时是否需要改写内容（去除元数据、添加水印） - 这类文件需要完整读入，不支持分片上传
+func (this *File) rewrite(policy *facade.UploadPolicyStruct, ext, key string) bool {
+	if policy.Reencode(ext) {
+		return true
+	}
+	return utils.In.Array(ext, fileImage) && facade.Watermark.Enable(facade.WatermarkModeUpload) && !facade.Watermark.Exclude(key)
+}
+
+// digest - 计算文件的 sha256 和 MIME 类型，完成后回到文件开头
+func (this *File) digest(file io.ReadSeeker) (hash, mime string, err error) {
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return
+	}
+	mime = http.DetectContentType(head[:n])
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	sum := sha256.New()
+	if _, err = io.Copy(sum, file); err != nil {
+		return
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+
+	return fmt.Sprintf("%x", sum.Sum(nil)), mime, err
+}
+
+// exist - 查找当前用户上传过的、内容相同且对象仍然存在的文件
+/**
+ * 只在同一用户内去重 - 跨用户去重会把他人文件的地址和操作权限交给知道哈希的人；游客不去重
+ */
+func (this *File) exist(ctx *gin.Context, hash string) (table model.Files, ok bool) {
+
+	uid := this.meta.user(ctx).Id
+	if uid == 0 {
+		return table, false
+	}
+
+	if utils.Is.Empty(facade.DB.Model(&table).Where("sha256", hash).Where("uid", uid).Find()) {
+		return table, false
+	}
+
+	// 存储中的对象可能已被删除
+	item := facade.StorageDriver(table.Driver).Exists(table.Key)
+
+	return table, item.Error == nil && item.Exist
+}
+
+// record - 记录上传的文件
+func (this *File) record(ctx *gin.Context, table model.Files) model.Files {
+
+	table.Uid = this.meta.user(ctx).Id
+
+	if tx := facade.DB.Model(&table).Create(&table); tx.Error != nil {
+		facade.Log.Error(map[string]any{
+			"error":     tx.Error,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "文件记录保存失败")
+	}
+
+	return table
+}
+
+// role - 上传策略使用的角色
+func (this *File) role(ctx *gin.Context) string {
+	if this.meta.user(ctx).Id == 0 {
+		return facade.UploadRoleGuest
+	}
+	return facade.UploadRoleUser
+}
+
+// key - 从参数中解析对象 key - 只允许操作 storage 目录下的文件
+func (this *File) key(value any) (key string, ok bool) {
+	if utils.Is.Empty(value) {
+		return "", false
+	}
+	key = facade.StorageKey(cast.ToString(value))
+	return key, strings.HasPrefix(key, "storage/") && !strings.HasPrefix(key, "storage/rand/")
+}
+
+// owner - 校验当前用户可以操作该对象 - 管理员，或者有该对象的上传记录
+/**
+ * @return ok 为 false 时已输出错误
+ */
+func (this *File) owner(ctx *gin.Context, key string) (ok bool) {
+
+	uid := this.meta.user(ctx).Id
+	if uid == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return false
+	}
+
+	if !model.FilesOwner(uid, key) {
+		this.json(ctx, nil, facade.Lang(ctx, "无权限！"), 403)
+		return false
+	}
+
+	return true
+}
+
+// info - 文件信息 - 只能查看自己上传的文件
+func (this *File) info(ctx *gin.Context) {
+
+	params := this.params(ctx)
+
+	key, ok := this.key(params["path"])
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不合法！", "path"), 400)
+		return
+	}
+
+	if !this.owner(ctx, key) {
+		return
+	}
+
+	item := facade.Storage.Stat(key)
+	if item.Error != nil {
+		exist := facade.Storage.Exists(key)
+		if exist.Error == nil && !exist.Exist {
+			this.json(ctx, nil, facade.Lang(ctx, "文件不存在！"), 204)
+			return
+		}
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	this.json(ctx, item.Object, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// list - 文件列表
+func (this *File) list(ctx *gin.Context) {
+
+	params := this.params(ctx, map[string]any{
+		"prefix": "storage/",
+		"limit":  100,
+	})
+
+	if this.meta.user(ctx).Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	prefix, ok := this.key(params["prefix"])
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不合法！", "prefix"), 400)
+		return
+	}
+	// StorageKey 会去掉末尾的 /，目录前缀需要补回来
+	if strings.HasSuffix(cast.ToString(params["prefix"]), "/") {
+		prefix += "/"
+	}
+
+	item := facade.Storage.List(prefix, cast.ToString(params["cursor"]), cast.ToInt(params["limit"]))
+	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	this.json(ctx, gin.H{
+		"data":   utils.Ternary[any](item.Objects == nil, []any{}, item.Objects),
+		"cursor": item.Cursor,
+	}, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// download - 读取文件内容 - 只能读取自己上传的文件，分享给他人使用签名地址
+func (this *File) download(ctx *gin.Context) {
+
+	params := this.params(ctx)
+
+	key, ok := this.key(params["path"])
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不合法！", "path"), 400)
+		return
+	}
+
+	if !this.owner(ctx, key) {
+		return
+	}
+
+	stat := facade.Storage.Stat(key)
+	if stat.Error != nil {
+		this.json(ctx, nil, stat.Error.Error(), 400)
+		return
+	}
+
+	item := facade.Storage.Get(key)
+	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(item.Reader)
+
+	// 下载模式
+	if cast.ToBool(params["attachment"]) {
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, path.Base(key)))
+	}
+
+	ctx.DataFromReader(200, stat.Object.Size, stat.Object.Mime, item.Reader, map[string]string{
+		"ETag":                   fmt.Sprintf(`"%s"`, stat.Object.ETag),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// sign - 生成带签名的临时访问地址 - 只能签发自己上传的文件，且只允许 GET（上传走 upload 接口，需经过上传策略）
+func (this *File) sign(ctx *gin.Context) {
+
+	params := this.params(ctx, map[string]any{
+		"ttl":    3600,
+		"method": "GET",
+	})
+
+	if !strings.EqualFold(cast.ToString(params["method"]), "GET") {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 只支持 GET！", "method"), 400)
+		return
+	}
+
+	key, ok := this.key(params["path"])
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不合法！", "path"), 400)
+		return
+	}
+
+	if !this.owner(ctx, key) {
+		return
+	}
+
+	// 有效期 - 1秒 ~ 7天
+	ttl := cast.ToInt64(params["ttl"])
+	if ttl < 1 || ttl > 7*24*60*60 {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 超出范围！", "ttl"), 400)
+		return
+	}
+
+	item := facade.Storage.SignedURL(key, time.Duration(ttl)*time.Second, "GET")
+
+	// 覆盖水印 - 只有上传者本人可以签发，且只对本地存储有效
+	if watermark := cast.ToString(params["watermark"]); !utils.Is.Empty(watermark) {
+
+		if facade.Storage != facade.LocalStorage {
+			this.json(ctx, nil, facade.Lang(ctx, "当前存储驱动不支持覆盖水印！"), 400)
+			return
+		}
+
+		owner := facade.DB.Model(&model.Files{}).Where("uid", this.meta.user(ctx).Id).Where("key", key).Exist()
+		if !owner {
+			this.json(ctx, nil, facade.Lang(ctx, "只能覆盖自己上传的图片的水印！"), 403)
+			return
+		}
+
+		item = facade.LocalStorage.WatermarkURL(key, time.Duration(ttl)*time.Second, watermark)
+	}
 
-	item := facade.Storage.Upload(facade.Storage.Path()+suffix, Byte)
 	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	this.json(ctx, gin.H{
+		"url":     item.URL,
+		"expires": time.Now().Unix() + ttl,
+	}, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// delete - 删除文件 - 删除自己的上传记录，对象不再被任何记录引用时才从存储中删除；管理员删除全部记录
+func (this *File) delete(ctx *gin.Context) {
+
+	params := this.params(ctx)
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	key, ok := this.key(params["path"])
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不合法！", "path"), 400)
+		return
+	}
+
+	admin := model.PermissionsAdmin(user.Id)
+
+	var files []model.Files
+	query := facade.DB.Drive().Unscoped().Where("`key` = ?", key)
+	if !admin {
+		query = query.Where("uid = ?", user.Id)
+	}
+	query.Find(&files)
+
+	if len(files) == 0 && !admin {
+		this.json(ctx, nil, facade.Lang(ctx, "无权限！"), 403)
+		return
+	}
+
+	if err := model.FilesRemove(files); err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "删除失败！"), 400)
+		return
+	}
+
+	// 没有上传记录的对象（如迁移前的旧文件）只有管理员可以删除
+	if len(files) == 0 {
+		if item := facade.Storage.Delete(key); item.Error != nil {
+			this.json(ctx, nil, item.Error.Error(), 400)
+			return
+		}
+	}
+
+	this.json(ctx, gin.H{"path": key}, facade.Lang(ctx, "删除成功！"), 200)
+}
+
+// fileUpload - 分片上传会话
+type fileUpload struct {
+	Id        string `json:"id"`
+	Uid       int    `json:"uid"`
+	// 存储驱动 - 会话期间切换默认驱动不影响已开始的上传
+	Driver    string `json:"driver"`
+	Key       string `json:"key"`
+	UploadId  string `json:"upload_id"`
+	Name      string `json:"name"`
+	// 小写扩展名（不带点） - 第一片上传时按此校验文件内容
+	Ext       string `json:"ext"`
+	Mime      string `json:"mime"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size"`
+	Total     int    `json:"total"`
+	Expire    int64  `json:"expire"`
+}
+
+// chunkSize - 第 index 片的大小
+func (this *fileUpload) chunkSize(index int) int64 {
+	if index == this.Total {
+		return this.Size - this.ChunkSize*int64(this.Total-1)
+	}
+	return this.ChunkSize
+}
+
+// ttl - 会话剩余有效期
+func (this *fileUpload) ttl() time.Duration {
+	return time.Until(time.Unix(this.Expire, 0))
+}
+
+// session - 读取分片上传会话
+func (this *File) session(ctx *gin.Context) (session fileUpload, ok bool) {
+
+	id := cast.ToString(this.param(ctx, "id"))
+	if utils.Is.Empty(id) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "id"), 400)
+		return
+	}
+
+	session, ok = facade.CacheGet[fileUpload](fmt.Sprintf("upload[%s]", id))
+	if !ok || session.Uid != this.meta.user(ctx).Id {
+		this.json(ctx, nil, facade.Lang(ctx, "上传任务不存在或已过期！"), 400)
+		return session, false
+	}
+
+	return
+}
+
+// parts - 已上传的分片
+func (this *File) parts(session fileUpload) (parts []facade.StoragePart) {
+	for index := 1; index <= session.Total; index++ {
+		if part, ok := facade.CacheGet[facade.StoragePart](fmt.Sprintf("upload[%s][%d]", session.Id, index)); ok {
+			parts = append(parts, part)
+		}
+	}
+	return
+}
+
+// initUpload - 初始化分片上传
+func (this *File) initUpload(ctx *gin.Context) {
+
+	params := this.params(ctx)
+
+	if utils.Is.Empty(params["name"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "name"), 400)
+		return
+	}
+
+	size := cast.ToInt64(params["size"])
+	if size <= 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不合法！", "size"), 400)
+		return
+	}
+
+	chunk := cast.ToInt64(facade.StorageToml.Get("upload.chunk_size", 5)) << 20
+	total := int((size + chunk - 1) / chunk)
+
+	// 对象存储最多 10000 个分片
+	if total > 10000 {
+		this.json(ctx, nil, facade.Lang(ctx, "文件过大！"), 400)
+		return
+	}
+
+	name := path.Base(cast.ToString(params["name"]))
+
+	// 上传策略 - 检查大小和扩展名
+	policy := facade.UploadPolicy(this.role(ctx))
+	ext, err := policy.Allow(name, size)
+	if err != nil {
 		this.json(ctx, nil, err.Error(), 400)
 		return
 	}
 
+	driver := cast.ToString(facade.StorageToml.Get("default"))
+	store  := facade.StorageDriver(driver)
+	key    := store.Path() + "." + ext
+
+	// 需要重新编码或添加水印的图片 - 分片上传无法处理，只能使用 file/upload
+	if this.rewrite(policy, ext, key) {
+		this.json(ctx, nil, facade.Lang(ctx, ".%s 文件不支持分片上传，请使用 %s 上传！", ext, "file/upload"), 400)
+		return
+	}
+
+	item := store.InitUpload(key)
+	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	expire := time.Duration(cast.ToInt64(facade.StorageToml.Get("upload.expire", 24))) * time.Hour
+
+	session := fileUpload{
+		Id:        uuid.New().String(),
+		Uid:       this.meta.user(ctx).Id,
+		Driver:    driver,
+		Key:       key,
+		UploadId:  item.UploadId,
+		Name:      name,
+		Ext:       ext,
+		Size:      size,
+		ChunkSize: chunk,
+		Total:     total,
+		Expire:    time.Now().Add(expire).Unix(),
+	}
+
+	if !facade.CacheSet(fmt.Sprintf("upload[%s]", session.Id), session, expire) {
+		store.AbortUpload(key, item.UploadId)
+		this.json(ctx, nil, facade.Lang(ctx, "上传任务创建失败！"), 400)
+		return
+	}
+
+	this.json(ctx, gin.H{
+		"id":         session.Id,
+		"chunk_size": session.ChunkSize,
+		"total":      session.Total,
+		"expire":     session.Expire,
+	}, facade.Lang(ctx, "创建成功！"), 200)
+}
+
+// chunkStatus - 分片上传进度 - 用于断点续传
+func (this *File) chunkStatus(ctx *gin.Context) {
+
+	session, ok := this.session(ctx)
+	if !ok {
+		return
+	}
+
+	uploaded := make([]int, 0)
+	for _, part := range this.parts(session) {
+		uploaded = append(uploaded, part.Index)
+	}
+
+	this.json(ctx, gin.H{
+		"id":         session.Id,
+		"name":       session.Name,
+		"size":       session.Size,
+		"chunk_size": session.ChunkSize,
+		"total":      session.Total,
+		"expire":     session.Expire,
+		"uploaded":   uploaded,
+	}, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// chunk - 上传分片 - body 为分片的二进制内容，checksum 为分片的 sha256
+/**
+ * @example：
+ * PUT /api/file/chunk?id=xxx&index=1&checksum=xxx
+ * Content-Type: application/octet-stream
+ */
+func (this *File) chunk(ctx *gin.Context) {
+
+	params := this.params(ctx)
+
+	session, ok := this.session(ctx)
+	if !ok {
+		return
+	}
+
+	index := cast.ToInt(params["index"])
+	if index < 1 || index > session.Total {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 超出范围！", "index"), 400)
+		return
+	}
+
+	checksum := strings.ToLower(cast.ToString(params["checksum"]))
+	if utils.Is.Empty(checksum) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "checksum"), 400)
+		return
+	}
+
+	// 分片大小有上限，读入内存校验后再上传
+	size := session.chunkSize(index)
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, size+1))
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+
+	if int64(len(body)) != size {
+		this.json(ctx, nil, facade.Lang(ctx, "分片大小错误！"), 400)
+		return
+	}
+
+	if fmt.Sprintf("%x", sha256.Sum256(body)) != checksum {
+		this.json(ctx, nil, facade.Lang(ctx, "分片校验失败！"), 400)
+		return
+	}
+
+	// 第一片包含文件头 - 校验文件内容与扩展名一致
+	if index == 1 {
+		head := body
+		if len(head) > facade.UploadSniffSize {
+			head = head[:facade.UploadSniffSize]
+		}
+		mime, err := facade.UploadPolicy(this.role(ctx)).Sniff(session.Ext, head)
+		if err != nil {
+			this.json(ctx, nil, err.Error(), 400)
+			return
+		}
+		session.Mime = mime
+		facade.CacheSet(fmt.Sprintf("upload[%s]", session.Id), session, session.ttl())
+	}
+
+	item := facade.StorageDriver(session.Driver).UploadPart(session.Key, session.UploadId, index, bytes.NewReader(body), size)
+	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	facade.CacheSet(fmt.Sprintf("upload[%s][%d]", session.Id, index), *item.Part, session.ttl())
+
+	this.json(ctx, gin.H{
+		"index": index,
+	}, facade.Lang(ctx, "上传成功！"), 200)
+}
+
+// complete - 合并分片
+func (this *File) complete(ctx *gin.Context) {
+
+	session, ok := this.session(ctx)
+	if !ok {
+		return
+	}
+
+	parts := this.parts(session)
+	if len(parts) != session.Total {
+
+		var missing []int
+		for index, next := 1, 0; index <= session.Total; index++ {
+			if next < len(parts) && parts[next].Index == index {
+				next++
+				continue
+			}
+			missing = append(missing, index)
+		}
+
+		this.json(ctx, gin.H{"missing": missing}, facade.Lang(ctx, "分片不完整！"), 400)
+		return
+	}
+
+	store := facade.StorageDriver(session.Driver)
+	item  := store.CompleteUpload(session.Key, session.UploadId, parts)
+	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	this.clear(session)
+
+	key := strings.TrimPrefix(item.Path, "/")
+
+	// 读回合并后的文件 - 重新校验内容并计算哈希，不通过时删除
+	hash, mime, err := this.stored(facade.UploadPolicy(this.role(ctx)), store, key, session.Ext)
+	if err != nil {
+		store.Delete(key)
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+
+	// 当前用户上传过相同内容的文件 - 删除本次合并的对象，返回已有的地址
+	if exist, ok := this.exist(ctx, hash); ok {
+		store.Delete(key)
+		this.record(ctx, model.Files{
+			Name: session.Name, Size: session.Size, Mime: mime, Sha256: hash,
+			Driver: exist.Driver, Key: exist.Key, Url: exist.Url,
+		})
+		this.json(ctx, map[string]any{
+			"path": exist.Url,
+		}, facade.Lang(ctx, "上传成功！"), 200)
+		return
+	}
+
+	this.record(ctx, model.Files{
+		Name: session.Name, Size: session.Size, Mime: mime, Sha256: hash,
+		Driver: session.Driver, Key: key, Url: item.Domain + item.Path,
+	})
+
 	this.json(ctx, map[string]any{
 		"path": item.Domain + item.Path,
 	}, facade.Lang(ctx, "上传成功！"), 200)
 }
 
+// stored - 从存储中读回文件，嗅探类型并计算哈希
+/**
+ * 分片可以重传，合并后的文件头不一定是第一次校验过的内容，所以合并后再校验一次
+ * @return hash sha256，mime 嗅探到的类型
+ */
+func (this *File) stored(policy *facade.UploadPolicyStruct, store facade.StorageInterface, key, ext string) (hash, mime string, err error) {
+
+	item := store.Get(key)
+	if item.Error != nil {
+		return "", "", item.Error
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(item.Reader)
+
+	head := make([]byte, facade.UploadSniffSize)
+	n, err := io.ReadFull(item.Reader, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", err
+	}
+
+	if mime, err = policy.Sniff(ext, head[:n]); err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.New()
+	sum.Write(head[:n])
+	if _, err = io.Copy(sum, item.Reader); err != nil {
+		return "", "", err
+	}
+
+	return fmt.Sprintf("%x", sum.Sum(nil)), mime, nil
+}
+
+// abort - 取消分片上传
+func (this *File) abort(ctx *gin.Context) {
+
+	session, ok := this.session(ctx)
+	if !ok {
+		return
+	}
+
+	item := facade.StorageDriver(session.Driver).AbortUpload(session.Key, session.UploadId)
+	if item.Error != nil {
+		this.json(ctx, nil, item.Error.Error(), 400)
+		return
+	}
+
+	this.clear(session)
+
+	this.json(ctx, nil, facade.Lang(ctx, "取消成功！"), 200)
+}
+
+// clear - 清除分片上传会话
+func (this *File) clear(session fileUpload) {
+	for index := 1; index <= session.Total; index++ {
+		facade.Cache.Del(fmt.Sprintf("upload[%s][%d]", session.Id, index))
+	}
+	facade.Cache.Del(fmt.Sprintf("upload[%s]", session.Id))
+}
+
 // rand - 随机图
 func (this *File) rand(ctx *gin.Context) {
 
@@ -229,28 +1023,34 @@ func (this *File) rand(ctx *gin.Context) {
 		return
 	}
 
-	// 正则表达式，匹配图片尺寸
-	reg := regexp.MustCompile(`^(\d+)\D+(\d+)$`)
-	// 从 query 的 size 中获取图片尺寸
-	match := reg.FindStringSubmatch(ctx.Query("size"))
-
 	var err error
 	var write int
-	img := item.Byte
+	img  := item.Byte
+	// 文件后缀 - 转小写
+	ext  := strings.ToLower(url[strings.LastIndex(url, ".")+1:])
+	mime := facade.ImageMime(ext)
 
-	if match != nil {
-
-		width  := cast.ToInt(match[1])
-		height := cast.ToInt(match[2])
+	// 图片处理参数 - size、mode、gravity、quality、rotate、blur、format
+	options, err := facade.ImageParse(ctx.Request.URL.Query(), ext, ctx.GetHeader("Accept"))
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
 
-		// 文件后缀 - 转小写
-		ext := strings.ToLower(url[strings.LastIndex(url, ".")+1:])
+	if options != nil {
 		// 图片压缩
-		img = compress(ctx, img, width, height, ext)
+		if img, err = compress(img, options); err != nil {
+			this.json(ctx, nil, err.Error(), 400)
+			return
+		}
+		mime = options.Mime()
+		if options.Auto {
+			ctx.Writer.Header().Set("Vary", "Accept")
+		}
 	}
 
 	// 输出图片到页面上
-	ctx.Writer.Header().Set("Content-Type", "image/jpeg")
+	ctx.Writer.Header().Set("Content-Type", mime)
 	ctx.Writer.Header().Set("Content-Length", cast.ToString(len(img)))
 
 	write, err = ctx.Writer.Write(img)
@@ -258,7 +1058,7 @@ func (this *File) rand(ctx *gin.Context) {
 		this.json(ctx, nil, err.Error(), 400)
 		return
 	}
-	if write != len(item.Byte) {
+	if write != len(img) {
 		this.json(ctx, nil, "写入失败！", 400)
 		return
 	}
@@ -295,53 +1095,9 @@ func (this *File) toBase64(ctx *gin.Context) {
 	// ctx.Writer.Write(item.Byte)
 }
 
-// compress - 图片压缩
-func compress(ctx *gin.Context, byte []byte, width, height int, ext string) (result []byte) {
-
-	// 图片处理模式
-	var mode string
-	mode = utils.Ternary[string](width == height, "fill", mode)
-	mode = ctx.DefaultQuery("mode", mode)
-
-	// byte 转 image
-	src, _ := imaging.Decode(bytes.NewReader(byte))
-
-	// 处理图片
-	var dstImage *image.NRGBA
-	switch mode {
-	case "fill":
-		// 填充
-		dstImage = imaging.Fill(src, width, height, imaging.Center, imaging.Lanczos)
-	case "resize":
-		// 完全自定义大小
-		dstImage = imaging.Resize(src, width, height, imaging.Lanczos)
-	case "fit":
-		// 等比例缩放
-		dstImage = imaging.Fit(src, width, height, imaging.Lanczos)
-	default:
-		// 等比例缩放
-		dstImage = imaging.Fit(src, width, height, imaging.Lanczos)
-	}
-
-	// 压缩的图片格式
-	var format imaging.Format
-	switch ext {
-	case "jpg", "jpeg":
-		format = imaging.JPEG
-	case "png":
-		format = imaging.PNG
-	case "gif":
-		format = imaging.GIF
-	case "tif", "tiff":
-		format = imaging.TIFF
-	case "bmp":
-		format = imaging.BMP
-	default:
-		format = imaging.JPEG
-	}
-	buffer := new(bytes.Buffer)
-	// 解决GIG压缩之后不会动的问题
-	_ = imaging.Encode(buffer, dstImage, format)
-
-	return buffer.Bytes()
-}
\ No newline at end of file
+// compress - 图片压缩 - 远程图片没有 ETag，以内容哈希作为缓存标识
+func compress(body []byte, options *facade.ImageOptions) (result []byte, err error) {
+	return facade.Image(fmt.Sprintf("%x", sha256.Sum256(body)), options, func() ([]byte, error) {
+		return body, nil
+	})
+}
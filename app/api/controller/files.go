@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"inis/app/model"
+	"math"
+	"strings"
+)
+
+type Files struct {
+	// 继承
+	base
+}
+
+// IGET - GET请求本体
+func (this *Files) IGET(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"one":    this.one,
+		"all":    this.all,
+		"search": this.search,
+		"count":  this.count,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPOST - POST请求本体
+func (this *Files) IPOST(ctx *gin.Context) {
+
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPUT - PUT请求本体
+func (this *Files) IPUT(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IDEL - DELETE请求本体
+func (this *Files) IDEL(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"delete": this.delete,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// INDEX - GET请求本体
+func (this *Files) INDEX(ctx *gin.Context) {
+	this.json(ctx, nil, facade.Lang(ctx, "没什么用！"), 202)
+}
+
+// one 获取指定数据 - 只能查看自己上传的文件
+func (this *Files) one(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	if utils.Is.Empty(params["id"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "id"), 400)
+		return
+	}
+
+	// 表数据结构体
+	table := model.Files{}
+	item  := facade.DB.Model(&table).Where("uid", user.Id).Where("id", params["id"]).Find()
+
+	if utils.Is.Empty(item) {
+		this.json(ctx, nil, facade.Lang(ctx, "无数据！"), 204)
+		return
+	}
+
+	this.json(ctx, item, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// all 获取全部数据 - 只返回自己上传的文件
+func (this *Files) all(ctx *gin.Context) {
+	this.list(ctx, nil)
+}
+
+// search 搜索 - 支持文件名（keyword）、类型前缀（mime，如 image/）、哈希（sha256）
+func (this *Files) search(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	this.list(ctx, func(mold *facade.ModelStruct) {
+		if !utils.Is.Empty(params["keyword"]) {
+			mold.Like("name", "%"+cast.ToString(params["keyword"])+"%")
+		}
+		if !utils.Is.Empty(params["mime"]) {
+			mold.Like("mime", cast.ToString(params["mime"])+"%")
+		}
+		if !utils.Is.Empty(params["sha256"]) {
+			mold.Where("sha256", strings.ToLower(cast.ToString(params["sha256"])))
+		}
+	})
+}
+
+// list - 分页查询
+func (this *Files) list(ctx *gin.Context, where func(mold *facade.ModelStruct)) {
+
+	code := 204
+	msg  := "无数据！"
+
+	// 获取请求参数
+	params := this.params(ctx, map[string]any{
+		"page":  1,
+		"order": "create_time desc",
+	})
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	// 查询条件
+	scope := func() *facade.ModelStruct {
+		mold := facade.DB.Model(&[]model.Files{}).Where("uid", user.Id)
+		if where != nil {
+			where(mold)
+		}
+		return mold
+	}
+
+	page  := cast.ToInt(params["page"])
+	limit := this.meta.limit(ctx)
+	count := scope().Count()
+	data  := scope().Limit(limit).Page(page).Order(params["order"]).Select()
+
+	if !utils.Is.Empty(data) {
+		code = 200
+		msg  = "数据请求成功！"
+	}
+
+	this.json(ctx, gin.H{
+		"data":  data,
+		"count": count,
+		"page":  math.Ceil(float64(count) / float64(limit)),
+	}, facade.Lang(ctx, msg), code)
+}
+
+// count 统计数据
+func (this *Files) count(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	item := facade.DB.Model(&model.Files{}).Where("uid", user.Id)
+
+	this.json(ctx, gin.H{
+		"count": item.Count(),
+		"size":  facade.DB.Model(&model.Files{}).Where("uid", user.Id).Sum("size"),
+	}, facade.Lang(ctx, "查询成功！"), 200)
+}
+
+// delete 真实删除 - 同一对象不再被任何记录引用时，同时从存储中删除
+func (this *Files) delete(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	// id 数组 - 参数归一化
+	ids := utils.Unity.Ids(params["ids"])
+
+	if utils.Is.Empty(ids) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "ids"), 400)
+		return
+	}
+
+	// 得到允许操作的数据
+	var files []model.Files
+	facade.DB.Drive().Where("uid = ?", user.Id).Where("id IN ?", ids).Find(&files)
+
+	// 无可操作数据
+	if utils.Is.Empty(files) {
+		this.json(ctx, nil, facade.Lang(ctx, "无可操作数据！"), 204)
+		return
+	}
+
+	ids = make([]any, 0, len(files))
+	for _, item := range files {
+		ids = append(ids, item.Id)
+	}
+
+	// 真实删除 - 同时删除不再被引用的对象
+	if err := model.FilesRemove(files); err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "删除失败！"), 400)
+		return
+	}
+
+	this.json(ctx, gin.H{ "ids": ids }, facade.Lang(ctx, "删除成功！"), 200)
+}
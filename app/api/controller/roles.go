@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"inis/app/model"
+	"strings"
+)
+
+// Roles - 角色和权限管理
+type Roles struct {
+	// 继承
+	base
+}
+
+// IGET - GET请求本体
+func (this *Roles) IGET(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"one":  this.one,
+		"all":  this.all,
+		"mine": this.mine,
+		"user": this.user,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPOST - POST请求本体
+func (this *Roles) IPOST(ctx *gin.Context) {
+
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"create": this.create,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPUT - PUT请求本体
+func (this *Roles) IPUT(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"update": this.update,
+		"assign": this.assign,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IDEL - DELETE请求本体
+func (this *Roles) IDEL(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"remove": this.remove,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// INDEX - GET请求本体
+func (this *Roles) INDEX(ctx *gin.Context) {
+	this.json(ctx, nil, facade.Lang(ctx, "没什么用！"), 202)
+}
+
+// one 获取指定角色
+func (this *Roles) one(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	if utils.Is.Empty(params["id"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "id"), 400)
+		return
+	}
+
+	item := model.RolesList(cast.ToInt(params["id"]))
+	if len(item) == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "无数据！"), 204)
+		return
+	}
+
+	this.json(ctx, item[0], facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// all 获取全部角色
+func (this *Roles) all(ctx *gin.Context) {
+
+	item := model.RolesList()
+	if len(item) == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "无数据！"), 204)
+		return
+	}
+
+	this.json(ctx, item, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// mine 当前用户的角色和权限集 - 供前端控制菜单和按钮
+func (this *Roles) mine(ctx *gin.Context) {
+
+	uid := this.meta.user(ctx).Id
+
+	roles := make([]model.Roles, 0)
+	if uid != 0 {
+		roles = model.RolesOfUser(uid)
+	}
+
+	this.json(ctx, gin.H{
+		"roles":       roles,
+		"permissions": model.PermissionsOf(uid),
+	}, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// user 指定用户的角色和权限集
+func (this *Roles) user(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	uid := cast.ToInt(params["uid"])
+	if uid == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "uid"), 400)
+		return
+	}
+
+	this.json(ctx, gin.H{
+		"roles":       model.RolesOfUser(uid),
+		"permissions": model.PermissionsOf(uid),
+	}, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// create 创建角色
+func (this *Roles) create(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	if utils.Is.Empty(params["key"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "key"), 400)
+		return
+	}
+
+	item := model.Roles{
+		Key:         cast.ToString(params["key"]),
+		Name:        cast.ToString(params["name"]),
+		Remark:      cast.ToString(params["remark"]),
+		Permissions: model.PermissionsNormalize(params["permissions"]),
+	}
+
+	if err := model.RolesSave(&item); err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, err.Error()), 400)
+		return
+	}
+
+	this.json(ctx, item, facade.Lang(ctx, "创建成功！"), 200)
+}
+
+// update 更新角色 - 提交了 permissions 时替换全部权限
+func (this *Roles) update(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	if utils.Is.Empty(params["id"]) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "id"), 400)
+		return
+	}
+
+	list := model.RolesList(cast.ToInt(params["id"]))
+	if len(list) == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "无数据！"), 204)
+		return
+	}
+	item := list[0]
+
+	if !utils.Is.Empty(params["key"]) && cast.ToString(params["key"]) != item.Key {
+		if utils.In.Array(item.Key, model.RolesBuiltin) {
+			this.json(ctx, nil, facade.Lang(ctx, "内置角色不能修改标识！"), 400)
+			return
+		}
+		item.Key = cast.ToString(params["key"])
+	}
+	if _, ok := params["name"]; ok {
+		item.Name = cast.ToString(params["name"])
+	}
+	if _, ok := params["remark"]; ok {
+		item.Remark = cast.ToString(params["remark"])
+	}
+	if _, ok := params["permissions"]; ok {
+		item.Permissions = model.PermissionsNormalize(params["permissions"])
+	}
+
+	// 管理员角色必须保留全部权限，避免没有人能管理角色
+	if item.Key == model.RolesAdmin && !utils.InArray("*", item.Permissions) {
+		this.json(ctx, nil, facade.Lang(ctx, "管理员角色必须拥有全部权限（*）！"), 400)
+		return
+	}
+
+	if err := model.RolesSave(&item); err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, err.Error()), 400)
+		return
+	}
+
+	this.json(ctx, item, facade.Lang(ctx, "更新成功！"), 200)
+}
+
+// assign 设置用户的角色 - 替换该用户的全部角色
+func (this *Roles) assign(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	uid := cast.ToInt(params["uid"])
+	if uid == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "uid"), 400)
+		return
+	}
+
+	if !facade.DB.Model(&model.Users{}).Where("id", uid).Exist() {
+		this.json(ctx, nil, facade.Lang(ctx, "用户不存在！"), 204)
+		return
+	}
+
+	// 不能取消自己的管理员权限
+	ids := cast.ToIntSlice(utils.Unity.Ids(params["ids"]))
+	if uid == this.meta.user(ctx).Id && model.PermissionsAdmin(uid) {
+		admin := false
+		if len(ids) != 0 {
+			for _, item := range model.RolesList(ids...) {
+				if utils.InArray("*", item.Permissions) {
+					admin = true
+				}
+			}
+		}
+		if !admin {
+			this.json(ctx, nil, facade.Lang(ctx, "不能取消自己的管理员权限！"), 400)
+			return
+		}
+	}
+
+	if err := model.RolesAssign(uid, ids); err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, err.Error()), 400)
+		return
+	}
+
+	this.json(ctx, gin.H{"uid": uid, "roles": model.RolesOfUser(uid)}, facade.Lang(ctx, "设置成功！"), 200)
+}
+
+// remove 删除角色 - 内置角色不能删除
+func (this *Roles) remove(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	// id 数组 - 参数归一化
+	ids := cast.ToIntSlice(utils.Unity.Ids(params["ids"]))
+
+	if utils.Is.Empty(ids) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "ids"), 400)
+		return
+	}
+
+	if err := model.RolesDelete(ids); err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, err.Error()), 400)
+		return
+	}
+
+	this.json(ctx, gin.H{"ids": ids}, facade.Lang(ctx, "删除成功！"), 200)
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"inis/app/model"
+	"strings"
+)
+
+// Sessions - 当前用户的登录设备
+type Sessions struct {
+	// 继承
+	base
+}
+
+// IGET - GET请求本体
+func (this *Sessions) IGET(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"all": this.all,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPOST - POST请求本体
+func (this *Sessions) IPOST(ctx *gin.Context) {
+
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPUT - PUT请求本体
+func (this *Sessions) IPUT(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IDEL - DELETE请求本体
+func (this *Sessions) IDEL(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"remove": this.remove,
+		"clear":  this.clear,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// INDEX - GET请求本体
+func (this *Sessions) INDEX(ctx *gin.Context) {
+	this.json(ctx, nil, facade.Lang(ctx, "没什么用！"), 202)
+}
+
+// all 当前用户的全部有效会话 - current 标记发起请求的设备
+func (this *Sessions) all(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	current := ctx.GetString("jti")
+
+	result := make([]gin.H, 0)
+	for _, item := range model.SessionsList(user.Id) {
+		result = append(result, gin.H{
+			"jti":         item.Jti,
+			"ip":          item.Ip,
+			"agent":       item.Agent,
+			"last_time":   item.LastTime,
+			"expire_time": item.ExpireTime,
+			"create_time": item.CreateTime,
+			"current":     item.Jti == current,
+		})
+	}
+
+	if utils.Is.Empty(result) {
+		this.json(ctx, nil, facade.Lang(ctx, "无数据！"), 204)
+		return
+	}
+
+	this.json(ctx, result, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// remove 退出指定设备 - 只能操作自己的会话
+func (this *Sessions) remove(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	jti := cast.ToString(params["jti"])
+	if utils.Is.Empty(jti) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "jti"), 400)
+		return
+	}
+
+	item, ok := model.SessionsFind(jti)
+	if !ok || item.Uid != user.Id || !item.Active() {
+		this.json(ctx, nil, facade.Lang(ctx, "无可操作数据！"), 204)
+		return
+	}
+
+	model.SessionsRevoke(jti)
+
+	this.json(ctx, gin.H{"jti": jti}, facade.Lang(ctx, "退出成功！"), 200)
+}
+
+// clear 退出全部设备 - keep 为真时保留当前设备
+func (this *Sessions) clear(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	var except string
+	if cast.ToBool(params["keep"]) {
+		except = ctx.GetString("jti")
+	}
+
+	count := model.SessionsRevokeUser(user.Id, except)
+
+	this.json(ctx, gin.H{"count": count}, facade.Lang(ctx, "退出成功！"), 200)
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cast"
@@ -24,10 +25,13 @@ func (this *Users) IGET(ctx *gin.Context) {
 	method := strings.ToLower(ctx.Param("method"))
 
 	allow := map[string]any{
-		"one":    this.one,
-		"all":    this.all,
-		"count":  this.count,
-		"column": this.column,
+		"one":        this.one,
+		"all":        this.all,
+		"count":      this.count,
+		"column":     this.column,
+		"2fa-status": this.totpStatus,
+		"2fa-qrcode": this.totpQRCode,
+		"identities": this.identities,
 	}
 	err := this.call(allow, method, ctx)
 
@@ -44,8 +48,11 @@ func (this *Users) IPOST(ctx *gin.Context) {
 	method := strings.ToLower(ctx.Param("method"))
 
 	allow := map[string]any{
-		"save":   this.save,
-		"create": this.create,
+		"save":         this.save,
+		"create":       this.create,
+		"2fa-setup":    this.totpSetup,
+		"2fa-confirm":  this.totpConfirm,
+		"2fa-recovery": this.totpRecovery,
 	}
 	err := this.call(allow, method, ctx)
 
@@ -84,9 +91,12 @@ func (this *Users) IDEL(ctx *gin.Context) {
 	method := strings.ToLower(ctx.Param("method"))
 
 	allow := map[string]any{
-		"remove": this.remove,
-		"delete": this.delete,
-		"clear":  this.clear,
+		"remove":      this.remove,
+		"delete":      this.delete,
+		"clear":       this.clear,
+		"logout":      this.logout,
+		"2fa-disable": this.totpDisable,
+		"unbind":      this.unbind,
 	}
 	err := this.call(allow, method, ctx)
 
@@ -110,6 +120,44 @@ func (this *Users) delCache() {
 	facade.Cache.DelTags([]any{"[GET]", "users"})
 }
 
+// public - 非管理员查看他人资料时只返回公开字段
+func (this *Users) public(ctx *gin.Context, data any) any {
+
+	user := this.meta.user(ctx)
+	if user.Id != 0 && model.PermissionsAdmin(user.Id) {
+		return data
+	}
+
+	item := func(value map[string]any) map[string]any {
+		if user.Id != 0 && cast.ToInt(value["id"]) == user.Id {
+			return value
+		}
+		return model.UsersPublic(value)
+	}
+
+	switch value := data.(type) {
+	case map[string]any:
+		return item(value)
+	case []map[string]any:
+		result := make([]map[string]any, 0, len(value))
+		for _, val := range value {
+			result = append(result, item(val))
+		}
+		return result
+	case []any:
+		result := make([]any, 0, len(value))
+		for _, val := range value {
+			if row, ok := val.(map[string]any); ok {
+				val = item(row)
+			}
+			result = append(result, val)
+		}
+		return result
+	}
+
+	return data
+}
+
 // one 获取指定数据
 func (this *Users) one(ctx *gin.Context) {
 
@@ -145,7 +193,7 @@ func (this *Users) one(ctx *gin.Context) {
 		mold := facade.DB.Model(&table)
 		mold.IWhere(params["where"]).IOr(params["or"]).ILike(params["like"]).INot(params["not"]).INull(params["null"]).INotNull(params["notNull"])
 
-		mold.WithoutField("password")
+		mold.WithoutField("password", "stamp", "phone_index")
 
 		item := mold.Where(table).Find()
 
@@ -162,7 +210,7 @@ func (this *Users) one(ctx *gin.Context) {
 		msg[0] = "数据请求成功！"
 	}
 
-	this.json(ctx, data, facade.Lang(ctx, strings.Join(msg, "")), code)
+	this.json(ctx, this.public(ctx, data), facade.Lang(ctx, strings.Join(msg, "")), code)
 }
 
 // all 获取全部数据
@@ -207,7 +255,7 @@ func (this *Users) all(ctx *gin.Context) {
 
 	} else {
 
-		mold.WithoutField("password")
+		mold.WithoutField("password", "stamp", "phone_index")
 
 		// 从数据库中获取数据
 		item := mold.Where(table).Limit(limit).Page(page).Order(params["order"]).Select()
@@ -226,7 +274,7 @@ func (this *Users) all(ctx *gin.Context) {
 	}
 
 	this.json(ctx, gin.H{
-		"data":  data,
+		"data":  this.public(ctx, data),
 		"count": count,
 		"page":  math.Ceil(float64(count) / float64(limit)),
 	}, facade.Lang(ctx, strings.Join(msg, "")), code)
@@ -239,6 +287,11 @@ func (this *Users) save(ctx *gin.Context) {
 	params := this.params(ctx)
 
 	if utils.Is.Empty(params["id"]) {
+		// 用户角色拥有 users:save 只用于修改自己的资料，创建用户需要 users:create 权限
+		if !model.PermissionsCheck(this.meta.user(ctx).Id, "users:create") {
+			this.json(ctx, nil, facade.Lang(ctx, "无权限！"), 403)
+			return
+		}
 		this.create(ctx)
 	} else {
 		this.update(ctx)
@@ -272,7 +325,7 @@ func (this *Users) create(ctx *gin.Context) {
 	for key, val := range params {
 		// 加密密码
 		if key == "password" {
-			val = utils.Password.Create(params["password"])
+			val = facade.Password.Create(params["password"])
 		}
 		// 防止恶意传入字段
 		if utils.In.Array(key, allow) {
@@ -302,6 +355,12 @@ func (this *Users) update(ctx *gin.Context) {
 		return
 	}
 
+	// 只能修改自己的资料，管理员除外
+	if uid := this.meta.user(ctx).Id; cast.ToInt(params["id"]) != uid && !model.PermissionsAdmin(uid) {
+		this.json(ctx, nil, facade.Lang(ctx, "无权限！"), 403)
+		return
+	}
+
 	// 验证器
 	err := validator.NewValid("users", params)
 
@@ -320,7 +379,9 @@ func (this *Users) update(ctx *gin.Context) {
 	for key, val := range params {
 		// 加密密码
 		if key == "password" {
-			val = utils.Password.Create(params["password"])
+			val = facade.Password.Create(params["password"])
+			// 更换安全戳 - 已签发的访问令牌立即失效
+			async.Set("stamp", model.UsersStampNew())
 		}
 		// 防止恶意传入字段
 		if utils.In.Array(key, allow) {
@@ -338,6 +399,10 @@ func (this *Users) update(ctx *gin.Context) {
 
 	// 删除缓存
 	facade.Cache.Del(fmt.Sprintf("user[%v]", params["id"]))
+	// 修改了密码 - 吊销全部会话
+	if !utils.Is.Empty(params["password"]) {
+		model.SessionsRevokeUser(params["id"], "")
+	}
 
 	this.json(ctx, gin.H{ "id": table.Id }, facade.Lang(ctx, "更新成功！"), 200)
 }
@@ -369,7 +434,7 @@ func (this *Users) column(ctx *gin.Context) {
 	item := facade.DB.Model(&table).Order(params["order"])
 	item.IWhere(params["where"]).IOr(params["or"]).ILike(params["like"]).INot(params["not"]).INull(params["null"]).INotNull(params["notNull"])
 
-	item.WithoutField("password")
+	item.WithoutField("password", "stamp", "phone_index")
 
 	if !strings.Contains(cast.ToString(params["field"]), "*") {
 		item.Field(params["field"])
@@ -383,6 +448,10 @@ func (this *Users) column(ctx *gin.Context) {
 
 	code := 200
 	data := item.Column()
+	// 直接查询不经过模型，手机号需要解密
+	if rows, ok := data.([]map[string]any); ok {
+		model.UsersDecrypt(rows)
+	}
 	msg := facade.Lang(ctx, "查询成功！")
 
 	if utils.Is.Empty(data) {
@@ -390,7 +459,7 @@ func (this *Users) column(ctx *gin.Context) {
 		msg = facade.Lang(ctx, "无数据！")
 	}
 
-	this.json(ctx, data, msg, code)
+	this.json(ctx, this.public(ctx, data), msg, code)
 }
 
 // remove 软删除
@@ -433,6 +502,12 @@ func (this *Users) remove(ctx *gin.Context) {
 		return
 	}
 
+	// 被删除的用户立即下线，API Key 一并吊销
+	for _, id := range ids {
+		model.SessionsRevokeUser(id, "")
+		model.ApiKeysRevokeUser(id)
+	}
+
 	this.json(ctx, gin.H{ "ids": ids }, facade.Lang(ctx, "删除成功！"), 200)
 }
 
@@ -476,6 +551,15 @@ func (this *Users) delete(ctx *gin.Context) {
 		return
 	}
 
+	// 被删除的用户立即下线，API Key 一并吊销
+	for _, id := range ids {
+		model.SessionsRevokeUser(id, "")
+		model.ApiKeysRevokeUser(id)
+	}
+
+	// 解除第三方帐号绑定 - 否则该第三方帐号无法再登录或绑定
+	model.IdentitiesRemove(ids...)
+
 	this.json(ctx, gin.H{ "ids": ids }, facade.Lang(ctx, "删除成功！"), 200)
 }
 
@@ -503,6 +587,9 @@ func (this *Users) clear(ctx *gin.Context) {
 		return
 	}
 
+	// 解除第三方帐号绑定
+	model.IdentitiesRemove(ids...)
+
 	this.json(ctx, gin.H{ "ids": ids }, facade.Lang(ctx, "清空成功！"), 200)
 }
 
@@ -543,3 +630,260 @@ func (this *Users) restore(ctx *gin.Context) {
 
 	this.json(ctx, gin.H{ "ids": ids }, facade.Lang(ctx, "恢复成功！"), 200)
 }
+
+// logout 强制下线 - 吊销指定用户的全部会话
+func (this *Users) logout(ctx *gin.Context) {
+
+	// 获取请求参数
+	params := this.params(ctx)
+
+	// id 数组 - 参数归一化
+	ids := utils.Unity.Ids(params["ids"])
+
+	if utils.Is.Empty(ids) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "ids"), 400)
+		return
+	}
+
+	count := 0
+	for _, id := range ids {
+		count += model.SessionsRevokeUser(id, "")
+	}
+
+	this.json(ctx, gin.H{"ids": ids, "count": count}, facade.Lang(ctx, "操作成功！"), 200)
+}
+
+// identities 当前用户绑定的第三方帐号
+func (this *Users) identities(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	this.json(ctx, model.IdentitiesList(user.Id), facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// unbind 解除第三方帐号绑定 - 未设置密码且没有其他绑定时不允许解除，避免无法再登录
+func (this *Users) unbind(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	id := this.param(ctx, "id")
+	if utils.Is.Empty(id) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "id"), 400)
+		return
+	}
+
+	if utils.Is.Empty(user.Password) && len(model.IdentitiesList(user.Id)) <= 1 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先设置密码，再解除绑定！"), 400)
+		return
+	}
+
+	if model.IdentitiesUnbind(user.Id, id) == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "无可操作数据！"), 204)
+		return
+	}
+
+	this.json(ctx, nil, facade.Lang(ctx, "解除绑定成功！"), 200)
+}
+
+// totpStatus 当前用户的两步验证状态
+func (this *Users) totpStatus(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	item, ok := model.TotpsFind(user.Id)
+
+	this.json(ctx, gin.H{
+		"enable":      ok,
+		"recovery":    item.Remaining(),
+		"create_time": item.CreateTime,
+	}, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// totpSetup 开启两步验证 - 生成待确认的密钥，确认（2fa-confirm）前不生效
+func (this *Users) totpSetup(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	if model.TotpsEnabled(user.Id) {
+		this.json(ctx, nil, facade.Lang(ctx, "已开启两步验证，如需更换请先关闭！"), 400)
+		return
+	}
+
+	secret := facade.Totp.Secret()
+	if utils.Is.Empty(secret) || !facade.CacheSet(this.totpCache(user.Id), secret, model.TotpsExpire) {
+		this.json(ctx, nil, facade.Lang(ctx, "两步验证初始化失败！"), 500)
+		return
+	}
+
+	uri := facade.Totp.URI(this.totpLabel(user), secret)
+
+	png, err := facade.Totp.QRCode(uri, 256)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	this.json(ctx, gin.H{
+		"secret": secret,
+		"uri":    uri,
+		"qrcode": "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		"expire": int64(model.TotpsExpire.Seconds()),
+	}, facade.Lang(ctx, "请使用认证器应用扫描二维码，并提交验证码完成开启！"), 200)
+}
+
+// totpQRCode 待确认密钥的二维码（PNG）
+func (this *Users) totpQRCode(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	secret, ok := facade.CacheGet[string](this.totpCache(user.Id))
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "请先开启两步验证！"), 400)
+		return
+	}
+
+	png, err := facade.Totp.QRCode(facade.Totp.URI(this.totpLabel(user), secret), cast.ToInt(this.param(ctx, "size", 256)))
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	// 二维码中包含密钥，不能缓存
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(200, "image/png", png)
+}
+
+// totpConfirm 确认开启两步验证 - 返回恢复码，只显示这一次
+func (this *Users) totpConfirm(ctx *gin.Context) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return
+	}
+
+	code := cast.ToString(this.param(ctx, "code"))
+	if utils.Is.Empty(code) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "code"), 400)
+		return
+	}
+
+	cacheName := this.totpCache(user.Id)
+	secret, ok := facade.CacheGet[string](cacheName)
+	if !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "两步验证已过期，请重新开启！"), 400)
+		return
+	}
+
+	step, pass := facade.Totp.Verify(secret, code)
+	if !pass {
+		this.json(ctx, nil, facade.Lang(ctx, "验证码错误！"), 400)
+		return
+	}
+
+	codes, err := model.TotpsEnable(user.Id, secret, step)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	go facade.Cache.Del(cacheName)
+
+	this.json(ctx, gin.H{"recovery": codes}, facade.Lang(ctx, "两步验证已开启，请妥善保存恢复码！"), 200)
+}
+
+// totpRecovery 重新生成恢复码 - 需要验证码
+func (this *Users) totpRecovery(ctx *gin.Context) {
+
+	item, ok := this.totpVerify(ctx)
+	if !ok {
+		return
+	}
+
+	codes, err := model.TotpsRecovery(item.Uid)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	this.json(ctx, gin.H{"recovery": codes}, facade.Lang(ctx, "恢复码已重新生成，旧的恢复码已失效！"), 200)
+}
+
+// totpDisable 关闭两步验证 - 需要验证码或恢复码
+func (this *Users) totpDisable(ctx *gin.Context) {
+
+	item, ok := this.totpVerify(ctx)
+	if !ok {
+		return
+	}
+
+	if err := model.TotpsDisable(item.Uid); err != nil {
+		this.json(ctx, nil, err.Error(), 500)
+		return
+	}
+
+	this.json(ctx, nil, facade.Lang(ctx, "两步验证已关闭！"), 200)
+}
+
+// totpVerify - 校验当前用户提交的验证码或恢复码 - 不通过时已输出响应
+func (this *Users) totpVerify(ctx *gin.Context) (item model.Totps, ok bool) {
+
+	user := this.meta.user(ctx)
+	if user.Id == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return item, false
+	}
+
+	code := cast.ToString(this.param(ctx, "code"))
+	if utils.Is.Empty(code) {
+		this.json(ctx, nil, facade.Lang(ctx, "%s 不能为空！", "code"), 400)
+		return item, false
+	}
+
+	if item, ok = model.TotpsFind(user.Id); !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "未开启两步验证！"), 400)
+		return item, false
+	}
+
+	if _, ok = item.Verify(code); !ok {
+		this.json(ctx, nil, facade.Lang(ctx, "验证码错误！"), 400)
+		return item, false
+	}
+
+	return item, true
+}
+
+// totpCache - 待确认密钥的缓存名称
+func (this *Users) totpCache(uid int) string {
+	return fmt.Sprintf("2fa-setup[%d]", uid)
+}
+
+// totpLabel - 认证器中显示的帐号 - 邮箱、帐号、手机号，都没有时为用户ID
+func (this *Users) totpLabel(user model.Users) string {
+	for _, item := range []string{user.Email, user.Account, user.Phone} {
+		if !utils.Is.Empty(item) {
+			return item
+		}
+	}
+	return cast.ToString(user.Id)
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"inis/app/model"
+	"time"
+)
+
+// Auth - 登录认证中间件 - 带 i-api-key 请求头时使用 API Key 签名认证，否则使用 JWT
+func Auth() gin.HandlerFunc {
+
+	jwt := Jwt()
+
+	return func(ctx *gin.Context) {
+
+		if utils.Is.Empty(ctx.GetHeader("i-api-key")) {
+			jwt(ctx)
+			return
+		}
+
+		result := gin.H{"code": 401, "msg": facade.Lang(ctx, "禁止非法操作！"), "data": nil}
+
+		item, err := model.ApiKeysAuth(ctx.Writer, ctx.Request)
+		if err != nil {
+			result["msg"] = facade.Lang(ctx, err.Error())
+			ctx.JSON(200, result)
+			ctx.Abort()
+			return
+		}
+
+		user := authUser(item.Uid, time.Duration(facade.JwtAccessExpire())*time.Second)
+		if utils.Is.Empty(user) {
+			result["msg"] = facade.Lang(ctx, "API Key 无效或已过期！")
+			ctx.JSON(200, result)
+			ctx.Abort()
+			return
+		}
+
+		go model.ApiKeysTouch(item, ctx.ClientIP())
+
+		// 与 JWT 一致挂载用户，scopes 由 Rbac 中间件与用户权限取交集
+		ctx.Set("user", user)
+		ctx.Set("api_key", item.KeyId)
+		ctx.Set("scopes", item.Scopes)
+
+		ctx.Next()
+	}
+}
+
+// authUser - 获取用户信息 - 开启了缓存时优先从缓存读取
+/**
+ * @param uid 用户ID
+ * @param expire 缓存时间
+ * @return map[string]any 用户不存在时为 nil
+ */
+func authUser(uid any, expire time.Duration) (user map[string]any) {
+
+	cacheName  := fmt.Sprintf("user[%v]", uid)
+	cacheState := cast.ToBool(facade.CacheToml.Get("open"))
+
+	// 如果开启了缓存 - 且缓存存在 - 直接从缓存中获取
+	var item model.Users
+	var exist bool
+	if cacheState {
+		item, exist = facade.CacheGet[model.Users](cacheName)
+	}
+
+	if !exist {
+
+		facade.DB.Drive().Where("id = ?", uid).Limit(1).Find(&item)
+		if item.Id == 0 {
+			return nil
+		}
+		if cacheState {
+			go facade.CacheSet(cacheName, item, expire)
+		}
+	}
+
+	return cast.ToStringMap(utils.Json.Decode(utils.Json.Encode(item)))
+}
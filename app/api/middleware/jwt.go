@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cast"
 	"github.com/unti-io/go-utils/utils"
@@ -10,10 +9,18 @@ import (
 	"time"
 )
 
+// jwtSkip - 不校验登录令牌的接口 - 访问令牌过期后仍需要能刷新、退出和重新登录
+var jwtSkip = []any{"/api/comm/login", "/api/comm/verify-2fa", "/api/comm/unlock", "/api/comm/refresh", "/api/comm/logout", "/api/comm/oauth-callback"}
+
 // Jwt - JWT 中间件
 func Jwt() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
+		if utils.In.Array(ctx.Request.URL.Path, jwtSkip) {
+			ctx.Next()
+			return
+		}
+
 		tokenName := cast.ToString(facade.AppToml.Get("app.token_name", "UNTI_LOGIN_TOKEN"))
 
 		var token string
@@ -40,25 +47,30 @@ func Jwt() gin.HandlerFunc {
 			return
 		}
 
-		var user map[string]any
-		cacheName  := fmt.Sprintf("user[%v]", jwt.Data["uid"])
-		cacheState := cast.ToBool(facade.CacheToml.Get("open"))
-
-		// 如果开启了缓存 - 且缓存存在 - 直接从缓存中获取
-		if cacheState && facade.Cache.Has(cacheName) {
-
-			user = cast.ToStringMap(facade.Cache.Get(cacheName))
-
-		}  else {
+		// 非访问令牌，或超过访问令牌的有效期（包括升级前签发的长期令牌） - 需要使用刷新令牌换取新的
+		if jwt.Data["type"] != nil && jwt.Data["type"] != "access" || time.Now().Unix()-jwt.Issued > facade.JwtAccessExpire() {
+			result["msg"] = facade.Lang(ctx, "登录已过期，请重新登录！")
+			ctx.JSON(200, result)
+			ctx.Abort()
+			return
+		}
 
-			user = facade.DB.Model(&model.Users{}).Find(jwt.Data["uid"])
-			if cacheState {
-				go facade.Cache.Set(cacheName, user, time.Duration(jwt.Valid)*time.Second)
-			}
+		// 会话已被吊销（退出登录、强制下线）或不存在（包括没有会话的旧令牌） - 访问令牌立即失效
+		session, _ := model.SessionsFind(jwt.Jti)
+		if utils.Is.Empty(jwt.Jti) || !session.Active() {
+			result["msg"] = facade.Lang(ctx, "登录已过期，请重新登录！")
+			ctx.SetCookie(tokenName, "", -1, "/", "", false, false)
+			ctx.JSON(200, result)
+			ctx.Abort()
+			return
 		}
+		go model.SessionsTouch(session, ctx.ClientIP(), 0)
+		ctx.Set("jti", jwt.Jti)
+
+		user := authUser(jwt.Data["uid"], time.Duration(jwt.Valid)*time.Second)
 
-		// 密码发生变化 - 强制退出
-		if jwt.Data["hash"] != facade.Hash.Sum32(user["password"]) {
+		// 安全戳发生变化（修改密码） - 强制退出
+		if stamp := cast.ToString(jwt.Data["stamp"]); utils.Is.Empty(stamp) || stamp != cast.ToString(user["stamp"]) {
 			result["msg"] = facade.Lang(ctx, "登录已过期，请重新登录！")
 			ctx.SetCookie(tokenName, "", -1, "/", "", false, false)
 			ctx.JSON(200, result)
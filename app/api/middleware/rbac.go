@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"inis/app/facade"
+	"inis/app/model"
+	"strings"
+)
+
+// Rbac - 权限中间件 - 按 控制器:方法（如 users:delete）校验当前用户的权限，需要在 Auth 中间件之后
+func Rbac() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// 未匹配到路由，或没有方法的 INDEX 路由
+		path := strings.Split(strings.Trim(strings.TrimPrefix(ctx.FullPath(), "/api/"), "/"), "/")
+		if len(path) < 2 {
+			ctx.Next()
+			return
+		}
+
+		name := strings.ToLower(fmt.Sprintf("%s:%s", path[0], ctx.Param("method")))
+
+		var uid int
+		if user, ok := ctx.Get("user"); ok {
+			uid = cast.ToInt(cast.ToStringMap(user)["id"])
+		}
+
+		// API Key - 权限为用户权限与 scopes 的交集
+		allow := model.PermissionsCheck(uid, name)
+		if scopes, ok := ctx.Get("scopes"); ok && allow {
+			allow = model.PermissionsMatch(cast.ToStringSlice(scopes), name)
+		}
+
+		if allow {
+			ctx.Next()
+			return
+		}
+
+		result := gin.H{"code": 403, "msg": facade.Lang(ctx, "无权限！"), "data": nil}
+		if uid == 0 {
+			result["code"], result["msg"] = 401, facade.Lang(ctx, "请先登录！")
+		}
+
+		ctx.JSON(200, result)
+		ctx.Abort()
+	}
+}
@@ -13,7 +13,8 @@ func Route(Gin *gin.Engine) {
 	// 全局中间件
 	group := Gin.Group("/api/").Use(
 		global.Params(),    // 解析参数
-		middle.Jwt(),       // 验证权限
+		middle.Auth(),      // 验证登录（JWT 或 API Key）
+		middle.Rbac(),      // 验证权限
 	)
 
 	// 允许动态挂载的路由
@@ -21,7 +22,11 @@ func Route(Gin *gin.Engine) {
 		"test":          &controller.Test{},
 		"comm":          &controller.Comm{},
 		"file":          &controller.File{},
+		"files":         &controller.Files{},
 		"users":         &controller.Users{},
+		"sessions":      &controller.Sessions{},
+		"roles":         &controller.Roles{},
+		"apikeys":       &controller.ApiKeys{},
 		"proxy":         &controller.Proxy{},
 	}
 
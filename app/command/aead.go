@@ -0,0 +1,55 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"inis/app/facade"
+	"inis/app/model"
+	"reflect"
+)
+
+func init() {
+	register(Command{
+		Name:   "aead:rotate",
+		Usage:  "轮换字段加密密钥：aead:rotate，生成新的加密密钥，旧密钥继续用于解密，执行 aead:migrate 后旧数据才会使用新密钥",
+		Handle: aeadRotate,
+	}, Command{
+		Name:   "aead:migrate",
+		Usage:  "重新加密加密字段：aead:migrate，使用当前密钥重新加密全部数据并重建盲索引，轮换密钥或修改 aead.index_key 后执行",
+		Handle: aeadMigrate,
+	})
+}
+
+// aeadRotate - 轮换字段加密密钥
+func aeadRotate(args ...string) (err error) {
+
+	key, err := facade.Aead.Generate()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("已生成密钥：%s\n", key.Kid)
+	fmt.Println("注意：旧密钥仍用于解密旧数据，执行 aead:migrate 重新加密后才可以删除")
+
+	return nil
+}
+
+// aeadMigrate - 使用当前密钥重新加密全部数据
+func aeadMigrate(args ...string) (err error) {
+
+	if facade.DB == nil || facade.DB.Drive() == nil {
+		return errors.New("数据库未连接，请检查 config/database.toml")
+	}
+
+	for _, item := range model.EncryptModels {
+
+		count, err := model.EncryptMigrate(item, true)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s：已重新加密 %d 条数据\n", reflect.TypeOf(item).Elem().Name(), count)
+	}
+
+	return nil
+}
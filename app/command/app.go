@@ -0,0 +1,76 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Command - 命令行任务
+type Command struct {
+	// 命令名称 - 如：cache:clear
+	Name   string
+	// 命令说明
+	Usage  string
+	// 命令本体
+	Handle func(args ...string) (err error)
+}
+
+// commands - 已注册的命令
+var commands = make(map[string]Command)
+
+// register - 注册命令
+func register(items ...Command) {
+	for _, item := range items {
+		commands[strings.ToLower(item.Name)] = item
+	}
+}
+
+// Run - 执行命令
+/**
+ * @param args 命令行参数 - 第一个为命令名称
+ * @return ok 是否为已注册的命令，不是时返回 false，继续启动服务
+ * @return err 命令执行失败的错误
+ * @example：
+ * ./unti cache:gc
+ * ./unti cache:clear redis
+ */
+func Run(args ...string) (ok bool, err error) {
+
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	name := strings.ToLower(args[0])
+
+	if name == "help" || name == "list" {
+		help()
+		return true, nil
+	}
+
+	item, exist := commands[name]
+	if !exist {
+		return false, nil
+	}
+
+	if err = item.Handle(args[1:]...); err != nil {
+		return true, fmt.Errorf("%s 执行失败：%v", item.Name, err)
+	}
+
+	return true, nil
+}
+
+// help - 打印命令列表
+func help() {
+
+	var names []string
+	for key := range commands {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	fmt.Println("可用命令：")
+	for _, name := range names {
+		fmt.Printf("  %-20s %s\n", commands[name].Name, commands[name].Usage)
+	}
+}
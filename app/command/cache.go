@@ -0,0 +1,62 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"inis/app/facade"
+	"strings"
+)
+
+func init() {
+	register(Command{
+		Name:   "cache:clear",
+		Usage:  "清空缓存，可指定驱动：file | redis，默认为当前驱动",
+		Handle: cacheClear,
+	}, Command{
+		Name:   "cache:gc",
+		Usage:  "清理文件缓存中过期的文件和旧版本的缓存文件，并按 file.max_size / file.max_files 淘汰最旧的文件",
+		Handle: cacheGC,
+	})
+}
+
+// cacheClear - 清空缓存
+func cacheClear(args ...string) (err error) {
+
+	cache := facade.Cache
+	name := "default"
+
+	if len(args) > 0 {
+		name = strings.ToLower(args[0])
+		// 内存缓存只存在于服务进程中，命令行无法清空
+		if name == facade.CacheModeRAM {
+			return errors.New("内存缓存只能通过重启服务清空")
+		}
+		cache = facade.NewCache(name)
+	}
+
+	if !cache.Clear() {
+		return fmt.Errorf("%s 缓存清空失败", name)
+	}
+
+	fmt.Printf("%s 缓存已清空\n", name)
+
+	return nil
+}
+
+// cacheGC - 文件缓存垃圾回收
+func cacheGC(args ...string) (err error) {
+
+	if facade.FileCache == nil || facade.FileCache.Client == nil {
+		return errors.New("文件缓存未初始化")
+	}
+
+	result, err := facade.FileCache.Client.GC()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("过期删除：%d，旧版本删除：%d，容量淘汰：%d，剩余文件：%d，占用：%.2f MB\n",
+		result.Expired, result.Legacy, result.Evicted, result.Files, float64(result.Size)/1024/1024)
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"inis/app/facade"
+	"strings"
+)
+
+func init() {
+	register(Command{
+		Name:   "jwt:generate",
+		Usage:  "生成JWT密钥：jwt:generate [算法]，该算法下还没有密钥时生成，已有时不做任何修改",
+		Handle: jwtGenerate,
+	}, Command{
+		Name:   "jwt:rotate",
+		Usage:  "轮换JWT密钥：jwt:rotate [算法]，生成新的签名密钥，旧密钥继续用于验证，停用超过令牌最长有效期（jwt.expire、jwt.access、jwt.refresh 中最大的）的密钥会被删除",
+		Handle: jwtRotate,
+	})
+}
+
+// jwtAlgorithm - 命令参数中的算法，默认为 jwt.algorithm
+func jwtAlgorithm(args ...string) (algorithm string, err error) {
+
+	algorithm = facade.JwtKeys.Algorithm()
+	if len(args) > 0 {
+		algorithm = strings.ToUpper(args[0])
+		if algorithm == strings.ToUpper(facade.JwtEdDSA) {
+			algorithm = facade.JwtEdDSA
+		}
+	}
+
+	if algorithm == facade.JwtHS256 {
+		return "", errors.New("HS256 使用 crypt.toml 中的 jwt.key，请配置 jwt.algorithm 为 RS256、ES256 或 EdDSA")
+	}
+
+	return algorithm, nil
+}
+
+// jwtGenerate - 生成JWT密钥 - 已有该算法的密钥时不重复生成
+func jwtGenerate(args ...string) (err error) {
+
+	algorithm, err := jwtAlgorithm(args...)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range facade.JwtKeys.Keys() {
+		if key.Algorithm == algorithm {
+			fmt.Printf("已有密钥：%s（%s），如需更换请执行 jwt:rotate\n", key.Kid, key.Algorithm)
+			return nil
+		}
+	}
+
+	key, err := facade.JwtKeys.Generate(algorithm)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("已生成密钥：%s（%s）\n", key.Kid, key.Algorithm)
+	fmt.Println("注意：多实例部署时，请把密钥目录共享给所有实例")
+
+	return nil
+}
+
+// jwtRotate - 轮换JWT密钥
+func jwtRotate(args ...string) (err error) {
+
+	algorithm, err := jwtAlgorithm(args...)
+	if err != nil {
+		return err
+	}
+
+	key, err := facade.JwtKeys.Generate(algorithm)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("已生成密钥：%s（%s）\n", key.Kid, key.Algorithm)
+	if algorithm != facade.JwtKeys.Algorithm() {
+		fmt.Printf("注意：当前 jwt.algorithm 为 %s，新密钥需要将其改为 %s 后才会用于签名\n", facade.JwtKeys.Algorithm(), algorithm)
+	}
+
+	pruned, err := facade.JwtKeys.Prune()
+	if err != nil {
+		return err
+	}
+	for _, kid := range pruned {
+		fmt.Printf("已删除停用的密钥：%s\n", kid)
+	}
+
+	return nil
+}
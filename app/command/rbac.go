@@ -0,0 +1,57 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"inis/app/facade"
+	"inis/app/model"
+	"strings"
+)
+
+func init() {
+	register(Command{
+		Name:   "rbac:assign",
+		Usage:  "设置用户的角色：rbac:assign 用户ID 角色标识...，如：rbac:assign 1 admin，不带角色时移除全部角色",
+		Handle: rbacAssign,
+	})
+}
+
+// rbacAssign - 设置用户的角色 - 用于没有管理员时恢复权限
+func rbacAssign(args ...string) (err error) {
+
+	if len(args) < 1 || cast.ToInt(args[0]) == 0 {
+		return errors.New("用法：rbac:assign 用户ID 角色标识...")
+	}
+
+	if facade.DB == nil || facade.DB.Drive() == nil {
+		return errors.New("数据库未连接，请检查 config/database.toml")
+	}
+
+	uid := cast.ToInt(args[0])
+	if !facade.DB.Model(&model.Users{}).Where("id", uid).Exist() {
+		return fmt.Errorf("用户不存在：%d", uid)
+	}
+
+	// 确保角色表存在
+	model.InitRoles()
+
+	var ids []int
+	for _, key := range args[1:] {
+		var id int
+		facade.DB.Drive().Model(&model.Roles{}).Where("`key` = ?", strings.ToLower(key)).Select("id").Scan(&id)
+		if id == 0 {
+			return fmt.Errorf("角色不存在：%s", key)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = model.RolesAssign(uid, ids); err != nil {
+		return err
+	}
+
+	fmt.Printf("用户 %d 的角色：%s\n", uid, strings.Join(args[1:], ", "))
+	fmt.Printf("权限：%s\n", strings.Join(model.PermissionsOf(uid), ", "))
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"inis/app/facade"
+	"inis/app/model"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+func init() {
+	register(Command{
+		Name:   "storage:migrate",
+		Usage:  "迁移存储：storage:migrate 源驱动 目标驱动 [并发数]，中断后再次执行会继续，完成后改写数据库中的文件地址",
+		Handle: storageMigrate,
+	}, Command{
+		Name:   "storage:migrate:reset",
+		Usage:  "清除迁移进度：storage:migrate:reset 源驱动 目标驱动",
+		Handle: storageMigrateReset,
+	})
+}
+
+// storageMigrate - 迁移存储
+func storageMigrate(args ...string) (err error) {
+
+	if len(args) < 2 {
+		return errors.New("用法：storage:migrate 源驱动 目标驱动 [并发数]")
+	}
+
+	migrate, err := facade.NewStorageMigrate(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 2 {
+		migrate.Concurrency = cast.ToInt(args[2])
+	}
+
+	migrate.Progress = func(key string, skipped bool, err error) {
+		switch {
+		case err != nil:
+			fmt.Printf("失败 %s：%v\n", key, err)
+		case skipped:
+			fmt.Printf("跳过 %s\n", key)
+		default:
+			fmt.Printf("复制 %s\n", key)
+		}
+	}
+
+	// Ctrl+C 中断 - 保留进度
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	state, err := migrate.Run(ctx)
+
+	fmt.Printf("共 %d 个，复制 %d，跳过 %d，失败 %d，复制大小：%.2f MB\n",
+		state.Total, state.Copied, state.Skipped, state.Failed, float64(state.Size)/1024/1024)
+
+	if err != nil {
+		return err
+	}
+
+	if state.Failed > 0 {
+		return fmt.Errorf("有 %d 个文件迁移失败，请重新执行以重试，文件地址未改写", state.Failed)
+	}
+
+	result, err := model.StorageRewrite(strings.ToLower(args[0]), strings.ToLower(args[1]))
+	if err != nil {
+		return fmt.Errorf("文件地址改写失败：%v", err)
+	}
+
+	for key, rows := range result {
+		fmt.Printf("改写 %s：%d 行\n", key, rows)
+	}
+
+	fmt.Printf("迁移完成，如需切换默认驱动，请修改 storage.toml 中的 default = \"%s\"\n", strings.ToLower(args[1]))
+
+	return nil
+}
+
+// storageMigrateReset - 清除迁移进度
+func storageMigrateReset(args ...string) (err error) {
+
+	if len(args) < 2 {
+		return errors.New("用法：storage:migrate:reset 源驱动 目标驱动")
+	}
+
+	migrate, err := facade.NewStorageMigrate(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	if err = migrate.Reset(); err != nil {
+		return err
+	}
+
+	fmt.Println("迁移进度已清除")
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"inis/app/model"
+	"net"
+	"strings"
+	"sync"
+)
+
+type Storage struct {
+	// 继承
+	base
+}
+
+// storageMigrate - 正在执行的迁移任务 - 同一时间只允许一个
+var storageMigrate struct {
+	mutex   sync.Mutex
+	job     *facade.StorageMigrateStruct
+	cancel  context.CancelFunc
+	rewrite map[string]int64
+}
+
+// IGET - GET请求本体
+func (this *Storage) IGET(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"migrate": this.state,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPOST - POST请求本体
+func (this *Storage) IPOST(ctx *gin.Context) {
+
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"migrate": this.migrate,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IPUT - PUT请求本体
+func (this *Storage) IPUT(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// IDEL - DELETE请求本体
+func (this *Storage) IDEL(ctx *gin.Context) {
+	// 转小写
+	method := strings.ToLower(ctx.Param("method"))
+
+	allow := map[string]any{
+		"migrate": this.stop,
+	}
+	err := this.call(allow, method, ctx)
+
+	if err != nil {
+		this.json(ctx, nil, facade.Lang(ctx, "方法调用错误：%v", err.Error()), 405)
+		return
+	}
+}
+
+// INDEX - GET请求本体
+func (this *Storage) INDEX(ctx *gin.Context) {
+	this.json(ctx, nil, facade.Lang(ctx, "没什么用！"), 202)
+}
+
+// local - 迁移会读写全部文件，只允许管理员在服务器本机调用
+/**
+ * 同机反向代理转发的请求也是本机地址，所以还必须是管理员（JWT 或 API Key）
+ */
+func (this *Storage) local(ctx *gin.Context) bool {
+
+	ip := net.ParseIP(ctx.RemoteIP())
+	if ip == nil || !ip.IsLoopback() {
+		this.json(ctx, nil, facade.Lang(ctx, "只允许在服务器本机调用！"), 403)
+		return false
+	}
+
+	user, _ := ctx.Get("user")
+	uid := cast.ToInt(cast.ToStringMap(user)["id"])
+	if uid == 0 {
+		this.json(ctx, nil, facade.Lang(ctx, "请先登录！"), 401)
+		return false
+	}
+
+	if !model.PermissionsAdmin(uid) {
+		this.json(ctx, nil, facade.Lang(ctx, "无权限！"), 403)
+		return false
+	}
+
+	// API Key 的 scopes 也需要包含该权限
+	if scopes, ok := ctx.Get("scopes"); ok && !model.PermissionsMatch(cast.ToStringSlice(scopes), "storage:migrate") {
+		this.json(ctx, nil, facade.Lang(ctx, "无权限！"), 403)
+		return false
+	}
+
+	return true
+}
+
+// migrate - 开始或继续迁移 - 在后台执行，通过 GET 查询进度
+/**
+ * @example：
+ * POST /dev/storage/migrate { "from": "oss", "to": "s3", "concurrency": 8 }
+ */
+func (this *Storage) migrate(ctx *gin.Context) {
+
+	if !this.local(ctx) {
+		return
+	}
+
+	params := this.params(ctx, map[string]any{
+		"concurrency": 4,
+	})
+
+	storageMigrate.mutex.Lock()
+	defer storageMigrate.mutex.Unlock()
+
+	if storageMigrate.job != nil && storageMigrate.job.State().Running {
+		this.json(ctx, storageMigrate.job.State(), facade.Lang(ctx, "已有迁移任务正在执行！"), 400)
+		return
+	}
+
+	from := strings.ToLower(cast.ToString(params["from"]))
+	to   := strings.ToLower(cast.ToString(params["to"]))
+
+	job, err := facade.NewStorageMigrate(from, to)
+	if err != nil {
+		this.json(ctx, nil, err.Error(), 400)
+		return
+	}
+	job.Concurrency = cast.ToInt(params["concurrency"])
+
+	background, cancel := context.WithCancel(context.Background())
+
+	storageMigrate.job     = job
+	storageMigrate.cancel  = cancel
+	storageMigrate.rewrite = nil
+
+	go func() {
+
+		defer cancel()
+
+		state, err := job.Run(background)
+		if err != nil || state.Failed > 0 {
+			return
+		}
+
+		// 全部复制成功后改写文件地址
+		result, err := model.StorageRewrite(from, to)
+		if err != nil {
+			facade.Log.Error(map[string]any{
+				"error":     err,
+				"from":      from,
+				"to":        to,
+				"func_name": utils.Caller().FuncName,
+				"file_name": utils.Caller().FileName,
+				"file_line": utils.Caller().Line,
+			}, "存储迁移改写文件地址失败")
+			return
+		}
+
+		storageMigrate.mutex.Lock()
+		storageMigrate.rewrite = result
+		storageMigrate.mutex.Unlock()
+	}()
+
+	this.json(ctx, job.State(), facade.Lang(ctx, "迁移已开始！"), 200)
+}
+
+// state - 迁移进度 - 未指定 from 和 to 时返回当前任务
+func (this *Storage) state(ctx *gin.Context) {
+
+	if !this.local(ctx) {
+		return
+	}
+
+	params := this.params(ctx)
+
+	storageMigrate.mutex.Lock()
+	job, rewrite := storageMigrate.job, storageMigrate.rewrite
+	storageMigrate.mutex.Unlock()
+
+	// 查询指定驱动的进度文件
+	if !utils.Is.Empty(params["from"]) || !utils.Is.Empty(params["to"]) {
+		from := strings.ToLower(cast.ToString(params["from"]))
+		to   := strings.ToLower(cast.ToString(params["to"]))
+		if job == nil || job.State().From != from || job.State().To != to {
+			item, err := facade.NewStorageMigrate(from, to)
+			if err != nil {
+				this.json(ctx, nil, err.Error(), 400)
+				return
+			}
+			job, rewrite = item, nil
+		}
+	}
+
+	if job == nil {
+		this.json(ctx, nil, facade.Lang(ctx, "无数据！"), 204)
+		return
+	}
+
+	this.json(ctx, gin.H{
+		"state":   job.State(),
+		"rewrite": rewrite,
+	}, facade.Lang(ctx, "数据请求成功！"), 200)
+}
+
+// stop - 停止迁移 - 进度会保留，再次开始时继续
+func (this *Storage) stop(ctx *gin.Context) {
+
+	if !this.local(ctx) {
+		return
+	}
+
+	storageMigrate.mutex.Lock()
+	defer storageMigrate.mutex.Unlock()
+
+	if storageMigrate.job == nil || !storageMigrate.job.State().Running {
+		this.json(ctx, nil, facade.Lang(ctx, "没有正在执行的迁移任务！"), 400)
+		return
+	}
+
+	storageMigrate.cancel()
+
+	this.json(ctx, storageMigrate.job.State(), facade.Lang(ctx, "已停止，当前页处理完后结束！"), 200)
+}
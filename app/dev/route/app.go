@@ -3,6 +3,7 @@ package route
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	middle "inis/app/api/middleware"
 	"inis/app/dev/controller"
 	global "inis/app/middleware"
 )
@@ -14,9 +15,15 @@ func Route(Gin *gin.Engine) {
 		global.Params(),	// 解析参数
 	)
 
+	// 运维接口 - 需要管理员登录
+	admin := Gin.Group("/dev/").Use(
+		global.Params(),	// 解析参数
+		middle.Auth(),		// 验证登录（JWT 或 API Key）
+	)
+
 	// 动态配置路由 - 允许动态挂载的路由
 	for key, item := range map[string]controller.ApiInterface{
-		"info":  &controller.Info{},
+		"info":    &controller.Info{},
 	} {
 		install.Any(key, item.INDEX)
 		install.GET(fmt.Sprintf("%s/:method", key), item.IGET)
@@ -24,4 +31,14 @@ func Route(Gin *gin.Engine) {
 		install.POST(fmt.Sprintf("%s/:method", key), item.IPOST)
 		install.DELETE(fmt.Sprintf("%s/:method", key), item.IDEL)
 	}
+
+	for key, item := range map[string]controller.ApiInterface{
+		"storage": &controller.Storage{},
+	} {
+		admin.Any(key, item.INDEX)
+		admin.GET(fmt.Sprintf("%s/:method", key), item.IGET)
+		admin.PUT(fmt.Sprintf("%s/:method", key), item.IPUT)
+		admin.POST(fmt.Sprintf("%s/:method", key), item.IPOST)
+		admin.DELETE(fmt.Sprintf("%s/:method", key), item.IDEL)
+	}
 }
@@ -0,0 +1,347 @@
+package facade
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"golang.org/x/crypto/chacha20poly1305"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AeadAesGcm - AES-256-GCM
+	AeadAesGcm   = "aes-gcm"
+	// AeadChaCha20 - XChaCha20-Poly1305（24字节 nonce，随机生成也不会碰撞）
+	AeadChaCha20 = "xchacha20-poly1305"
+	// AeadPrefix - 密文前缀，格式：enc:v1:算法:kid:base64(nonce+密文)
+	AeadPrefix   = "enc:v1:"
+)
+
+// AeadKey - 加密密钥
+type AeadKey struct {
+	// 密钥ID - 即文件名（不含 .key）
+	Kid     string
+	// 32字节密钥
+	Secret  []byte
+	// 创建时间 - 即文件修改时间
+	Created time.Time
+}
+
+// AeadStruct - 认证加密 - aead.keys 目录下的密钥，最新的（或 aead.kid 指定的）用于加密，全部用于解密
+type AeadStruct struct {
+	// 已加载的密钥 - 按创建时间从新到旧
+	keys    []*AeadKey
+	// 加载时目录的修改时间 - 目录变化（增删密钥）后自动重新加载
+	modTime time.Time
+	mutex   sync.RWMutex
+}
+
+// Aead - 认证加密实例
+/**
+ * @example：
+ * text, err := facade.Aead.Encrypt("13800138000", "users.phone")
+ * plain, err := facade.Aead.Decrypt(text, "users.phone")
+ * index := facade.Aead.BlindIndex("13800138000", "users.phone")
+ */
+var Aead = &AeadStruct{}
+
+// config - 读取 [aead] 配置 - 旧版本升级上来的配置没有该节时使用内置值
+func (this *AeadStruct) config(key string, def any) any {
+	if CryptToml == nil || !CryptToml.Viper.IsSet("aead." + key) {
+		return def
+	}
+	return CryptToml.Viper.Get("aead." + key)
+}
+
+// Algorithm - 配置的加密算法
+func (this *AeadStruct) Algorithm() string {
+
+	value := strings.ToLower(cast.ToString(this.config("algorithm", AeadAesGcm)))
+	if strings.Contains(value, "chacha20") {
+		return AeadChaCha20
+	}
+
+	return AeadAesGcm
+}
+
+// dir - 密钥目录
+func (this *AeadStruct) dir() string {
+	return cast.ToString(this.config("keys", "config/aead"))
+}
+
+// Keys - 全部密钥 - 按创建时间从新到旧
+func (this *AeadStruct) Keys() []*AeadKey {
+
+	info, err := os.Stat(this.dir())
+	if err != nil {
+		return nil
+	}
+
+	this.mutex.RLock()
+	if info.ModTime().Equal(this.modTime) {
+		defer this.mutex.RUnlock()
+		return this.keys
+	}
+	this.mutex.RUnlock()
+
+	keys := this.load()
+
+	this.mutex.Lock()
+	this.keys, this.modTime = keys, info.ModTime()
+	this.mutex.Unlock()
+
+	return keys
+}
+
+// load - 读取目录下的密钥
+func (this *AeadStruct) load() (keys []*AeadKey) {
+
+	files, err := filepath.Glob(filepath.Join(this.dir(), "*.key"))
+	if err != nil {
+		return nil
+	}
+
+	for _, file := range files {
+		key, err := this.read(file)
+		if err != nil {
+			Log.Error(map[string]any{
+				"error":     err,
+				"file":      file,
+				"func_name": utils.Caller().FuncName,
+				"file_name": utils.Caller().FileName,
+				"file_line": utils.Caller().Line,
+			}, "加密密钥读取失败")
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].Created.After(keys[j].Created)
+	})
+
+	return keys
+}
+
+// read - 读取密钥文件 - 内容为 base64 编码的32字节密钥
+func (this *AeadStruct) read(file string) (*AeadKey, error) {
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secret) != 32 {
+		return nil, errors.New("密钥长度必须为32字节")
+	}
+
+	return &AeadKey{
+		Kid:     strings.TrimSuffix(filepath.Base(file), ".key"),
+		Secret:  secret,
+		Created: info.ModTime(),
+	}, nil
+}
+
+// Find - 按 kid 查找密钥
+func (this *AeadStruct) Find(kid string) (*AeadKey, bool) {
+	for _, key := range this.Keys() {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Active - 当前用于加密的密钥 - aead.kid 指定的，或最新的；没有时自动生成
+func (this *AeadStruct) Active() (*AeadKey, error) {
+
+	if kid := cast.ToString(this.config("kid", "")); !utils.Is.Empty(kid) {
+		key, ok := this.Find(kid)
+		if !ok {
+			return nil, fmt.Errorf("加密密钥不存在：%s", kid)
+		}
+		return key, nil
+	}
+
+	if keys := this.Keys(); len(keys) > 0 {
+		return keys[0], nil
+	}
+
+	return this.Generate()
+}
+
+// Generate - 生成新密钥 - 生成后即成为最新的加密密钥
+func (this *AeadStruct) Generate() (*AeadKey, error) {
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(this.dir(), 0700); err != nil {
+		return nil, err
+	}
+
+	kid  := "k" + time.Now().Format("20060102150405")
+	file := filepath.Join(this.dir(), kid+".key")
+
+	// 同一秒内重复生成时追加序号
+	for i := 2; utils.File().Exist(file); i++ {
+		file = filepath.Join(this.dir(), fmt.Sprintf("%s-%d.key", kid, i))
+	}
+
+	if err := os.WriteFile(file, []byte(base64.StdEncoding.EncodeToString(secret)), 0600); err != nil {
+		return nil, err
+	}
+
+	return this.read(file)
+}
+
+// cipher - 按算法创建 AEAD
+func (this *AeadStruct) cipher(algorithm string, secret []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case AeadAesGcm:
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AeadChaCha20:
+		return chacha20poly1305.NewX(secret)
+	}
+	return nil, fmt.Errorf("不支持的加密算法：%s", algorithm)
+}
+
+// Encrypted - 是否为本类加密的密文
+func (this *AeadStruct) Encrypted(text any) bool {
+	return strings.HasPrefix(cast.ToString(text), AeadPrefix)
+}
+
+// Encrypt - 加密 - 每次使用随机 nonce，相同明文的密文也不同
+/**
+ * @param plain 明文
+ * @param aad 附加数据 - 绑定密文的用途（如 users.phone），解密时必须一致，防止密文被挪用到其他字段
+ * @return string 密文，格式：enc:v1:算法:kid:base64(nonce+密文)
+ */
+func (this *AeadStruct) Encrypt(plain any, aad string) (result string, err error) {
+
+	key, err := this.Active()
+	if err != nil {
+		return "", err
+	}
+
+	algorithm := this.Algorithm()
+	item, err := this.cipher(algorithm, key.Secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, item.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	body := item.Seal(nonce, nonce, []byte(cast.ToString(plain)), []byte(aad))
+
+	return fmt.Sprintf("%s%s:%s:%s", AeadPrefix, algorithm, key.Kid, base64.RawURLEncoding.EncodeToString(body)), nil
+}
+
+// Decrypt - 解密 - 密文被篡改、附加数据不一致、密钥不存在时返回错误
+/**
+ * @param text 密文
+ * @param aad 附加数据 - 与加密时一致
+ * @return string 明文
+ */
+func (this *AeadStruct) Decrypt(text any, aad string) (result string, err error) {
+
+	value := cast.ToString(text)
+	if !this.Encrypted(value) {
+		return "", errors.New("不是有效的密文")
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, AeadPrefix), ":", 3)
+	if len(parts) != 3 {
+		return "", errors.New("不是有效的密文")
+	}
+
+	key, ok := this.Find(parts[1])
+	if !ok {
+		return "", fmt.Errorf("加密密钥不存在：%s", parts[1])
+	}
+
+	item, err := this.cipher(parts[0], key.Secret)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+
+	if len(body) < item.NonceSize()+item.Overhead() {
+		return "", errors.New("不是有效的密文")
+	}
+
+	plain, err := item.Open(nil, body[:item.NonceSize()], body[item.NonceSize():], []byte(aad))
+	if err != nil {
+		return "", errors.New("密文校验失败")
+	}
+
+	return string(plain), nil
+}
+
+// indexKey - 盲索引密钥 - aead.index_key，为空时由 jwt.key 派生
+func (this *AeadStruct) indexKey() []byte {
+
+	if key := cast.ToString(this.config("index_key", "")); !utils.Is.Empty(key) {
+		return []byte(key)
+	}
+
+	item := hmac.New(sha256.New, []byte(cast.ToString(CryptToml.Get("jwt.key"))))
+	item.Write([]byte("aead-blind-index"))
+
+	return item.Sum(nil)
+}
+
+// BlindIndex - 盲索引 - 对明文做 HMAC，用于查询加密字段（只支持等值查询）
+/**
+ * @param value 明文 - 去除首尾空格并转小写后计算
+ * @param context 用途（如 users.phone） - 不同字段的相同值得到不同的索引
+ * @return string 64位十六进制，明文为空时为空
+ */
+func (this *AeadStruct) BlindIndex(value any, context string) string {
+
+	text := strings.ToLower(strings.TrimSpace(cast.ToString(value)))
+	if text == "" {
+		return ""
+	}
+
+	item := hmac.New(sha256.New, this.indexKey())
+	item.Write([]byte(context + ":" + text))
+
+	return hex.EncodeToString(item.Sum(nil))
+}
@@ -0,0 +1,238 @@
+package facade
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAead - 使用临时的密钥目录和指定的 [aead] 配置，结束后还原
+func testAead(t *testing.T, config map[string]any) string {
+
+	dir := t.TempDir()
+	if config == nil {
+		config = map[string]any{}
+	}
+	config["keys"] = dir
+
+	for key, value := range config {
+		key := key
+		old := CryptToml.Viper.Get("aead." + key)
+		CryptToml.Viper.Set("aead."+key, value)
+		t.Cleanup(func() { CryptToml.Viper.Set("aead."+key, old) })
+	}
+
+	return dir
+}
+
+// testAeadKey - 写入密钥文件 - 修改时间决定新旧
+func testAeadKey(t *testing.T, dir, kid string, created time.Time) {
+
+	file := filepath.Join(dir, kid+".key")
+	if err := os.WriteFile(file, []byte(base64.StdEncoding.EncodeToString([]byte(strings.Repeat(kid[:1], 32)))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, created, created); err != nil {
+		t.Fatal(err)
+	}
+	// 目录的修改时间变化后才会重新加载
+	if err := os.Chtimes(dir, created, created); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAeadEncrypt(t *testing.T) {
+
+	for _, algorithm := range []string{AeadAesGcm, AeadChaCha20} {
+		t.Run(algorithm, func(t *testing.T) {
+
+			testAead(t, map[string]any{"algorithm": algorithm, "kid": ""})
+
+			// 没有密钥时自动生成
+			text, err := Aead.Encrypt("13800138000", "users.phone")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !Aead.Encrypted(text) || !strings.HasPrefix(text, AeadPrefix+algorithm+":") || strings.Contains(text, "13800138000") {
+				t.Fatalf("密文格式错误：%q", text)
+			}
+			if len(Aead.Keys()) != 1 {
+				t.Fatal("应当自动生成一个密钥")
+			}
+
+			if other, _ := Aead.Encrypt("13800138000", "users.phone"); other == text {
+				t.Fatal("相同明文每次的密文应当不同")
+			}
+
+			plain, err := Aead.Decrypt(text, "users.phone")
+			if err != nil || plain != "13800138000" {
+				t.Fatalf("解密结果错误：%q %v", plain, err)
+			}
+
+			// 空字符串同样可以加密
+			if text, err = Aead.Encrypt("", "users.phone"); err != nil {
+				t.Fatal(err)
+			}
+			if plain, err = Aead.Decrypt(text, "users.phone"); err != nil || plain != "" {
+				t.Fatalf("空字符串解密结果错误：%q %v", plain, err)
+			}
+		})
+	}
+}
+
+// TestAeadTamper - 篡改密文、附加数据不一致、密钥不存在时都不能解密
+func TestAeadTamper(t *testing.T) {
+
+	testAead(t, map[string]any{"algorithm": AeadAesGcm, "kid": ""})
+
+	text, err := Aead.Encrypt("13800138000", "users.phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(text, AeadPrefix), ":", 3)
+	body, _ := base64.RawURLEncoding.DecodeString(parts[2])
+
+	// 翻转密文、nonce、认证标签中的一位
+	flip := func(index int) string {
+		value := append([]byte{}, body...)
+		value[index] ^= 0x01
+		return AeadPrefix + parts[0] + ":" + parts[1] + ":" + base64.RawURLEncoding.EncodeToString(value)
+	}
+
+	for _, item := range []struct {
+		name string
+		text string
+		aad  string
+	}{
+		{"附加数据不一致", text, "users.email"},
+		{"附加数据为空", text, ""},
+		{"篡改 nonce", flip(0), "users.phone"},
+		{"篡改密文", flip(12), "users.phone"},
+		{"篡改认证标签", flip(len(body) - 1), "users.phone"},
+		{"截断", AeadPrefix + parts[0] + ":" + parts[1] + ":" + base64.RawURLEncoding.EncodeToString(body[:len(body)-1]), "users.phone"},
+		{"太短", AeadPrefix + parts[0] + ":" + parts[1] + ":" + base64.RawURLEncoding.EncodeToString(body[:10]), "users.phone"},
+		{"换算法", AeadPrefix + AeadChaCha20 + ":" + parts[1] + ":" + parts[2], "users.phone"},
+		{"未知算法", AeadPrefix + "none:" + parts[1] + ":" + parts[2], "users.phone"},
+		{"密钥不存在", AeadPrefix + parts[0] + ":missing:" + parts[2], "users.phone"},
+		{"base64 错误", AeadPrefix + parts[0] + ":" + parts[1] + ":!!!", "users.phone"},
+		{"缺少部分", AeadPrefix + parts[0] + ":" + parts[1], "users.phone"},
+		{"没有前缀", "13800138000", "users.phone"},
+		{"空", "", "users.phone"},
+	} {
+		if plain, err := Aead.Decrypt(item.text, item.aad); err == nil {
+			t.Errorf("%s：不应当解密，得到 %q", item.name, plain)
+		}
+	}
+}
+
+// TestAeadRotate - 轮换密钥后新数据使用新密钥，旧数据仍能解密
+func TestAeadRotate(t *testing.T) {
+
+	dir := testAead(t, map[string]any{"algorithm": AeadAesGcm, "kid": ""})
+	now := time.Now()
+
+	testAeadKey(t, dir, "old", now.Add(-time.Hour))
+	old, err := Aead.Encrypt("13800138000", "users.phone")
+	if err != nil || !strings.Contains(old, ":old:") {
+		t.Fatalf("应当使用唯一的密钥：%q %v", old, err)
+	}
+
+	testAeadKey(t, dir, "new", now)
+	next, err := Aead.Encrypt("13800138000", "users.phone")
+	if err != nil || !strings.Contains(next, ":new:") {
+		t.Fatalf("应当使用最新的密钥：%q %v", next, err)
+	}
+
+	for _, text := range []string{old, next} {
+		if plain, err := Aead.Decrypt(text, "users.phone"); err != nil || plain != "13800138000" {
+			t.Fatalf("轮换后应当仍能解密：%q %v", plain, err)
+		}
+	}
+
+	// 指定密钥
+	CryptToml.Viper.Set("aead.kid", "old")
+	if text, err := Aead.Encrypt("13800138000", "users.phone"); err != nil || !strings.Contains(text, ":old:") {
+		t.Fatalf("应当使用 aead.kid 指定的密钥：%q %v", text, err)
+	}
+	CryptToml.Viper.Set("aead.kid", "missing")
+	if _, err := Aead.Encrypt("13800138000", "users.phone"); err == nil {
+		t.Fatal("指定的密钥不存在时应当返回错误")
+	}
+	CryptToml.Viper.Set("aead.kid", "")
+
+	// 长度错误的密钥文件被忽略，删除旧密钥后旧数据无法解密
+	if err = os.WriteFile(filepath.Join(dir, "bad.key"), []byte(base64.StdEncoding.EncodeToString([]byte("short"))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(filepath.Join(dir, "old.key")); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(dir, now, now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Aead.Find("bad"); ok {
+		t.Fatal("长度错误的密钥应当被忽略")
+	}
+	if _, err = Aead.Decrypt(old, "users.phone"); err == nil {
+		t.Fatal("密钥删除后不应当解密")
+	}
+}
+
+func TestAeadBlindIndex(t *testing.T) {
+
+	testAead(t, map[string]any{"index_key": "index"})
+
+	index := Aead.BlindIndex("13800138000", "users.phone")
+	if len(index) != 64 || strings.Contains(index, "13800138000") {
+		t.Fatalf("盲索引格式错误：%q", index)
+	}
+
+	for _, item := range []struct {
+		name    string
+		value   any
+		context string
+		same    bool
+	}{
+		{"相同的值", "13800138000", "users.phone", true},
+		{"首尾空格", " 13800138000 ", "users.phone", true},
+		{"数字", 13800138000, "users.phone", true},
+		{"不同的值", "13800138001", "users.phone", false},
+		{"不同的字段", "13800138000", "users.email", false},
+		{"分隔符不能拼接", "phone:13800138000", "users", false},
+	} {
+		if same := Aead.BlindIndex(item.value, item.context) == index; same != item.same {
+			t.Errorf("%s：得到 %v", item.name, same)
+		}
+	}
+
+	// 不区分大小写
+	if Aead.BlindIndex("Admin@Example.com", "users.email") != Aead.BlindIndex("admin@example.com", "users.email") {
+		t.Error("盲索引应当不区分大小写")
+	}
+
+	for _, value := range []any{"", "   ", nil} {
+		if result := Aead.BlindIndex(value, "users.phone"); result != "" {
+			t.Errorf("%q 的盲索引应当为空，得到 %q", value, result)
+		}
+	}
+
+	// 修改盲索引密钥后索引变化，未配置时由 jwt.key 派生
+	CryptToml.Viper.Set("aead.index_key", "other")
+	if Aead.BlindIndex("13800138000", "users.phone") == index {
+		t.Error("修改盲索引密钥后索引应当变化")
+	}
+
+	key := CryptToml.Viper.Get("jwt.key")
+	defer CryptToml.Viper.Set("jwt.key", key)
+	CryptToml.Viper.Set("aead.index_key", "")
+	CryptToml.Viper.Set("jwt.key", "jwt-a")
+	derived := Aead.BlindIndex("13800138000", "users.phone")
+	CryptToml.Viper.Set("jwt.key", "jwt-b")
+	if derived == index || Aead.BlindIndex("13800138000", "users.phone") == derived {
+		t.Error("未配置盲索引密钥时应当由 jwt.key 派生")
+	}
+}
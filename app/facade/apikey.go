@@ -0,0 +1,128 @@
+package facade
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"math"
+	"strings"
+	"time"
+)
+
+// ApiKeyStruct - API Key 签名（服务间调用）
+/**
+ * 请求头：
+ * i-api-key   - Key ID
+ * i-timestamp - Unix 时间戳（秒）
+ * i-nonce     - 随机字符串，同一 Key 在 apikey.window 内不能重复
+ * i-signature - hex(HMAC-SHA256(secret, 方法 + "\n" + 路径和查询参数 + "\n" + 时间戳 + "\n" + nonce + "\n" + hex(SHA256(body))))
+ *
+ * 密钥不入库：由 apikey.key 和 Key ID、随机盐派生，库中只保存密钥的哈希
+ */
+type ApiKeyStruct struct{}
+
+// ApiKey - API Key 实例
+/**
+ * @example：
+ * secret := facade.ApiKey.Secret(keyId, salt)
+ * sign   := facade.ApiKey.Sign(secret, "GET", "/api/users/one?id=1", timestamp, nonce, nil)
+ */
+var ApiKey = &ApiKeyStruct{}
+
+// master - 主密钥 - apikey.key，为空时使用 jwt.key
+func (this *ApiKeyStruct) master() []byte {
+
+	key := cast.ToString(CryptToml.Get("apikey.key"))
+	if utils.Is.Empty(key) {
+		key = cast.ToString(CryptToml.Get("jwt.key"))
+	}
+
+	return []byte(key)
+}
+
+// Window - 签名时间戳允许的误差（秒） - apikey.window，默认300
+func (this *ApiKeyStruct) Window() int64 {
+	if !CryptToml.Viper.IsSet("apikey.window") {
+		return 300
+	}
+	return cast.ToInt64(CryptToml.Viper.Get("apikey.window"))
+}
+
+// MaxBody - 签名请求体的最大字节数 - apikey.max_body（MB），默认10
+func (this *ApiKeyStruct) MaxBody() int64 {
+	if !CryptToml.Viper.IsSet("apikey.max_body") {
+		return 10 << 20
+	}
+	return cast.ToInt64(CryptToml.Viper.Get("apikey.max_body")) << 20
+}
+
+// Secret - 派生密钥
+/**
+ * @param keyId Key ID
+ * @param salt 随机盐
+ * @return string 密钥（hex）
+ */
+func (this *ApiKeyStruct) Secret(keyId, salt string) string {
+	mac := hmac.New(sha256.New, this.master())
+	mac.Write([]byte(keyId + ":" + salt))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Hash - 密钥的哈希 - 入库保存，主密钥变化后派生出的密钥与之不一致，Key 即失效
+func (this *ApiKeyStruct) Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign - 请求签名
+/**
+ * @param secret 密钥
+ * @param method 请求方法
+ * @param uri 路径和查询参数，如：/api/users/one?id=1
+ * @param timestamp 时间戳
+ * @param nonce 随机字符串
+ * @param body 请求体
+ * @return string 签名（hex）
+ */
+func (this *ApiKeyStruct) Sign(secret, method, uri, timestamp, nonce string, body []byte) string {
+
+	sum := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{strings.ToUpper(method), uri, timestamp, nonce, hex.EncodeToString(sum[:])}, "\n")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify - 校验签名 - 恒定时间比较
+func (this *ApiKeyStruct) Verify(secret, signature, method, uri, timestamp, nonce string, body []byte) bool {
+	return hmac.Equal([]byte(this.Sign(secret, method, uri, timestamp, nonce, body)), []byte(strings.ToLower(signature)))
+}
+
+// Fresh - 时间戳是否在允许的误差内
+func (this *ApiKeyStruct) Fresh(timestamp string) bool {
+	value, err := cast.ToInt64E(timestamp)
+	if err != nil || value <= 0 {
+		return false
+	}
+	return math.Abs(float64(time.Now().Unix()-value)) <= float64(this.Window())
+}
+
+// Nonce - 记录 nonce - 已使用过时返回 false
+/**
+ * @param keyId Key ID
+ * @param nonce 随机字符串
+ */
+func (this *ApiKeyStruct) Nonce(keyId, nonce string) bool {
+
+	if len(nonce) < 8 || len(nonce) > 64 {
+		return false
+	}
+
+	// 判断和写入必须是原子的，否则并发重放的请求都能通过
+	// 时间戳前后都有误差，保存两倍的时间
+	return CacheAdd(fmt.Sprintf("nonce[%s:%s]", keyId, nonce), 1, time.Duration(this.Window()*2)*time.Second)
+}
@@ -0,0 +1,149 @@
+package facade
+
+import (
+	"github.com/spf13/cast"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestApiKeySecret(t *testing.T) {
+
+	key := CryptToml.Viper.Get("apikey.key")
+	defer CryptToml.Viper.Set("apikey.key", key)
+	CryptToml.Viper.Set("apikey.key", "master")
+
+	secret := ApiKey.Secret("ak_1", "salt")
+	if secret != ApiKey.Secret("ak_1", "salt") || len(secret) != 64 {
+		t.Fatal("同样的参数应当派生出同样的密钥")
+	}
+	for _, item := range [][2]string{{"ak_2", "salt"}, {"ak_1", "salt2"}, {"ak_1:salt", ""}} {
+		if ApiKey.Secret(item[0], item[1]) == secret {
+			t.Errorf("%v 不应当派生出同样的密钥", item)
+		}
+	}
+
+	// 主密钥变化后派生出的密钥与入库的哈希不一致
+	hash := ApiKey.Hash(secret)
+	CryptToml.Viper.Set("apikey.key", "rotated")
+	if ApiKey.Hash(ApiKey.Secret("ak_1", "salt")) == hash {
+		t.Fatal("主密钥变化后 Key 应当失效")
+	}
+}
+
+func TestApiKeySign(t *testing.T) {
+
+	secret    := "secret"
+	timestamp := cast.ToString(time.Now().Unix())
+	body      := []byte(`{"id":1}`)
+	signature := ApiKey.Sign(secret, "POST", "/api/users/save?id=1", timestamp, "nonce-123", body)
+
+	for _, item := range []struct {
+		name      string
+		secret    string
+		signature string
+		method    string
+		uri       string
+		timestamp string
+		nonce     string
+		body      []byte
+		ok        bool
+	}{
+		{"正确", secret, signature, "POST", "/api/users/save?id=1", timestamp, "nonce-123", body, true},
+		{"方法不区分大小写", secret, signature, "post", "/api/users/save?id=1", timestamp, "nonce-123", body, true},
+		{"签名不区分大小写", secret, strings.ToUpper(signature), "POST", "/api/users/save?id=1", timestamp, "nonce-123", body, true},
+		{"密钥错误", "other", signature, "POST", "/api/users/save?id=1", timestamp, "nonce-123", body, false},
+		{"篡改方法", secret, signature, "PUT", "/api/users/save?id=1", timestamp, "nonce-123", body, false},
+		{"篡改路径", secret, signature, "POST", "/api/users/delete?id=1", timestamp, "nonce-123", body, false},
+		{"篡改查询参数", secret, signature, "POST", "/api/users/save?id=2", timestamp, "nonce-123", body, false},
+		{"篡改时间戳", secret, signature, "POST", "/api/users/save?id=1", timestamp + "0", "nonce-123", body, false},
+		{"篡改 nonce", secret, signature, "POST", "/api/users/save?id=1", timestamp, "nonce-124", body, false},
+		{"篡改请求体", secret, signature, "POST", "/api/users/save?id=1", timestamp, "nonce-123", []byte(`{"id":2}`), false},
+		{"去掉请求体", secret, signature, "POST", "/api/users/save?id=1", timestamp, "nonce-123", nil, false},
+		{"字段移位", secret, signature, "POST", "/api/users/save?id=1\n" + timestamp, "", "nonce-123", body, false},
+		{"空签名", secret, "", "POST", "/api/users/save?id=1", timestamp, "nonce-123", body, false},
+		{"截断的签名", secret, signature[:32], "POST", "/api/users/save?id=1", timestamp, "nonce-123", body, false},
+	} {
+		if ok := ApiKey.Verify(item.secret, item.signature, item.method, item.uri, item.timestamp, item.nonce, item.body); ok != item.ok {
+			t.Errorf("%s：得到 %v", item.name, ok)
+		}
+	}
+}
+
+func TestApiKeyFresh(t *testing.T) {
+
+	window := CryptToml.Viper.Get("apikey.window")
+	defer CryptToml.Viper.Set("apikey.window", window)
+	CryptToml.Viper.Set("apikey.window", 300)
+
+	now := time.Now().Unix()
+
+	for _, item := range []struct {
+		name      string
+		timestamp string
+		ok        bool
+	}{
+		{"当前", cast.ToString(now), true},
+		{"误差内（过去）", cast.ToString(now - 290), true},
+		{"误差内（未来）", cast.ToString(now + 290), true},
+		{"已过期", cast.ToString(now - 310), false},
+		{"未来太远", cast.ToString(now + 310), false},
+		{"毫秒", cast.ToString(now * 1000), false},
+		{"零", "0", false},
+		{"负数", "-1", false},
+		{"非数字", "now", false},
+		{"空", "", false},
+	} {
+		if ok := ApiKey.Fresh(item.timestamp); ok != item.ok {
+			t.Errorf("%s：得到 %v", item.name, ok)
+		}
+	}
+}
+
+func TestApiKeyNonce(t *testing.T) {
+
+	before := Cache
+	Cache   = BigCache
+	defer func() { Cache = before }()
+	Cache.Clear()
+
+	for _, item := range []struct {
+		name  string
+		key   string
+		nonce string
+		ok    bool
+	}{
+		{"首次使用", "ak_1", "nonce-001", true},
+		{"重复使用", "ak_1", "nonce-001", false},
+		{"其他 Key 的同一 nonce", "ak_2", "nonce-001", true},
+		{"太短", "ak_1", "1234567", false},
+		{"最短", "ak_1", "12345678", true},
+		{"最长", "ak_1", strings.Repeat("a", 64), true},
+		{"太长", "ak_1", strings.Repeat("b", 65), false},
+		{"空", "ak_1", "", false},
+	} {
+		if ok := ApiKey.Nonce(item.key, item.nonce); ok != item.ok {
+			t.Errorf("%s：得到 %v", item.name, ok)
+		}
+	}
+
+	// 并发重放只有一个能通过
+	var pass int32
+	var group sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			if ApiKey.Nonce("ak_1", "concurrent-nonce") {
+				atomic.AddInt32(&pass, 1)
+			}
+		}()
+	}
+	group.Wait()
+
+	if pass != 1 {
+		t.Fatalf("并发重放时应当只通过一次，实际 %d 次", pass)
+	}
+}
@@ -0,0 +1,182 @@
+package facade_test
+
+import (
+	"bytes"
+	"github.com/spf13/cast"
+	"inis/app/facade"
+	"inis/app/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSigned - 带签名的请求
+func testSigned(keyId, secret, method, uri string, body []byte, nonce string, timestamp int64) *http.Request {
+
+	request := httptest.NewRequest(method, uri, bytes.NewReader(body))
+	request.Header.Set("i-api-key", keyId)
+	request.Header.Set("i-timestamp", cast.ToString(timestamp))
+	request.Header.Set("i-nonce", nonce)
+	request.Header.Set("i-signature", facade.ApiKey.Sign(secret, method, request.URL.RequestURI(), cast.ToString(timestamp), nonce, body))
+
+	return request
+}
+
+// testApiKeys - 内存缓存下的 API Key 表
+func testApiKeys(t *testing.T) {
+
+	testDB(t, &model.ApiKeys{})
+
+	cache := facade.Cache
+	facade.Cache = facade.BigCache
+	facade.Cache.Clear()
+	t.Cleanup(func() {
+		facade.Cache.Clear()
+		facade.Cache = cache
+	})
+}
+
+func TestApiKeysAuth(t *testing.T) {
+
+	testApiKeys(t)
+
+	item, secret, err := model.ApiKeysCreate(1, "test", []string{"users:one"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, _ := model.ApiKeysCreate(1, "revoked", nil, 0)
+	model.ApiKeysRevoke(1, []int{revoked.Id})
+	expired, expiredSecret, _ := model.ApiKeysCreate(1, "expired", nil, time.Hour)
+	facade.DB.Drive().Model(&model.ApiKeys{}).Where("id = ?", expired.Id).UpdateColumn("expire_time", time.Now().Add(-time.Second).Unix())
+
+	now  := time.Now().Unix()
+	body := []byte(`{"name":"test"}`)
+
+	// 缺少签名头
+	missing := testSigned(item.KeyId, secret, "POST", "/api/users/save?id=1", body, "nonce-missing", now)
+	missing.Header.Del("i-signature")
+
+	// 篡改：签名后修改请求
+	tampered := testSigned(item.KeyId, secret, "POST", "/api/users/save?id=1", body, "nonce-tampered", now)
+	tampered.Body = io.NopCloser(strings.NewReader(`{"name":"admin"}`))
+	moved := testSigned(item.KeyId, secret, "POST", "/api/users/save?id=1", body, "nonce-moved", now)
+	moved.URL.RawQuery = "id=2"
+
+	for _, value := range []struct {
+		name    string
+		request *http.Request
+		err     string
+	}{
+		{"缺少签名头", missing, "签名参数不完整！"},
+		{"已过期的时间戳", testSigned(item.KeyId, secret, "GET", "/api/users/one", nil, "nonce-stale", now-3600), "签名已过期！"},
+		{"不存在的 Key", testSigned("ak_missing", secret, "GET", "/api/users/one", nil, "nonce-unknown", now), "API Key 无效或已过期！"},
+		{"已吊销", testSigned(revoked.KeyId, revokedSecret, "GET", "/api/users/one", nil, "nonce-revoked", now), "API Key 无效或已过期！"},
+		{"已过期", testSigned(expired.KeyId, expiredSecret, "GET", "/api/users/one", nil, "nonce-expired", now), "API Key 无效或已过期！"},
+		{"其他 Key 的密钥", testSigned(item.KeyId, revokedSecret, "GET", "/api/users/one", nil, "nonce-other", now), "签名错误！"},
+		{"篡改请求体", tampered, "签名错误！"},
+		{"篡改查询参数", moved, "签名错误！"},
+		{"nonce 太短", testSigned(item.KeyId, secret, "GET", "/api/users/one", nil, "short", now), "请求已被使用！"},
+	} {
+		if _, err := model.ApiKeysAuth(httptest.NewRecorder(), value.request); err == nil || err.Error() != value.err {
+			t.Errorf("%s：得到 %v，应当为 %s", value.name, err, value.err)
+		}
+	}
+
+	// 签名正确 - 请求体还原给后续的处理
+	request := testSigned(item.KeyId, secret, "POST", "/api/users/save?id=1", body, "nonce-valid", now)
+	result, err := model.ApiKeysAuth(httptest.NewRecorder(), request)
+	if err != nil || result.Id != item.Id || result.Uid != 1 {
+		t.Fatalf("签名正确时应当通过：%v", err)
+	}
+	if value, _ := io.ReadAll(request.Body); !bytes.Equal(value, body) {
+		t.Fatal("校验后应当还原请求体")
+	}
+
+	// 重放
+	replay := testSigned(item.KeyId, secret, "POST", "/api/users/save?id=1", body, "nonce-valid", now)
+	if _, err = model.ApiKeysAuth(httptest.NewRecorder(), replay); err == nil || err.Error() != "请求已被使用！" {
+		t.Fatalf("重放的请求不应当通过：%v", err)
+	}
+
+	// 签名错误的请求不占用 nonce
+	forged := testSigned(item.KeyId, "forged", "GET", "/api/users/one", nil, "nonce-later", now)
+	model.ApiKeysAuth(httptest.NewRecorder(), forged)
+	if _, err = model.ApiKeysAuth(httptest.NewRecorder(), testSigned(item.KeyId, secret, "GET", "/api/users/one", nil, "nonce-later", now)); err != nil {
+		t.Fatalf("伪造的请求不应当占用 nonce：%v", err)
+	}
+}
+
+func TestApiKeysMaxBody(t *testing.T) {
+
+	testApiKeys(t)
+
+	limit := facade.CryptToml.Viper.Get("apikey.max_body")
+	defer facade.CryptToml.Viper.Set("apikey.max_body", limit)
+	facade.CryptToml.Viper.Set("apikey.max_body", 1)
+
+	item, secret, err := model.ApiKeysCreate(1, "test", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+
+	body := bytes.Repeat([]byte("a"), 1<<20)
+	if _, err = model.ApiKeysAuth(httptest.NewRecorder(), testSigned(item.KeyId, secret, "POST", "/api/file/upload", body, "nonce-limit", now)); err != nil {
+		t.Fatalf("不超过 max_body 时应当通过：%v", err)
+	}
+
+	body = append(body, 'a')
+	if _, err = model.ApiKeysAuth(httptest.NewRecorder(), testSigned(item.KeyId, secret, "POST", "/api/file/upload", body, "nonce-large", now)); err == nil || err.Error() != "请求体过大！" {
+		t.Fatalf("超过 max_body 时应当拒绝：%v", err)
+	}
+}
+
+// TestApiKeysCache - 开启缓存时 Salt、Hash 随缓存保存，从缓存读出的 Key 仍能校验
+func TestApiKeysCache(t *testing.T) {
+
+	testApiKeys(t)
+	testCacheOpen(t)
+
+	item, secret, err := model.ApiKeysCreate(1, "test", []string{"files:*"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCodec(t, func(t *testing.T) {
+
+		facade.Cache.Clear()
+		if _, ok := model.ApiKeysFind(item.KeyId); !ok {
+			t.Fatal("应当可以查到")
+		}
+
+		// 异步写入缓存
+		name := "apikey[" + item.KeyId + "]"
+		for i := 0; i < 100 && !facade.Cache.Has(name); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if !facade.Cache.Has(name) {
+			t.Fatal("应当写入缓存")
+		}
+
+		cached, ok := model.ApiKeysFind(item.KeyId)
+		if !ok || cached.Salt != item.Salt || cached.Hash != item.Hash || cached.Uid != 1 || len(cached.Scopes) != 1 {
+			t.Fatalf("从缓存读出的 Key 不完整：%+v", cached)
+		}
+
+		request := testSigned(item.KeyId, secret, "GET", "/api/files/one", nil, "nonce-"+t.Name()[len("TestApiKeysCache/"):], time.Now().Unix())
+		if _, err := model.ApiKeysAuth(httptest.NewRecorder(), request); err != nil {
+			t.Fatalf("从缓存读出的 Key 应当可以校验：%v", err)
+		}
+	})
+
+	// 吊销后清除缓存
+	model.ApiKeysRevoke(1, []int{item.Id})
+	request := testSigned(item.KeyId, secret, "GET", "/api/files/one", nil, "nonce-revoked", time.Now().Unix())
+	if _, err = model.ApiKeysAuth(httptest.NewRecorder(), request); err == nil {
+		t.Fatal("吊销后不应当通过")
+	}
+}
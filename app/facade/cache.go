@@ -1,22 +1,39 @@
 package facade
 
 import (
+	"bytes"
+	"container/list"
 	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/allegro/bigcache/v3"
 	"github.com/fsnotify/fsnotify"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cast"
 	"github.com/unti-io/go-utils/utils"
+	"github.com/vmihailenco/msgpack/v5"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 func init() {
 
+	// gob 编码 any 字段时需要注册具体类型 - 结构体中常见的 JSON 数据
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+
 	// 初始化配置文件
 	initCacheToml()
 	// 初始化缓存
@@ -52,11 +69,11 @@ func NewCache(mode any) CacheInterface {
 	case CacheModeRedis:
 		Cache = Redis
 	case CacheModeFile:
-		Cache = FileCache
+		Cache = fileCache()
 	case CacheModeRAM:
 		Cache = BigCache
 	default:
-		Cache = FileCache
+		Cache = fileCache()
 	}
 	return Cache
 }
@@ -73,6 +90,7 @@ func initCacheToml() {
 		Content: utils.Replace(TempCache, map[string]any{
 			"${open}":           "false",
 			"${default}":        "file",
+			"${codec}":          "json",
 			"${local.expire}":   300,
 			"${redis.host}":     "localhost",
 			"${redis.port}":     "6379",
@@ -83,7 +101,14 @@ func initCacheToml() {
 			"${file.expire}"   : "2 * 60 * 60",
 			"${file.path}":      "runtime/cache",
 			"${file.prefix}":    "unti_",
+			"${file.gc_interval}": "10 * 60",
+			"${file.max_size}":  1024,
+			"${file.max_files}": 0,
 			"${ram.expire}":     "2 * 60 * 60",
+			"${ram.size}":       256,
+			"${ram.max_item}":   0,
+			"${ram.eviction}":   "lru",
+			"${ram.shards}":     256,
 		}),
 	}).Read()
 
@@ -103,6 +128,9 @@ func initCacheToml() {
 // 初始化缓存
 func initCache() {
 
+	// 序列化方式
+	CacheCodec = NewCacheCodec(CacheToml.Get("codec", CacheCodecJSON))
+
 	host := cast.ToString(CacheToml.Get("redis.host"))
 	port := cast.ToString(CacheToml.Get("redis.port"))
 
@@ -122,32 +150,68 @@ func initCache() {
 	}
 
 	// 文件缓存
-	FileClient, _ := utils.NewFileCache(
-		CacheToml.Get("file.path"),
-		utils.Calc(CacheToml.Get("file.expire", 7200)),
-		CacheToml.Get("file.prefix"),
-	)
+	FileClient, err := NewFileCache(FileCacheConfig{
+		Dir:      cast.ToString(CacheToml.Get("file.path", "runtime/cache")),
+		Expire:   utils.Calc(CacheToml.Get("file.expire", 7200)),
+		Prefix:   cast.ToString(CacheToml.Get("file.prefix")),
+		Interval: cast.ToInt(utils.Calc(CacheToml.Get("file.gc_interval", 600))),
+		MaxSize:  cast.ToInt(CacheToml.Get("file.max_size", 1024)),
+		MaxFiles: cast.ToInt(CacheToml.Get("file.max_files", 0)),
+	})
+
+	if err != nil {
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "文件缓存初始化错误")
+	}
+
+	// 创建成功时才替换并释放旧的实例 - 失败时继续使用旧的实例，首次初始化失败时使用内存缓存
+	if err == nil {
+		if FileCache != nil && FileCache.Client != nil {
+			FileCache.Client.Close()
+		}
+		FileCache = &FileCacheStruct{
+			Client: FileClient,
+		}
+	}
 
-	// File 缓存
-	FileCache = &FileCacheStruct{
-		Client: FileClient,
+	// 释放旧的内存缓存实例
+	if BigCache != nil && BigCache.Client != nil {
+		BigCache.Client.Close()
 	}
 
 	// BigCache 缓存
 	BigCache = &BigCacheStruct{
-		Client: NewBigCache(utils.Calc(CacheToml.Get("file.expire", 7200))),
+		Client: NewBigCache(BigCacheConfig{
+			Expire:   utils.Calc(CacheToml.Get("ram.expire", 7200)),
+			Size:     cast.ToInt(CacheToml.Get("ram.size", 256)),
+			MaxItem:  cast.ToInt(CacheToml.Get("ram.max_item", 0)),
+			Eviction: cast.ToString(CacheToml.Get("ram.eviction", RAMEvictionLRU)),
+			Shards:   cast.ToInt(CacheToml.Get("ram.shards", 256)),
+		}),
 	}
 
 	switch cast.ToString(CacheToml.Get("default")) {
 	case CacheModeRedis:
 		Cache = Redis
 	case CacheModeFile:
-		Cache = FileCache
+		Cache = fileCache()
 	case CacheModeRAM:
 		Cache = BigCache
 	default:
-		Cache = FileCache
+		Cache = fileCache()
+	}
+}
+
+// fileCache - 文件缓存 - 文件缓存不可用（目录无法创建等）时使用内存缓存
+func fileCache() CacheInterface {
+	if FileCache == nil || FileCache.Client == nil {
+		return BigCache
 	}
+	return FileCache
 }
 
 // Cache - Cache实例
@@ -214,6 +278,264 @@ type CacheInterface interface {
 	Clear() (ok bool)
 }
 
+// CacheBytesInterface - 可选的扩展接口 - 内置的驱动都实现了，CacheGet、CacheSet、CacheAdd 会先断言
+/**
+ * 自定义的驱动可以只实现 CacheInterface，这时退回到 Get、Set（Add 不是原子操作）
+ */
+type CacheBytesInterface interface {
+	// GetBytes
+	/**
+	 * @name 获取缓存的原始字节 - 不做解码
+	 * @param key 缓存的key
+	 * @return []byte 不存在时为 nil
+	 */
+	GetBytes(key any) (value []byte)
+	// SetBytes
+	/**
+	 * @name 设置缓存的原始字节 - 不做编码
+	 * @param key 缓存的key
+	 * @param value 已编码的值
+	 * @param expire （可选）过期时间
+	 * @return bool
+	 */
+	SetBytes(key any, value []byte, expire ...any) (ok bool)
+	// Add
+	/**
+	 * @name 缓存不存在时才设置 - 原子操作，用于一次性标记和计数加锁
+	 * @param key 缓存的key
+	 * @param value 缓存的值
+	 * @param expire （可选）过期时间
+	 * @return bool 是否设置成功 - 已存在时为 false
+	 */
+	Add(key any, value any, expire ...any) (ok bool)
+}
+
+
+// ============================ 序列化 ============================
+
+
+const (
+	// CacheCodecJSON    - JSON（默认，兼容旧数据）
+	CacheCodecJSON    = "json"
+	// CacheCodecMsgpack - MessagePack，体积更小，适合 Redis
+	CacheCodecMsgpack = "msgpack"
+	// CacheCodecGob     - Gob，仅适用于 CacheGet / CacheSet
+	CacheCodecGob     = "gob"
+)
+
+// 非 JSON 编码的值以 0x00 + 编码编号开头，JSON 文本不会以 0x00 开头，因此旧数据依然可读
+const cacheCodecMark byte = 0x00
+
+var cacheCodecIds = map[string]byte{
+	CacheCodecMsgpack: 1,
+	CacheCodecGob:     2,
+}
+
+// CacheCodec - 当前序列化方式
+var CacheCodec CacheCodecInterface = &CacheCodecJSONStruct{}
+
+type CacheCodecInterface interface {
+	// Name - 编码名称
+	Name() string
+	// Encode - 编码
+	Encode(value any) (data []byte, err error)
+	// Decode - 解码到 dest（指针）
+	Decode(data []byte, dest any) (err error)
+}
+
+// NewCacheCodec - 创建序列化实例
+/**
+ * @param mode 编码方式
+ * @return CacheCodecInterface
+ * @example：
+ * codec := facade.NewCacheCodec(facade.CacheCodecMsgpack)
+ */
+func NewCacheCodec(mode any) CacheCodecInterface {
+	switch strings.ToLower(cast.ToString(mode)) {
+	case CacheCodecMsgpack:
+		return &CacheCodecMsgpackStruct{}
+	case CacheCodecGob:
+		return &CacheCodecGobStruct{}
+	default:
+		return &CacheCodecJSONStruct{}
+	}
+}
+
+// CacheCodecJSONStruct - JSON 编码
+type CacheCodecJSONStruct struct{}
+
+func (this *CacheCodecJSONStruct) Name() string {
+	return CacheCodecJSON
+}
+
+func (this *CacheCodecJSONStruct) Encode(value any) (data []byte, err error) {
+	return json.Marshal(value)
+}
+
+func (this *CacheCodecJSONStruct) Decode(data []byte, dest any) (err error) {
+	return json.Unmarshal(data, dest)
+}
+
+// CacheCodecMsgpackStruct - MessagePack 编码，沿用结构体的 json 标签
+type CacheCodecMsgpackStruct struct{}
+
+func (this *CacheCodecMsgpackStruct) Name() string {
+	return CacheCodecMsgpack
+}
+
+func (this *CacheCodecMsgpackStruct) Encode(value any) (data []byte, err error) {
+	var buffer bytes.Buffer
+	encoder := msgpack.NewEncoder(&buffer)
+	encoder.SetCustomStructTag("json")
+	if err = encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (this *CacheCodecMsgpackStruct) Decode(data []byte, dest any) (err error) {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(dest)
+}
+
+// CacheCodecGobStruct - Gob 编码
+type CacheCodecGobStruct struct{}
+
+func (this *CacheCodecGobStruct) Name() string {
+	return CacheCodecGob
+}
+
+func (this *CacheCodecGobStruct) Encode(value any) (data []byte, err error) {
+	var buffer bytes.Buffer
+	if err = gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (this *CacheCodecGobStruct) Decode(data []byte, dest any) (err error) {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(dest)
+}
+
+// cacheEncode - 按当前序列化方式编码
+/**
+ * gob 无法把值解码回 any，所以非泛型的 Set 在 gob 模式下仍使用 JSON
+ */
+func cacheEncode(value any, typed bool) (data []byte, err error) {
+
+	codec := CacheCodec
+	if !typed && codec.Name() == CacheCodecGob {
+		codec = &CacheCodecJSONStruct{}
+	}
+
+	id, ok := cacheCodecIds[codec.Name()]
+	if !ok {
+		return codec.Encode(value)
+	}
+
+	body, err := codec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{cacheCodecMark, id}, body...), nil
+}
+
+// cacheDecode - 根据数据头自动选择解码方式
+func cacheDecode(data []byte, dest any) (err error) {
+
+	if len(data) >= 2 && data[0] == cacheCodecMark {
+		for name, id := range cacheCodecIds {
+			if id == data[1] {
+				return NewCacheCodec(name).Decode(data[2:], dest)
+			}
+		}
+		return fmt.Errorf("unknown cache codec: %d", data[1])
+	}
+
+	return json.Unmarshal(data, dest)
+}
+
+// cacheValue - 解码为 any - 供非泛型的 Get 使用
+func cacheValue(data []byte) (value any) {
+	if data == nil {
+		return nil
+	}
+	if err := cacheDecode(data, &value); err != nil {
+		return nil
+	}
+	return value
+}
+
+// CacheGet - 按类型获取缓存，结构体和整数类型原样返回
+/**
+ * @param key 缓存的key
+ * @return T, bool 缓存不存在或类型不匹配时 ok 为 false
+ * @example：
+ * user, ok := facade.CacheGet[model.Users]("user[1]")
+ */
+func CacheGet[T any](key any) (value T, ok bool) {
+
+	var data []byte
+	if item, yes := Cache.(CacheBytesInterface); yes {
+		data = item.GetBytes(key)
+	} else if result := Cache.Get(key); result != nil {
+		data, _ = json.Marshal(result)
+	}
+
+	if data == nil {
+		return value, false
+	}
+
+	if err := cacheDecode(data, &value); err != nil {
+		return value, false
+	}
+
+	return value, true
+}
+
+// CacheSet - 按类型设置缓存
+/**
+ * @param key 缓存的key
+ * @param value 缓存的值
+ * @param expire （可选）过期时间
+ * @return bool
+ * @example：
+ * facade.CacheSet[model.Users]("user[1]", user, 5 * time.Minute)
+ */
+func CacheSet[T any](key any, value T, expire ...any) (ok bool) {
+
+	item, yes := Cache.(CacheBytesInterface)
+	if !yes {
+		return Cache.Set(key, value, expire...)
+	}
+
+	data, err := cacheEncode(value, true)
+	if err != nil {
+		return false
+	}
+
+	return item.SetBytes(key, data, expire...)
+}
+
+// CacheAdd - 缓存不存在时才设置 - 驱动没有实现 CacheBytesInterface 时不是原子操作
+/**
+ * @param key 缓存的key
+ * @param value 缓存的值
+ * @param expire （可选）过期时间
+ * @return bool 是否设置成功 - 已存在时为 false
+ * @example：
+ * ok := facade.CacheAdd("nonce[xxx]", 1, 5 * time.Minute)
+ */
+func CacheAdd(key any, value any, expire ...any) (ok bool) {
+
+	if item, yes := Cache.(CacheBytesInterface); yes {
+		return item.Add(key, value, expire...)
+	}
+
+	return !Cache.Has(key) && Cache.Set(key, value, expire...)
+}
 
 // ==================== Redis 缓存 ====================
 
@@ -233,16 +555,30 @@ func (this *RedisCacheStruct) Has(key any) (ok bool) {
 }
 
 func (this *RedisCacheStruct) Get(key any) (value any) {
+	return cacheValue(this.GetBytes(key))
+}
+
+func (this *RedisCacheStruct) GetBytes(key any) (value []byte) {
 
 	ctx := context.Background()
 
-	result, err := this.Client.Get(ctx, this.Prefix+cast.ToString(key)).Result()
+	result, err := this.Client.Get(ctx, this.Prefix+cast.ToString(key)).Bytes()
 
-	return utils.Ternary[any](err != nil, nil, utils.Json.Decode(result))
+	return utils.Ternary[[]byte](err != nil, nil, result)
 }
 
 func (this *RedisCacheStruct) Set(key any, value any, expire ...any) (ok bool) {
 
+	data, err := cacheEncode(value, false)
+	if err != nil {
+		return false
+	}
+
+	return this.SetBytes(key, data, expire...)
+}
+
+func (this *RedisCacheStruct) SetBytes(key any, value []byte, expire ...any) (ok bool) {
+
 	ctx := context.Background()
 	// 设置过期时间
 	if len(expire) == 0 {
@@ -254,10 +590,32 @@ func (this *RedisCacheStruct) Set(key any, value any, expire ...any) (ok bool) {
 		expire[0] = time.Duration(cast.ToInt(expire[0])) * time.Second
 	}
 
-	err := this.Client.Set(ctx, this.Prefix+cast.ToString(key), utils.Json.Encode(value), cast.ToDuration(expire[0])).Err()
+	err := this.Client.Set(ctx, this.Prefix+cast.ToString(key), value, cast.ToDuration(expire[0])).Err()
 	return utils.Ternary[bool](err != nil, false, true)
 }
 
+func (this *RedisCacheStruct) Add(key any, value any, expire ...any) (ok bool) {
+
+	data, err := cacheEncode(value, false)
+	if err != nil {
+		return false
+	}
+
+	ctx := context.Background()
+	// 设置过期时间
+	if len(expire) == 0 {
+		expire = append(expire, this.Expire)
+	}
+
+	// 如果 exp不为时间类型，则转码为时间类型
+	if reflect.ValueOf(expire[0]).Kind() != reflect.Int64 && expire[0] != 0 {
+		expire[0] = time.Duration(cast.ToInt(expire[0])) * time.Second
+	}
+
+	result, err := this.Client.SetNX(ctx, this.Prefix+cast.ToString(key), data, cast.ToDuration(expire[0])).Result()
+	return utils.Ternary[bool](err != nil, false, result)
+}
+
 func (this *RedisCacheStruct) Del(key any) (ok bool) {
 
 	ctx := context.Background()
@@ -370,7 +728,7 @@ func (this *RedisCacheStruct) Clear() (ok bool) {
 
 
 type FileCacheStruct struct {
-	Client *utils.FileCacheClient
+	Client *FileCacheClient
 }
 
 func (this *FileCacheStruct) Has(key any) (ok bool) {
@@ -378,11 +736,25 @@ func (this *FileCacheStruct) Has(key any) (ok bool) {
 }
 
 func (this *FileCacheStruct) Get(key any) (value any) {
-	return utils.Json.Decode(this.Client.Get(key))
+	return cacheValue(this.Client.Get(key))
+}
+
+func (this *FileCacheStruct) GetBytes(key any) (value []byte) {
+	return this.Client.Get(key)
 }
 
 func (this *FileCacheStruct) Set(key any, value any, expire ...any) (ok bool) {
-	return this.Client.Set(key, []byte(utils.Json.Encode(value)), expire...)
+	data, err := cacheEncode(value, false)
+	return utils.Ternary(err != nil, false, this.Client.Set(key, data, expire...))
+}
+
+func (this *FileCacheStruct) SetBytes(key any, value []byte, expire ...any) (ok bool) {
+	return this.Client.Set(key, value, expire...)
+}
+
+func (this *FileCacheStruct) Add(key any, value any, expire ...any) (ok bool) {
+	data, err := cacheEncode(value, false)
+	return utils.Ternary(err != nil, false, this.Client.Add(key, data, expire...))
 }
 
 func (this *FileCacheStruct) Del(key any) (ok bool) {
@@ -402,78 +774,107 @@ func (this *FileCacheStruct) Clear() (ok bool) {
 }
 
 
-// ============================ 内存缓存 ============================
-
-
-type BigCacheStruct struct {
-	Client *BigCacheClient
+// FileCacheConfig 文件缓存配置
+type FileCacheConfig struct {
+	// 缓存目录
+	Dir      string
+	// 默认缓存过期时间(秒) - 0为永不过期
+	Expire   any
+	// 缓存文件名前缀
+	Prefix   string
+	// 过期清理间隔(秒) - 0为不自动清理
+	Interval int
+	// 缓存目录最大占用(MB) - 0为不限制
+	MaxSize  int
+	// 缓存文件最大数量 - 0为不限制
+	MaxFiles int
 }
 
-func (this *BigCacheStruct) Has(key any) (ok bool) {
-	return this.Client.Has(key)
+// FileCacheClient 文件缓存 - 过期时间写入文件头，重启后依然可以清理
+type FileCacheClient struct {
+	dir      string        // 缓存目录
+	prefix   string        // 缓存文件名前缀
+	expire   int64         // 默认缓存过期时间
+	maxSize  int64         // 缓存目录最大占用字节
+	maxFiles int           // 缓存文件最大数量
+	mutex    sync.Mutex    // 互斥锁 - 保证清理任务不并发执行
+	adding   sync.Mutex    // 互斥锁 - 保证 Add 的判断和写入不被打断
+	done     chan struct{} // 关闭信号 - 停止定时清理
+	once     sync.Once
 }
 
-func (this *BigCacheStruct) Get(key any) (value any) {
-	return utils.Json.Decode(this.Client.Get(key))
+// FileCacheGC 清理结果
+type FileCacheGC struct {
+	// 扫描文件数
+	Files   int   `json:"files"`
+	// 扫描后占用字节
+	Size    int64 `json:"size"`
+	// 删除的过期文件数
+	Expired int   `json:"expired"`
+	// 超出容量被淘汰的文件数
+	Evicted int   `json:"evicted"`
+	// 删除的旧版本缓存文件数
+	Legacy  int   `json:"legacy"`
 }
 
-func (this *BigCacheStruct) Set(key any, value any, expire ...any) (ok bool) {
-	return this.Client.Set(key, []byte(utils.Json.Encode(value)), expire...)
-}
+// fileCacheTemp 临时文件后缀
+const fileCacheTemp = ".tmp"
 
-func (this *BigCacheStruct) Del(key any) (ok bool) {
-	return this.Client.Del(key)
-}
+// fileCacheMagic 缓存文件头标识 - 区分旧版本的缓存文件
+/**
+ * 旧版本（go-utils 的 FileCacheClient）只写入缓存内容，过期时间和 key 只保存在内存中，
+ * 重启后就无法再读取，这类文件没有文件头，清理时直接删除
+ */
+const fileCacheMagic = "UFC1"
 
-func (this *BigCacheStruct) DelPrefix(prefix ...any) (ok bool) {
-	return this.Client.DelPrefix(prefix...)
-}
+// fileCacheHead 文件头长度：标识 + 8字节过期时间(毫秒) + 2字节key长度
+const fileCacheHead = len(fileCacheMagic) + 10
 
-func (this *BigCacheStruct) DelTags(tag ...any) (ok bool) {
-	return this.Client.DelTags(tag...)
-}
+// errFileCacheLegacy 旧版本的缓存文件
+var errFileCacheLegacy = errors.New("legacy cache file")
 
-func (this *BigCacheStruct) Clear() (ok bool) {
-	return this.Client.Clear()
-}
+// NewFileCache 创建一个新的文件缓存实例
+func NewFileCache(config FileCacheConfig) (*FileCacheClient, error) {
 
-// BigCacheClient 缓存
-type BigCacheClient struct {
-	mutex      sync.Mutex   	// 互斥锁，用于保证并发安全
-	prefix	   string			// 缓存文件名前缀
-	expire	   int64			// 默认缓存过期时间
-	items 	   map[string]*bigcache.BigCache
-}
+	if utils.Is.Empty(config.Dir) {
+		config.Dir = "runtime/cache"
+	}
 
-// NewBigCache 创建一个新的缓存实例
-func NewBigCache(expire any, prefix ...string) *BigCacheClient {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create cache dir error: %v", err)
+	}
 
-	var cache BigCacheClient
+	client := &FileCacheClient{
+		dir:      config.Dir,
+		prefix:   utils.Ternary(utils.Is.Empty(config.Prefix), "cache_", config.Prefix),
+		expire:   cast.ToInt64(config.Expire),
+		maxSize:  int64(config.MaxSize) * 1024 * 1024,
+		maxFiles: config.MaxFiles,
+		done:     make(chan struct{}),
+	}
 
-	cache.expire = cast.ToInt64(expire)
-	cache.items  = make(map[string]*bigcache.BigCache)
-	cache.prefix = "cache_"
-	if len(prefix) > 0 {
-		cache.prefix = prefix[0]
+	// 定时清理过期和超量的缓存文件
+	if config.Interval > 0 {
+		go client.timer(time.Duration(config.Interval) * time.Second)
 	}
 
-	return &cache
+	return client, nil
 }
 
 // Get 获取缓存
-func (this *BigCacheClient) Get(key any) (result []byte) {
+func (this *FileCacheClient) Get(key any) (result []byte) {
 	res, err := this.GetE(key)
 	return utils.Ternary(err != nil, nil, res)
 }
 
 // Has 判断缓存是否存在
-func (this *BigCacheClient) Has(key any) (ok bool) {
-	_, ok = this.items[this.name(key)]
-	return
+func (this *FileCacheClient) Has(key any) (ok bool) {
+	_, _, err := this.header(this.name(key))
+	return err == nil
 }
 
 // Set 设置缓存
-func (this *BigCacheClient) Set(key any, value []byte, expire ...any) (ok bool) {
+func (this *FileCacheClient) Set(key any, value []byte, expire ...any) (ok bool) {
 
 	exp := this.expire
 
@@ -494,112 +895,366 @@ func (this *BigCacheClient) Set(key any, value []byte, expire ...any) (ok bool)
 	return utils.Ternary(err != nil, false, true)
 }
 
-// Del 删除缓存
-func (this *BigCacheClient) Del(key any) (ok bool) {
-
-	err := this.DelE(key)
+// Add 缓存不存在时才设置 - 只保证本进程内原子，多个实例共享缓存时请使用 Redis
+func (this *FileCacheClient) Add(key any, value []byte, expire ...any) (ok bool) {
 
-	return utils.Ternary(err != nil, false, true)
-}
+	this.adding.Lock()
+	defer this.adding.Unlock()
 
-// Clear 清空缓存
-func (this *BigCacheClient) Clear() (ok bool) {
+	if this.Has(key) {
+		return false
+	}
 
-	err := this.ClearE()
+	return this.Set(key, value, expire...)
+}
 
+// Del 删除缓存
+func (this *FileCacheClient) Del(key any) (ok bool) {
+	err := this.DelE(key)
 	return utils.Ternary(err != nil, false, true)
 }
 
 // DelPrefix 根据前缀删除缓存
-func (this *BigCacheClient) DelPrefix(prefix ...any) (ok bool) {
+func (this *FileCacheClient) DelPrefix(prefix ...any) (ok bool) {
 	err := this.DelPrefixE(prefix...)
 	return utils.Ternary(err != nil, false, true)
 }
 
 // DelTags 根据标签删除缓存
-func (this *BigCacheClient) DelTags(tags ...any) (ok bool) {
+func (this *FileCacheClient) DelTags(tags ...any) (ok bool) {
 	err := this.DelTagsE(tags...)
 	return utils.Ternary(err != nil, false, true)
 }
 
+// Clear 清空缓存
+func (this *FileCacheClient) Clear() (ok bool) {
+	err := this.ClearE()
+	return utils.Ternary(err != nil, false, true)
+}
+
+// Close 停止定时清理 - 配置重载时释放旧实例
+func (this *FileCacheClient) Close() {
+	this.once.Do(func() {
+		close(this.done)
+	})
+}
+
 // GetE 获取缓存
-func (this *BigCacheClient) GetE(key any) (result []byte, err error) {
+func (this *FileCacheClient) GetE(key any) (result []byte, err error) {
 
-	this.mutex.Lock()
-	defer this.mutex.Unlock()
+	path := this.name(key)
 
-	item, ok := this.items[this.name(key)]
-	if !ok {
-		delete(this.items, this.name(key))
-		return nil, fmt.Errorf("cache %s not exists", this.name(key))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
 
-	value, err := item.Get(this.name(key))
+	end, name, body, err := this.parse(data)
 	if err != nil {
 		return nil, err
 	}
 
-	return value, nil
+	// 不同的 key 过滤非法字符后可能得到同一个文件名
+	if name != this.key(key) {
+		return nil, fmt.Errorf("cache %s not found", cast.ToString(key))
+	}
+
+	if end > 0 && time.Now().UnixMilli() > end {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("cache %s expired", cast.ToString(key))
+	}
+
+	return body, nil
 }
 
-// SetE 设置缓存
-func (this *BigCacheClient) SetE(key any, value []byte, expire int64) (err error) {
+// SetE 设置缓存 - 先写临时文件再重命名，避免读到写了一半的文件
+func (this *FileCacheClient) SetE(key any, value []byte, expire int64) (err error) {
 
-	// end 过期时间，expire = 0 表示永不过期
-	var end time.Duration
-	if expire == 0 {
-		// 100年后过期
-		end = time.Duration(100 * 365 * 24 * 60 * 60 * 1e9)
-	} else {
-		end = time.Duration(expire) * time.Second
+	if err = os.MkdirAll(this.dir, 0755); err != nil {
+		return fmt.Errorf("create cache dir error: %v", err)
 	}
 
-	item, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(end))
+	// end 过期时间，expire = 0 表示永不过期
+	var end int64
+	if expire > 0 {
+		end = time.Now().Add(time.Duration(expire) * time.Second).UnixMilli()
+	}
 
-	err = item.Set(this.name(key), value)
+	path := this.name(key)
 
+	file, err := os.CreateTemp(this.dir, filepath.Base(path)+".*"+fileCacheTemp)
 	if err != nil {
-		return err
+		return fmt.Errorf("create cache file %s error: %v", path, err)
 	}
 
-	this.mutex.Lock()
-	defer this.mutex.Unlock()
+	_, err = file.Write(this.encode(this.key(key), end, value))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("write to cache file %s error: %v", path, err)
+	}
 
-	this.items[this.name(key)] = item
+	if err = os.Rename(file.Name(), path); err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("rename cache file %s error: %v", path, err)
+	}
 
 	return nil
 }
 
 // DelE 删除缓存
-func (this *BigCacheClient) DelE(key any) (err error) {
+func (this *FileCacheClient) DelE(key any) (err error) {
 
-	this.mutex.Lock()
-	defer this.mutex.Unlock()
+	err = os.Remove(this.name(key))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete cache file %s error: %v", this.name(key), err)
+	}
 
-	item, ok := this.items[this.name(key)]
-	if !ok {
-		return fmt.Errorf("cache %s not exists", this.name(key))
+	return nil
+}
+
+// ClearE 清空缓存 - 只删除本实例前缀的文件
+func (this *FileCacheClient) ClearE() (err error) {
+	return this.walk(func(path string, key string, info os.FileInfo) error {
+		return this.remove(path)
+	})
+}
+
+// DelPrefixE 删除指定前缀的缓存
+func (this *FileCacheClient) DelPrefixE(prefix ...any) (err error) {
+
+	var prefixes []string
+
+	if len(prefix) == 0 {
+		return nil
 	}
 
-	err = item.Delete(this.name(key))
-	if err != nil {
-		return err
+	for _, value := range prefix {
+		// 判断是否为切片
+		if reflect.ValueOf(value).Kind() == reflect.Slice {
+			for _, val := range cast.ToSlice(value) {
+				prefixes = append(prefixes, cast.ToString(val))
+			}
+		} else {
+			prefixes = append(prefixes, cast.ToString(value))
+		}
 	}
 
-	delete(this.items, this.name(key))
+	return this.walk(func(path string, key string, info os.FileInfo) error {
+		for _, value := range prefixes {
+			if strings.HasPrefix(key, value) {
+				return this.remove(path)
+			}
+		}
+		return nil
+	})
+}
+
+// DelTagsE 删除指定标签的缓存
+func (this *FileCacheClient) DelTagsE(tag ...any) (err error) {
 
-	return nil
+	var tags []string
+
+	if len(tag) == 0 {
+		return nil
+	}
+
+	for _, value := range tag {
+
+		var item string
+
+		// 判断是否为切片
+		if reflect.ValueOf(value).Kind() == reflect.Slice {
+			var tmp []string
+			for _, val := range cast.ToSlice(value) {
+				tmp = append(tmp, cast.ToString(val))
+			}
+			item = strings.Join(tmp, "*")
+		} else {
+			item = cast.ToString(value)
+		}
+
+		tags = append(tags, fmt.Sprintf("*%s*", item))
+	}
+
+	return this.walk(func(path string, key string, info os.FileInfo) error {
+		for _, value := range tags {
+			if matched, _ := filepath.Match(value, key); matched {
+				return this.remove(path)
+			}
+		}
+		return nil
+	})
 }
 
-// ClearE 清空缓存
-func (this *BigCacheClient) ClearE() (err error) {
+// GC 清理过期文件和残留的临时文件，超出容量时从最旧的文件开始淘汰
+func (this *FileCacheClient) GC() (result FileCacheGC, err error) {
 
 	this.mutex.Lock()
 	defer this.mutex.Unlock()
 
-	for _, item := range this.items {
-		err = item.Reset()
+	type item struct {
+		path string
+		size int64
+		time time.Time
+	}
+
+	var items []item
+	now := time.Now()
+
+	entries, err := os.ReadDir(this.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, err
+	}
+
+	for _, entry := range entries {
+
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, this.prefix) {
+			continue
+		}
+
+		info, err := entry.Info()
 		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(this.dir, name)
+
+		// 写入中断残留的临时文件 - 超过一小时视为垃圾
+		if strings.HasSuffix(name, fileCacheTemp) {
+			if now.Sub(info.ModTime()) > time.Hour && this.remove(path) == nil {
+				result.Expired++
+			}
+			continue
+		}
+
+		if _, _, err := this.header(path); err != nil {
+			// 旧版本的缓存文件 - 已无法读取
+			if errors.Is(err, errFileCacheLegacy) {
+				if this.remove(path) == nil {
+					result.Legacy++
+				}
+				continue
+			}
+			// 已过期或无法解析的文件
+			if _, ok := err.(*fileCacheExpired); ok || !os.IsNotExist(err) {
+				if this.remove(path) == nil {
+					result.Expired++
+				}
+			}
+			continue
+		}
+
+		items = append(items, item{path: path, size: info.Size(), time: info.ModTime()})
+		result.Size += info.Size()
+	}
+
+	result.Files = len(items)
+
+	over := func() bool {
+		if this.maxSize > 0 && result.Size > this.maxSize {
+			return true
+		}
+		return this.maxFiles > 0 && result.Files > this.maxFiles
+	}
+
+	if !over() {
+		return result, nil
+	}
+
+	// 最旧的文件优先淘汰
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].time.Before(items[j].time)
+	})
+
+	for _, value := range items {
+		if !over() {
+			break
+		}
+		if this.remove(value.path) != nil {
+			continue
+		}
+		result.Files--
+		result.Size -= value.size
+		result.Evicted++
+	}
+
+	return result, nil
+}
+
+// timer 定时器 - 每隔一段时间执行一次清理
+func (this *FileCacheClient) timer(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this.done:
+			return
+		case <-ticker.C:
+			result, err := this.GC()
+			if err != nil {
+				Log.Error(map[string]any{
+					"error":     err,
+					"func_name": utils.Caller().FuncName,
+					"file_name": utils.Caller().FileName,
+					"file_line": utils.Caller().Line,
+				}, "文件缓存清理错误")
+			}
+			if result.Legacy > 0 {
+				Log.Info(map[string]any{
+					"dir":    this.dir,
+					"legacy": result.Legacy,
+				}, "已删除旧版本的缓存文件")
+			}
+		}
+	}
+}
+
+// walk 遍历本实例的缓存文件
+func (this *FileCacheClient) walk(fn func(path string, key string, info os.FileInfo) error) (err error) {
+
+	entries, err := os.ReadDir(this.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, this.prefix) || strings.HasSuffix(name, fileCacheTemp) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(this.dir, name)
+
+		// 缓存文件头里记录了原始的 key
+		key := strings.TrimPrefix(name, this.prefix)
+		if data, err := this.head(path); err == nil {
+			if _, item, _, err := this.parse(data); err == nil {
+				key = item
+			}
+		}
+
+		if err := fn(path, key, info); err != nil {
 			return err
 		}
 	}
@@ -607,29 +1262,559 @@ func (this *BigCacheClient) ClearE() (err error) {
 	return nil
 }
 
+// key 写入文件头的 key - 超出长度时截断
+func (this *FileCacheClient) key(key any) string {
+	name := cast.ToString(key)
+	if len(name) > math.MaxUint16 {
+		name = name[:math.MaxUint16]
+	}
+	return name
+}
+
+// name 返回缓存文件名
+func (this *FileCacheClient) name(key any) (result string) {
+
+	// 过滤掉 windows、linux和mac下的非法字符
+	name := regexp.MustCompile(`[\\/:*?"<>|]`).ReplaceAllString(fmt.Sprintf("%s%s", this.prefix, cast.ToString(key)), "")
+
+	return filepath.Join(this.dir, name)
+}
+
+// remove 删除文件 - 文件不存在不算错误
+func (this *FileCacheClient) remove(path string) (err error) {
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete cache file %s error: %v", path, err)
+	}
+	return nil
+}
+
+// fileCacheExpired 缓存已过期
+type fileCacheExpired struct{ path string }
+
+func (this *fileCacheExpired) Error() string {
+	return fmt.Sprintf("cache file %s expired", this.path)
+}
+
+// header 读取文件头 - 返回过期时间和原始 key
+func (this *FileCacheClient) header(path string) (end int64, key string, err error) {
+
+	data, err := this.head(path)
+	if err != nil {
+		return 0, "", err
+	}
+
+	end, key, _, err = this.parse(data)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if end > 0 && time.Now().UnixMilli() > end {
+		return end, key, &fileCacheExpired{path: path}
+	}
+
+	return end, key, nil
+}
+
+// head 读取文件头部字节
+func (this *FileCacheClient) head(path string) (data []byte, err error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	data = make([]byte, fileCacheHead+math.MaxUint16)
+	size, err := io.ReadFull(file, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return data[:size], nil
+}
+
+// encode 文件格式：标识 + 8字节过期时间(毫秒) + 2字节key长度 + key + 缓存内容
+func (this *FileCacheClient) encode(key string, end int64, value []byte) (result []byte) {
+
+	offset := len(fileCacheMagic)
+
+	result = make([]byte, fileCacheHead, fileCacheHead+len(key)+len(value))
+	copy(result, fileCacheMagic)
+	binary.BigEndian.PutUint64(result[offset:offset+8], uint64(end))
+	binary.BigEndian.PutUint16(result[offset+8:offset+10], uint16(len(key)))
+	result = append(result, key...)
+	result = append(result, value...)
+
+	return result
+}
+
+// parse 解析文件头 - 没有标识的为旧版本的缓存文件
+func (this *FileCacheClient) parse(data []byte) (end int64, key string, value []byte, err error) {
+
+	if !bytes.HasPrefix(data, []byte(fileCacheMagic)) {
+		return 0, "", nil, errFileCacheLegacy
+	}
+
+	if len(data) < fileCacheHead {
+		return 0, "", nil, errors.New("invalid cache file")
+	}
+
+	offset := len(fileCacheMagic)
+	end     = int64(binary.BigEndian.Uint64(data[offset : offset+8]))
+	size   := int(binary.BigEndian.Uint16(data[offset+8 : offset+10]))
+
+	if len(data) < fileCacheHead+size {
+		return 0, "", nil, errors.New("invalid cache file")
+	}
+
+	return end, string(data[fileCacheHead : fileCacheHead+size]), data[fileCacheHead+size:], nil
+}
+
+// ============================ 内存缓存 ============================
+
+
+type BigCacheStruct struct {
+	Client *BigCacheClient
+}
+
+func (this *BigCacheStruct) Has(key any) (ok bool) {
+	return this.Client.Has(key)
+}
+
+func (this *BigCacheStruct) Get(key any) (value any) {
+	return cacheValue(this.Client.Get(key))
+}
+
+func (this *BigCacheStruct) GetBytes(key any) (value []byte) {
+	return this.Client.Get(key)
+}
+
+func (this *BigCacheStruct) Set(key any, value any, expire ...any) (ok bool) {
+	data, err := cacheEncode(value, false)
+	return utils.Ternary(err != nil, false, this.Client.Set(key, data, expire...))
+}
+
+func (this *BigCacheStruct) SetBytes(key any, value []byte, expire ...any) (ok bool) {
+	return this.Client.Set(key, value, expire...)
+}
+
+func (this *BigCacheStruct) Add(key any, value any, expire ...any) (ok bool) {
+	data, err := cacheEncode(value, false)
+	return utils.Ternary(err != nil, false, this.Client.Add(key, data, expire...))
+}
+
+func (this *BigCacheStruct) Del(key any) (ok bool) {
+	return this.Client.Del(key)
+}
+
+func (this *BigCacheStruct) DelPrefix(prefix ...any) (ok bool) {
+	return this.Client.DelPrefix(prefix...)
+}
+
+func (this *BigCacheStruct) DelTags(tag ...any) (ok bool) {
+	return this.Client.DelTags(tag...)
+}
+
+func (this *BigCacheStruct) Clear() (ok bool) {
+	return this.Client.Clear()
+}
+
+const (
+	// RAMEvictionLRU - 最近最少使用淘汰
+	RAMEvictionLRU   = "lru"
+	// RAMEvictionClock - 时钟（二次机会）淘汰
+	RAMEvictionClock = "clock"
+)
+
+// BigCacheConfig 内存缓存配置
+type BigCacheConfig struct {
+	// 默认缓存过期时间(秒) - 0为永不过期
+	Expire   any
+	// 最大内存占用(MB) - 0为不限制
+	Size     int
+	// 单个缓存最大占用(MB) - 0为最大内存占用的 1/8
+	MaxItem  int
+	// 淘汰策略 - lru 或 clock
+	Eviction string
+	// 分片数量 - 会被修正为2的幂
+	Shards   int
+	// 缓存名前缀
+	Prefix   string
+}
+
+// BigCacheClient 缓存 - 单实例分片存储，每个分片一把锁
+type BigCacheClient struct {
+	prefix   string          // 缓存名前缀
+	expire   int64           // 默认缓存过期时间
+	eviction string          // 淘汰策略
+	mask     uint32          // 分片掩码
+	shards   []*bigCacheShard
+	max      int64           // 最大占用字节 - 0为不限制
+	item     int64           // 单个条目最大占用字节 - 0为不限制
+	size     atomic.Int64    // 所有分片的占用字节
+	hand     atomic.Uint32   // 淘汰时轮流选择分片
+	done     chan struct{}   // 关闭信号 - 停止过期清理
+	once     sync.Once
+}
+
+// bigCacheEntry 缓存条目
+type bigCacheEntry struct {
+	key   string
+	value []byte
+	end   int64         // 过期时间戳(纳秒) - 0为永不过期
+	ref   bool          // clock 访问标记
+	slot  int           // clock 环形槽位
+	elem  *list.Element // lru 链表节点
+}
+
+// bigCacheShard 缓存分片
+type bigCacheShard struct {
+	mutex sync.Mutex
+	items map[string]*bigCacheEntry
+	size  int64         // 当前占用字节
+	total *atomic.Int64 // 所有分片的占用字节 - 即 BigCacheClient.size
+	lru   *list.List
+	ring  []*bigCacheEntry
+	free  []int
+	hand  int
+}
+
+// NewBigCache 创建一个新的缓存实例
+func NewBigCache(config BigCacheConfig) *BigCacheClient {
+
+	shards := 1
+	for shards < config.Shards {
+		shards <<= 1
+	}
+
+	cache := &BigCacheClient{
+		prefix:   "cache_",
+		expire:   cast.ToInt64(config.Expire),
+		eviction: strings.ToLower(config.Eviction),
+		mask:     uint32(shards - 1),
+		shards:   make([]*bigCacheShard, shards),
+		max:      int64(config.Size) * 1024 * 1024,
+		item:     int64(config.MaxItem) * 1024 * 1024,
+		done:     make(chan struct{}),
+	}
+
+	// 单个条目的上限与分片数量无关，默认为总内存的 1/8
+	if cache.item <= 0 {
+		cache.item = cache.max / 8
+	}
+
+	if !utils.Is.Empty(config.Prefix) {
+		cache.prefix = config.Prefix
+	}
+
+	if cache.eviction != RAMEvictionClock {
+		cache.eviction = RAMEvictionLRU
+	}
+
+	// 内存上限按所有分片合计，超出后由 trim 从各分片轮流淘汰
+	for key := range cache.shards {
+		cache.shards[key] = &bigCacheShard{
+			items: make(map[string]*bigCacheEntry),
+			total: &cache.size,
+			lru:   list.New(),
+		}
+	}
+
+	// 定时清理过期的缓存
+	go cache.timer()
+
+	return cache
+}
+
+// Get 获取缓存
+func (this *BigCacheClient) Get(key any) (result []byte) {
+	res, err := this.GetE(key)
+	return utils.Ternary(err != nil, nil, res)
+}
+
+// Has 判断缓存是否存在
+func (this *BigCacheClient) Has(key any) (ok bool) {
+
+	name  := this.name(key)
+	shard := this.shard(name)
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	_, ok = shard.get(name, time.Now().UnixNano())
+	return
+}
+
+// Set 设置缓存
+func (this *BigCacheClient) Set(key any, value []byte, expire ...any) (ok bool) {
+
+	exp := this.expire
+
+	if len(expire) > 0 {
+		if !utils.Is.Empty(expire[0]) {
+			// 判断 expire[0] 是否为Duration类型
+			if reflect.TypeOf(expire[0]).String() == "time.Duration" {
+				// 转换为int64
+				exp = cast.ToInt64(cast.ToDuration(expire[0]).Seconds())
+			} else {
+				exp = cast.ToInt64(expire[0])
+			}
+		}
+	}
+
+	err := this.SetE(key, value, exp)
+
+	return utils.Ternary(err != nil, false, true)
+}
+
+// Add 缓存不存在时才设置
+func (this *BigCacheClient) Add(key any, value []byte, expire ...any) (ok bool) {
+
+	exp := this.expire
+
+	if len(expire) > 0 {
+		if !utils.Is.Empty(expire[0]) {
+			// 判断 expire[0] 是否为Duration类型
+			if reflect.TypeOf(expire[0]).String() == "time.Duration" {
+				// 转换为int64
+				exp = cast.ToInt64(cast.ToDuration(expire[0]).Seconds())
+			} else {
+				exp = cast.ToInt64(expire[0])
+			}
+		}
+	}
+
+	ok, err := this.AddE(key, value, exp)
+
+	return err == nil && ok
+}
+
+// Del 删除缓存
+func (this *BigCacheClient) Del(key any) (ok bool) {
+
+	err := this.DelE(key)
+
+	return utils.Ternary(err != nil, false, true)
+}
+
+// Clear 清空缓存
+func (this *BigCacheClient) Clear() (ok bool) {
+
+	err := this.ClearE()
+
+	return utils.Ternary(err != nil, false, true)
+}
+
+// DelPrefix 根据前缀删除缓存
+func (this *BigCacheClient) DelPrefix(prefix ...any) (ok bool) {
+	err := this.DelPrefixE(prefix...)
+	return utils.Ternary(err != nil, false, true)
+}
+
+// DelTags 根据标签删除缓存
+func (this *BigCacheClient) DelTags(tags ...any) (ok bool) {
+	err := this.DelTagsE(tags...)
+	return utils.Ternary(err != nil, false, true)
+}
+
+// Close 停止过期清理 - 配置重载时释放旧实例
+func (this *BigCacheClient) Close() {
+	this.once.Do(func() {
+		close(this.done)
+	})
+}
+
+// GetE 获取缓存
+func (this *BigCacheClient) GetE(key any) (result []byte, err error) {
+
+	name  := this.name(key)
+	shard := this.shard(name)
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	item, ok := shard.get(name, time.Now().UnixNano())
+	if !ok {
+		return nil, fmt.Errorf("cache %s not exists", name)
+	}
+
+	shard.touch(item, this.eviction)
+
+	// 返回副本，避免调用方修改缓存中的数据
+	result = make([]byte, len(item.value))
+	copy(result, item.value)
+
+	return result, nil
+}
+
+// SetE 设置缓存
+func (this *BigCacheClient) SetE(key any, value []byte, expire int64) (err error) {
+
+	name  := this.name(key)
+	shard := this.shard(name)
+
+	// end 过期时间，expire = 0 表示永不过期
+	var end int64
+	if expire > 0 {
+		end = time.Now().Add(time.Duration(expire) * time.Second).UnixNano()
+	}
+
+	// 拷贝一份，避免调用方修改底层数组
+	data := make([]byte, len(value))
+	copy(data, value)
+
+	item := &bigCacheEntry{key: name, value: data, end: end}
+	if err = this.limit(item); err != nil {
+		return err
+	}
+
+	shard.mutex.Lock()
+	shard.put(item, this.eviction, this.max)
+	shard.mutex.Unlock()
+
+	this.trim()
+
+	return nil
+}
+
+// AddE 缓存不存在时才设置 - 判断和写入在同一把分片锁内
+func (this *BigCacheClient) AddE(key any, value []byte, expire int64) (ok bool, err error) {
+
+	name  := this.name(key)
+	shard := this.shard(name)
+
+	// end 过期时间，expire = 0 表示永不过期
+	var end int64
+	if expire > 0 {
+		end = time.Now().Add(time.Duration(expire) * time.Second).UnixNano()
+	}
+
+	data := make([]byte, len(value))
+	copy(data, value)
+
+	item := &bigCacheEntry{key: name, value: data, end: end}
+	if err = this.limit(item); err != nil {
+		return false, err
+	}
+
+	shard.mutex.Lock()
+
+	if _, exist := shard.get(name, time.Now().UnixNano()); exist {
+		shard.mutex.Unlock()
+		return false, nil
+	}
+
+	shard.put(item, this.eviction, this.max)
+	shard.mutex.Unlock()
+
+	this.trim()
+
+	return true, nil
+}
+
+// limit 检查单个条目的大小
+func (this *BigCacheClient) limit(item *bigCacheEntry) (err error) {
+	if this.item > 0 && item.cost() > this.item {
+		return fmt.Errorf("cache %s is too large: %d bytes", item.key, item.cost())
+	}
+	return nil
+}
+
+// trim 总占用超出上限时，从各分片轮流按策略淘汰，直到不超出 - 调用方不能持有分片锁
+func (this *BigCacheClient) trim() {
+
+	if this.max <= 0 {
+		return
+	}
+
+	// 连续一轮所有分片都没有可淘汰的条目时停止
+	for idle := 0; this.size.Load() > this.max && idle < len(this.shards); {
+
+		shard := this.shards[this.hand.Add(1)&this.mask]
+
+		shard.mutex.Lock()
+		ok := shard.evict(this.eviction)
+		shard.mutex.Unlock()
+
+		idle = utils.Ternary(ok, 0, idle+1)
+	}
+}
+
+// DelE 删除缓存
+func (this *BigCacheClient) DelE(key any) (err error) {
+
+	name  := this.name(key)
+	shard := this.shard(name)
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	item, ok := shard.items[name]
+	if !ok {
+		return fmt.Errorf("cache %s not exists", name)
+	}
+
+	shard.remove(item)
+
+	return nil
+}
+
+// ClearE 清空缓存
+func (this *BigCacheClient) ClearE() (err error) {
+
+	for _, shard := range this.shards {
+		shard.mutex.Lock()
+		shard.total.Add(-shard.size)
+		shard.items = make(map[string]*bigCacheEntry)
+		shard.size  = 0
+		shard.lru   = list.New()
+		shard.ring  = nil
+		shard.free  = nil
+		shard.hand  = 0
+		shard.mutex.Unlock()
+	}
+
+	return nil
+}
+
 // DelPrefixE 删除指定前缀的缓存
 func (this *BigCacheClient) DelPrefixE(prefix ...any) (err error) {
 
-	this.mutex.Lock()
-	defer this.mutex.Unlock()
+	var prefixes []string
 
-	for key, item := range this.items {
-		if strings.HasPrefix(key, cast.ToString(prefix)) {
-			err = item.Reset()
-			if err != nil {
-				return err
+	if len(prefix) == 0 {
+		return nil
+	}
+
+	for _, value := range prefix {
+		// 判断是否为切片
+		if reflect.ValueOf(value).Kind() == reflect.Slice {
+			for _, val := range cast.ToSlice(value) {
+				prefixes = append(prefixes, this.name(val))
 			}
-			delete(this.items, key)
+		} else {
+			prefixes = append(prefixes, this.name(value))
 		}
 	}
 
+	this.each(func(shard *bigCacheShard, item *bigCacheEntry) {
+		for _, value := range prefixes {
+			if strings.HasPrefix(item.key, value) {
+				shard.remove(item)
+				return
+			}
+		}
+	})
+
 	return nil
 }
 
 // DelTagsE 删除指定标签的缓存
 func (this *BigCacheClient) DelTagsE(tag ...any) (err error) {
 
-	var keys []string
 	var tags []string
 
 	if len(tag) == 0 {
@@ -654,27 +1839,52 @@ func (this *BigCacheClient) DelTagsE(tag ...any) (err error) {
 		tags = append(tags, fmt.Sprintf("*%s*", item))
 	}
 
-	// 获取所有缓存名称
-	for key := range this.items {
-		keys = append(keys, key)
-	}
+	this.each(func(shard *bigCacheShard, item *bigCacheEntry) {
+		if len(this.fuzzyMatch([]string{item.key}, tags)) > 0 {
+			shard.remove(item)
+		}
+	})
 
-	// 模糊匹配
-	keys = this.fuzzyMatch(keys, tags)
+	return nil
+}
 
-	for _, key := range keys {
-		item, ok := this.items[key]
-		if !ok {
-			continue
+// each 逐个分片加锁遍历
+func (this *BigCacheClient) each(fn func(shard *bigCacheShard, item *bigCacheEntry)) {
+	for _, shard := range this.shards {
+		shard.mutex.Lock()
+		for _, item := range shard.items {
+			fn(shard, item)
 		}
-		err = item.Reset()
-		if err != nil {
-			return err
+		shard.mutex.Unlock()
+	}
+}
+
+// timer 定时器 - 每隔一段时间清理过期的缓存
+func (this *BigCacheClient) timer() {
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this.done:
+			return
+		case <-ticker.C:
+			now := time.Now().UnixNano()
+			for _, shard := range this.shards {
+				shard.mutex.Lock()
+				shard.expired(now)
+				shard.mutex.Unlock()
+			}
 		}
-		delete(this.items, key)
 	}
+}
 
-	return nil
+// shard 根据缓存名称获取分片
+func (this *BigCacheClient) shard(name string) *bigCacheShard {
+	item := fnv.New32a()
+	_, _ = item.Write([]byte(name))
+	return this.shards[item.Sum32()&this.mask]
 }
 
 // name 获取缓存名称
@@ -693,4 +1903,130 @@ func (this *BigCacheClient) fuzzyMatch(keys []string, tags []string) (result []s
 		}
 	}
 	return result
-}
\ No newline at end of file
+}
+
+// cost 条目占用的字节数
+func (this *bigCacheEntry) cost() int64 {
+	return int64(len(this.key) + len(this.value) + 64)
+}
+
+// get 获取未过期的条目 - 过期则顺带删除
+func (this *bigCacheShard) get(name string, now int64) (item *bigCacheEntry, ok bool) {
+
+	item, ok = this.items[name]
+	if !ok {
+		return nil, false
+	}
+
+	if item.end > 0 && now > item.end {
+		this.remove(item)
+		return nil, false
+	}
+
+	return item, true
+}
+
+// touch 标记访问
+func (this *bigCacheShard) touch(item *bigCacheEntry, eviction string) {
+	if eviction == RAMEvictionClock {
+		item.ref = true
+		return
+	}
+	this.lru.MoveToFront(item.elem)
+}
+
+// put 写入条目 - 替换同名条目，总占用超出上限时先清理本分片过期的条目 - 调用方持有分片锁
+func (this *bigCacheShard) put(item *bigCacheEntry, eviction string, max int64) {
+
+	if old, ok := this.items[item.key]; ok {
+		this.remove(old)
+	}
+
+	if max > 0 && this.total.Load()+item.cost() > max {
+		this.expired(time.Now().UnixNano())
+	}
+
+	this.insert(item, eviction)
+}
+
+// insert 写入条目
+func (this *bigCacheShard) insert(item *bigCacheEntry, eviction string) {
+
+	if eviction == RAMEvictionClock {
+		if size := len(this.free); size > 0 {
+			item.slot = this.free[size-1]
+			this.free = this.free[:size-1]
+			this.ring[item.slot] = item
+		} else {
+			item.slot = len(this.ring)
+			this.ring = append(this.ring, item)
+		}
+	} else {
+		item.elem = this.lru.PushFront(item)
+	}
+
+	this.items[item.key] = item
+	this.size += item.cost()
+	this.total.Add(item.cost())
+}
+
+// remove 删除条目
+func (this *bigCacheShard) remove(item *bigCacheEntry) {
+
+	if item.elem != nil {
+		this.lru.Remove(item.elem)
+		item.elem = nil
+	} else if item.slot < len(this.ring) && this.ring[item.slot] == item {
+		this.ring[item.slot] = nil
+		this.free = append(this.free, item.slot)
+	}
+
+	delete(this.items, item.key)
+	this.size -= item.cost()
+	this.total.Add(-item.cost())
+}
+
+// evict 按策略淘汰一个条目
+func (this *bigCacheShard) evict(eviction string) (ok bool) {
+
+	if len(this.items) == 0 {
+		return false
+	}
+
+	if eviction != RAMEvictionClock {
+		if elem := this.lru.Back(); elem != nil {
+			this.remove(elem.Value.(*bigCacheEntry))
+			return true
+		}
+		return false
+	}
+
+	// 时钟指针最多转两圈，第一圈清除访问标记
+	for step := 0; step < 2*len(this.ring); step++ {
+		if this.hand >= len(this.ring) {
+			this.hand = 0
+		}
+		item := this.ring[this.hand]
+		this.hand++
+		if item == nil {
+			continue
+		}
+		if item.ref {
+			item.ref = false
+			continue
+		}
+		this.remove(item)
+		return true
+	}
+
+	return false
+}
+
+// expired 清理过期条目
+func (this *bigCacheShard) expired(now int64) {
+	for _, item := range this.items {
+		if item.end > 0 && now > item.end {
+			this.remove(item)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package facade
+
+import (
+	"os"
+	"testing"
+)
+
+func TestInitCacheFileError(t *testing.T) {
+
+	path := CacheToml.Get("file.path")
+	defer func() {
+		CacheToml.Viper.Set("file.path", path)
+		initCache()
+	}()
+
+	// 普通文件下无法创建缓存目录
+	if err := os.WriteFile("blocker", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	initCache()
+	before := FileCache
+
+	// 重新初始化失败 - 继续使用旧的实例
+	CacheToml.Viper.Set("file.path", "blocker/cache")
+	initCache()
+	if FileCache != before || FileCache.Client == nil {
+		t.Fatal("重新初始化失败时应当保留旧的文件缓存")
+	}
+	if !FileCache.Set("key", "value") || FileCache.Get("key") != "value" {
+		t.Fatal("旧的文件缓存应当仍然可用")
+	}
+
+	// 首次初始化失败 - 使用内存缓存
+	FileCache = nil
+	initCache()
+	if FileCache != nil || NewCache(CacheModeFile) != CacheInterface(BigCache) {
+		t.Fatal("首次初始化失败时应当使用内存缓存")
+	}
+	if !Cache.Set("key", "value") || Cache.Get("key") != "value" {
+		t.Fatal("内存缓存应当可用")
+	}
+}
+
+// cacheMap - 只实现了 CacheInterface 的自定义驱动
+type cacheMap map[string]any
+
+func (this cacheMap) Has(key any) bool {
+	_, ok := this[key.(string)]
+	return ok
+}
+
+func (this cacheMap) Get(key any) any {
+	return this[key.(string)]
+}
+
+func (this cacheMap) Set(key any, value any, expire ...any) bool {
+	this[key.(string)] = value
+	return true
+}
+
+func (this cacheMap) Del(key any) bool {
+	delete(this, key.(string))
+	return true
+}
+
+func (this cacheMap) DelPrefix(prefix ...any) bool {
+	return true
+}
+
+func (this cacheMap) DelTags(tag ...any) bool {
+	return true
+}
+
+func (this cacheMap) Clear() bool {
+	return true
+}
+
+func TestCacheCustomDriver(t *testing.T) {
+
+	before := Cache
+	defer func() { Cache = before }()
+	Cache = cacheMap{}
+
+	type item struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	if !CacheSet("item", item{Id: 1, Name: "a"}) {
+		t.Fatal("写入失败")
+	}
+	if value, ok := CacheGet[item]("item"); !ok || value.Id != 1 || value.Name != "a" {
+		t.Fatalf("读取错误：%+v %v", value, ok)
+	}
+	if _, ok := CacheGet[item]("none"); ok {
+		t.Fatal("不存在的缓存应当返回 false")
+	}
+
+	if !CacheAdd("lock", 1) || CacheAdd("lock", 1) {
+		t.Fatal("CacheAdd 只有第一次应当成功")
+	}
+}
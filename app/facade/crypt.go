@@ -33,8 +33,14 @@ func initCryptToml() {
 		Content: utils.Replace(TempCrypt, map[string]any{
 			"${jwt.key}": 		secret,
 			"${jwt.expire}":    "7 * 24 * 60 * 60",
+			"${jwt.access}":    "15 * 60",
+			"${jwt.refresh}":   "30 * 24 * 60 * 60",
 			"${jwt.issuer}" :   "unti.io",
 			"${jwt.subject}":   "Unti",
+			"${jwt.algorithm}": JwtHS256,
+			"${jwt.keys}":      "config/jwt",
+			"${apikey.key}":    fmt.Sprintf("Unti-%x", md5.Sum([]byte(uuid.New().String()+"-apikey"))),
+			"${aead.index_key}": fmt.Sprintf("Unti-%x", md5.Sum([]byte(uuid.New().String()+"-aead"))),
 		}),
 	}).Read()
 
@@ -85,6 +91,10 @@ type JwtRequest struct {
 	Subject string       `json:"subject"`
 	// 密钥
 	Key     string       `json:"key"`
+	// 签名算法 - HS256 使用 Key，其他使用 jwt.keys 目录下的密钥
+	Algorithm string     `json:"algorithm"`
+	// 令牌ID - 登录令牌为会话ID
+	Jti     string       `json:"jti"`
 }
 
 // JwtResponse - JWT响应
@@ -93,6 +103,10 @@ type JwtResponse struct {
 	Data  map[string]any `json:"data"`
 	Error error          `json:"error"`
 	Valid int64          `json:"valid"`
+	// 签发时间戳
+	Issued int64         `json:"issued"`
+	// 令牌ID
+	Jti   string         `json:"jti"`
 }
 
 // Jwt - 入口
@@ -122,6 +136,11 @@ func Jwt(request ...JwtRequest) *JwtStruct {
 		request[0].Key = cast.ToString(CryptToml.Get("jwt.key", "Unti"))
 	}
 
+	// 签名算法
+	if utils.Is.Empty(request[0].Algorithm) {
+		request[0].Algorithm = JwtKeys.Algorithm()
+	}
+
 	return &JwtStruct{
 		request: request[0],
 		response: JwtResponse{
@@ -130,6 +149,16 @@ func Jwt(request ...JwtRequest) *JwtStruct {
 	}
 }
 
+// JwtAccessExpire - 登录访问令牌的有效期（秒）
+func JwtAccessExpire() int64 {
+	return cast.ToInt64(utils.Calc(CryptToml.Get("jwt.access", "15 * 60")))
+}
+
+// JwtRefreshExpire - 刷新令牌的有效期（秒）
+func JwtRefreshExpire() int64 {
+	return cast.ToInt64(utils.Calc(CryptToml.Get("jwt.refresh", "30 * 24 * 60 * 60")))
+}
+
 // Create - 创建JWT
 func (this *JwtStruct) Create(data map[string]any) (result JwtResponse) {
 
@@ -141,15 +170,33 @@ func (this *JwtStruct) Create(data map[string]any) (result JwtResponse) {
 	IssuedAt  := JWT.NewNumericDate(time.Now())
 	ExpiresAt := JWT.NewNumericDate(time.Now().Add(time.Second * time.Duration(this.request.Expire)))
 
-	item, err := JWT.NewWithClaims(JWT.SigningMethodHS256, JwtClaims{
+	claims := JwtClaims{
 		Data: data,
 		RegisteredClaims: JWT.RegisteredClaims{
 			IssuedAt:  IssuedAt,				// 签发时间戳
 			ExpiresAt: ExpiresAt,				// 过期时间戳
 			Issuer:    this.request.Issuer,		// 颁发者签名
 			Subject:   this.request.Subject,	// 签名主题
+			ID:        this.request.Jti,		// 令牌ID
 		},
-	}).SignedString([]byte(this.request.Key))
+	}
+
+	var item string
+	var err error
+
+	if this.request.Algorithm == JwtHS256 {
+		item, err = JWT.NewWithClaims(JWT.SigningMethodHS256, claims).SignedString([]byte(this.request.Key))
+	} else {
+		// 非对称签名 - 头部带上 kid，验证时按 kid 查找公钥
+		key, fail := JwtKeys.Active()
+		if fail != nil {
+			this.response.Error = fail
+			return this.response
+		}
+		token := JWT.NewWithClaims(key.Method(), claims)
+		token.Header["kid"] = key.Kid
+		item, err = token.SignedString(key.Private)
+	}
 
 	if err != nil {
 		this.response.Error = err
@@ -170,7 +217,28 @@ func (this *JwtStruct) Parse(token any) (result JwtResponse) {
 	}
 
 	item, err := JWT.ParseWithClaims(cast.ToString(token), &JwtClaims{}, func(token *JWT.Token) (any, error) {
-		return []byte(this.request.Key), nil
+
+		kid, ok := token.Header["kid"].(string)
+
+		// 没有 kid 的是 HS256 令牌，使用 jwt.key 验证
+		// 切换到非对称算法后不再接受 - 否则持有 jwt.key 的人仍然可以签发令牌，切换前签发的令牌需要重新登录
+		if !ok {
+			if token.Method != JWT.SigningMethodHS256 || JwtKeys.Algorithm() != JwtHS256 {
+				return nil, fmt.Errorf("不支持的签名算法：%v", token.Header["alg"])
+			}
+			return []byte(this.request.Key), nil
+		}
+
+		// 停用的密钥仍可验证，轮换密钥不会让已登录的用户失效
+		key, ok := JwtKeys.Find(kid)
+		if !ok {
+			return nil, fmt.Errorf("JWT密钥不存在：%s", kid)
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("签名算法与密钥不符：%s", token.Method.Alg())
+		}
+
+		return key.Public(), nil
 	})
 
 	if err != nil {
@@ -187,6 +255,10 @@ func (this *JwtStruct) Parse(token any) (result JwtResponse) {
 	if key, _ := item.Claims.(*JwtClaims); item.Valid {
 		this.response.Data  = key.Data
 		this.response.Valid = key.RegisteredClaims.ExpiresAt.Time.Unix() - time.Now().Unix()
+		this.response.Jti = key.RegisteredClaims.ID
+		if key.RegisteredClaims.IssuedAt != nil {
+			this.response.Issued = key.RegisteredClaims.IssuedAt.Time.Unix()
+		}
 	}
 
 	return this.response
@@ -210,7 +282,7 @@ type CipherResponse struct {
 	Error error
 }
 
-// Cipher - 对称加密
+// Cipher - 对称加密 - AES-CBC，没有完整性校验，只用于兼容前端的传输加密，存储敏感数据请使用 Aead
 func Cipher(key, iv any) *CipherRequest {
 	return &CipherRequest{
 		Key: cast.ToString(key),
@@ -233,10 +305,15 @@ func (this *CipherRequest) Encrypt(text any) (result *CipherResponse) {
 	block, err := aes.NewCipher([]byte(this.Key))
 	if err != nil {
 		result.Error = err
+		return
 	}
 
 	// 每个块的大小
 	blockSize := block.BlockSize()
+	if len(this.Iv) != blockSize {
+		result.Error = errors.New("invalid iv")
+		return
+	}
 	// 计算需要填充的长度
 	padding := blockSize - len([]byte(cast.ToString(text)))%blockSize
 
@@ -279,18 +356,27 @@ func (this *CipherRequest) Decrypt(text any) (result *CipherResponse) {
 
 	// 确保 newText 是 blockSize 的整数倍
 	blockSize := block.BlockSize()
-	if len(newText)%blockSize != 0 {
+	if len(newText) == 0 || len(newText)%blockSize != 0 {
 		result.Error = errors.New("invalid ciphertext")
 		return
 	}
 
+	if len(this.Iv) != blockSize {
+		result.Error = errors.New("invalid iv")
+		return
+	}
+
 	decode := make([]byte, len(newText))
 	item := cipher.NewCBCDecrypter(block, []byte(this.Iv))
 	item.CryptBlocks(decode, newText)
 
-	// 去除填充
-	padding := decode[len(decode)-1]
-	result.Byte = decode[:len(decode)-int(padding)]
+	// 去除填充 - 校验 PKCS7 填充，密钥或向量错误时填充不合法
+	padding := int(decode[len(decode)-1])
+	if padding == 0 || padding > blockSize || !bytes.Equal(decode[len(decode)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		result.Error = errors.New("invalid padding")
+		return
+	}
+	result.Byte = decode[:len(decode)-padding]
 	result.Text = string(result.Byte)
 
 	return
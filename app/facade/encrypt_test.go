@@ -0,0 +1,159 @@
+package facade_test
+
+import (
+	"inis/app/facade"
+	"inis/app/model"
+	"strings"
+	"testing"
+)
+
+// testUsers - 临时密钥目录下的用户表
+func testUsers(t *testing.T) {
+
+	testDB(t, &model.Users{}, &model.Totps{})
+
+	dir := facade.CryptToml.Viper.Get("aead.keys")
+	facade.CryptToml.Viper.Set("aead.keys", t.TempDir())
+	t.Cleanup(func() { facade.CryptToml.Viper.Set("aead.keys", dir) })
+}
+
+// testRaw - 直接读取数据库中的值，不经过序列化器
+func testRaw(t *testing.T, table, column string, id int) (result string) {
+	facade.DB.Drive().Table(table).Where("id = ?", id).Limit(1).Pluck(column, &result)
+	return result
+}
+
+func TestEncryptSerializer(t *testing.T) {
+
+	testUsers(t)
+
+	user := model.Users{Account: "test", Avatar: "a.png", Phone: "13800138000"}
+	if err := facade.DB.Drive().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据库中为密文，盲索引与明文对应
+	raw := testRaw(t, "inis_users", "phone", user.Id)
+	if !facade.Aead.Encrypted(raw) || strings.Contains(raw, "13800138000") {
+		t.Fatalf("手机号应当加密存储：%q", raw)
+	}
+	if index := testRaw(t, "inis_users", "phone_index", user.Id); index != facade.Aead.BlindIndex("13800138000", "users.phone") {
+		t.Fatalf("盲索引错误：%q", index)
+	}
+
+	// 读取时解密，按盲索引查询
+	var found model.Users
+	where := model.UsersSocial("phone", " 13800138000 ")
+	facade.DB.Drive().Where(where[0].(string)+" = ?", where[2]).Limit(1).Find(&found)
+	if found.Id != user.Id || found.Phone != "13800138000" {
+		t.Fatalf("按手机号查询结果错误：%+v", found)
+	}
+
+	// 使用结构体更新 - 重新加密并更新盲索引（查询出的结构体 Result 为 map，不能直接保存）
+	item := model.Users{Id: user.Id, Account: "test", Avatar: "a.png", Phone: "13900139000"}
+	if err := facade.DB.Drive().Save(&item).Error; err != nil {
+		t.Fatal(err)
+	}
+	if index := testRaw(t, "inis_users", "phone_index", user.Id); index != facade.Aead.BlindIndex("13900139000", "users.phone") {
+		t.Fatal("保存后应当更新盲索引")
+	}
+
+	// 使用 map 更新 - 不经过序列化器，由 BeforeSave 加密
+	if err := facade.DB.Drive().Model(&model.Users{Id: user.Id}).Updates(map[string]any{"phone": "13700137000"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	raw = testRaw(t, "inis_users", "phone", user.Id)
+	if !facade.Aead.Encrypted(raw) || strings.Contains(raw, "13700137000") {
+		t.Fatalf("使用 map 更新时应当加密：%q", raw)
+	}
+	if index := testRaw(t, "inis_users", "phone_index", user.Id); index != facade.Aead.BlindIndex("13700137000", "users.phone") {
+		t.Fatal("使用 map 更新时应当更新盲索引")
+	}
+
+	// 清空手机号 - 空值不加密，盲索引为空
+	if err := facade.DB.Drive().Model(&model.Users{Id: user.Id}).Updates(map[string]any{"phone": ""}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if raw, index := testRaw(t, "inis_users", "phone", user.Id), testRaw(t, "inis_users", "phone_index", user.Id); raw != "" || index != "" {
+		t.Fatalf("清空后应当为空：%q %q", raw, index)
+	}
+}
+
+// TestEncryptMoved - 附加数据绑定字段，密文挪到其他模型的加密字段后无法解密
+func TestEncryptMoved(t *testing.T) {
+
+	testUsers(t)
+
+	user := model.Users{Account: "test", Avatar: "a.png", Phone: "13800138000"}
+	if err := facade.DB.Drive().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	raw := testRaw(t, "inis_users", "phone", user.Id)
+
+	if _, err := model.TotpsEnable(user.Id, "SECRET", 0); err != nil {
+		t.Fatal(err)
+	}
+	facade.DB.Drive().Table("inis_totps").Where("uid = ?", user.Id).UpdateColumn("secret", raw)
+
+	var item model.Totps
+	if err := facade.DB.Drive().Where("uid = ?", user.Id).Limit(1).Find(&item).Error; err == nil || !strings.Contains(err.Error(), "解密失败") {
+		t.Fatalf("挪用的密文不应当解密：%q %v", item.Secret, err)
+	}
+}
+
+// TestEncryptMigrate - 加密启用前的明文数据迁移为密文并补全盲索引
+func TestEncryptMigrate(t *testing.T) {
+
+	testUsers(t)
+
+	user := model.Users{Account: "test", Avatar: "a.png"}
+	if err := facade.DB.Drive().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	empty := model.Users{Account: "empty", Avatar: "a.png"}
+	if err := facade.DB.Drive().Create(&empty).Error; err != nil {
+		t.Fatal(err)
+	}
+	facade.DB.Drive().Table("inis_users").Where("id = ?", user.Id).UpdateColumns(map[string]any{"phone": "13800138000", "phone_index": ""})
+
+	// 明文原样读出
+	var found model.Users
+	facade.DB.Drive().Where("id = ?", user.Id).Limit(1).Find(&found)
+	if found.Phone != "13800138000" {
+		t.Fatalf("明文应当原样读出：%q", found.Phone)
+	}
+
+	count, err := model.EncryptMigrate(&model.Users{}, false)
+	if err != nil || count != 1 {
+		t.Fatalf("应当只迁移 1 行明文，得到 %d %v", count, err)
+	}
+
+	raw := testRaw(t, "inis_users", "phone", user.Id)
+	if !facade.Aead.Encrypted(raw) {
+		t.Fatalf("迁移后应当为密文：%q", raw)
+	}
+	if index := testRaw(t, "inis_users", "phone_index", user.Id); index != facade.Aead.BlindIndex("13800138000", "users.phone") {
+		t.Fatal("迁移后应当补全盲索引")
+	}
+	if value := testRaw(t, "inis_users", "phone", empty.Id); value != "" {
+		t.Fatalf("空值不应当加密：%q", value)
+	}
+
+	// 再次迁移时没有明文
+	if count, err = model.EncryptMigrate(&model.Users{}, false); err != nil || count != 0 {
+		t.Fatalf("已加密的数据不应当重复迁移，得到 %d %v", count, err)
+	}
+
+	// 全部重新加密 - 密文变化，明文和盲索引不变
+	if count, err = model.EncryptMigrate(&model.Users{}, true); err != nil || count != 2 {
+		t.Fatalf("应当处理全部数据，得到 %d %v", count, err)
+	}
+	if next := testRaw(t, "inis_users", "phone", user.Id); next == raw || !facade.Aead.Encrypted(next) {
+		t.Fatal("全部重新加密后密文应当变化")
+	}
+	found = model.Users{}
+	facade.DB.Drive().Where("id = ?", user.Id).Limit(1).Find(&found)
+	if found.Phone != "13800138000" {
+		t.Fatalf("重新加密后应当仍能解密：%q", found.Phone)
+	}
+}
@@ -0,0 +1,34 @@
+package facade
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testDir - 测试的工作目录 - 包变量在 init 之前初始化，配置文件写到临时目录中，不污染源码目录
+var testDir = func() string {
+	dir, err := os.MkdirTemp("", "inis-facade-")
+	if err != nil {
+		panic(err)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "config"), 0755); err != nil {
+		panic(err)
+	}
+	// 安装锁 - 存在时 model 的定时任务不会连接 MySQL，测试使用 SQLite
+	if err = os.WriteFile(filepath.Join(dir, "install.lock"), nil, 0644); err != nil {
+		panic(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	return dir
+}()
+
+func TestMain(m *testing.M) {
+	// init 时配置文件刚生成，读取到的是空配置，重新读取一次
+	initStorageToml()
+	code := m.Run()
+	_ = os.RemoveAll(testDir)
+	os.Exit(code)
+}
@@ -0,0 +1,758 @@
+package facade
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/singleflight"
+	"image"
+	"image/gif"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ImageCacheRuntime - 缩略图缓存在 runtime/image 目录
+	ImageCacheRuntime = "runtime"
+	// ImageCacheStorage - 缩略图缓存在默认存储驱动的 cache/image 目录
+	ImageCacheStorage = "storage"
+	// ImageCacheNone    - 不缓存
+	ImageCacheNone    = "none"
+)
+
+// imageFormats - 输出格式
+var imageFormats = map[string]imaging.Format{
+	"jpg":  imaging.JPEG,
+	"jpeg": imaging.JPEG,
+	"png":  imaging.PNG,
+	"gif":  imaging.GIF,
+	"bmp":  imaging.BMP,
+	"tif":  imaging.TIFF,
+	"tiff": imaging.TIFF,
+}
+
+// imageWebP - WebP 编码器 - 仅在支持 cgo 时可用，见 image_webp.go
+var imageWebP func(writer io.Writer, item image.Image, quality int) error
+
+// ErrImageAnimated - 动图指定输出为 webp - 编码器不支持动画，会丢失除第一帧外的所有帧
+var ErrImageAnimated = errors.New("动图不支持输出为 webp，请使用 format=gif 或 format=auto")
+
+// imageGravity - 裁剪锚点
+var imageGravity = map[string]imaging.Anchor{
+	"center":       imaging.Center,
+	"top":          imaging.Top,
+	"bottom":       imaging.Bottom,
+	"left":         imaging.Left,
+	"right":        imaging.Right,
+	"top-left":     imaging.TopLeft,
+	"top-right":    imaging.TopRight,
+	"bottom-left":  imaging.BottomLeft,
+	"bottom-right": imaging.BottomRight,
+}
+
+// imageSizes - 默认允许的尺寸
+var imageSizes = []string{"64x64", "128x128", "256x256", "300x200", "512x512", "800x600", "1024x1024", "1920x1080"}
+
+// imageQualities - 默认允许的质量
+var imageQualities = []int{60, 75, 85, 95}
+
+// imageSize - 匹配图片尺寸，如：200x200
+var imageSize = regexp.MustCompile(`^(\d+)\D+(\d+)$`)
+
+// ImageOptions - 图片处理参数
+type ImageOptions struct {
+	Width   int
+	Height  int
+	// 缩放模式 - fit：等比例缩放，fill：裁剪填充，resize：拉伸到指定大小
+	Mode    string
+	// 裁剪锚点 - mode 为 fill 时有效
+	Gravity string
+	// 质量 - 1 ~ 100，仅 jpeg 有效
+	Quality int
+	// 顺时针旋转角度 - 90、180、270
+	Rotate  int
+	// 高斯模糊
+	Blur    float64
+	// 输出格式 - jpeg、png、gif、bmp、tiff、webp - 动图只有 gif 保留动画，jpeg 等静态格式取第一帧，webp 不支持
+	Format  string
+	// 是否根据 Accept 协商格式 - 为 true 时响应需要带上 Vary: Accept
+	Auto    bool
+	// 水印位置 - 为空不添加水印
+	Watermark string
+}
+
+// ImageParse - 解析图片处理参数 - 没有任何处理参数时返回 nil
+/**
+ * @param query 请求参数 - size、mode、gravity、quality、rotate、blur、format（jpeg、png、gif、bmp、tiff、webp、auto）
+ * @param ext 原图后缀
+ * @param accept 请求头 Accept - format 为 auto 时，支持 webp 则输出 webp
+ * @return *ImageOptions, error
+ * @example：
+ * options, err := facade.ImageParse(ctx.Request.URL.Query(), "png", ctx.GetHeader("Accept"))
+ */
+func ImageParse(query url.Values, ext, accept string) (*ImageOptions, error) {
+
+	empty := true
+	for _, key := range []string{"size", "mode", "gravity", "quality", "rotate", "blur", "format"} {
+		if query.Get(key) != "" {
+			empty = false
+		}
+	}
+	if empty {
+		return nil, nil
+	}
+
+	ext = strings.ToLower(ext)
+	this := &ImageOptions{
+		Gravity: strings.ToLower(query.Get("gravity")),
+		Quality: cast.ToInt(StorageToml.Get("image.quality", 85)),
+		Format:  strings.ToLower(query.Get("format")),
+	}
+
+	if size := query.Get("size"); size != "" {
+
+		match := imageSize.FindStringSubmatch(size)
+		if match == nil {
+			return nil, errors.New("size 格式错误，如：200x200")
+		}
+
+		this.Width, this.Height = cast.ToInt(match[1]), cast.ToInt(match[2])
+
+		// 只允许配置的尺寸，防止被用来无限生成缩略图
+		sizes := cast.ToStringSlice(StorageToml.Get("image.sizes", imageSizes))
+		if !utils.InArray("*", sizes) && !utils.InArray(fmt.Sprintf("%dx%d", this.Width, this.Height), sizes) {
+			return nil, fmt.Errorf("不支持的尺寸：%dx%d", this.Width, this.Height)
+		}
+
+		// 兼容旧的默认行为 - 宽高相同时裁剪填充，否则等比例缩放
+		this.Mode = utils.Ternary(this.Width == this.Height, "fill", "fit")
+	}
+
+	if mode := strings.ToLower(query.Get("mode")); mode != "" {
+		this.Mode = mode
+	}
+	if this.Mode != "" && !utils.InArray(this.Mode, []string{"fit", "fill", "resize"}) {
+		return nil, fmt.Errorf("不支持的模式：%s", this.Mode)
+	}
+
+	if this.Gravity == "" {
+		this.Gravity = "center"
+	}
+	if _, ok := imageGravity[this.Gravity]; !ok {
+		return nil, fmt.Errorf("不支持的锚点：%s", this.Gravity)
+	}
+	// 锚点只对 fill 有效 - 其他模式统一为 center，避免同一结果生成多份缓存
+	if this.Mode != "fill" {
+		this.Gravity = "center"
+	}
+
+	// 只允许默认质量和配置的质量，防止被用来无限生成缩略图
+	if quality := query.Get("quality"); quality != "" && cast.ToInt(quality) != this.Quality {
+		this.Quality = cast.ToInt(quality)
+		if !utils.InArray(this.Quality, cast.ToIntSlice(StorageToml.Get("image.qualities", imageQualities))) {
+			return nil, fmt.Errorf("不支持的质量：%d", this.Quality)
+		}
+	}
+	if this.Quality < 1 || this.Quality > 100 {
+		return nil, errors.New("quality 范围为 1 ~ 100")
+	}
+
+	this.Rotate = cast.ToInt(query.Get("rotate")) % 360
+	if this.Rotate < 0 {
+		this.Rotate += 360
+	}
+	if this.Rotate%90 != 0 {
+		return nil, errors.New("rotate 只能为 90 的倍数")
+	}
+
+	// 模糊半径只取整数
+	this.Blur = math.Round(cast.ToFloat64(query.Get("blur")))
+	if this.Blur < 0 || this.Blur > cast.ToFloat64(StorageToml.Get("image.max_blur", 20)) {
+		return nil, errors.New("blur 超出范围")
+	}
+
+	if this.Format == "auto" {
+		this.Auto   = true
+		this.Format = ""
+		// 动图保持 gif，避免丢失动画
+		if ext != "gif" && imageWebP != nil && imageAccept(accept, "image/webp") {
+			this.Format = "webp"
+		}
+	}
+
+	if this.Format == "" {
+		this.Format = ext
+		// 不支持 webp 编码时，webp 原图输出为 png
+		if this.Format == "webp" && imageWebP == nil {
+			this.Format = "png"
+		}
+	}
+	if this.Format == "jpg" {
+		this.Format = "jpeg"
+	}
+	if this.Format == "tif" {
+		this.Format = "tiff"
+	}
+
+	if this.Format == "webp" {
+		if imageWebP == nil {
+			return nil, errors.New("当前环境不支持 webp 输出")
+		}
+	} else if _, ok := imageFormats[this.Format]; !ok {
+		return nil, fmt.Errorf("不支持的格式：%s", this.Format)
+	}
+
+	return this, nil
+}
+
+// ImageOriginal - 原图尺寸的处理参数 - 用于只需要添加水印的原图
+/**
+ * @param ext 原图后缀
+ * @return *ImageOptions
+ */
+func ImageOriginal(ext string) *ImageOptions {
+	options, err := ImageParse(url.Values{"format": []string{strings.ToLower(ext)}}, ext, "")
+	if err != nil {
+		// 不支持的格式（如不支持 webp 编码时的 webp 原图）输出为 png
+		options, _ = ImageParse(url.Values{"format": []string{"png"}}, ext, "")
+	}
+	return options
+}
+
+// imageAccept - Accept 是否接受指定类型 - q=0 表示不接受
+func imageAccept(accept, mime string) bool {
+	for _, item := range strings.Split(accept, ",") {
+		parts := strings.Split(item, ";")
+		if strings.TrimSpace(parts[0]) != mime {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok && cast.ToFloat64(value) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Key - 参数的唯一标识 - 用于缓存
+func (this *ImageOptions) Key() string {
+
+	key := fmt.Sprintf("%dx%d,%s,%s,q%d,r%d,b%g,%s",
+		this.Width, this.Height, this.Mode, this.Gravity, this.Quality, this.Rotate, this.Blur, this.Format)
+
+	// 水印配置变化后重新生成
+	if this.Watermark != "" {
+		key += ",w" + this.Watermark + "," + Watermark.Key()
+	}
+
+	return key
+}
+
+// ETag - 缩略图的 ETag - 由原图 ETag 和处理参数组成
+func (this *ImageOptions) ETag(etag string) string {
+	return fmt.Sprintf("%s-%x", etag, sha256.Sum256([]byte(this.Key())))[:len(etag)+17]
+}
+
+// Mime - 输出的文件类型
+func (this *ImageOptions) Mime() string {
+	return ImageMime(this.Format)
+}
+
+// ImageMime - 图片后缀对应的文件类型
+/**
+ * @param ext 图片后缀
+ * @return string
+ * @example：
+ * facade.ImageMime("webp") // image/webp
+ */
+func ImageMime(ext string) string {
+	switch ext = strings.ToLower(strings.TrimPrefix(ext, ".")); ext {
+	case "jpg", "jpeg":
+		return "image/jpeg"
+	case "tif", "tiff":
+		return "image/tiff"
+	case "png", "gif", "bmp", "webp":
+		return "image/" + ext
+	}
+	return utils.Mime.Type(ext)
+}
+
+// Process - 处理图片
+/**
+ * @param body 原图内容
+ * @return 处理后的图片内容
+ */
+func (this *ImageOptions) Process(body []byte) ([]byte, error) {
+
+	config, err := imageConfig(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 动图逐帧处理，保留动画
+	if (this.Format == "gif" || this.Format == "webp") && bytes.HasPrefix(body, []byte("GIF8")) {
+		// 先数帧数，检查通过后再解码全部帧
+		frames, err := gifFrames(body)
+		if err != nil {
+			return nil, err
+		}
+		if frames > 1 {
+			if this.Format == "webp" {
+				return nil, ErrImageAnimated
+			}
+			if err = imageFrames(config, frames); err != nil {
+				return nil, err
+			}
+			item, err := gif.DecodeAll(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			return this.animate(item)
+		}
+	}
+
+	src, err := imaging.Decode(bytes.NewReader(body), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := this.transform(src)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+	if this.Format == "webp" {
+		err = imageWebP(buffer, dst, this.Quality)
+	} else {
+		err = imaging.Encode(buffer, dst, imageFormats[this.Format], imaging.JPEGQuality(this.Quality))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// imageConfig - 先只读取尺寸，拒绝像素过大的图片（解压炸弹）
+func imageConfig(body []byte) (image.Config, error) {
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return config, err
+	}
+	if int64(config.Width)*int64(config.Height) > cast.ToInt64(StorageToml.Get("image.max_pixels", 40))*1000000 {
+		return config, errors.New("图片尺寸过大")
+	}
+
+	return config, nil
+}
+
+// imageFrames - 动图的总像素同样受 image.max_pixels 限制
+func imageFrames(config image.Config, frames int) error {
+	if int64(config.Width)*int64(config.Height)*int64(frames) > cast.ToInt64(StorageToml.Get("image.max_pixels", 40))*1000000 {
+		return errors.New("动图帧数过多")
+	}
+	return nil
+}
+
+// gifFrames - 只遍历 GIF 的数据块统计帧数，不解码像素
+func gifFrames(body []byte) (int, error) {
+
+	fail := errors.New("GIF 格式错误")
+
+	// 文件头 6 字节 + 逻辑屏幕描述 7 字节
+	if len(body) < 13 {
+		return 0, fail
+	}
+	index := 13
+	if body[10]&0x80 != 0 {
+		index += 3 << (body[10]&0x07 + 1)
+	}
+
+	// blocks - 跳过以 0 结尾的数据子块
+	blocks := func() bool {
+		for index < len(body) {
+			size := int(body[index])
+			index += size + 1
+			if size == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	frames := 0
+	for index < len(body) {
+		switch body[index] {
+		case 0x21:
+			// 扩展块：标识 + 数据子块
+			index += 2
+			if !blocks() {
+				return 0, fail
+			}
+		case 0x2C:
+			// 图像描述 9 字节 + 局部颜色表 + LZW 最小码长 1 字节 + 数据子块
+			if index+10 > len(body) {
+				return 0, fail
+			}
+			if body[index+9]&0x80 != 0 {
+				index += 3 << (body[index+9]&0x07 + 1)
+			}
+			index += 11
+			if !blocks() {
+				return 0, fail
+			}
+			frames++
+		case 0x3B:
+			return frames, nil
+		default:
+			return 0, fail
+		}
+	}
+
+	// 缺少结束符
+	return 0, fail
+}
+
+// transform - 缩放、旋转、模糊、水印
+func (this *ImageOptions) transform(src image.Image) (image.Image, error) {
+
+	dst := src
+
+	if this.Width > 0 || this.Height > 0 {
+		switch this.Mode {
+		case "fill":
+			// 填充
+			dst = imaging.Fill(dst, this.Width, this.Height, imageGravity[this.Gravity], imaging.Lanczos)
+		case "resize":
+			// 完全自定义大小
+			dst = imaging.Resize(dst, this.Width, this.Height, imaging.Lanczos)
+		default:
+			// 等比例缩放
+			dst = imaging.Fit(dst, this.Width, this.Height, imaging.Lanczos)
+		}
+	}
+
+	switch this.Rotate {
+	case 90:
+		dst = imaging.Rotate270(dst)
+	case 180:
+		dst = imaging.Rotate180(dst)
+	case 270:
+		dst = imaging.Rotate90(dst)
+	}
+
+	if this.Blur > 0 {
+		dst = imaging.Blur(dst, this.Blur)
+	}
+
+	if this.Watermark != "" {
+		return Watermark.Apply(dst, this.Watermark)
+	}
+
+	return dst, nil
+}
+
+// animate - 动图逐帧处理 - 先把每一帧合成到完整画布上，处理后再按原调色板量化
+func (this *ImageOptions) animate(item *gif.GIF) ([]byte, error) {
+
+	canvas := image.NewRGBA(image.Rect(0, 0, item.Config.Width, item.Config.Height))
+	result := &gif.GIF{LoopCount: item.LoopCount}
+
+	for index, frame := range item.Image {
+
+		disposal := byte(0)
+		if index < len(item.Disposal) {
+			disposal = item.Disposal[index]
+		}
+
+		// 下一帧需要恢复到当前帧之前的画面
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			draw.Draw(previous, canvas.Bounds(), canvas, image.Point{}, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		dst, err := this.transform(canvas)
+		if err != nil {
+			return nil, err
+		}
+
+		paletted := image.NewPaletted(dst.Bounds(), frame.Palette)
+		draw.FloydSteinberg.Draw(paletted, dst.Bounds(), dst, dst.Bounds().Min)
+
+		result.Image = append(result.Image, paletted)
+		if index < len(item.Delay) {
+			result.Delay = append(result.Delay, item.Delay[index])
+		} else {
+			result.Delay = append(result.Delay, 0)
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := gif.EncodeAll(buffer, result); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// imageLimit - 同时处理的图片数量
+var imageLimit = make(chan struct{}, runtime.NumCPU())
+
+// imageGroup - 同一缩略图同时只生成一次，等待的请求共用结果
+var imageGroup singleflight.Group
+
+// Image - 获取缩略图 - 优先读取缓存，没有则处理后写入缓存
+/**
+ * @param etag 原图的 ETag，原图变化后缓存自动失效
+ * @param options 处理参数
+ * @param source 读取原图
+ * @return 缩略图内容
+ * @example：
+ * body, err := facade.Image(item.Object.ETag, options, func() ([]byte, error) { return os.ReadFile(name) })
+ */
+func Image(etag string, options *ImageOptions, source func() ([]byte, error)) ([]byte, error) {
+
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(etag+"|"+options.Key())))
+
+	if body, ok := imageCacheGet(key, options.Format); ok {
+		return body, nil
+	}
+
+	result, err, _ := imageGroup.Do(key, func() (any, error) {
+
+		// 读取缓存和进入 Do 之间可能已由其他请求生成
+		if body, ok := imageCacheGet(key, options.Format); ok {
+			return body, nil
+		}
+
+		imageLimit <- struct{}{}
+		defer func() { <-imageLimit }()
+
+		body, err := source()
+		if err != nil {
+			return nil, err
+		}
+
+		if body, err = options.Process(body); err != nil {
+			return nil, err
+		}
+
+		imageCacheSet(key, options.Format, body)
+
+		return body, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]byte), nil
+}
+
+// imageCacheKey - 缓存在存储驱动中的路径
+func imageCacheKey(key, format string) string {
+	return fmt.Sprintf("cache/image/%s/%s.%s", key[:2], key, format)
+}
+
+// imageRuntime - runtime 缓存的目录
+var imageRuntime = filepath.Join("runtime", "image")
+
+// imageRuntimeSize - runtime 缓存的大致大小 - 首次写入时统计，-1 为未统计
+var imageRuntimeSize atomic.Int64
+
+// imageRuntimeGC - 是否正在清理 runtime 缓存
+var imageRuntimeGC atomic.Bool
+
+func init() {
+	imageRuntimeSize.Store(-1)
+}
+
+// imageCacheGet - 读取缓存
+func imageCacheGet(key, format string) ([]byte, bool) {
+
+	switch cast.ToString(StorageToml.Get("image.cache", ImageCacheRuntime)) {
+	case ImageCacheRuntime:
+		name := filepath.Join(imageRuntime, key[:2], key+"."+format)
+		body, err := os.ReadFile(name)
+		if err != nil {
+			return nil, false
+		}
+		// 更新修改时间 - 清理时最久未访问的优先删除，一小时内只更新一次
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > time.Hour {
+			now := time.Now()
+			_ = os.Chtimes(name, now, now)
+		}
+		return body, true
+	case ImageCacheStorage:
+		item := Storage.Get(imageCacheKey(key, format))
+		if item.Error != nil {
+			return nil, false
+		}
+		defer func(reader io.ReadCloser) {
+			_ = reader.Close()
+		}(item.Reader)
+		body, err := io.ReadAll(item.Reader)
+		return body, err == nil
+	}
+
+	return nil, false
+}
+
+// imageCacheSet - 写入缓存
+func imageCacheSet(key, format string, body []byte) {
+
+	var err error
+
+	switch cast.ToString(StorageToml.Get("image.cache", ImageCacheRuntime)) {
+	case ImageCacheRuntime:
+		name := filepath.Join(imageRuntime, key[:2], key+"."+format)
+		if err = os.MkdirAll(filepath.Dir(name), 0755); err == nil {
+			err = os.WriteFile(name, body, 0644)
+		}
+		if err == nil {
+			imageRuntimeAdd(int64(len(body)))
+		}
+	case ImageCacheStorage:
+		name := imageCacheKey(key, format)
+		// 本地存储的上传路径需要带上 public 目录
+		if Storage == LocalStorage {
+			name = "public/" + name
+		}
+		err = Storage.Upload(name, bytes.NewReader(body)).Error
+	}
+
+	if err != nil {
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "缩略图缓存写入失败")
+	}
+}
+
+// imageRuntimeAdd - 记录 runtime 缓存写入的大小，超出 image.cache_size 时在后台清理
+func imageRuntimeAdd(size int64) {
+
+	limit := cast.ToInt64(StorageToml.Get("image.cache_size", 1024)) << 20
+	if limit <= 0 {
+		return
+	}
+
+	// 首次写入 - 统计已有的缓存（包括上次运行留下的）
+	if imageRuntimeSize.Load() < 0 && imageRuntimeSize.CompareAndSwap(-1, 0) {
+		total, _ := imageRuntimeWalk(nil)
+		imageRuntimeSize.Add(total)
+	}
+
+	if imageRuntimeSize.Add(size) <= limit || !imageRuntimeGC.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer imageRuntimeGC.Store(false)
+		if err := ImageRuntimeGC(limit); err != nil {
+			Log.Error(map[string]any{
+				"error":     err,
+				"func_name": utils.Caller().FuncName,
+				"file_name": utils.Caller().FileName,
+				"file_line": utils.Caller().Line,
+			}, "缩略图缓存清理错误")
+		}
+	}()
+}
+
+// ImageRuntimeGC - 清理 runtime 缓存 - 最久未访问的优先删除，直到不超过 limit 的 80%
+/**
+ * @param limit 缓存大小上限（字节）
+ * @return error
+ */
+func ImageRuntimeGC(limit int64) error {
+
+	type item struct {
+		path string
+		size int64
+		time time.Time
+	}
+
+	var items []item
+	total, err := imageRuntimeWalk(func(path string, info os.FileInfo) {
+		items = append(items, item{path: path, size: info.Size(), time: info.ModTime()})
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].time.Before(items[j].time)
+	})
+
+	for _, value := range items {
+		if total <= limit/10*8 {
+			break
+		}
+		if os.Remove(value.path) != nil {
+			continue
+		}
+		total -= value.size
+	}
+
+	imageRuntimeSize.Store(total)
+
+	return nil
+}
+
+// imageRuntimeWalk - 遍历 runtime 缓存文件
+/**
+ * @param fn 每个文件的回调，可以为 nil
+ * @return 文件总大小
+ */
+func imageRuntimeWalk(fn func(path string, info os.FileInfo)) (total int64, err error) {
+
+	err = filepath.Walk(imageRuntime, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		total += info.Size()
+		if fn != nil {
+			fn(path, info)
+		}
+		return nil
+	})
+
+	return total, err
+}
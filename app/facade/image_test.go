@@ -0,0 +1,109 @@
+package facade
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testGif - 生成指定画布大小、帧数的动图，每帧只有 1 像素
+func testGif(t *testing.T, width, height, frames int) []byte {
+
+	palette := color.Palette{color.Black, color.White}
+	item    := &gif.GIF{Config: image.Config{ColorModel: palette, Width: width, Height: height}}
+	for i := 0; i < frames; i++ {
+		item.Image = append(item.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), palette))
+		item.Delay = append(item.Delay, 10)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := gif.EncodeAll(buffer, item); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestGifFrames(t *testing.T) {
+
+	for _, frames := range []int{1, 2, 300} {
+		count, err := gifFrames(testGif(t, 10, 10, frames))
+		if err != nil || count != frames {
+			t.Fatalf("帧数错误：%d != %d %v", count, frames, err)
+		}
+	}
+
+	body := testGif(t, 10, 10, 3)
+	for name, item := range map[string][]byte{
+		"空内容":   nil,
+		"缺少结束符": body[:len(body)-1],
+		"截断":    body[:20],
+		"未知数据块": append(append([]byte{}, body[:len(body)-1]...), 0x99),
+	} {
+		if _, err := gifFrames(item); err == nil {
+			t.Fatalf("%s 应当返回错误", name)
+		}
+	}
+}
+
+func TestImageFramesLimit(t *testing.T) {
+
+	// 1000x1000 的画布 50 帧，超过默认的 4000 万像素
+	body := testGif(t, 1000, 1000, 50)
+
+	if _, err := (&ImageOptions{Format: "gif", Quality: 75}).Process(body); err == nil {
+		t.Fatal("缩略图应当拒绝帧数过多的动图")
+	}
+	if _, err := (&ImageOptions{Format: "webp", Quality: 75}).Process(body); err != ErrImageAnimated {
+		t.Fatalf("动图转 webp 应当返回 ErrImageAnimated：%v", err)
+	}
+	if _, err := (&UploadPolicyStruct{}).Sanitize("gif", body); err == nil {
+		t.Fatal("上传应当拒绝帧数过多的动图")
+	}
+
+	// 未超过限制的动图正常处理
+	if _, err := (&ImageOptions{Format: "gif", Quality: 75}).Process(testGif(t, 100, 100, 5)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageOnce(t *testing.T) {
+
+	buffer := new(bytes.Buffer)
+	if err := png.Encode(buffer, image.NewRGBA(image.Rect(0, 0, 20, 20))); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一缩略图的并发请求只读取、处理一次原图
+	var count atomic.Int32
+	source := func() ([]byte, error) {
+		count.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return buffer.Bytes(), nil
+	}
+
+	// 每次运行使用不同的 ETag，不命中之前生成的缓存
+	etag := fmt.Sprintf("once-%d", time.Now().UnixNano())
+
+	var wait sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if _, err := Image(etag, &ImageOptions{Width: 10, Height: 10, Format: "png", Quality: 75}, source); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wait.Wait()
+
+	if count.Load() != 1 {
+		t.Fatalf("原图处理了 %d 次", count.Load())
+	}
+}
@@ -0,0 +1,16 @@
+//go:build cgo
+
+package facade
+
+import (
+	"github.com/chai2010/webp"
+	"image"
+	"io"
+)
+
+// WebP 编码依赖 libwebp（cgo），CGO_ENABLED=0 编译时不支持 webp 输出，format=auto 会回退为原图格式
+func init() {
+	imageWebP = func(writer io.Writer, item image.Image, quality int) error {
+		return webp.Encode(writer, item, &webp.Options{Quality: float32(quality)})
+	}
+}
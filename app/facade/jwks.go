@@ -0,0 +1,369 @@
+package facade
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	JWT "github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// JwtHS256 - 对称签名，使用 jwt.key
+	JwtHS256 = "HS256"
+	// JwtRS256 - RSA 2048
+	JwtRS256 = "RS256"
+	// JwtES256 - ECDSA P-256
+	JwtES256 = "ES256"
+	// JwtEdDSA - Ed25519
+	JwtEdDSA = "EdDSA"
+)
+
+// jwtMethods - 非对称签名算法
+var jwtMethods = map[string]JWT.SigningMethod{
+	JwtRS256: JWT.SigningMethodRS256,
+	JwtES256: JWT.SigningMethodES256,
+	JwtEdDSA: JWT.SigningMethodEdDSA,
+}
+
+// JwtKey - 非对称签名密钥
+type JwtKey struct {
+	// 密钥ID - 即文件名（不含 .pem）
+	Kid       string
+	// 签名算法
+	Algorithm string
+	// 私钥
+	Private   crypto.Signer
+	// 创建时间 - 即文件修改时间
+	Created   time.Time
+}
+
+// Method - 签名方法
+func (this *JwtKey) Method() JWT.SigningMethod {
+	return jwtMethods[this.Algorithm]
+}
+
+// Public - 公钥
+func (this *JwtKey) Public() crypto.PublicKey {
+	return this.Private.Public()
+}
+
+// JWK - 公钥的 JWK 格式
+func (this *JwtKey) JWK() map[string]any {
+
+	encode := base64.RawURLEncoding.EncodeToString
+	result := map[string]any{"kid": this.Kid, "alg": this.Algorithm, "use": "sig"}
+
+	switch item := this.Public().(type) {
+	case *rsa.PublicKey:
+		result["kty"] = "RSA"
+		result["n"]   = encode(item.N.Bytes())
+		result["e"]   = encode(big.NewInt(int64(item.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (item.Curve.Params().BitSize + 7) / 8
+		result["kty"] = "EC"
+		result["crv"] = item.Curve.Params().Name
+		result["x"]   = encode(item.X.FillBytes(make([]byte, size)))
+		result["y"]   = encode(item.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		result["kty"] = "OKP"
+		result["crv"] = "Ed25519"
+		result["x"]   = encode(item)
+	}
+
+	return result
+}
+
+// JwtKeysStruct - 密钥集 - jwt.keys 目录下的 PEM 私钥，最新的（或 jwt.kid 指定的）用于签名，其余只用于验证
+type JwtKeysStruct struct {
+	// 已加载的密钥 - 按创建时间从新到旧
+	keys    []*JwtKey
+	// 加载时目录的修改时间 - 目录变化（增删密钥）后自动重新加载
+	modTime time.Time
+	mutex   sync.RWMutex
+}
+
+// JwtKeys - 密钥集实例
+/**
+ * @example：
+ * key, err := facade.JwtKeys.Active()
+ * jwks := facade.JwtKeys.JWKS()
+ */
+var JwtKeys = &JwtKeysStruct{}
+
+// Algorithm - 配置的签名算法
+func (this *JwtKeysStruct) Algorithm() string {
+
+	value := strings.ToUpper(cast.ToString(CryptToml.Get("jwt.algorithm", JwtHS256)))
+	if value == strings.ToUpper(JwtEdDSA) {
+		return JwtEdDSA
+	}
+
+	return value
+}
+
+// dir - 密钥目录
+func (this *JwtKeysStruct) dir() string {
+	return cast.ToString(CryptToml.Get("jwt.keys", "config/jwt"))
+}
+
+// Keys - 全部密钥 - 按创建时间从新到旧
+func (this *JwtKeysStruct) Keys() []*JwtKey {
+
+	info, err := os.Stat(this.dir())
+	if err != nil {
+		return nil
+	}
+
+	this.mutex.RLock()
+	if info.ModTime().Equal(this.modTime) {
+		defer this.mutex.RUnlock()
+		return this.keys
+	}
+	this.mutex.RUnlock()
+
+	keys := this.load()
+
+	this.mutex.Lock()
+	this.keys, this.modTime = keys, info.ModTime()
+	this.mutex.Unlock()
+
+	return keys
+}
+
+// load - 读取目录下的密钥
+func (this *JwtKeysStruct) load() (keys []*JwtKey) {
+
+	files, err := filepath.Glob(filepath.Join(this.dir(), "*.pem"))
+	if err != nil {
+		return nil
+	}
+
+	for _, file := range files {
+		key, err := this.read(file)
+		if err != nil {
+			Log.Error(map[string]any{
+				"error":     err,
+				"file":      file,
+				"func_name": utils.Caller().FuncName,
+				"file_name": utils.Caller().FileName,
+				"file_line": utils.Caller().Line,
+			}, "JWT密钥读取失败")
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].Created.After(keys[j].Created)
+	})
+
+	return keys
+}
+
+// read - 读取 PEM 私钥
+func (this *JwtKeysStruct) read(file string) (*JwtKey, error) {
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("不是有效的 PEM 文件")
+	}
+
+	item, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &JwtKey{
+		Kid:     strings.TrimSuffix(filepath.Base(file), ".pem"),
+		Created: info.ModTime(),
+	}
+
+	switch value := item.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm, key.Private = JwtRS256, value
+	case *ecdsa.PrivateKey:
+		if value.Curve != elliptic.P256() {
+			return nil, errors.New("ECDSA 只支持 P-256")
+		}
+		key.Algorithm, key.Private = JwtES256, value
+	case ed25519.PrivateKey:
+		key.Algorithm, key.Private = JwtEdDSA, value
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型：%T", item)
+	}
+
+	return key, nil
+}
+
+// Find - 按 kid 查找密钥
+func (this *JwtKeysStruct) Find(kid string) (*JwtKey, bool) {
+	for _, key := range this.Keys() {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Active - 当前用于签名的密钥 - jwt.kid 指定的，或配置算法下最新的
+/**
+ * 没有密钥时不自动生成：多实例部署时各自生成的密钥互不认识，签发的令牌在其他实例上无法验证，
+ * 需要执行 jwt:generate 生成后，把密钥目录共享给所有实例
+ */
+func (this *JwtKeysStruct) Active() (*JwtKey, error) {
+
+	algorithm := this.Algorithm()
+	if _, ok := jwtMethods[algorithm]; !ok {
+		return nil, fmt.Errorf("不支持的签名算法：%s", algorithm)
+	}
+
+	if kid := cast.ToString(CryptToml.Get("jwt.kid")); !utils.Is.Empty(kid) {
+		key, ok := this.Find(kid)
+		if !ok {
+			return nil, fmt.Errorf("JWT密钥不存在：%s", kid)
+		}
+		return key, nil
+	}
+
+	for _, key := range this.Keys() {
+		if key.Algorithm == algorithm {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("没有 %s 签名密钥，请执行 jwt:generate 生成，多实例部署时需要共享 %s 目录", algorithm, this.dir())
+}
+
+// Generate - 生成新密钥 - 生成后即成为配置算法下最新的签名密钥
+/**
+ * @param algorithm 签名算法 - RS256、ES256、EdDSA
+ * @return *JwtKey, error
+ */
+func (this *JwtKeysStruct) Generate(algorithm string) (*JwtKey, error) {
+
+	var item crypto.Signer
+	var err error
+
+	switch algorithm {
+	case JwtRS256:
+		item, err = rsa.GenerateKey(rand.Reader, 2048)
+	case JwtES256:
+		item, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case JwtEdDSA:
+		_, item, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("不支持的签名算法：%s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := x509.MarshalPKCS8PrivateKey(item)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(this.dir(), 0700); err != nil {
+		return nil, err
+	}
+
+	kid  := fmt.Sprintf("%s-%s", strings.ToLower(algorithm), time.Now().Format("20060102150405"))
+	file := filepath.Join(this.dir(), kid+".pem")
+
+	// 同一秒内重复生成时追加序号
+	for i := 2; utils.File().Exist(file); i++ {
+		file = filepath.Join(this.dir(), fmt.Sprintf("%s-%d.pem", kid, i))
+	}
+
+	if err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: body}), 0600); err != nil {
+		return nil, err
+	}
+
+	return this.read(file)
+}
+
+// Prune - 删除已停用且不再需要的密钥 - 停用（被更新的密钥替代）超过令牌的最长有效期后，用它签发的令牌都已过期
+/**
+ * @return []string 删除的 kid
+ */
+func (this *JwtKeysStruct) Prune() (result []string, err error) {
+
+	// HS256 时没有签名密钥，全部密钥都只用于验证
+	active := &JwtKey{}
+	if this.Algorithm() != JwtHS256 {
+		if active, err = this.Active(); err != nil {
+			return nil, err
+		}
+	}
+
+	expire := time.Duration(this.lifetime()) * time.Second
+	keys   := this.Keys()
+
+	for i, key := range keys {
+		// 最新的密钥、当前签名密钥不删除
+		if i == 0 || key.Kid == active.Kid {
+			continue
+		}
+		// 停用时间为下一个更新的密钥的创建时间
+		if time.Since(keys[i-1].Created) < expire {
+			continue
+		}
+		if err = os.Remove(filepath.Join(this.dir(), key.Kid+".pem")); err != nil {
+			return result, err
+		}
+		result = append(result, key.Kid)
+	}
+
+	return result, nil
+}
+
+// lifetime - 签发的令牌中最长的有效期（秒） - 密钥停用后至少保留这么久，期间签发的令牌才能验证到过期
+func (this *JwtKeysStruct) lifetime() int64 {
+
+	result := cast.ToInt64(utils.Calc(CryptToml.Get("jwt.expire", "7200")))
+	for _, item := range []int64{JwtAccessExpire(), JwtRefreshExpire()} {
+		if item > result {
+			result = item
+		}
+	}
+
+	return result
+}
+
+// JWKS - 公钥集 - 发布在 /.well-known/jwks.json，供其他服务验证令牌
+func (this *JwtKeysStruct) JWKS() map[string]any {
+
+	keys := make([]map[string]any, 0)
+	for _, key := range this.Keys() {
+		keys = append(keys, key.JWK())
+	}
+
+	return map[string]any{"keys": keys}
+}
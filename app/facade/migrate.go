@@ -0,0 +1,375 @@
+package facade
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/unti-io/go-utils/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StorageModes - 全部存储驱动
+var StorageModes = []string{StorageModeLocal, StorageModeOSS, StorageModeCOS, StorageModeKODO, StorageModeS3}
+
+// StorageMigrateState - 迁移进度 - 保存在 runtime/storage 下，中断后再次执行会从上次的位置继续
+type StorageMigrateState struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Prefix     string            `json:"prefix"`
+	// 已处理完的最后一页的游标
+	Cursor     string            `json:"cursor"`
+	// 源驱动是否已全部列出 - 之后只需重试失败的对象
+	Listed     bool              `json:"listed"`
+	Total      int               `json:"total"`
+	Copied     int               `json:"copied"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Size       int64             `json:"size"`
+	// 失败的对象 - key => 错误信息，再次执行时优先重试
+	Failures   map[string]string `json:"failures"`
+	Running    bool              `json:"running"`
+	Done       bool              `json:"done"`
+	Error      string            `json:"error"`
+	StartTime  int64             `json:"start_time"`
+	UpdateTime int64             `json:"update_time"`
+}
+
+// storageReader - 已知大小的 Reader - 目标驱动可以据此直接上传，无需分片
+type storageReader struct {
+	io.Reader
+	size int64
+}
+
+// Len - 剩余大小
+func (this *storageReader) Len() int {
+	return int(this.size)
+}
+
+// StorageMigrateStruct - 存储迁移
+type StorageMigrateStruct struct {
+	// 并发数
+	Concurrency int
+	// 每处理完一个对象的回调
+	Progress    func(key string, skipped bool, err error)
+	state       StorageMigrateState
+	mutex       sync.Mutex
+}
+
+// NewStorageMigrate - 创建存储迁移任务
+/**
+ * @param from 源驱动
+ * @param to 目标驱动
+ * @return *StorageMigrateStruct, error
+ * @example：
+ * migrate, err := facade.NewStorageMigrate("oss", "s3")
+ * state, err := migrate.Run(context.Background())
+ */
+func NewStorageMigrate(from, to string) (*StorageMigrateStruct, error) {
+
+	from, to = strings.ToLower(from), strings.ToLower(to)
+
+	for _, mode := range []string{from, to} {
+		if !utils.InArray(mode, StorageModes) {
+			return nil, fmt.Errorf("不支持的存储驱动：%s", mode)
+		}
+	}
+
+	if from == to {
+		return nil, errors.New("源驱动和目标驱动不能相同")
+	}
+
+	for _, mode := range []string{from, to} {
+		if mode == StorageModeS3 && (S3 == nil || S3.Client == nil) {
+			return nil, errors.New("S3 存储未配置")
+		}
+	}
+
+	this := &StorageMigrateStruct{Concurrency: 4}
+	this.state = StorageMigrateState{From: from, To: to, Prefix: "storage/"}
+
+	// 读取上次的进度
+	if body, err := os.ReadFile(this.file()); err == nil {
+		var state StorageMigrateState
+		if json.Unmarshal(body, &state) == nil && !state.Done {
+			state.Running = false
+			this.state = state
+		}
+	}
+
+	if this.state.Failures == nil {
+		this.state.Failures = make(map[string]string)
+	}
+
+	return this, nil
+}
+
+// file - 进度文件
+func (this *StorageMigrateStruct) file() string {
+	return filepath.Join("runtime", "storage", fmt.Sprintf("migrate-%s-%s.json", this.state.From, this.state.To))
+}
+
+// save - 保存进度
+func (this *StorageMigrateStruct) save() {
+
+	this.mutex.Lock()
+	this.state.UpdateTime = time.Now().Unix()
+	body, err := json.Marshal(this.state)
+	this.mutex.Unlock()
+
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(this.file()), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(this.file(), body, 0644)
+	}
+
+	if err != nil {
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "存储迁移进度保存失败")
+	}
+}
+
+// State - 当前进度
+func (this *StorageMigrateStruct) State() StorageMigrateState {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	state := this.state
+	state.Failures = make(map[string]string, len(this.state.Failures))
+	for key, value := range this.state.Failures {
+		state.Failures[key] = value
+	}
+
+	return state
+}
+
+// Reset - 清除进度，下次从头开始
+func (this *StorageMigrateStruct) Reset() error {
+
+	this.mutex.Lock()
+	this.state = StorageMigrateState{From: this.state.From, To: this.state.To, Prefix: this.state.Prefix, Failures: make(map[string]string)}
+	this.mutex.Unlock()
+
+	if err := os.Remove(this.file()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Run - 执行迁移 - 目标中已存在且大小相同的对象会跳过，复制后读回校验 sha256
+/**
+ * @param ctx 取消后在当前页处理完后停止，进度会保留
+ * @return StorageMigrateState, error
+ */
+func (this *StorageMigrateStruct) Run(ctx context.Context) (StorageMigrateState, error) {
+
+	this.mutex.Lock()
+	if this.state.StartTime == 0 {
+		this.state.StartTime = time.Now().Unix()
+	}
+	this.state.Running = true
+	this.state.Error   = ""
+	this.mutex.Unlock()
+
+	err := this.run(ctx)
+
+	this.mutex.Lock()
+	this.state.Running = false
+	if err != nil {
+		this.state.Error = err.Error()
+	} else {
+		this.state.Done = this.state.Failed == 0
+	}
+	this.mutex.Unlock()
+
+	this.save()
+
+	return this.State(), err
+}
+
+// run - 先重试上次失败的对象，再从游标处继续列出源驱动的对象
+func (this *StorageMigrateStruct) run(ctx context.Context) error {
+
+	source := StorageDriver(this.state.From)
+
+	// 重试上次失败的对象
+	var retry []StorageObject
+	for key := range this.State().Failures {
+		// 源文件已不存在 - 无需再迁移
+		if exist := source.Exists(key); exist.Error == nil && !exist.Exist {
+			this.mutex.Lock()
+			this.state.Failed--
+			delete(this.state.Failures, key)
+			this.mutex.Unlock()
+			continue
+		}
+		item := source.Stat(key)
+		if item.Error != nil || item.Object == nil {
+			continue
+		}
+		retry = append(retry, *item.Object)
+	}
+	if len(retry) > 0 {
+		this.mutex.Lock()
+		this.state.Total  -= len(retry)
+		this.state.Failed -= len(retry)
+		this.mutex.Unlock()
+		this.batch(retry)
+		this.save()
+	}
+
+	for !this.State().Listed {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		state := this.State()
+		item  := source.List(state.Prefix, state.Cursor, 100)
+		if item.Error != nil {
+			return item.Error
+		}
+
+		this.batch(item.Objects)
+
+		this.mutex.Lock()
+		this.state.Cursor = item.Cursor
+		this.state.Listed = utils.Is.Empty(item.Cursor)
+		this.mutex.Unlock()
+		this.save()
+	}
+
+	return nil
+}
+
+// batch - 并发复制一页对象
+func (this *StorageMigrateStruct) batch(objects []StorageObject) {
+
+	concurrency := this.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	wg    := sync.WaitGroup{}
+	queue := make(chan StorageObject)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range queue {
+				skipped, err := this.copy(object)
+				this.done(object, skipped, err)
+			}
+		}()
+	}
+
+	for _, object := range objects {
+		queue <- object
+	}
+	close(queue)
+
+	wg.Wait()
+}
+
+// done - 记录单个对象的结果
+func (this *StorageMigrateStruct) done(object StorageObject, skipped bool, err error) {
+
+	this.mutex.Lock()
+	this.state.Total++
+	switch {
+	case err != nil:
+		this.state.Failed++
+		this.state.Failures[object.Key] = err.Error()
+	case skipped:
+		this.state.Skipped++
+		delete(this.state.Failures, object.Key)
+	default:
+		this.state.Copied++
+		this.state.Size += object.Size
+		delete(this.state.Failures, object.Key)
+	}
+	this.mutex.Unlock()
+
+	if this.Progress != nil {
+		this.Progress(object.Key, skipped, err)
+	}
+}
+
+// copy - 复制单个对象
+func (this *StorageMigrateStruct) copy(object StorageObject) (skipped bool, err error) {
+
+	source := StorageDriver(this.state.From)
+	target := StorageDriver(this.state.To)
+
+	// 目标中已存在且大小相同 - 跳过
+	if item := target.Stat(object.Key); item.Error == nil && item.Object != nil && item.Object.Size == object.Size {
+		return true, nil
+	}
+
+	item := source.Get(object.Key)
+	if item.Error != nil {
+		return false, item.Error
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(item.Reader)
+
+	// 边读边计算源文件的哈希
+	sum := sha256.New()
+
+	// 本地存储的上传路径需要带上 public 目录
+	key := object.Key
+	if this.state.To == StorageModeLocal {
+		key = "public/" + key
+	}
+
+	reader := &storageReader{Reader: io.TeeReader(item.Reader, sum), size: object.Size}
+	if result := target.Upload(key, reader); result.Error != nil {
+		return false, result.Error
+	}
+
+	hash, err := this.hash(target, object.Key)
+	if err != nil {
+		return false, err
+	}
+
+	if hash != fmt.Sprintf("%x", sum.Sum(nil)) {
+		target.Delete(object.Key)
+		return false, errors.New("校验失败，目标文件与源文件不一致")
+	}
+
+	return false, nil
+}
+
+// hash - 读回目标对象计算 sha256
+func (this *StorageMigrateStruct) hash(store StorageInterface, key string) (string, error) {
+
+	item := store.Get(key)
+	if item.Error != nil {
+		return "", item.Error
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(item.Reader)
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, item.Reader); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sum.Sum(nil)), nil
+}
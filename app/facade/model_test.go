@@ -0,0 +1,121 @@
+// model 的测试 - facade 初始化时需要 config 目录，放在这里才能使用 facade_test.go 中切换的临时目录
+package facade_test
+
+import (
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"inis/app/facade"
+	"inis/app/model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDB - 每个测试独立的 SQLite 内存数据库 - 命名规则与 InitMySQL 一致，结束后还原 facade.DB
+func testDB(t *testing.T, models ...any) *gorm.DB {
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	conn, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{TablePrefix: "inis_", SingularTable: true},
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+
+	// SQLite 只有 INTEGER PRIMARY KEY 才自增，模型的主键是 int(32)，建表后替换（连同索引重建）
+	var tables []struct{ Name, Sql string }
+	conn.Raw("SELECT name, sql FROM sqlite_master WHERE type = 'table' AND sql LIKE '%`id` int(32)%'").Scan(&tables)
+	for _, item := range tables {
+		var indexes []string
+		conn.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", item.Name).Scan(&indexes)
+		conn.Exec(fmt.Sprintf("DROP TABLE `%s`", item.Name))
+		conn.Exec(strings.Replace(item.Sql, "`id` int(32)", "`id` integer", 1))
+		for _, index := range indexes {
+			conn.Exec(index)
+		}
+	}
+
+	db := facade.DB
+	facade.DB = &facade.MySqlStruct{Conn: conn}
+	t.Cleanup(func() {
+		facade.DB = db
+		if value, err := conn.DB(); err == nil {
+			_ = value.Close()
+		}
+	})
+
+	return conn
+}
+
+// testCacheOpen - 开启缓存（内存），结束后还原
+func testCacheOpen(t *testing.T) {
+
+	cache, open := facade.Cache, facade.CacheToml.Get("open")
+	facade.Cache = facade.BigCache
+	facade.CacheToml.Viper.Set("open", true)
+	facade.Cache.Clear()
+
+	t.Cleanup(func() {
+		facade.Cache.Clear()
+		facade.Cache = cache
+		facade.CacheToml.Viper.Set("open", open)
+	})
+}
+
+// testCodec - 依次使用每种序列化方式执行 fn
+func testCodec(t *testing.T, fn func(t *testing.T)) {
+
+	cache, codec := facade.Cache, facade.CacheCodec
+	defer func() {
+		facade.Cache, facade.CacheCodec = cache, codec
+	}()
+	facade.Cache = facade.BigCache
+
+	for _, name := range []string{facade.CacheCodecJSON, facade.CacheCodecMsgpack, facade.CacheCodecGob} {
+		facade.CacheCodec = facade.NewCacheCodec(name)
+		t.Run(name, fn)
+	}
+}
+
+// testRoundTrip - 写入缓存再读出，与原值比较
+func testRoundTrip[T any](t *testing.T, value T) {
+
+	t.Helper()
+
+	if !facade.CacheSet("codec[test]", value) {
+		t.Fatalf("%T 写入缓存失败", value)
+	}
+
+	result, ok := facade.CacheGet[T]("codec[test]")
+	if !ok {
+		t.Fatalf("%T 读取缓存失败", value)
+	}
+	if !reflect.DeepEqual(result, value) {
+		t.Fatalf("%T 读出的值不一致：\n%#v\n%#v", value, result, value)
+	}
+}
+
+// TestCacheCodec - 实际缓存的类型在每种序列化方式下都能原样读出
+func TestCacheCodec(t *testing.T) {
+	testCodec(t, func(t *testing.T) {
+
+		testRoundTrip(t, model.Users{
+			Id: 1, Account: "admin", Nickname: "管理员", Email: "admin@example.com", Phone: "13800000000",
+			Stamp: "stamp", Exp: 10, LoginTime: 1700000000,
+			Json:   map[string]any{"theme": "dark", "tags": []any{"a", "b"}, "nested": map[string]any{"level": "2"}},
+			Text:   "text",
+			Result: map[string]any{},
+		})
+		testRoundTrip(t, model.Sessions{Id: 1, Uid: 1, Jti: "jti", Ip: "127.0.0.1", ExpireTime: 1700000000})
+		testRoundTrip(t, []string{"comm:*", "users:one"})
+		testRoundTrip(t, int64(1700000000))
+		testRoundTrip(t, facade.ThrottleState{Fails: 3, Until: 1700000000, Lock: true})
+	})
+}
@@ -0,0 +1,655 @@
+package facade
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+
+	// 初始化配置文件
+	initOAuthToml()
+
+	if OAuthToml == nil {
+		return
+	}
+
+	// 监听配置文件变化
+	OAuthToml.Viper.WatchConfig()
+	// 配置文件变化时，清除发现文档缓存
+	OAuthToml.Viper.OnConfigChange(func(event fsnotify.Event) {
+		oauthDiscovery.Range(func(key, value any) bool {
+			oauthDiscovery.Delete(key)
+			return true
+		})
+	})
+}
+
+const (
+	// OAuthGitHub - GitHub
+	OAuthGitHub = "github"
+	// OAuthGoogle - Google（OIDC）
+	OAuthGoogle = "google"
+	// OAuthQQ - QQ互联
+	OAuthQQ     = "qq"
+	// OAuthWeChat - 微信开放平台
+	OAuthWeChat = "wechat"
+	// OAuthOIDC - 通用 OIDC
+	OAuthOIDC   = "oidc"
+)
+
+// oauthEndpoints - 内置的授权、令牌、用户信息地址 - OIDC 通过发现文档获取
+var oauthEndpoints = map[string][3]string{
+	OAuthGitHub: {"https://github.com/login/oauth/authorize", "https://github.com/login/oauth/access_token", "https://api.github.com/user"},
+	OAuthQQ:     {"https://graph.qq.com/oauth2.0/authorize", "https://graph.qq.com/oauth2.0/token", "https://graph.qq.com/user/get_user_info"},
+	OAuthWeChat: {"https://open.weixin.qq.com/connect/qrconnect", "https://api.weixin.qq.com/sns/oauth2/access_token", "https://api.weixin.qq.com/sns/userinfo"},
+}
+
+// oauthDiscovery - OIDC 发现文档缓存 - issuer => *oauthDocument
+var oauthDiscovery sync.Map
+
+// oauthDocument - OIDC 发现文档
+type oauthDocument struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	UserURL  string `json:"userinfo_endpoint"`
+	// 缓存过期时间
+	expire   time.Time
+}
+
+// oauthClient - 请求第三方平台的客户端
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// OAuthToml - 第三方登录配置文件
+var OAuthToml *utils.ViperResponse
+
+// initOAuthToml - 初始化第三方登录配置文件
+func initOAuthToml() {
+
+	item := utils.Viper(utils.ViperModel{
+		Path: "config",
+		Mode: "toml",
+		Name: "oauth",
+		Content: utils.Replace(TempOAuth, map[string]any{
+			"${redirect}":   "",
+			"${link_email}": "false",
+		}),
+	}).Read()
+
+	if item.Error != nil {
+		Log.Error(map[string]any{
+			"error":     item.Error,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "OAuth配置初始化错误")
+		return
+	}
+
+	OAuthToml = &item
+}
+
+// OAuthProvider - 第三方登录平台
+type OAuthProvider struct {
+	// 名称 - 即 provider 参数，也是 Users.Source 和绑定记录中的平台
+	Name         string
+	// 类型 - github、google、qq、wechat、oidc
+	Kind         string
+	ClientId     string
+	ClientSecret string
+	Scope        string
+	// OIDC 签发者 - 用于发现文档
+	Issuer       string
+	// 授权、令牌、用户信息地址 - 为空时使用内置地址或发现文档
+	AuthURL      string
+	TokenURL     string
+	UserURL      string
+}
+
+// OAuthToken - 令牌响应
+type OAuthToken struct {
+	AccessToken string
+	IdToken     string
+	// QQ、微信的用户标识
+	OpenId      string
+	UnionId     string
+}
+
+// OAuthProfile - 第三方用户信息
+type OAuthProfile struct {
+	// 平台
+	Provider      string         `json:"provider"`
+	// 平台内的唯一标识 - 不会变化，用于绑定
+	Subject       string         `json:"subject"`
+	Email         string         `json:"email"`
+	// 邮箱是否已由平台验证 - 只有已验证的邮箱才会用于自动绑定
+	EmailVerified bool           `json:"email_verified"`
+	Nickname      string         `json:"nickname"`
+	Avatar        string         `json:"avatar"`
+	// 原始数据
+	Raw           map[string]any `json:"raw"`
+}
+
+// OAuthProviders - 已开启的平台
+func OAuthProviders() (result []*OAuthProvider) {
+
+	if OAuthToml == nil {
+		return nil
+	}
+
+	for _, name := range []string{OAuthGitHub, OAuthGoogle, OAuthQQ, OAuthWeChat} {
+		if item := oauthProvider(name, name, cast.ToStringMap(OAuthToml.Get(name))); item != nil {
+			result = append(result, item)
+		}
+	}
+
+	for _, value := range cast.ToSlice(OAuthToml.Viper.Get("oidc")) {
+		config := cast.ToStringMap(value)
+		if item := oauthProvider(strings.ToLower(cast.ToString(config["name"])), OAuthOIDC, config); item != nil {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// oauthProvider - 读取平台配置 - 未开启或未配置 client_id 时为 nil
+func oauthProvider(name, kind string, config map[string]any) *OAuthProvider {
+
+	if utils.Is.Empty(name) || !cast.ToBool(config["enable"]) || utils.Is.Empty(config["client_id"]) {
+		return nil
+	}
+
+	item := &OAuthProvider{
+		Name:         name,
+		Kind:         kind,
+		ClientId:     cast.ToString(config["client_id"]),
+		ClientSecret: cast.ToString(config["client_secret"]),
+		Scope:        cast.ToString(config["scope"]),
+		Issuer:       strings.TrimSuffix(cast.ToString(config["issuer"]), "/"),
+		AuthURL:      cast.ToString(config["auth_url"]),
+		TokenURL:     cast.ToString(config["token_url"]),
+		UserURL:      cast.ToString(config["user_url"]),
+	}
+
+	// Google 即预设了 issuer 的 OIDC
+	if kind == OAuthGoogle {
+		item.Kind = OAuthOIDC
+		if utils.Is.Empty(item.Issuer) {
+			item.Issuer = "https://accounts.google.com"
+		}
+	}
+
+	if endpoints, ok := oauthEndpoints[kind]; ok {
+		item.AuthURL  = utils.Ternary(utils.Is.Empty(item.AuthURL), endpoints[0], item.AuthURL)
+		item.TokenURL = utils.Ternary(utils.Is.Empty(item.TokenURL), endpoints[1], item.TokenURL)
+		item.UserURL  = utils.Ternary(utils.Is.Empty(item.UserURL), endpoints[2], item.UserURL)
+	}
+
+	return item
+}
+
+// NewOAuth - 获取已开启的平台
+/**
+ * @param name 平台名称
+ * @return *OAuthProvider, error
+ * @example：
+ * provider, err := facade.NewOAuth("github")
+ */
+func NewOAuth(name string) (*OAuthProvider, error) {
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, item := range OAuthProviders() {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+
+	return nil, fmt.Errorf("未开启的第三方登录：%s", name)
+}
+
+// OAuthRandom - 随机字符串 - 用于 state、nonce 和 PKCE
+func OAuthRandom() string {
+	random := make([]byte, 32)
+	_, _ = rand.Read(random)
+	return base64.RawURLEncoding.EncodeToString(random)
+}
+
+// OAuthChallenge - PKCE 的 code_challenge（S256）
+func OAuthChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// pkce - 是否使用 PKCE - QQ、微信不支持
+func (this *OAuthProvider) pkce() bool {
+	return this.Kind == OAuthGitHub || this.Kind == OAuthOIDC
+}
+
+// discover - 读取 OIDC 发现文档，填充未配置的地址 - 缓存1小时
+func (this *OAuthProvider) discover() error {
+
+	if this.Kind != OAuthOIDC || !utils.Is.Empty(this.AuthURL) && !utils.Is.Empty(this.TokenURL) && !utils.Is.Empty(this.UserURL) {
+		return nil
+	}
+
+	if utils.Is.Empty(this.Issuer) {
+		return fmt.Errorf("%s 未配置 issuer", this.Name)
+	}
+
+	var document *oauthDocument
+	if value, ok := oauthDiscovery.Load(this.Issuer); ok && value.(*oauthDocument).expire.After(time.Now()) {
+		document = value.(*oauthDocument)
+	} else {
+
+		body, err := oauthRequest(http.MethodGet, this.Issuer+"/.well-known/openid-configuration", nil, nil)
+		if err != nil {
+			return err
+		}
+
+		document = &oauthDocument{}
+		if err = json.Unmarshal(body, document); err != nil {
+			return err
+		}
+
+		// 发现文档中的 issuer 必须与配置一致，防止被替换
+		if strings.TrimSuffix(document.Issuer, "/") != this.Issuer {
+			return fmt.Errorf("OIDC issuer 不匹配：%s", document.Issuer)
+		}
+
+		document.expire = time.Now().Add(time.Hour)
+		oauthDiscovery.Store(this.Issuer, document)
+	}
+
+	this.AuthURL  = utils.Ternary(utils.Is.Empty(this.AuthURL), document.AuthURL, this.AuthURL)
+	this.TokenURL = utils.Ternary(utils.Is.Empty(this.TokenURL), document.TokenURL, this.TokenURL)
+	this.UserURL  = utils.Ternary(utils.Is.Empty(this.UserURL), document.UserURL, this.UserURL)
+
+	return nil
+}
+
+// AuthorizeURL - 授权地址
+/**
+ * @param redirect 回调地址
+ * @param state 防 CSRF 的随机值
+ * @param verifier PKCE 的 code_verifier
+ * @param nonce OIDC 的 nonce
+ * @return string, error
+ */
+func (this *OAuthProvider) AuthorizeURL(redirect, state, verifier, nonce string) (string, error) {
+
+	if err := this.discover(); err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("redirect_uri", redirect)
+	query.Set("state", state)
+	if !utils.Is.Empty(this.Scope) {
+		query.Set("scope", this.Scope)
+	}
+
+	if this.Kind == OAuthWeChat {
+		// 微信要求参数顺序固定并以 #wechat_redirect 结尾
+		return fmt.Sprintf("%s?appid=%s&redirect_uri=%s&response_type=code&scope=%s&state=%s#wechat_redirect",
+			this.AuthURL, url.QueryEscape(this.ClientId), url.QueryEscape(redirect), url.QueryEscape(this.Scope), url.QueryEscape(state)), nil
+	}
+
+	query.Set("client_id", this.ClientId)
+
+	if this.pkce() {
+		query.Set("code_challenge", OAuthChallenge(verifier))
+		query.Set("code_challenge_method", "S256")
+	}
+
+	if this.Kind == OAuthOIDC {
+		query.Set("nonce", nonce)
+	}
+
+	return this.AuthURL + utils.Ternary(strings.Contains(this.AuthURL, "?"), "&", "?") + query.Encode(), nil
+}
+
+// Exchange - 用授权码换取令牌
+/**
+ * @param code 授权码
+ * @param verifier PKCE 的 code_verifier
+ * @param redirect 回调地址 - 必须与授权时一致
+ * @return *OAuthToken, error
+ */
+func (this *OAuthProvider) Exchange(code, verifier, redirect string) (*OAuthToken, error) {
+
+	if err := this.discover(); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	var err error
+
+	switch this.Kind {
+	case OAuthWeChat:
+		query := url.Values{}
+		query.Set("appid", this.ClientId)
+		query.Set("secret", this.ClientSecret)
+		query.Set("code", code)
+		query.Set("grant_type", "authorization_code")
+		body, err = oauthRequest(http.MethodGet, this.TokenURL+"?"+query.Encode(), nil, nil)
+	case OAuthQQ:
+		query := url.Values{}
+		query.Set("grant_type", "authorization_code")
+		query.Set("client_id", this.ClientId)
+		query.Set("client_secret", this.ClientSecret)
+		query.Set("code", code)
+		query.Set("redirect_uri", redirect)
+		query.Set("fmt", "json")
+		query.Set("need_openid", "1")
+		body, err = oauthRequest(http.MethodGet, this.TokenURL+"?"+query.Encode(), nil, nil)
+	default:
+		form := url.Values{}
+		form.Set("grant_type", "authorization_code")
+		form.Set("client_id", this.ClientId)
+		form.Set("client_secret", this.ClientSecret)
+		form.Set("code", code)
+		form.Set("redirect_uri", redirect)
+		if this.pkce() {
+			form.Set("code_verifier", verifier)
+		}
+		body, err = oauthRequest(http.MethodPost, this.TokenURL, form, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]any{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	token := &OAuthToken{
+		AccessToken: cast.ToString(result["access_token"]),
+		IdToken:     cast.ToString(result["id_token"]),
+		OpenId:      cast.ToString(result["openid"]),
+		UnionId:     cast.ToString(result["unionid"]),
+	}
+
+	if utils.Is.Empty(token.AccessToken) {
+		return nil, errors.New("第三方平台未返回 access_token")
+	}
+
+	return token, nil
+}
+
+// Profile - 获取用户信息
+/**
+ * @param token 令牌
+ * @param nonce OIDC 授权时的 nonce - 与 id_token 中的不一致时拒绝
+ * @return *OAuthProfile, error
+ */
+func (this *OAuthProvider) Profile(token *OAuthToken, nonce string) (profile *OAuthProfile, err error) {
+
+	profile = &OAuthProfile{Provider: this.Name, Raw: map[string]any{}}
+
+	switch this.Kind {
+	case OAuthGitHub:
+		err = this.github(token, profile)
+	case OAuthQQ:
+		err = this.qq(token, profile)
+	case OAuthWeChat:
+		err = this.wechat(token, profile)
+	default:
+		err = this.oidc(token, nonce, profile)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if utils.Is.Empty(profile.Subject) {
+		return nil, errors.New("第三方平台未返回用户标识")
+	}
+
+	return profile, nil
+}
+
+// github - GitHub 用户信息 - 公开邮箱可能为空或未验证，从 /user/emails 中取已验证的主邮箱
+func (this *OAuthProvider) github(token *OAuthToken, profile *OAuthProfile) error {
+
+	header := map[string]string{"Authorization": "Bearer " + token.AccessToken, "Accept": "application/vnd.github+json"}
+
+	body, err := oauthRequest(http.MethodGet, this.UserURL, nil, header)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, &profile.Raw); err != nil {
+		return err
+	}
+
+	profile.Subject  = cast.ToString(cast.ToInt64(profile.Raw["id"]))
+	profile.Nickname = cast.ToString(utils.Ternary(utils.Is.Empty(profile.Raw["name"]), profile.Raw["login"], profile.Raw["name"]))
+	profile.Avatar   = cast.ToString(profile.Raw["avatar_url"])
+	profile.Email    = cast.ToString(profile.Raw["email"])
+
+	if profile.Subject == "0" {
+		profile.Subject = ""
+	}
+
+	// 需要 user:email 权限，没有时忽略
+	body, err = oauthRequest(http.MethodGet, strings.TrimSuffix(this.UserURL, "/user")+"/user/emails", nil, header)
+	if err != nil {
+		return nil
+	}
+
+	var emails []map[string]any
+	if json.Unmarshal(body, &emails) != nil {
+		return nil
+	}
+	for _, item := range emails {
+		if cast.ToBool(item["primary"]) && cast.ToBool(item["verified"]) {
+			profile.Email, profile.EmailVerified = cast.ToString(item["email"]), true
+		}
+	}
+
+	return nil
+}
+
+// qq - QQ 用户信息 - 以 openid 作为标识
+func (this *OAuthProvider) qq(token *OAuthToken, profile *OAuthProfile) error {
+
+	if utils.Is.Empty(token.OpenId) {
+
+		body, err := oauthRequest(http.MethodGet, "https://graph.qq.com/oauth2.0/me?fmt=json&access_token="+url.QueryEscape(token.AccessToken), nil, nil)
+		if err != nil {
+			return err
+		}
+
+		result := map[string]any{}
+		if err = json.Unmarshal(body, &result); err != nil {
+			return err
+		}
+		token.OpenId = cast.ToString(result["openid"])
+	}
+
+	query := url.Values{}
+	query.Set("access_token", token.AccessToken)
+	query.Set("oauth_consumer_key", this.ClientId)
+	query.Set("openid", token.OpenId)
+
+	body, err := oauthRequest(http.MethodGet, this.UserURL+"?"+query.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, &profile.Raw); err != nil {
+		return err
+	}
+
+	if ret := cast.ToInt(profile.Raw["ret"]); ret != 0 {
+		return fmt.Errorf("QQ登录失败：%v", profile.Raw["msg"])
+	}
+
+	profile.Subject  = token.OpenId
+	profile.Nickname = cast.ToString(profile.Raw["nickname"])
+	profile.Avatar   = cast.ToString(utils.Ternary(utils.Is.Empty(profile.Raw["figureurl_qq_2"]), profile.Raw["figureurl_qq_1"], profile.Raw["figureurl_qq_2"]))
+
+	return nil
+}
+
+// wechat - 微信用户信息 - 有 unionid 时以 unionid 作为标识（同一开放平台下的应用共用）
+func (this *OAuthProvider) wechat(token *OAuthToken, profile *OAuthProfile) error {
+
+	query := url.Values{}
+	query.Set("access_token", token.AccessToken)
+	query.Set("openid", token.OpenId)
+
+	body, err := oauthRequest(http.MethodGet, this.UserURL+"?"+query.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, &profile.Raw); err != nil {
+		return err
+	}
+
+	unionid := utils.Ternary(utils.Is.Empty(profile.Raw["unionid"]), token.UnionId, cast.ToString(profile.Raw["unionid"]))
+
+	profile.Subject  = utils.Ternary(utils.Is.Empty(unionid), token.OpenId, unionid)
+	profile.Nickname = cast.ToString(profile.Raw["nickname"])
+	profile.Avatar   = cast.ToString(profile.Raw["headimgurl"])
+
+	return nil
+}
+
+// oidc - OIDC 用户信息 - id_token 直接从令牌地址（TLS）获取，校验 iss、aud、nonce 后使用其中的 sub，再合并 userinfo
+func (this *OAuthProvider) oidc(token *OAuthToken, nonce string, profile *OAuthProfile) error {
+
+	if !utils.Is.Empty(token.IdToken) {
+
+		parts := strings.Split(token.IdToken, ".")
+		if len(parts) != 3 {
+			return errors.New("id_token 格式错误")
+		}
+
+		body, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return err
+		}
+
+		claims := map[string]any{}
+		if err = json.Unmarshal(body, &claims); err != nil {
+			return err
+		}
+
+		if strings.TrimSuffix(cast.ToString(claims["iss"]), "/") != this.Issuer {
+			return errors.New("id_token 的 iss 不匹配")
+		}
+		// aud 可以是字符串或数组
+		audience := cast.ToStringSlice(claims["aud"])
+		if value, ok := claims["aud"].(string); ok {
+			audience = []string{value}
+		}
+		if !utils.InArray(this.ClientId, audience) {
+			return errors.New("id_token 的 aud 不匹配")
+		}
+		if cast.ToString(claims["nonce"]) != nonce {
+			return errors.New("id_token 的 nonce 不匹配")
+		}
+		if exp := cast.ToInt64(claims["exp"]); exp != 0 && exp < time.Now().Unix() {
+			return errors.New("id_token 已过期")
+		}
+
+		profile.Raw = claims
+	}
+
+	if !utils.Is.Empty(this.UserURL) {
+
+		body, err := oauthRequest(http.MethodGet, this.UserURL, nil, map[string]string{"Authorization": "Bearer " + token.AccessToken})
+		if err != nil {
+			return err
+		}
+
+		info := map[string]any{}
+		if err = json.Unmarshal(body, &info); err != nil {
+			return err
+		}
+
+		// userinfo 的 sub 必须与 id_token 一致
+		if sub, ok := profile.Raw["sub"]; ok && cast.ToString(sub) != cast.ToString(info["sub"]) {
+			return errors.New("userinfo 的 sub 不匹配")
+		}
+
+		for key, value := range info {
+			profile.Raw[key] = value
+		}
+	}
+
+	profile.Subject       = cast.ToString(profile.Raw["sub"])
+	profile.Email         = cast.ToString(profile.Raw["email"])
+	profile.EmailVerified = cast.ToBool(profile.Raw["email_verified"])
+	profile.Nickname      = cast.ToString(utils.Ternary(utils.Is.Empty(profile.Raw["name"]), profile.Raw["preferred_username"], profile.Raw["name"]))
+	profile.Avatar        = cast.ToString(profile.Raw["picture"])
+
+	return nil
+}
+
+// oauthRequest - 请求第三方平台 - 返回 JSON 响应体，HTTP 错误或响应中带 error、errcode 时返回错误
+func oauthRequest(method, address string, form url.Values, header map[string]string) ([]byte, error) {
+
+	var reader io.Reader
+	if form != nil {
+		reader = strings.NewReader(form.Encode())
+	}
+
+	request, err := http.NewRequest(method, address, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+
+	response, err := oauthClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(response.Body)
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]any{}
+	_ = json.Unmarshal(body, &result)
+
+	if !utils.Is.Empty(result["error"]) {
+		return nil, fmt.Errorf("第三方登录失败：%v %v", result["error"], result["error_description"])
+	}
+	if code := cast.ToInt(result["errcode"]); code != 0 {
+		return nil, fmt.Errorf("第三方登录失败：%d %v", code, result["errmsg"])
+	}
+	if response.StatusCode >= 400 {
+		return nil, fmt.Errorf("第三方登录失败：HTTP %d", response.StatusCode)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,198 @@
+package facade
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// oidcMock - 本地模拟的 OIDC 服务
+type oidcMock struct {
+	*httptest.Server
+	// 发现文档中的 issuer - 为空时使用服务地址
+	issuer string
+	// 授权码 => code_challenge
+	codes  map[string]string
+	// 令牌地址返回的 id_token 声明
+	claims map[string]any
+	mutex  sync.Mutex
+}
+
+func newOidcMock(t *testing.T) *oidcMock {
+
+	mock := &oidcMock{codes: map[string]string{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 mock.issuer,
+			"authorization_endpoint": mock.URL + "/auth",
+			"token_endpoint":         mock.URL + "/token",
+			"userinfo_endpoint":      mock.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+
+		_ = r.ParseForm()
+
+		mock.mutex.Lock()
+		challenge, ok := mock.codes[r.Form.Get("code")]
+		delete(mock.codes, r.Form.Get("code"))
+		mock.mutex.Unlock()
+
+		if !ok || OAuthChallenge(r.Form.Get("code_verifier")) != challenge || r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(400)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "invalid_grant"})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "access", "id_token": oidcToken(mock.claims)})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(401)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"sub": "u-1", "email": "a@example.com", "email_verified": true, "name": "Alice"})
+	})
+
+	mock.Server = httptest.NewServer(mux)
+	mock.issuer = mock.URL
+	mock.claims = map[string]any{"iss": mock.URL, "aud": "client", "sub": "u-1", "nonce": "nonce", "exp": time.Now().Add(time.Hour).Unix()}
+	t.Cleanup(mock.Close)
+
+	return mock
+}
+
+// authorize - 模拟用户同意授权 - 记录 code_challenge 并返回授权码
+func (this *oidcMock) authorize(t *testing.T, address string) string {
+
+	item, err := url.Parse(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := item.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("授权地址缺少 PKCE 参数：%s", address)
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	code := OAuthRandom()
+	this.codes[code] = query.Get("code_challenge")
+
+	return code
+}
+
+func (this *oidcMock) provider() *OAuthProvider {
+	return &OAuthProvider{Name: "corp", Kind: OAuthOIDC, ClientId: "client", ClientSecret: "secret", Scope: "openid email", Issuer: this.URL}
+}
+
+// oidcToken - 未签名的 id_token - 令牌直接从令牌地址获取，只校验声明
+func oidcToken(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "none"})
+	body, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body) + ".sig"
+}
+
+func TestOAuthDiscover(t *testing.T) {
+
+	mock := newOidcMock(t)
+	item := mock.provider()
+
+	address, err := item.AuthorizeURL("https://example.com/api/comm/oauth-callback", "state", "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(address, mock.URL+"/auth?") {
+		t.Fatalf("授权地址未使用发现文档：%s", address)
+	}
+	if item.TokenURL != mock.URL+"/token" || item.UserURL != mock.URL+"/userinfo" {
+		t.Fatalf("发现文档未填充地址：%s %s", item.TokenURL, item.UserURL)
+	}
+
+	query, _ := url.ParseQuery(strings.SplitN(address, "?", 2)[1])
+	for key, value := range map[string]string{"state": "state", "nonce": "nonce", "client_id": "client", "code_challenge": OAuthChallenge("verifier")} {
+		if query.Get(key) != value {
+			t.Fatalf("授权地址的 %s 错误：%s", key, query.Get(key))
+		}
+	}
+}
+
+func TestOAuthDiscoverIssuer(t *testing.T) {
+
+	mock := newOidcMock(t)
+	mock.issuer = "https://attacker.example.com"
+
+	if _, err := mock.provider().AuthorizeURL("https://example.com/", "state", "verifier", "nonce"); err == nil {
+		t.Fatal("发现文档的 issuer 不一致时应当拒绝")
+	}
+}
+
+func TestOAuthExchange(t *testing.T) {
+
+	mock := newOidcMock(t)
+	item := mock.provider()
+
+	verifier := OAuthRandom()
+	address, err := item.AuthorizeURL("https://example.com/", "state", verifier, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// code_verifier 错误
+	if _, err = item.Exchange(mock.authorize(t, address), OAuthRandom(), "https://example.com/"); err == nil {
+		t.Fatal("code_verifier 错误时应当拒绝")
+	}
+
+	token, err := item.Exchange(mock.authorize(t, address), verifier, "https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := item.Profile(token, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.Subject != "u-1" || profile.Email != "a@example.com" || !profile.EmailVerified || profile.Nickname != "Alice" {
+		t.Fatalf("用户信息错误：%+v", profile)
+	}
+}
+
+func TestOAuthProfileReject(t *testing.T) {
+
+	mock := newOidcMock(t)
+
+	for name, claims := range map[string]map[string]any{
+		"nonce":   {"nonce": "other"},
+		"iss":     {"iss": "https://attacker.example.com"},
+		"aud":     {"aud": []string{"other"}},
+		"expired": {"exp": time.Now().Add(-time.Minute).Unix()},
+	} {
+		t.Run(name, func(t *testing.T) {
+
+			item := map[string]any{}
+			for key, value := range mock.claims {
+				item[key] = value
+			}
+			for key, value := range claims {
+				item[key] = value
+			}
+
+			token := &OAuthToken{AccessToken: "access", IdToken: oidcToken(item)}
+			if _, err := mock.provider().Profile(token, "nonce"); err == nil {
+				t.Fatalf("id_token 的 %s 错误时应当拒绝", name)
+			}
+		})
+	}
+}
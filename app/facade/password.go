@@ -0,0 +1,210 @@
+package facade
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"github.com/spf13/cast"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+)
+
+const (
+	// PasswordArgon2id - argon2id（推荐）
+	PasswordArgon2id = "argon2id"
+	// PasswordBcrypt - bcrypt
+	PasswordBcrypt   = "bcrypt"
+)
+
+// PasswordStruct - 密码哈希 - 支持 argon2id（PHC 格式）和 bcrypt，旧版本使用的是最低 cost 的 bcrypt
+type PasswordStruct struct {
+	// 假哈希 - 帐号不存在时用于校验，使响应时间与帐号存在时一致
+	dummy string
+	mutex sync.Mutex
+}
+
+// Password - 密码哈希实例
+/**
+ * @example：
+ * hash := facade.Password.Create("123456")
+ * ok   := facade.Password.Verify(hash, "123456")
+ * if ok && facade.Password.NeedsRehash(hash) { ... }
+ */
+var Password = &PasswordStruct{}
+
+// argon2Params - argon2id 参数
+type argon2Params struct {
+	// 内存(KiB)
+	Memory  uint32
+	// 迭代次数
+	Time    uint32
+	// 并行度
+	Threads uint8
+}
+
+// config - 读取 [password] 配置 - 旧版本升级上来的配置没有该节时使用内置值
+func (this *PasswordStruct) config(key string, def any) any {
+	if CryptToml == nil || !CryptToml.Viper.IsSet("password." + key) {
+		return def
+	}
+	return CryptToml.Viper.Get("password." + key)
+}
+
+// algorithm - 当前使用的算法
+func (this *PasswordStruct) algorithm() string {
+	algorithm := strings.ToLower(cast.ToString(this.config("algorithm", PasswordArgon2id)))
+	if algorithm != PasswordBcrypt {
+		return PasswordArgon2id
+	}
+	return algorithm
+}
+
+// cost - bcrypt 的 cost
+func (this *PasswordStruct) cost() int {
+	cost := cast.ToInt(this.config("cost", 12))
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return 12
+	}
+	return cost
+}
+
+// argon2 - 当前的 argon2id 参数
+func (this *PasswordStruct) argon2() argon2Params {
+
+	item := argon2Params{
+		Memory:  cast.ToUint32(this.config("memory", 64*1024)),
+		Time:    cast.ToUint32(this.config("time", 3)),
+		Threads: cast.ToUint8(this.config("threads", 2)),
+	}
+
+	if item.Memory < 8*1024 {
+		item.Memory = 8 * 1024
+	}
+	if item.Time < 1 {
+		item.Time = 1
+	}
+	if item.Threads < 1 {
+		item.Threads = 1
+	}
+
+	return item
+}
+
+// Create - 生成密码哈希
+/**
+ * @param password 明文密码
+ * @return string 哈希，失败时为空
+ */
+func (this *PasswordStruct) Create(password any) (result string) {
+
+	if this.algorithm() == PasswordBcrypt {
+		item, err := bcrypt.GenerateFromPassword([]byte(cast.ToString(password)), this.cost())
+		if err != nil {
+			return ""
+		}
+		return string(item)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return ""
+	}
+
+	params := this.argon2()
+	key    := argon2.IDKey([]byte(cast.ToString(password)), salt, params.Time, params.Memory, params.Threads, 32)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// Verify - 校验密码 - 根据哈希的格式自动识别算法
+/**
+ * @param hash 密码哈希
+ * @param password 明文密码
+ * @return bool
+ */
+func (this *PasswordStruct) Verify(hash, password any) (ok bool) {
+
+	text := cast.ToString(hash)
+
+	if strings.HasPrefix(text, "$argon2id$") {
+
+		params, salt, key, err := this.decode(text)
+		if err != nil {
+			return false
+		}
+
+		value := argon2.IDKey([]byte(cast.ToString(password)), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+
+		return subtle.ConstantTimeCompare(value, key) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(text), []byte(cast.ToString(password))) == nil
+}
+
+// Dummy - 假哈希 - 使用当前的算法和参数，帐号不存在或未设置密码时用它校验一次，不暴露帐号是否存在
+/**
+ * @example：
+ * hash := utils.Ternary(exist, user.Password, facade.Password.Dummy())
+ * ok   := facade.Password.Verify(hash, password) && exist
+ */
+func (this *PasswordStruct) Dummy() string {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	// 修改了算法或参数后重新生成
+	if this.dummy == "" || this.NeedsRehash(this.dummy) {
+		random := make([]byte, 32)
+		_, _ = rand.Read(random)
+		this.dummy = this.Create(base64.RawStdEncoding.EncodeToString(random))
+	}
+
+	return this.dummy
+}
+
+// NeedsRehash - 哈希的算法或参数与当前配置不一致，需要在登录成功时重新生成
+func (this *PasswordStruct) NeedsRehash(hash any) bool {
+
+	text := cast.ToString(hash)
+
+	if this.algorithm() == PasswordBcrypt {
+		cost, err := bcrypt.Cost([]byte(text))
+		return err != nil || cost != this.cost()
+	}
+
+	params, _, _, err := this.decode(text)
+
+	return err != nil || params != this.argon2()
+}
+
+// decode - 解析 argon2id 的 PHC 格式：$argon2id$v=19$m=65536,t=3,p=2$salt$key
+func (this *PasswordStruct) decode(hash string) (params argon2Params, salt, key []byte, err error) {
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != PasswordArgon2id {
+		return params, nil, nil, fmt.Errorf("argon2id 哈希格式错误")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("argon2id 版本不支持")
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, err
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, err
+	}
+
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("argon2id 哈希格式错误")
+	}
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,161 @@
+package facade
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+// testPassword - 使用指定的 [password] 配置，结束后还原 - 测试中使用最低的参数
+func testPassword(t *testing.T, config map[string]any) {
+	for key, value := range config {
+		key := key
+		old := CryptToml.Viper.Get("password." + key)
+		CryptToml.Viper.Set("password."+key, value)
+		t.Cleanup(func() { CryptToml.Viper.Set("password."+key, old) })
+	}
+}
+
+// testArgon2 - argon2id 的最低参数
+var testArgon2 = map[string]any{"algorithm": PasswordArgon2id, "memory": 8 * 1024, "time": 1, "threads": 1}
+
+func TestPasswordVerify(t *testing.T) {
+
+	for _, algorithm := range []string{PasswordArgon2id, PasswordBcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+
+			testPassword(t, testArgon2)
+			testPassword(t, map[string]any{"algorithm": algorithm, "cost": bcrypt.MinCost})
+
+			hash := Password.Create("123456")
+			if hash == "" || strings.Contains(hash, "123456") {
+				t.Fatalf("哈希错误：%q", hash)
+			}
+			if algorithm == PasswordArgon2id && !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+				t.Fatalf("argon2id 哈希格式错误：%q", hash)
+			}
+			if Password.Create("123456") == hash {
+				t.Fatal("同一密码每次的哈希应当不同")
+			}
+
+			for _, item := range []struct {
+				password any
+				ok       bool
+			}{
+				{"123456", true},
+				{123456, true},
+				{"1234567", false},
+				{"12345", false},
+				{"", false},
+				{" 123456", false},
+			} {
+				if ok := Password.Verify(hash, item.password); ok != item.ok {
+					t.Errorf("%v 得到 %v", item.password, ok)
+				}
+			}
+		})
+	}
+}
+
+// TestPasswordMalformed - 格式错误的哈希一律不通过
+func TestPasswordMalformed(t *testing.T) {
+
+	testPassword(t, testArgon2)
+
+	valid := Password.Create("123456")
+	parts := strings.Split(valid, "$")
+
+	for _, hash := range []string{
+		"",
+		"123456",
+		"$argon2id$",
+		"$argon2i$" + strings.Join(parts[2:], "$"),
+		strings.Replace(valid, "v=19", "v=16", 1),
+		strings.Replace(valid, "m=8192,t=1,p=1", "m=x,t=1,p=1", 1),
+		strings.Join(append(parts[:4:4], "!!!", parts[5]), "$"),
+		strings.Join(append(parts[:5:5], ""), "$"),
+		strings.Join(parts[:5], "$"),
+		valid + "$extra",
+		"$2a$04$invalid",
+	} {
+		if Password.Verify(hash, "123456") {
+			t.Errorf("%q 不应当通过", hash)
+		}
+		if !Password.NeedsRehash(hash) {
+			t.Errorf("%q 应当需要重新生成", hash)
+		}
+	}
+}
+
+func TestPasswordRehash(t *testing.T) {
+
+	testPassword(t, testArgon2)
+
+	argon := Password.Create("123456")
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+
+	// 旧版本的 bcrypt 哈希仍然可以登录，登录后重新生成
+	if !Password.Verify(string(legacy), "123456") || !Password.NeedsRehash(string(legacy)) {
+		t.Fatal("旧版本的 bcrypt 哈希应当可以校验并需要重新生成")
+	}
+
+	for _, item := range []struct {
+		name   string
+		config map[string]any
+		rehash bool
+	}{
+		{"参数不变", map[string]any{}, false},
+		{"内存", map[string]any{"memory": 16 * 1024}, true},
+		{"迭代次数", map[string]any{"time": 2}, true},
+		{"并行度", map[string]any{"threads": 2}, true},
+		{"低于下限按下限", map[string]any{"memory": 1024, "time": 0, "threads": 0}, false},
+		{"算法", map[string]any{"algorithm": PasswordBcrypt, "cost": bcrypt.MinCost}, true},
+		{"未知算法按 argon2id", map[string]any{"algorithm": "md5"}, false},
+	} {
+		t.Run(item.name, func(t *testing.T) {
+			testPassword(t, item.config)
+			if rehash := Password.NeedsRehash(argon); rehash != item.rehash {
+				t.Errorf("得到 %v", rehash)
+			}
+			// 修改配置后旧哈希仍然可以校验
+			if !Password.Verify(argon, "123456") {
+				t.Error("修改配置后旧哈希应当仍然可以校验")
+			}
+		})
+	}
+
+	// bcrypt - cost 不一致时重新生成
+	testPassword(t, map[string]any{"algorithm": PasswordBcrypt, "cost": bcrypt.MinCost})
+	if Password.NeedsRehash(string(legacy)) {
+		t.Fatal("cost 一致时不应当重新生成")
+	}
+	testPassword(t, map[string]any{"cost": bcrypt.MinCost + 1})
+	if !Password.NeedsRehash(string(legacy)) {
+		t.Fatal("cost 不一致时应当重新生成")
+	}
+	testPassword(t, map[string]any{"cost": 100})
+	if Password.cost() != 12 {
+		t.Fatal("cost 超出范围时应当使用默认值")
+	}
+}
+
+func TestPasswordDummy(t *testing.T) {
+
+	testPassword(t, testArgon2)
+
+	dummy := Password.Dummy()
+	if dummy == "" || Password.Dummy() != dummy || Password.NeedsRehash(dummy) {
+		t.Fatal("假哈希应当使用当前的参数并复用")
+	}
+	for _, password := range []string{"", "123456", "password"} {
+		if Password.Verify(dummy, password) {
+			t.Errorf("假哈希不应当通过 %q", password)
+		}
+	}
+
+	// 修改参数后重新生成，校验耗时与真实帐号一致
+	testPassword(t, map[string]any{"time": 2})
+	if next := Password.Dummy(); next == dummy || Password.NeedsRehash(next) {
+		t.Fatal("修改参数后假哈希应当重新生成")
+	}
+}
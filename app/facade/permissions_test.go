@@ -0,0 +1,192 @@
+package facade_test
+
+import (
+	"inis/app/facade"
+	"inis/app/model"
+	"reflect"
+	"testing"
+)
+
+func TestPermissionsNormalize(t *testing.T) {
+
+	for _, item := range []struct {
+		name   string
+		value  any
+		result []string
+	}{
+		{"数组", []string{"users:delete", "Users:Save", "users:delete"}, []string{"users:delete", "users:save"}},
+		{"逗号分隔", "users:one, files:*，comm:*\nroles:mine", []string{"comm:*", "files:*", "roles:mine", "users:one"}},
+		{"全部权限", "*", []string{"*"}},
+		{"丢弃格式错误", []string{"users", "users:", ":delete", "*:delete", "users:de lete", "users:*:x", "users:del;ete", "../x:y"}, nil},
+		{"方法通配符", []string{"users:*"}, []string{"users:*"}},
+		{"空", "", nil},
+	} {
+		if result := model.PermissionsNormalize(item.value); !reflect.DeepEqual(result, item.result) {
+			t.Errorf("%s：得到 %v，应当为 %v", item.name, result, item.result)
+		}
+	}
+}
+
+func TestPermissionsMatch(t *testing.T) {
+
+	for _, item := range []struct {
+		grants []string
+		name   string
+		ok     bool
+	}{
+		{[]string{"*"}, "users:delete", true},
+		{[]string{"users:*"}, "users:delete", true},
+		{[]string{"users:delete"}, "users:delete", true},
+		{[]string{"users:save"}, "users:delete", false},
+		{[]string{"users:*"}, "usersx:delete", false},
+		{[]string{"user:*"}, "users:delete", false},
+		{[]string{"files:*"}, "users:delete", false},
+		{[]string{"users:delete"}, "users:*", false},
+		{[]string{"users:delete"}, "users", false},
+		{nil, "users:delete", false},
+		{[]string{}, "", false},
+	} {
+		if ok := model.PermissionsMatch(item.grants, item.name); ok != item.ok {
+			t.Errorf("%v 匹配 %q 得到 %v", item.grants, item.name, ok)
+		}
+	}
+}
+
+// testRoles - 写入内置角色和一个编辑角色
+func testRoles(t *testing.T) (editor model.Roles) {
+
+	testDB(t, &model.Users{}, &model.Roles{}, &model.Permissions{}, &model.UsersRoles{})
+	model.InitRoles()
+
+	editor = model.Roles{Key: "editor", Name: "编辑", Permissions: []string{"article:*", "users:delete"}}
+	if err := model.RolesSave(&editor); err != nil {
+		t.Fatal(err)
+	}
+
+	return editor
+}
+
+func TestPermissionsOf(t *testing.T) {
+
+	editor := testRoles(t)
+
+	var admin model.Roles
+	facade.DB.Drive().Where("`key` = ?", model.RolesAdmin).First(&admin)
+
+	if err := model.RolesAssign(2, []int{editor.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.RolesAssign(3, []int{admin.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []struct {
+		name  string
+		uid   int
+		allow []string
+		deny  []string
+	}{
+		{"游客", 0, []string{"comm:login", "file:rand", "roles:mine"}, []string{"users:one", "files:upload", "users:delete", "roles:save"}},
+		{"用户", 1, []string{"comm:login", "users:one", "users:update", "files:upload", "apikeys:create"}, []string{"users:delete", "users:create", "roles:save", "article:save"}},
+		{"编辑", 2, []string{"comm:login", "users:one", "article:save", "users:delete"}, []string{"users:create", "roles:save"}},
+		{"管理员", 3, []string{"users:create", "roles:save", "anything:any"}, nil},
+	} {
+		for _, name := range item.allow {
+			if !model.PermissionsCheck(item.uid, name) {
+				t.Errorf("%s 应当拥有 %s", item.name, name)
+			}
+		}
+		for _, name := range item.deny {
+			if model.PermissionsCheck(item.uid, name) {
+				t.Errorf("%s 不应当拥有 %s", item.name, name)
+			}
+		}
+		if model.PermissionsAdmin(item.uid) != (item.uid == 3) {
+			t.Errorf("%s 的管理员判断错误", item.name)
+		}
+	}
+
+	// 收回角色后立即生效
+	if err := model.RolesAssign(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if model.PermissionsCheck(2, "article:save") {
+		t.Fatal("收回角色后不应当保留权限")
+	}
+}
+
+// TestPermissionsCache - 开启缓存时，角色和权限变化后立即生效
+func TestPermissionsCache(t *testing.T) {
+
+	editor := testRoles(t)
+	testCacheOpen(t)
+
+	if err := model.RolesAssign(2, []int{editor.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if !model.PermissionsCheck(2, "article:save") {
+		t.Fatal("分配角色后应当拥有权限")
+	}
+	if _, ok := facade.CacheGet[[]string]("rbac[2]"); !ok {
+		t.Fatal("权限集应当写入缓存")
+	}
+
+	// 修改角色的权限 - 清除全部用户的缓存
+	editor.Permissions = []string{"users:delete"}
+	if err := model.RolesSave(&editor); err != nil {
+		t.Fatal(err)
+	}
+	if model.PermissionsCheck(2, "article:save") {
+		t.Fatal("角色收回的权限不应当留在缓存中")
+	}
+
+	// 收回角色 - 清除该用户的缓存
+	if err := model.RolesAssign(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if model.PermissionsCheck(2, "users:delete") {
+		t.Fatal("收回角色后不应当保留权限")
+	}
+}
+
+// TestPermissionsPages - 用户资料中的 pages 字段不参与权限计算
+func TestPermissionsPages(t *testing.T) {
+
+	testRoles(t)
+
+	user := model.Users{Account: "test", Pages: "*,users:delete,roles:save"}
+	if err := facade.DB.Drive().Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"users:delete", "roles:save"} {
+		if model.PermissionsCheck(user.Id, name) {
+			t.Errorf("pages 不应当授予 %s", name)
+		}
+	}
+}
+
+// TestPermissionsFallback - 数据库不可用或角色表为空时使用内置角色的默认权限
+func TestPermissionsFallback(t *testing.T) {
+
+	db := facade.DB
+	defer func() { facade.DB = db }()
+
+	for _, item := range []struct {
+		name  string
+		setup func(t *testing.T)
+	}{
+		{"没有数据库", func(t *testing.T) { facade.DB = nil }},
+		{"角色表为空", func(t *testing.T) { testDB(t, &model.Roles{}, &model.Permissions{}, &model.UsersRoles{}) }},
+	} {
+		t.Run(item.name, func(t *testing.T) {
+			item.setup(t)
+			if !model.PermissionsCheck(0, "comm:login") || model.PermissionsCheck(0, "users:one") {
+				t.Error("游客的默认权限错误")
+			}
+			if !model.PermissionsCheck(1, "users:one") || model.PermissionsCheck(1, "users:delete") {
+				t.Error("用户的默认权限错误")
+			}
+		})
+	}
+}
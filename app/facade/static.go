@@ -0,0 +1,393 @@
+package facade
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrStaticForbidden - 访问 public 目录以外或隐藏的文件
+var ErrStaticForbidden = errors.New("禁止访问！")
+
+// staticEncodings - 预压缩文件 - 按优先级排列，Content-Encoding => 文件后缀
+var staticEncodings = [][2]string{{"br", ".br"}, {"gzip", ".gz"}}
+
+// staticText - 需要带 charset 的非 text/* 类型
+var staticText = []string{"application/javascript", "application/json", "application/xml", "application/manifest+json", "image/svg+xml"}
+
+// staticCache - 内置的缓存策略 - 配置文件中没有 [[static.cache]] 时使用（旧版本升级上来的配置）
+var staticCache = []any{
+	map[string]any{"prefix": "/assets/", "control": "public, max-age=604800"},
+	map[string]any{"prefix": "/storage/", "control": "public, max-age=86400"},
+}
+
+// staticExclude - 接口路由前缀 - 不参与静态挂载和 SPA 回退
+var staticExclude = []string{"/api/", "/dev/", "/socket/"}
+
+// StaticStruct - 静态文件服务
+type StaticStruct struct {
+	// 域名 - 为空时匹配所有域名
+	Host     string
+	// 路径前缀，如：/admin
+	Prefix   string
+	// 根目录
+	Root     string
+	// SPA 回退文件 - 为空时不回退，如：index.html
+	Fallback string
+}
+
+// Static - 静态文件服务实例 - public 目录
+/**
+ * @example：
+ * err := facade.Static.Serve(ctx.Writer, ctx.Request, "/assets/js/app.js")
+ */
+var Static = &StaticStruct{Prefix: "/", Root: "public"}
+
+// StaticClean - 规范化请求路径 - 合并 //、/./、/../ 和反斜杠，保留末尾的 /
+/**
+ * 存储文件的签名校验按前缀判断，必须在规范化后的路径上进行，否则 //storage/ 之类的路径可以绕过
+ */
+func StaticClean(value string) string {
+
+	value  = strings.ReplaceAll(value, "\\", "/")
+	result := path.Clean("/" + value)
+
+	if strings.HasSuffix(value, "/") && result != "/" {
+		result += "/"
+	}
+
+	return result
+}
+
+// StaticMount - 按域名和路径匹配挂载点 - 指定了域名的优先，其次是最长的前缀
+/**
+ * @param host 请求的域名，可以带端口
+ * @param path 请求路径
+ * @return mount 挂载点，name 挂载点内的路径，ok 为 false 时表示接口路由或没有匹配的挂载点
+ * @example：
+ * mount, name, ok := facade.StaticMount("admin.example.com", "/admin/users")
+ */
+func StaticMount(host, path string) (mount *StaticStruct, name string, ok bool) {
+
+	path = StaticClean(path)
+
+	for _, item := range staticExclude {
+		if strings.HasPrefix(path+"/", item) {
+			return nil, "", false
+		}
+	}
+
+	// 存储文件始终在 public 目录下，签名和图片处理都依赖这个位置
+	if strings.HasPrefix(path, "/storage/") {
+		return Static, path, true
+	}
+
+	if value, _, err := net.SplitHostPort(host); err == nil {
+		host = value
+	}
+	host = strings.ToLower(host)
+
+	score := -1
+	for _, item := range staticMounts() {
+
+		if item.Host != "" && item.Host != host {
+			continue
+		}
+
+		prefix := strings.TrimSuffix(item.Prefix, "/")
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+
+		value := len(prefix)
+		if item.Host != "" {
+			value += 1 << 16
+		}
+		if value <= score {
+			continue
+		}
+
+		score, mount = value, item
+		name = "/" + strings.TrimPrefix(strings.TrimPrefix(path, prefix), "/")
+	}
+
+	return mount, name, mount != nil
+}
+
+// staticMounts - 读取 [[static.mount]] - 未配置时只有 public 目录
+func staticMounts() (result []*StaticStruct) {
+
+	if !AppToml.Viper.IsSet("static.mount") {
+		return []*StaticStruct{Static}
+	}
+
+	for _, item := range cast.ToSlice(AppToml.Viper.Get("static.mount")) {
+		rule := cast.ToStringMap(item)
+		root := cast.ToString(rule["root"])
+		if utils.Is.Empty(root) {
+			continue
+		}
+		result = append(result, &StaticStruct{
+			Host:     strings.ToLower(cast.ToString(rule["host"])),
+			Prefix:   "/" + strings.Trim(cast.ToString(rule["prefix"]), "/"),
+			Root:     root,
+			Fallback: cast.ToString(rule["fallback"]),
+		})
+	}
+
+	return result
+}
+
+// Open - 打开根目录下的文件 - 拒绝越出根目录（包括软链接）和隐藏文件
+/**
+ * @param name 请求路径，如：/assets/js/app.js
+ * @return *os.File, os.FileInfo, error
+ */
+func (this *StaticStruct) Open(name string) (*os.File, os.FileInfo, error) {
+
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if strings.Contains(name, "\x00") {
+		return nil, nil, ErrStaticForbidden
+	}
+
+	// .env、.git 等隐藏文件不对外提供，.well-known 除外
+	for _, item := range strings.Split(name, "/") {
+		if strings.HasPrefix(item, ".") && item != ".well-known" {
+			return nil, nil, ErrStaticForbidden
+		}
+	}
+
+	root, err := filepath.Abs(this.Root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if value, err := filepath.EvalSymlinks(root); err == nil {
+		root = value
+	}
+
+	// 解析软链接后必须仍在根目录下
+	file, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if file != root && !strings.HasPrefix(file, root+string(filepath.Separator)) {
+		return nil, nil, ErrStaticForbidden
+	}
+
+	item, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := item.Stat()
+	if err != nil {
+		_ = item.Close()
+		return nil, nil, err
+	}
+
+	if info.IsDir() {
+		_ = item.Close()
+		return nil, nil, os.ErrNotExist
+	}
+
+	return item, info, nil
+}
+
+// Stat - 文件信息
+/**
+ * @param name 请求路径
+ * @return os.FileInfo, error
+ */
+func (this *StaticStruct) Stat(name string) (os.FileInfo, error) {
+
+	file, info, err := this.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, file.Close()
+}
+
+// ETag - 由修改时间和大小组成，与本地存储的 ETag 一致
+func (this *StaticStruct) ETag(info os.FileInfo) string {
+	return fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+}
+
+// Serve - 输出文件 - 支持 ETag、Last-Modified、Range 和预压缩的 .br、.gz 文件
+/**
+ * @param writer 响应
+ * @param request 请求
+ * @param name 请求路径，如：/assets/js/app.js
+ * @return error 文件不存在时 os.IsNotExist(err) 为 true
+ */
+func (this *StaticStruct) Serve(writer http.ResponseWriter, request *http.Request, name string) error {
+
+	file, info, err := this.Open(name)
+	if err != nil {
+		return err
+	}
+
+	header := writer.Header()
+	header.Set("Content-Type", this.Mime(name))
+	// 禁止浏览器嗅探类型 - 上传的文件按扩展名输出，不能被当作 HTML、脚本执行
+	header.Set("X-Content-Type-Options", "nosniff")
+	// 调用方已设置时（如签名地址）不覆盖
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", this.CacheControl(request.URL.Path))
+	}
+
+	// 预压缩文件 - 修改时间不早于原文件时才使用，避免输出过期内容
+	if !AppToml.Viper.IsSet("static.precompressed") || AppToml.Viper.GetBool("static.precompressed") {
+		header.Add("Vary", "Accept-Encoding")
+		if item, stat, encoding := this.encoded(request, name, info); item != nil {
+			_ = file.Close()
+			file, info = item, stat
+			header.Set("Content-Encoding", encoding)
+		}
+	}
+
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	etag := this.ETag(info)
+	if encoding := header.Get("Content-Encoding"); encoding != "" {
+		etag += "-" + encoding
+	}
+	header.Set("ETag", `"`+etag+`"`)
+
+	http.ServeContent(writer, request, name, info.ModTime(), file)
+
+	return nil
+}
+
+// Content - 输出内存中的内容（如缩略图） - 同样支持条件请求和 Range
+/**
+ * @param writer 响应
+ * @param request 请求
+ * @param name 请求路径，用于匹配 Cache-Control
+ * @param mime 内容类型
+ * @param etag 内容标识
+ * @param modified 修改时间
+ * @param body 内容
+ */
+func (this *StaticStruct) Content(writer http.ResponseWriter, request *http.Request, name, mime, etag string, modified time.Time, body []byte) {
+
+	header := writer.Header()
+	header.Set("Content-Type", mime)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", this.CacheControl(name))
+	}
+	header.Set("ETag", `"`+etag+`"`)
+
+	http.ServeContent(writer, request, name, modified, bytes.NewReader(body))
+}
+
+// SPA - 输出 SPA 回退文件 - 只对 GET、HEAD 请求且路径不带扩展名时生效
+/**
+ * @param writer 响应
+ * @param request 请求
+ * @param name 挂载点内的路径
+ * @return bool 是否已输出
+ */
+func (this *StaticStruct) SPA(writer http.ResponseWriter, request *http.Request, name string) bool {
+
+	if utils.Is.Empty(this.Fallback) || path.Ext(name) != "" {
+		return false
+	}
+
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return false
+	}
+
+	// 回退页面随版本更新，不能缓存
+	writer.Header().Set("Cache-Control", "no-cache")
+
+	return this.Serve(writer, request, "/"+strings.TrimPrefix(this.Fallback, "/")) == nil
+}
+
+// encoded - 查找客户端可接受的预压缩文件
+func (this *StaticStruct) encoded(request *http.Request, name string, origin os.FileInfo) (*os.File, os.FileInfo, string) {
+
+	accept := request.Header.Get("Accept-Encoding")
+
+	for _, item := range staticEncodings {
+		if !imageAccept(accept, item[0]) {
+			continue
+		}
+		file, info, err := this.Open(name + item[1])
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(origin.ModTime()) {
+			_ = file.Close()
+			continue
+		}
+		return file, info, item[0]
+	}
+
+	return nil, nil, ""
+}
+
+// Mime - 文件类型 - 只有文本类型带 charset
+func (this *StaticStruct) Mime(name string) string {
+
+	ext := strings.ToLower(path.Ext(name))
+
+	value := mime.TypeByExtension(ext)
+	if utils.Is.Empty(value) {
+		value = utils.Mime.Type(strings.TrimPrefix(ext, "."))
+	}
+	if utils.Is.Empty(value) {
+		return "application/octet-stream"
+	}
+
+	if strings.Contains(value, "charset=") {
+		return value
+	}
+
+	if strings.HasPrefix(value, "text/") || utils.InArray(value, staticText) {
+		return value + "; charset=utf-8"
+	}
+
+	return value
+}
+
+// CacheControl - 按 [[static.cache]] 中最长匹配的前缀获取 Cache-Control
+/**
+ * @param name 请求路径
+ * @return string 未匹配时为 static.cache_control
+ */
+func (this *StaticStruct) CacheControl(name string) (result string) {
+
+	result = cast.ToString(AppToml.Get("static.cache_control", "no-cache"))
+
+	rules := staticCache
+	if AppToml.Viper.IsSet("static.cache") {
+		rules = cast.ToSlice(AppToml.Viper.Get("static.cache"))
+	}
+
+	length := -1
+	for _, item := range rules {
+		rule   := cast.ToStringMap(item)
+		prefix := cast.ToString(rule["prefix"])
+		if !strings.HasPrefix(name, prefix) || len(prefix) <= length {
+			continue
+		}
+		length = len(prefix)
+		result = cast.ToString(rule["control"])
+	}
+
+	return result
+}
@@ -0,0 +1,141 @@
+package facade
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStatic - 临时目录下的静态文件服务
+func testStatic(t *testing.T, files map[string]string) *StaticStruct {
+
+	root := t.TempDir()
+	for name, body := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &StaticStruct{Prefix: "/", Root: root}
+}
+
+func TestStaticNosniff(t *testing.T) {
+
+	static := testStatic(t, map[string]string{"storage/a.txt": "<script>alert(1)</script>"})
+
+	writer := httptest.NewRecorder()
+	if err := static.Serve(writer, httptest.NewRequest("GET", "/storage/a.txt", nil), "/storage/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if writer.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("静态文件应当带有 nosniff")
+	}
+
+	writer = httptest.NewRecorder()
+	static.Content(writer, httptest.NewRequest("GET", "/storage/a.png", nil), "/storage/a.png", "image/png", "etag", time.Now(), []byte("png"))
+	if writer.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("缩略图应当带有 nosniff")
+	}
+}
+
+func TestStaticClean(t *testing.T) {
+
+	for value, want := range map[string]string{
+		"":                     "/",
+		"/":                    "/",
+		"//storage//a.png":     "/storage/a.png",
+		"/storage/../config/a": "/config/a",
+		"/./storage/./a.png":   "/storage/a.png",
+		"\\storage\\a.png":     "/storage/a.png",
+		"/../../etc/passwd":    "/etc/passwd",
+		"/admin/":              "/admin/",
+		"/admin//":             "/admin/",
+	} {
+		if result := StaticClean(value); result != want {
+			t.Errorf("%q 得到 %q，应当为 %q", value, result, want)
+		}
+	}
+}
+
+func TestStaticOpen(t *testing.T) {
+
+	static := testStatic(t, map[string]string{
+		"public/index.html":               "index",
+		"public/assets/app.js":            "app",
+		"public/.env":                     "env",
+		"public/.git/config":              "git",
+		"public/.well-known/security.txt": "security",
+		"secret.txt":                      "secret",
+		"public-other/a.txt":              "other",
+	})
+	base := static.Root
+	static.Root = filepath.Join(base, "public")
+
+	// 指向根目录外的软链接，以及根目录内的软链接
+	if err := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(static.Root, "link.txt")); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Symlink(base, filepath.Join(static.Root, "up")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(static.Root, "assets", "app.js"), filepath.Join(static.Root, "alias.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []struct {
+		name string
+		body string
+		err  error
+	}{
+		{"/index.html", "index", nil},
+		{"/assets/app.js", "app", nil},
+		{"assets/app.js", "app", nil},
+		{"/assets/../index.html", "index", nil},
+		{"/.well-known/security.txt", "security", nil},
+		{"/alias.js", "app", nil},
+		{"/../secret.txt", "", os.ErrNotExist},
+		{"/../../secret.txt", "", os.ErrNotExist},
+		{"..\\secret.txt", "", os.ErrNotExist},
+		{"/assets/..\\..\\secret.txt", "", os.ErrNotExist},
+		{"/../public-other/a.txt", "", os.ErrNotExist},
+		{"/%2e%2e/secret.txt", "", os.ErrNotExist},
+		{"/link.txt", "", ErrStaticForbidden},
+		{"/up/secret.txt", "", ErrStaticForbidden},
+		{"/up/public-other/a.txt", "", ErrStaticForbidden},
+		{"/.env", "", ErrStaticForbidden},
+		{"/.git/config", "", ErrStaticForbidden},
+		{"/assets/../.env", "", ErrStaticForbidden},
+		{"/index.html\x00.png", "", ErrStaticForbidden},
+		{"/assets", "", os.ErrNotExist},
+		{"/", "", os.ErrNotExist},
+	} {
+
+		file, _, err := static.Open(item.name)
+		if item.err != nil {
+			if err == nil {
+				_ = file.Close()
+				t.Errorf("%q 不应当可以访问", item.name)
+			} else if !errors.Is(err, item.err) {
+				t.Errorf("%q 得到 %v，应当为 %v", item.name, err, item.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q：%v", item.name, err)
+			continue
+		}
+		body, _ := os.ReadFile(file.Name())
+		_ = file.Close()
+		if string(body) != item.body {
+			t.Errorf("%q 得到 %q", item.name, body)
+		}
+	}
+}
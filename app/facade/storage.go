@@ -2,10 +2,17 @@ package facade
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
 	"github.com/qiniu/go-sdk/v7/storage"
 	"github.com/spf13/cast"
@@ -14,6 +21,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -43,6 +54,8 @@ const (
 	StorageModeCOS   = "cos"
 	// StorageModeKODO - KODO存储
 	StorageModeKODO  = "kodo"
+	// StorageModeS3 - S3兼容存储（AWS S3、MinIO、R2、Ceph 等）
+	StorageModeS3    = "s3"
 )
 
 // NewStorage - 创建Storage实例
@@ -54,19 +67,30 @@ const (
  * 2. storage := facade.NewStorage(facade.StorageModeOSS)
  */
 func NewStorage(mode any) StorageInterface {
+	Storage = StorageDriver(mode)
+	return Storage
+}
+
+// StorageDriver - 按驱动名称获取Storage实例 - 不修改默认驱动
+/**
+ * @param mode 驱动模式
+ * @return StorageInterface
+ * @example：
+ * storage := facade.StorageDriver(facade.StorageModeS3)
+ */
+func StorageDriver(mode any) StorageInterface {
 	switch strings.ToLower(cast.ToString(mode)) {
-	case StorageModeLocal:
-		Storage = LocalStorage
 	case StorageModeOSS:
-		Storage = OSS
+		return OSS
 	case StorageModeCOS:
-		Storage = COS
+		return COS
 	case StorageModeKODO:
-		Storage = KODO
+		return KODO
+	case StorageModeS3:
+		return S3
 	default:
-		Storage = LocalStorage
+		return LocalStorage
 	}
-	return Storage
 }
 
 // StorageToml - 存储配置文件
@@ -81,6 +105,8 @@ func initStorageToml() {
 		Content: utils.Replace(TempStorage, map[string]any{
 			"${default}": "local",
 			"${local.domain}": "",
+			"${local.sign_key}": fmt.Sprintf("Unti-%x", md5.Sum([]byte(fmt.Sprintf("%v-%v", uuid.New().String(), time.Now().UnixNano())))),
+			"${local.private}": "false",
 			"${oss.access_key_id}": "",
 			"${oss.access_key_secret}": "",
 			"${oss.endpoint}": "",
@@ -97,6 +123,39 @@ func initStorageToml() {
 			"${kodo.bucket}": "unti-kodo",
 			"${kodo.region}": "z2",
 			"${kodo.domain}": "",
+			"${s3.endpoint}": "",
+			"${s3.region}": "us-east-1",
+			"${s3.bucket}": "unti-s3",
+			"${s3.access_key}": "",
+			"${s3.secret_key}": "",
+			"${s3.path_style}": "false",
+			"${s3.domain}": "",
+			"${upload.chunk_size}": 5,
+			"${upload.expire}": 24,
+			"${upload.reencode}": "true",
+			"${upload.guest.max_size}": uploadRoles[UploadRoleGuest].MaxSize,
+			"${upload.guest.types}": utils.Json.Encode(uploadRoles[UploadRoleGuest].Types),
+			"${upload.user.max_size}": uploadRoles[UploadRoleUser].MaxSize,
+			"${upload.user.types}": utils.Json.Encode(uploadRoles[UploadRoleUser].Types),
+			"${image.cache}": ImageCacheRuntime,
+			"${image.cache_size}": 1024,
+			"${image.sizes}": utils.Json.Encode(imageSizes),
+			"${image.quality}": 85,
+			"${image.qualities}": utils.Json.Encode(imageQualities),
+			"${image.max_blur}": 20,
+			"${image.max_pixels}": 40,
+			"${watermark.enable}": "false",
+			"${watermark.mode}": WatermarkModeLazy,
+			"${watermark.type}": "text",
+			"${watermark.text}": "unti",
+			"${watermark.image}": "public/assets/images/watermark.png",
+			"${watermark.position}": "bottom-right",
+			"${watermark.opacity}": 0.5,
+			"${watermark.scale}": 0.2,
+			"${watermark.margin}": 0.02,
+			"${watermark.min_width}": 300,
+			"${watermark.min_height}": 300,
+			"${watermark.exclude}": `["storage/rand/", "assets/"]`,
 		}),
 	}).Read()
 
@@ -178,6 +237,21 @@ func initStorage() {
 		),
 	}
 
+	// S3 兼容存储
+	s3Client, err := NewS3Client()
+	if err != nil {
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "S3 初始化错误")
+	}
+
+	S3 = &S3Struct{
+		Client: s3Client,
+	}
+
 	// 本地存储
 	LocalStorage = &LocalStorageStruct{}
 
@@ -190,6 +264,8 @@ func initStorage() {
 		Storage = COS
 	case "kodo":
 		Storage = KODO
+	case "s3":
+		Storage = S3
 	default:
 		Storage = LocalStorage
 	}
@@ -206,16 +282,123 @@ var LocalStorage *LocalStorageStruct
 var OSS *OSSStruct
 var COS *COSStruct
 var KODO *KODOStruct
+var S3 *S3Struct
 
 type StorageResponse struct {
-	Error  error
-	Path   string
-	Domain string
+	Error   error
+	Path    string
+	Domain  string
+	// Exists - 对象是否存在
+	Exist   bool
+	// Stat - 对象信息
+	Object  *StorageObject
+	// List - 对象列表
+	Objects []StorageObject
+	// List - 下一页游标，为空表示没有更多
+	Cursor  string
+	// Get - 对象内容，调用方负责关闭
+	Reader  io.ReadCloser
+	// SignedURL - 带签名的访问地址
+	URL     string
+	// InitUpload - 分片上传ID
+	UploadId string
+	// UploadPart - 已上传的分片
+	Part    *StoragePart
+}
+
+// StoragePart - 分片信息
+type StoragePart struct {
+	// 分片序号 - 从 1 开始
+	Index int    `json:"index"`
+	ETag  string `json:"etag"`
+	Size  int64  `json:"size"`
+}
+
+// StorageObject - 对象信息
+type StorageObject struct {
+	Key     string `json:"key"`
+	Size    int64  `json:"size"`
+	Mime    string `json:"mime"`
+	ETag    string `json:"etag"`
+	ModTime int64  `json:"mod_time"`
 }
 
 type StorageInterface interface {
+	// Upload - 上传文件
 	Upload(key string, reader io.Reader) *StorageResponse
+	// Path - 生成文件路径
 	Path() string
+	// Get - 读取对象内容
+	Get(key string) *StorageResponse
+	// Delete - 删除对象
+	Delete(key string) *StorageResponse
+	// Exists - 对象是否存在
+	Exists(key string) *StorageResponse
+	// Stat - 对象信息（大小、类型、ETag、修改时间）
+	Stat(key string) *StorageResponse
+	// List - 按前缀分页列出对象，cursor 为上一页返回的游标
+	List(prefix, cursor string, limit int) *StorageResponse
+	// Copy - 复制对象
+	Copy(src, dst string) *StorageResponse
+	// Move - 移动对象
+	Move(src, dst string) *StorageResponse
+	// SignedURL - 生成带签名的临时访问地址，method 为 GET、PUT 等
+	SignedURL(key string, ttl time.Duration, method string) *StorageResponse
+	// InitUpload - 初始化分片上传
+	InitUpload(key string) *StorageResponse
+	// UploadPart - 上传分片，index 从 1 开始，重复上传同一序号会覆盖
+	UploadPart(key, uploadId string, index int, reader io.Reader, size int64) *StorageResponse
+	// CompleteUpload - 按序号合并分片
+	CompleteUpload(key, uploadId string, parts []StoragePart) *StorageResponse
+	// AbortUpload - 取消分片上传
+	AbortUpload(key, uploadId string) *StorageResponse
+}
+
+// StorageKey - 把 URL 或 路径 转换为对象 key（不带前导 /）
+/**
+ * @example：
+ * facade.StorageKey("https://cdn.inis.cn/storage/2023-04/10/1.png") => "storage/2023-04/10/1.png"
+ */
+func StorageKey(value string) string {
+	if strings.Contains(value, "://") {
+		if item, err := url.Parse(value); err == nil {
+			value = item.Path
+		}
+	}
+	return strings.TrimLeft(path.Clean("/"+value), "/")
+}
+
+// storageLimit - 列表分页数量
+func storageLimit(limit int) int {
+	if limit <= 0 {
+		return 100
+	}
+	if limit > 1000 {
+		return 1000
+	}
+	return limit
+}
+
+// storageMethod - 签名地址的请求方式 - 默认 GET
+func storageMethod(method string) string {
+	return strings.ToUpper(utils.Ternary(utils.Is.Empty(method), "GET", method))
+}
+
+// storageObject - 从响应头中解析对象信息
+func storageObject(key string, header http.Header) *StorageObject {
+
+	object := &StorageObject{
+		Key:  key,
+		Size: cast.ToInt64(header.Get("Content-Length")),
+		Mime: header.Get("Content-Type"),
+		ETag: strings.Trim(header.Get("ETag"), `"`),
+	}
+
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		object.ModTime = modified.Unix()
+	}
+
+	return object
 }
 
 // =================================== 本地存储存储 - 开始 ===================================
@@ -251,296 +434,1947 @@ func (this *LocalStorageStruct) Path() string {
 	return "public/storage/" + dir + name
 }
 
-// ================================== 阿里云对象存储 - 开始 ==================================
-
-// OSSStruct 阿里云对象存储
-type OSSStruct struct {
-	Client *oss.Client
+// file - key 转换为本地文件路径 - 限制在 public 目录内
+func (this *LocalStorageStruct) file(key string) string {
+	key = strings.TrimPrefix(StorageKey(key), "public/")
+	return filepath.Join("public", filepath.FromSlash(path.Clean("/"+key)))
 }
 
-// Bucket - 获取Bucket（存储桶）
-func (this *OSSStruct) Bucket() *oss.Bucket {
+// Get - 读取文件
+func (this *LocalStorageStruct) Get(key string) (result *StorageResponse) {
 
-	exist, err := this.Client.IsBucketExist(cast.ToString(StorageToml.Get("oss.bucket")))
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
 
+	file, err := os.Open(this.file(key))
 	if err != nil {
-		Log.Error(map[string]any{
-			"error":     err,
-			"func_name": utils.Caller().FuncName,
-			"file_name": utils.Caller().FileName,
-			"file_line": utils.Caller().Line,
-		}, "OSS Bucket 初始化错误")
+		result.Error = err
+		return
 	}
 
-	wg := sync.WaitGroup{}
+	result.Reader = file
 
-	if !exist {
-		wg.Add(1)
-		go func(wg *sync.WaitGroup) {
-			defer wg.Done()
-			// 创建存储空间。
-			err = this.Client.CreateBucket(cast.ToString(StorageToml.Get("oss.bucket")))
-			if err != nil {
-				Log.Error(map[string]any{
-					"error":     err,
-					"func_name": utils.Caller().FuncName,
-					"file_name": utils.Caller().FileName,
-					"file_line": utils.Caller().Line,
-				}, "OSS Bucket 创建错误")
-			}
-		}(&wg)
+	return
+}
+
+// Delete - 删除文件
+func (this *LocalStorageStruct) Delete(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	if err := os.Remove(this.file(key)); err != nil && !os.IsNotExist(err) {
+		result.Error = err
 	}
 
-	wg.Wait()
+	return
+}
 
-	bucket, err := this.Client.Bucket(cast.ToString(StorageToml.Get("oss.bucket")))
+// Exists - 文件是否存在
+func (this *LocalStorageStruct) Exists(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	info, err := os.Stat(this.file(key))
 	if err != nil {
-		Log.Error(map[string]any{
-			"error":     err,
-			"func_name": utils.Caller().FuncName,
-			"file_name": utils.Caller().FileName,
-			"file_line": utils.Caller().Line,
-		}, "OSS Bucket 获取错误")
-		return nil
+		if !os.IsNotExist(err) {
+			result.Error = err
+		}
+		return
 	}
 
-	return bucket
+	result.Exist = !info.IsDir()
+
+	return
 }
 
-// Upload - 上传文件
-func (this *OSSStruct) Upload(key string, reader io.Reader) (result *StorageResponse) {
+// Stat - 文件信息
+func (this *LocalStorageStruct) Stat(key string) (result *StorageResponse) {
 
-	result = &StorageResponse{}
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
 
-	err := OSS.Bucket().PutObject(key, reader)
+	info, err := os.Stat(this.file(key))
 	if err != nil {
 		result.Error = err
 		return
 	}
 
-	if utils.Is.Empty(StorageToml.Get("oss.domain")) {
-		result.Domain = "https://" + cast.ToString(StorageToml.Get("oss.bucket")) + "." + cast.ToString(StorageToml.Get("oss.endpoint"))
-	} else {
-		result.Domain = cast.ToString(StorageToml.Get("oss.domain"))
+	if info.IsDir() {
+		result.Error = fmt.Errorf("%s is a directory", key)
+		return
 	}
 
-	result.Path = "/" + key
+	result.Exist  = true
+	result.Object = this.object(StorageKey(key), info)
 
 	return
 }
 
-// Path - OSS存储位置 - 生成文件路径
-func (this *OSSStruct) Path() string {
-	// 生成年月日目录 - 如：2023-04/10
-	dir := time.Now().Format("2006-01/02/")
-	// 生成文件名 - 年月日+毫秒时间戳
-	name := cast.ToString(time.Now().UnixNano() / 1e6)
-	return "storage/" + dir + name
-}
+// object - 本地文件信息 - ETag 由修改时间和大小组成
+func (this *LocalStorageStruct) object(key string, info os.FileInfo) *StorageObject {
 
-// ================================== 腾讯云对象存储 - 开始 ==================================
+	ext := strings.TrimPrefix(filepath.Ext(key), ".")
 
-// COSStruct 腾讯云对象存储
-type COSStruct struct {
-	Client *cos.Client
+	return &StorageObject{
+		Key:     strings.TrimPrefix(key, "public/"),
+		Size:    info.Size(),
+		Mime:    utils.Mime.Type(ext),
+		ETag:    fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+		ModTime: info.ModTime().Unix(),
+	}
 }
 
-// Object - 获取Object（对象存储）
-func (this *COSStruct) Object() *cos.ObjectService {
+// List - 按前缀列出文件 - 按 key 字典序分页，游标为上一页最后一个 key
+func (this *LocalStorageStruct) List(prefix, cursor string, limit int) (result *StorageResponse) {
 
-	// 查询存储桶
-	exist, err := this.Client.Bucket.IsExist(context.Background())
+	result = &StorageResponse{}
+	limit  = storageLimit(limit)
+	prefix = strings.TrimPrefix(StorageKey(prefix), "public/")
 
-	if err != nil {
-		Log.Error(map[string]any{
-			"error":     err,
-			"func_name": utils.Caller().FuncName,
-			"file_name": utils.Caller().FileName,
-			"file_line": utils.Caller().Line,
-		}, "COS Bucket 查询失败")
+	// 从前缀所在的目录开始遍历
+	root := this.file(prefix)
+	if !strings.HasSuffix(prefix, "/") {
+		root = filepath.Dir(root)
 	}
 
-	wg := sync.WaitGroup{}
+	var keys []string
+	infos := make(map[string]os.FileInfo)
 
-	if !exist {
-		wg.Add(1)
-		go func(wg *sync.WaitGroup) {
-			defer wg.Done()
-			// 创建存储桶 - 默认公共读私有写
-			_, err = this.Client.Bucket.Put(context.Background(), &cos.BucketPutOptions{
-				XCosACL: "public-read",
-			})
-			if err != nil {
-				Log.Error(map[string]any{
-					"error":     err,
-					"func_name": utils.Caller().FuncName,
-					"file_name": utils.Caller().FileName,
-					"file_line": utils.Caller().Line,
-				}, "COS Bucket 创建失败")
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
 			}
-		}(&wg)
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		key, err := filepath.Rel("public", name)
+		if err != nil {
+			return nil
+		}
+		key = filepath.ToSlash(key)
+		if strings.HasPrefix(key, prefix) && key > cursor {
+			keys = append(keys, key)
+			infos[key] = info
+		}
+		return nil
+	})
+
+	if err != nil {
+		result.Error = err
+		return
 	}
 
-	wg.Wait()
+	sort.Strings(keys)
 
-	return this.Client.Object
+	if len(keys) > limit {
+		keys = keys[:limit]
+		result.Cursor = keys[limit-1]
+	}
+
+	for _, key := range keys {
+		result.Objects = append(result.Objects, *this.object(key, infos[key]))
+	}
+
+	return
 }
 
-// Upload - 上传文件
-func (this *COSStruct) Upload(key string, reader io.Reader) (result *StorageResponse) {
+// Copy - 复制文件
+func (this *LocalStorageStruct) Copy(src, dst string) (result *StorageResponse) {
 
-	result = &StorageResponse{}
+	result = &StorageResponse{Path: "/" + StorageKey(dst)}
 
-	_, err := this.Object().Put(context.Background(), key, reader, nil)
+	reader, err := os.Open(this.file(src))
 	if err != nil {
 		result.Error = err
 		return
 	}
+	defer func(reader *os.File) {
+		_ = reader.Close()
+	}(reader)
 
-	if utils.Is.Empty(StorageToml.Get("cos.domain")) {
-		result.Domain = fmt.Sprintf("https://%s-%s.cos.%s.myqcloud.com",
-			cast.ToString(StorageToml.Get("cos.bucket")),
-			cast.ToString(StorageToml.Get("cos.app_id")),
-			cast.ToString(StorageToml.Get("cos.region")),
-		)
-	} else {
-		result.Domain = cast.ToString(StorageToml.Get("cos.domain"))
+	item := utils.File().Save(reader, this.file(dst))
+	if item.Error != nil {
+		result.Error = item.Error
+		return
 	}
 
-	result.Path = "/" + key
+	result.Domain = cast.ToString(StorageToml.Get("local.domain"))
 
 	return
 }
 
-// Path - COS存储位置 - 生成文件路径
-func (this *COSStruct) Path() string {
-	// 生成年月日目录 - 如：2023-04/10
-	dir := time.Now().Format("2006-01/02/")
-	// 生成文件名 - 年月日+毫秒时间戳
-	name := cast.ToString(time.Now().UnixNano() / 1e6)
-	return "storage/" + dir + name
-}
-
-// ================================== 七牛云对象存储 - 开始 ==================================
-
-// KODOStruct 七牛云对象存储
-type KODOStruct struct {
-	Client *qbox.Mac
-}
+// Move - 移动文件
+func (this *LocalStorageStruct) Move(src, dst string) (result *StorageResponse) {
 
-// IsExist - 存储空间是否存在
-func (this *KODOStruct) IsExist() bool {
+	result = &StorageResponse{Path: "/" + StorageKey(dst)}
 
-	bucket := storage.NewBucketManager(this.Client, nil)
-	_, err := bucket.GetBucketInfo(cast.ToString(StorageToml.Get("kodo.bucket")))
+	if err := os.MkdirAll(filepath.Dir(this.file(dst)), 0755); err != nil {
+		result.Error = err
+		return
+	}
 
-	if err != nil {
-		// 不存在则创建
-		if strings.Contains(err.Error(), "no such entry") {
-			return false
+	// 跨分区时 Rename 会失败，退化为复制后删除
+	if err := os.Rename(this.file(src), this.file(dst)); err != nil {
+		if result = this.Copy(src, dst); result.Error != nil {
+			return
+		}
+		if item := this.Delete(src); item.Error != nil {
+			result.Error = item.Error
+			return
 		}
 	}
 
-	return true
+	result.Domain = cast.ToString(StorageToml.Get("local.domain"))
+
+	return
 }
 
-func (this *KODOStruct) CreateBucket() error {
+// SignedURL - 生成带签名的临时访问地址 - 由 notRoute 调用 Verify 校验
+/**
+ * @example：
+ * /storage/2023-04/10/1.png?expires=1681142400&sig=xxx
+ */
+func (this *LocalStorageStruct) SignedURL(key string, ttl time.Duration, method string) (result *StorageResponse) {
 
-	bucketName := cast.ToString(StorageToml.Get("kodo.bucket"))
-	regionName := cast.ToString(StorageToml.Get("kodo.region"))
+	result = &StorageResponse{Path: "/" + strings.TrimPrefix(StorageKey(key), "public/")}
 
-	// 创建存储空间
-	config := storage.Config{
-		// 空间对应的机房
-		Zone: &storage.ZoneHuanan,
-		// 是否使用https域名
-		UseHTTPS: true,
-		// 上传是否使用CDN上传加速
-		UseCdnDomains: false,
+	// 本地文件只能通过 notRoute 读取
+	if method = storageMethod(method); method != "GET" && method != "HEAD" {
+		result.Error = fmt.Errorf("本地存储不支持 %s 签名", method)
+		return
 	}
 
-	// 创建存储空间
-	bucket := storage.NewBucketManager(this.Client, &config)
-	if region, ok := storage.GetRegionByID(storage.RegionID(regionName)); ok {
+	expires := cast.ToString(time.Now().Add(ttl).Unix())
 
-		config.Region = &region
-		err := bucket.CreateBucket(bucketName, storage.RegionID(regionName))
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("sig", this.sign(result.Path, expires, ""))
 
-		return utils.Ternary(err == nil, nil, err)
-	}
+	result.Domain = cast.ToString(StorageToml.Get("local.domain"))
+	result.URL    = result.Domain + result.Path + "?" + query.Encode()
 
-	return errors.New("存储空间创建失败")
+	return
 }
 
-func (this *KODOStruct) Bucket() *qbox.Mac {
+// WatermarkURL - 生成覆盖水印的签名地址 - watermark 参数参与签名，客户端无法自行修改或去除
+/**
+ * @param key 文件路径
+ * @param ttl 有效期
+ * @param watermark off：去除水印，其他为水印位置，如：top-left
+ * @example：
+ * /storage/2023-04/10/1.png?expires=1681142400&sig=xxx&watermark=off
+ */
+func (this *LocalStorageStruct) WatermarkURL(key string, ttl time.Duration, watermark string) (result *StorageResponse) {
 
-	// 如果存储空间不存在 - 则创建
-	if !this.IsExist() {
-		err := this.CreateBucket()
-		if err != nil {
-			Log.Error(map[string]any{
-				"error":     err,
-				"func_name": utils.Caller().FuncName,
-				"file_name": utils.Caller().FileName,
-				"file_line": utils.Caller().Line,
-			}, "KODO 存储空间创建失败")
-			return nil
+	result = &StorageResponse{Path: "/" + strings.TrimPrefix(StorageKey(key), "public/")}
+
+	if _, err := Watermark.Override(watermark); err != nil {
+		result.Error = err
+		return
+	}
+
+	expires := cast.ToString(time.Now().Add(ttl).Unix())
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("watermark", strings.ToLower(watermark))
+	query.Set("sig", this.sign(result.Path, expires, strings.ToLower(watermark)))
+
+	result.Domain = cast.ToString(StorageToml.Get("local.domain"))
+	result.URL    = result.Domain + result.Path + "?" + query.Encode()
+
+	return
+}
+
+// Verify - 校验签名地址
+/**
+ * @param path 请求路径，如：/storage/2023-04/10/1.png
+ * @param query 请求参数 - expires 过期时间戳，sig 签名，watermark 水印（可选，参与签名）
+ * @return error 为 nil 时表示允许访问；未开启 local.private 时，不带签名的请求直接放行
+ */
+func (this *LocalStorageStruct) Verify(path string, query url.Values) error {
+
+	expires, sig := query.Get("expires"), query.Get("sig")
+
+	if utils.Is.Empty(sig) {
+		if cast.ToBool(StorageToml.Get("local.private")) {
+			return errors.New("缺少签名！")
 		}
+		return nil
 	}
 
-	return this.Client
+	if cast.ToInt64(expires) < time.Now().Unix() {
+		return errors.New("签名已过期！")
+	}
+
+	path = "/" + strings.TrimPrefix(StorageKey(path), "public/")
+	if !hmac.Equal([]byte(sig), []byte(this.sign(path, expires, query.Get("watermark")))) {
+		return errors.New("签名错误！")
+	}
+
+	return nil
 }
 
-func (this *KODOStruct) Upload(key string, reader io.Reader) (result *StorageResponse) {
+// sign - HMAC-SHA256 签名 - 未配置 local.sign_key 时使用 jwt.key
+func (this *LocalStorageStruct) sign(path, expires, watermark string) string {
 
-	result = &StorageResponse{}
+	key := cast.ToString(StorageToml.Get("local.sign_key"))
+	if utils.Is.Empty(key) {
+		key = cast.ToString(CryptToml.Get("jwt.key"))
+	}
 
-	bucketName := cast.ToString(StorageToml.Get("kodo.bucket"))
-	regionName := cast.ToString(StorageToml.Get("kodo.region"))
+	item := hmac.New(sha256.New, []byte(key))
+	item.Write([]byte(path + "\n" + expires))
+	// 带水印参数时一起签名，不带时与旧的签名保持一致
+	if watermark != "" {
+		item.Write([]byte("\nwatermark=" + watermark))
+	}
 
-	policy := storage.PutPolicy{
-		Scope: bucketName,
+	return base64.RawURLEncoding.EncodeToString(item.Sum(nil))
+}
+
+// upload - 分片暂存目录
+func (this *LocalStorageStruct) upload(uploadId string) (string, error) {
+	if _, err := uuid.Parse(uploadId); err != nil {
+		return "", errors.New("upload id 不合法")
 	}
-	token := policy.UploadToken(this.Bucket())
+	return filepath.Join("runtime", "upload", uploadId), nil
+}
 
-	config := storage.Config{
-		// 空间对应的机房
-		Region: &storage.ZoneHuanan,
-		// 上传是否使用CDN上传加速
-		UseCdnDomains: false,
-		// 是否使用https域名
-		UseHTTPS: true,
+// InitUpload - 初始化分片上传 - 分片暂存在 runtime/upload 目录下
+func (this *LocalStorageStruct) InitUpload(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + strings.TrimPrefix(StorageKey(key), "public/")}
+
+	uploadId := uuid.New().String()
+	dir, _ := this.upload(uploadId)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		result.Error = err
+		return
 	}
 
-	// 构建表单上传的对象
-	bucket := storage.NewFormUploader(&config)
+	result.UploadId = uploadId
 
-	if region, ok := storage.GetRegionByID(storage.RegionID(regionName)); ok {
-		config.Region = &region
+	return
+}
+
+// UploadPart - 上传分片
+func (this *LocalStorageStruct) UploadPart(key, uploadId string, index int, reader io.Reader, size int64) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + strings.TrimPrefix(StorageKey(key), "public/"), UploadId: uploadId}
+
+	dir, err := this.upload(uploadId)
+	if err != nil {
+		result.Error = err
+		return
 	}
 
-	body := storage.PutRet{}
-	err := bucket.Put(context.Background(), &body, token, key, reader, -1, &storage.PutExtra{})
+	if !utils.File().Exist(dir) {
+		result.Error = errors.New("分片上传不存在或已过期")
+		return
+	}
+
+	// 先写临时文件再重命名，避免断线时留下不完整的分片
+	name := filepath.Join(dir, fmt.Sprintf("%d.part", index))
+	file, err := os.CreateTemp(dir, "*.tmp")
 	if err != nil {
 		result.Error = err
 		return
 	}
 
-	result.Path = "/" + key
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), reader)
+	_ = file.Close()
 
-	domain := cast.ToString(StorageToml.Get("kodo.domain"))
-	if !utils.Is.Empty(domain) {
-		result.Domain = cast.ToString(StorageToml.Get("kodo.domain"))
+	if err == nil && written != size {
+		err = fmt.Errorf("分片大小不一致：%d != %d", written, size)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		result.Error = err
+		return
+	}
+
+	result.Part = &StoragePart{Index: index, ETag: fmt.Sprintf("%x", hash.Sum(nil)), Size: written}
+
+	return
+}
+
+// CompleteUpload - 合并分片
+func (this *LocalStorageStruct) CompleteUpload(key, uploadId string, parts []StoragePart) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + strings.TrimPrefix(StorageKey(key), "public/"), UploadId: uploadId}
+
+	dir, err := this.upload(uploadId)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(this.file(key)), 0755); err != nil {
+		result.Error = err
+		return
+	}
+
+	// 先合并到临时文件，成功后再移动到目标位置
+	file, err := os.CreateTemp(dir, "*.merge")
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	for _, part := range parts {
+		if err = this.append(file, filepath.Join(dir, fmt.Sprintf("%d.part", part.Index))); err != nil {
+			break
+		}
+	}
+	_ = file.Close()
+
+	if err == nil {
+		err = os.Rename(file.Name(), this.file(key))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		result.Error = err
+		return
+	}
+
+	_ = os.RemoveAll(dir)
+
+	result.Domain = cast.ToString(StorageToml.Get("local.domain"))
+
+	return
+}
+
+// append - 把分片追加到文件末尾
+func (this *LocalStorageStruct) append(file *os.File, name string) error {
+
+	part, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func(part *os.File) {
+		_ = part.Close()
+	}(part)
+
+	_, err = io.Copy(file, part)
+
+	return err
+}
+
+// AbortUpload - 取消分片上传
+func (this *LocalStorageStruct) AbortUpload(key, uploadId string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + strings.TrimPrefix(StorageKey(key), "public/"), UploadId: uploadId}
+
+	dir, err := this.upload(uploadId)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if err = os.RemoveAll(dir); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// ================================== 阿里云对象存储 - 开始 ==================================
+
+// OSSStruct 阿里云对象存储
+type OSSStruct struct {
+	Client *oss.Client
+}
+
+// Bucket - 获取Bucket（存储桶）
+func (this *OSSStruct) Bucket() *oss.Bucket {
+
+	exist, err := this.Client.IsBucketExist(cast.ToString(StorageToml.Get("oss.bucket")))
+
+	if err != nil {
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "OSS Bucket 初始化错误")
+	}
+
+	wg := sync.WaitGroup{}
+
+	if !exist {
+		wg.Add(1)
+		go func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			// 创建存储空间。
+			err = this.Client.CreateBucket(cast.ToString(StorageToml.Get("oss.bucket")))
+			if err != nil {
+				Log.Error(map[string]any{
+					"error":     err,
+					"func_name": utils.Caller().FuncName,
+					"file_name": utils.Caller().FileName,
+					"file_line": utils.Caller().Line,
+				}, "OSS Bucket 创建错误")
+			}
+		}(&wg)
+	}
+
+	wg.Wait()
+
+	bucket, err := this.Client.Bucket(cast.ToString(StorageToml.Get("oss.bucket")))
+	if err != nil {
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "OSS Bucket 获取错误")
+		return nil
+	}
+
+	return bucket
+}
+
+// Upload - 上传文件
+func (this *OSSStruct) Upload(key string, reader io.Reader) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+
+	err := OSS.Bucket().PutObject(key, reader)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if utils.Is.Empty(StorageToml.Get("oss.domain")) {
+		result.Domain = "https://" + cast.ToString(StorageToml.Get("oss.bucket")) + "." + cast.ToString(StorageToml.Get("oss.endpoint"))
+	} else {
+		result.Domain = cast.ToString(StorageToml.Get("oss.domain"))
 	}
 
+	result.Path = "/" + key
+
 	return
 }
 
 // Path - OSS存储位置 - 生成文件路径
-func (this *KODOStruct) Path() string {
+func (this *OSSStruct) Path() string {
 	// 生成年月日目录 - 如：2023-04/10
 	dir := time.Now().Format("2006-01/02/")
 	// 生成文件名 - 年月日+毫秒时间戳
 	name := cast.ToString(time.Now().UnixNano() / 1e6)
 	return "storage/" + dir + name
 }
+
+// Get - 读取对象
+func (this *OSSStruct) Get(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	reader, err := this.Bucket().GetObject(StorageKey(key))
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Reader = reader
+
+	return
+}
+
+// Delete - 删除对象
+func (this *OSSStruct) Delete(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	if err := this.Bucket().DeleteObject(StorageKey(key)); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// Exists - 对象是否存在
+func (this *OSSStruct) Exists(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	exist, err := this.Bucket().IsObjectExist(StorageKey(key))
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Exist = exist
+
+	return
+}
+
+// Stat - 对象信息
+func (this *OSSStruct) Stat(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	header, err := this.Bucket().GetObjectDetailedMeta(StorageKey(key))
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Exist  = true
+	result.Object = storageObject(StorageKey(key), header)
+
+	return
+}
+
+// List - 按前缀列出对象
+func (this *OSSStruct) List(prefix, cursor string, limit int) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+
+	options := []oss.Option{
+		oss.Prefix(StorageKey(prefix)),
+		oss.MaxKeys(storageLimit(limit)),
+	}
+	if !utils.Is.Empty(cursor) {
+		options = append(options, oss.ContinuationToken(cursor))
+	}
+
+	item, err := this.Bucket().ListObjectsV2(options...)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	for _, value := range item.Objects {
+		result.Objects = append(result.Objects, StorageObject{
+			Key:     value.Key,
+			Size:    value.Size,
+			Mime:    utils.Mime.Type(strings.TrimPrefix(filepath.Ext(value.Key), ".")),
+			ETag:    strings.Trim(value.ETag, `"`),
+			ModTime: value.LastModified.Unix(),
+		})
+	}
+
+	if item.IsTruncated {
+		result.Cursor = item.NextContinuationToken
+	}
+
+	return
+}
+
+// Copy - 复制对象
+func (this *OSSStruct) Copy(src, dst string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(dst)}
+
+	if _, err := this.Bucket().CopyObject(StorageKey(src), StorageKey(dst)); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// Move - 移动对象 - OSS 没有原生移动，复制后删除
+func (this *OSSStruct) Move(src, dst string) (result *StorageResponse) {
+
+	if result = this.Copy(src, dst); result.Error != nil {
+		return
+	}
+
+	if item := this.Delete(src); item.Error != nil {
+		result.Error = item.Error
+	}
+
+	return
+}
+
+// SignedURL - 生成带签名的临时访问地址
+func (this *OSSStruct) SignedURL(key string, ttl time.Duration, method string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	item, err := this.Bucket().SignURL(StorageKey(key), oss.HTTPMethod(storageMethod(method)), int64(ttl.Seconds()))
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.URL = item
+
+	return
+}
+
+// multipart - 分片上传信息
+func (this *OSSStruct) multipart(key, uploadId string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{
+		Bucket:   cast.ToString(StorageToml.Get("oss.bucket")),
+		Key:      StorageKey(key),
+		UploadID: uploadId,
+	}
+}
+
+// InitUpload - 初始化分片上传
+func (this *OSSStruct) InitUpload(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	item, err := this.Bucket().InitiateMultipartUpload(StorageKey(key))
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.UploadId = item.UploadID
+
+	return
+}
+
+// UploadPart - 上传分片
+func (this *OSSStruct) UploadPart(key, uploadId string, index int, reader io.Reader, size int64) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	item, err := this.Bucket().UploadPart(this.multipart(key, uploadId), reader, size, index)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Part = &StoragePart{Index: index, ETag: item.ETag, Size: size}
+
+	return
+}
+
+// CompleteUpload - 合并分片
+func (this *OSSStruct) CompleteUpload(key, uploadId string, parts []StoragePart) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	var items []oss.UploadPart
+	for _, part := range parts {
+		items = append(items, oss.UploadPart{PartNumber: part.Index, ETag: part.ETag})
+	}
+
+	if _, err := this.Bucket().CompleteMultipartUpload(this.multipart(key, uploadId), items); err != nil {
+		result.Error = err
+		return
+	}
+
+	if utils.Is.Empty(StorageToml.Get("oss.domain")) {
+		result.Domain = "https://" + cast.ToString(StorageToml.Get("oss.bucket")) + "." + cast.ToString(StorageToml.Get("oss.endpoint"))
+	} else {
+		result.Domain = cast.ToString(StorageToml.Get("oss.domain"))
+	}
+
+	return
+}
+
+// AbortUpload - 取消分片上传
+func (this *OSSStruct) AbortUpload(key, uploadId string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	if err := this.Bucket().AbortMultipartUpload(this.multipart(key, uploadId)); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// ================================== 腾讯云对象存储 - 开始 ==================================
+
+// COSStruct 腾讯云对象存储
+type COSStruct struct {
+	Client *cos.Client
+}
+
+// Object - 获取Object（对象存储）
+func (this *COSStruct) Object() *cos.ObjectService {
+
+	// 查询存储桶
+	exist, err := this.Client.Bucket.IsExist(context.Background())
+
+	if err != nil {
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "COS Bucket 查询失败")
+	}
+
+	wg := sync.WaitGroup{}
+
+	if !exist {
+		wg.Add(1)
+		go func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			// 创建存储桶 - 默认公共读私有写
+			_, err = this.Client.Bucket.Put(context.Background(), &cos.BucketPutOptions{
+				XCosACL: "public-read",
+			})
+			if err != nil {
+				Log.Error(map[string]any{
+					"error":     err,
+					"func_name": utils.Caller().FuncName,
+					"file_name": utils.Caller().FileName,
+					"file_line": utils.Caller().Line,
+				}, "COS Bucket 创建失败")
+			}
+		}(&wg)
+	}
+
+	wg.Wait()
+
+	return this.Client.Object
+}
+
+// Upload - 上传文件
+func (this *COSStruct) Upload(key string, reader io.Reader) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+
+	_, err := this.Object().Put(context.Background(), key, reader, nil)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if utils.Is.Empty(StorageToml.Get("cos.domain")) {
+		result.Domain = fmt.Sprintf("https://%s-%s.cos.%s.myqcloud.com",
+			cast.ToString(StorageToml.Get("cos.bucket")),
+			cast.ToString(StorageToml.Get("cos.app_id")),
+			cast.ToString(StorageToml.Get("cos.region")),
+		)
+	} else {
+		result.Domain = cast.ToString(StorageToml.Get("cos.domain"))
+	}
+
+	result.Path = "/" + key
+
+	return
+}
+
+// Path - COS存储位置 - 生成文件路径
+func (this *COSStruct) Path() string {
+	// 生成年月日目录 - 如：2023-04/10
+	dir := time.Now().Format("2006-01/02/")
+	// 生成文件名 - 年月日+毫秒时间戳
+	name := cast.ToString(time.Now().UnixNano() / 1e6)
+	return "storage/" + dir + name
+}
+
+// Get - 读取对象
+func (this *COSStruct) Get(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	item, err := this.Object().Get(context.Background(), StorageKey(key), nil)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Reader = item.Body
+
+	return
+}
+
+// Delete - 删除对象
+func (this *COSStruct) Delete(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	if _, err := this.Object().Delete(context.Background(), StorageKey(key)); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// Exists - 对象是否存在
+func (this *COSStruct) Exists(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	exist, err := this.Object().IsExist(context.Background(), StorageKey(key))
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Exist = exist
+
+	return
+}
+
+// Stat - 对象信息
+func (this *COSStruct) Stat(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	item, err := this.Object().Head(context.Background(), StorageKey(key), nil)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Exist  = true
+	result.Object = storageObject(StorageKey(key), item.Header)
+
+	return
+}
+
+// List - 按前缀列出对象
+func (this *COSStruct) List(prefix, cursor string, limit int) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+
+	item, _, err := this.Client.Bucket.Get(context.Background(), &cos.BucketGetOptions{
+		Prefix:  StorageKey(prefix),
+		Marker:  cursor,
+		MaxKeys: storageLimit(limit),
+	})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	for _, value := range item.Contents {
+		object := StorageObject{
+			Key:  value.Key,
+			Size: value.Size,
+			Mime: utils.Mime.Type(strings.TrimPrefix(filepath.Ext(value.Key), ".")),
+			ETag: strings.Trim(value.ETag, `"`),
+		}
+		if modified, err := time.Parse(time.RFC3339, value.LastModified); err == nil {
+			object.ModTime = modified.Unix()
+		}
+		result.Objects = append(result.Objects, object)
+	}
+
+	if item.IsTruncated {
+		result.Cursor = item.NextMarker
+		// 未指定分隔符时 COS 不返回 NextMarker，使用最后一个 key
+		if utils.Is.Empty(result.Cursor) && len(item.Contents) > 0 {
+			result.Cursor = item.Contents[len(item.Contents)-1].Key
+		}
+	}
+
+	return
+}
+
+// Copy - 复制对象
+func (this *COSStruct) Copy(src, dst string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(dst)}
+
+	source := fmt.Sprintf("%s/%s", this.Client.BaseURL.BucketURL.Host, StorageKey(src))
+
+	if _, _, err := this.Object().Copy(context.Background(), StorageKey(dst), source, nil); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// Move - 移动对象 - COS 没有原生移动，复制后删除
+func (this *COSStruct) Move(src, dst string) (result *StorageResponse) {
+
+	if result = this.Copy(src, dst); result.Error != nil {
+		return
+	}
+
+	if item := this.Delete(src); item.Error != nil {
+		result.Error = item.Error
+	}
+
+	return
+}
+
+// SignedURL - 生成带签名的临时访问地址
+func (this *COSStruct) SignedURL(key string, ttl time.Duration, method string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	item, err := this.Object().GetPresignedURL(context.Background(), storageMethod(method), StorageKey(key),
+		cast.ToString(StorageToml.Get("cos.secret_id")),
+		cast.ToString(StorageToml.Get("cos.secret_key")),
+		ttl, nil,
+	)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.URL = item.String()
+
+	return
+}
+
+// InitUpload - 初始化分片上传
+func (this *COSStruct) InitUpload(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	item, _, err := this.Object().InitiateMultipartUpload(context.Background(), StorageKey(key), nil)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.UploadId = item.UploadID
+
+	return
+}
+
+// UploadPart - 上传分片
+func (this *COSStruct) UploadPart(key, uploadId string, index int, reader io.Reader, size int64) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	item, err := this.Object().UploadPart(context.Background(), StorageKey(key), uploadId, index, reader, &cos.ObjectUploadPartOptions{
+		ContentLength: size,
+	})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Part = &StoragePart{Index: index, ETag: item.Header.Get("ETag"), Size: size}
+
+	return
+}
+
+// CompleteUpload - 合并分片
+func (this *COSStruct) CompleteUpload(key, uploadId string, parts []StoragePart) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	options := &cos.CompleteMultipartUploadOptions{}
+	for _, part := range parts {
+		options.Parts = append(options.Parts, cos.Object{PartNumber: part.Index, ETag: part.ETag})
+	}
+
+	if _, _, err := this.Object().CompleteMultipartUpload(context.Background(), StorageKey(key), uploadId, options); err != nil {
+		result.Error = err
+		return
+	}
+
+	if utils.Is.Empty(StorageToml.Get("cos.domain")) {
+		result.Domain = "https://" + this.Client.BaseURL.BucketURL.Host
+	} else {
+		result.Domain = cast.ToString(StorageToml.Get("cos.domain"))
+	}
+
+	return
+}
+
+// AbortUpload - 取消分片上传
+func (this *COSStruct) AbortUpload(key, uploadId string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	if _, err := this.Object().AbortMultipartUpload(context.Background(), StorageKey(key), uploadId); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// ================================== 七牛云对象存储 - 开始 ==================================
+
+// KODOStruct 七牛云对象存储
+type KODOStruct struct {
+	Client *qbox.Mac
+}
+
+// IsExist - 存储空间是否存在
+func (this *KODOStruct) IsExist() bool {
+
+	bucket := storage.NewBucketManager(this.Client, nil)
+	_, err := bucket.GetBucketInfo(cast.ToString(StorageToml.Get("kodo.bucket")))
+
+	if err != nil {
+		// 不存在则创建
+		if strings.Contains(err.Error(), "no such entry") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (this *KODOStruct) CreateBucket() error {
+
+	bucketName := cast.ToString(StorageToml.Get("kodo.bucket"))
+	regionName := cast.ToString(StorageToml.Get("kodo.region"))
+
+	// 创建存储空间
+	config := storage.Config{
+		// 空间对应的机房
+		Zone: &storage.ZoneHuanan,
+		// 是否使用https域名
+		UseHTTPS: true,
+		// 上传是否使用CDN上传加速
+		UseCdnDomains: false,
+	}
+
+	// 创建存储空间
+	bucket := storage.NewBucketManager(this.Client, &config)
+	if region, ok := storage.GetRegionByID(storage.RegionID(regionName)); ok {
+
+		config.Region = &region
+		err := bucket.CreateBucket(bucketName, storage.RegionID(regionName))
+
+		return utils.Ternary(err == nil, nil, err)
+	}
+
+	return errors.New("存储空间创建失败")
+}
+
+func (this *KODOStruct) Bucket() *qbox.Mac {
+
+	// 如果存储空间不存在 - 则创建
+	if !this.IsExist() {
+		err := this.CreateBucket()
+		if err != nil {
+			Log.Error(map[string]any{
+				"error":     err,
+				"func_name": utils.Caller().FuncName,
+				"file_name": utils.Caller().FileName,
+				"file_line": utils.Caller().Line,
+			}, "KODO 存储空间创建失败")
+			return nil
+		}
+	}
+
+	return this.Client
+}
+
+func (this *KODOStruct) Upload(key string, reader io.Reader) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+
+	bucketName := cast.ToString(StorageToml.Get("kodo.bucket"))
+	regionName := cast.ToString(StorageToml.Get("kodo.region"))
+
+	policy := storage.PutPolicy{
+		Scope: bucketName,
+	}
+	token := policy.UploadToken(this.Bucket())
+
+	config := storage.Config{
+		// 空间对应的机房
+		Region: &storage.ZoneHuanan,
+		// 上传是否使用CDN上传加速
+		UseCdnDomains: false,
+		// 是否使用https域名
+		UseHTTPS: true,
+	}
+
+	// 构建表单上传的对象
+	bucket := storage.NewFormUploader(&config)
+
+	if region, ok := storage.GetRegionByID(storage.RegionID(regionName)); ok {
+		config.Region = &region
+	}
+
+	body := storage.PutRet{}
+	err := bucket.Put(context.Background(), &body, token, key, reader, -1, &storage.PutExtra{})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Path = "/" + key
+
+	domain := cast.ToString(StorageToml.Get("kodo.domain"))
+	if !utils.Is.Empty(domain) {
+		result.Domain = cast.ToString(StorageToml.Get("kodo.domain"))
+	}
+
+	return
+}
+
+// Path - OSS存储位置 - 生成文件路径
+func (this *KODOStruct) Path() string {
+	// 生成年月日目录 - 如：2023-04/10
+	dir := time.Now().Format("2006-01/02/")
+	// 生成文件名 - 年月日+毫秒时间戳
+	name := cast.ToString(time.Now().UnixNano() / 1e6)
+	return "storage/" + dir + name
+}
+
+// manager - 对象管理
+func (this *KODOStruct) manager() *storage.BucketManager {
+
+	config := storage.Config{
+		// 是否使用https域名
+		UseHTTPS: true,
+	}
+
+	if region, ok := storage.GetRegionByID(storage.RegionID(cast.ToString(StorageToml.Get("kodo.region")))); ok {
+		config.Region = &region
+	}
+
+	return storage.NewBucketManager(this.Client, &config)
+}
+
+// Get - 读取对象 - 通过带签名的下载链接获取，私有空间也可用
+func (this *KODOStruct) Get(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	domain := cast.ToString(StorageToml.Get("kodo.domain"))
+	if utils.Is.Empty(domain) {
+		result.Error = errors.New("kodo.domain 未配置")
+		return
+	}
+
+	link := storage.MakePrivateURLv2(this.Client, domain, StorageKey(key), time.Now().Add(time.Hour).Unix())
+
+	item, err := http.Get(link)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if item.StatusCode != http.StatusOK {
+		_ = item.Body.Close()
+		result.Error = fmt.Errorf("状态码：%d", item.StatusCode)
+		return
+	}
+
+	result.Reader = item.Body
+
+	return
+}
+
+// Delete - 删除对象
+func (this *KODOStruct) Delete(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	err := this.manager().Delete(cast.ToString(StorageToml.Get("kodo.bucket")), StorageKey(key))
+	if err != nil && !this.notFound(err) {
+		result.Error = err
+	}
+
+	return
+}
+
+// Exists - 对象是否存在
+func (this *KODOStruct) Exists(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	_, err := this.manager().Stat(cast.ToString(StorageToml.Get("kodo.bucket")), StorageKey(key))
+	if err != nil {
+		if !this.notFound(err) {
+			result.Error = err
+		}
+		return
+	}
+
+	result.Exist = true
+
+	return
+}
+
+// Stat - 对象信息
+func (this *KODOStruct) Stat(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	item, err := this.manager().Stat(cast.ToString(StorageToml.Get("kodo.bucket")), StorageKey(key))
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Exist  = true
+	result.Object = &StorageObject{
+		Key:  StorageKey(key),
+		Size: item.Fsize,
+		Mime: item.MimeType,
+		ETag: item.Hash,
+		// 上传时间单位为100纳秒
+		ModTime: item.PutTime / 1e7,
+	}
+
+	return
+}
+
+// List - 按前缀列出对象
+func (this *KODOStruct) List(prefix, cursor string, limit int) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+
+	items, _, marker, next, err := this.manager().ListFiles(
+		cast.ToString(StorageToml.Get("kodo.bucket")), StorageKey(prefix), "", cursor, storageLimit(limit),
+	)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	for _, value := range items {
+		result.Objects = append(result.Objects, StorageObject{
+			Key:     value.Key,
+			Size:    value.Fsize,
+			Mime:    value.MimeType,
+			ETag:    value.Hash,
+			ModTime: value.PutTime / 1e7,
+		})
+	}
+
+	if next {
+		result.Cursor = marker
+	}
+
+	return
+}
+
+// Copy - 复制对象
+func (this *KODOStruct) Copy(src, dst string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(dst)}
+
+	bucket := cast.ToString(StorageToml.Get("kodo.bucket"))
+	if err := this.manager().Copy(bucket, StorageKey(src), bucket, StorageKey(dst), true); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// Move - 移动对象
+func (this *KODOStruct) Move(src, dst string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(dst)}
+
+	bucket := cast.ToString(StorageToml.Get("kodo.bucket"))
+	if err := this.manager().Move(bucket, StorageKey(src), bucket, StorageKey(dst), true); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// notFound - 对象不存在的错误
+func (this *KODOStruct) notFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such file or directory")
+}
+
+// SignedURL - 生成带签名的临时访问地址 - KODO 仅支持下载签名，上传请使用上传凭证
+func (this *KODOStruct) SignedURL(key string, ttl time.Duration, method string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	if method = storageMethod(method); method != "GET" && method != "HEAD" {
+		result.Error = fmt.Errorf("KODO 不支持 %s 签名", method)
+		return
+	}
+
+	domain := cast.ToString(StorageToml.Get("kodo.domain"))
+	if utils.Is.Empty(domain) {
+		result.Error = errors.New("kodo.domain 未配置")
+		return
+	}
+
+	result.URL = storage.MakePrivateURLv2(this.Client, domain, StorageKey(key), time.Now().Add(ttl).Unix())
+
+	return
+}
+
+// resume - 分片上传 v2 的上传器、凭证和上传域名
+func (this *KODOStruct) resume(key string) (uploader *storage.ResumeUploaderV2, token, host string, err error) {
+
+	bucket := cast.ToString(StorageToml.Get("kodo.bucket"))
+
+	config := storage.Config{
+		// 是否使用https域名
+		UseHTTPS: true,
+	}
+	if region, ok := storage.GetRegionByID(storage.RegionID(cast.ToString(StorageToml.Get("kodo.region")))); ok {
+		config.Region = &region
+	}
+
+	policy := storage.PutPolicy{
+		// 指定 key 允许覆盖同名文件
+		Scope: bucket + ":" + StorageKey(key),
+	}
+
+	uploader = storage.NewResumeUploaderV2(&config)
+	token    = policy.UploadToken(this.Client)
+	host, err = uploader.UpHost(this.Client.AccessKey, bucket)
+
+	return
+}
+
+// InitUpload - 初始化分片上传
+func (this *KODOStruct) InitUpload(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	uploader, token, host, err := this.resume(key)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	item := storage.InitPartsRet{}
+	err = uploader.InitParts(context.Background(), token, host, cast.ToString(StorageToml.Get("kodo.bucket")), StorageKey(key), true, &item)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.UploadId = item.UploadID
+
+	return
+}
+
+// UploadPart - 上传分片
+func (this *KODOStruct) UploadPart(key, uploadId string, index int, reader io.Reader, size int64) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	uploader, token, host, err := this.resume(key)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	item := storage.UploadPartsRet{}
+	err = uploader.UploadParts(context.Background(), token, host, cast.ToString(StorageToml.Get("kodo.bucket")),
+		StorageKey(key), true, uploadId, int64(index), "", &item, reader, int(size),
+	)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Part = &StoragePart{Index: index, ETag: item.Etag, Size: size}
+
+	return
+}
+
+// CompleteUpload - 合并分片
+func (this *KODOStruct) CompleteUpload(key, uploadId string, parts []StoragePart) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	uploader, token, host, err := this.resume(key)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	extra := &storage.RputV2Extra{}
+	for _, part := range parts {
+		extra.Progresses = append(extra.Progresses, storage.UploadPartInfo{PartNumber: int64(part.Index), Etag: part.ETag})
+	}
+
+	item := storage.PutRet{}
+	err = uploader.CompleteParts(context.Background(), token, host, &item, cast.ToString(StorageToml.Get("kodo.bucket")),
+		StorageKey(key), true, uploadId, extra,
+	)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Domain = cast.ToString(StorageToml.Get("kodo.domain"))
+
+	return
+}
+
+// AbortUpload - 取消分片上传 - SDK 未提供取消接口，未合并的分片由七牛过期清理
+func (this *KODOStruct) AbortUpload(key, uploadId string) (result *StorageResponse) {
+	return &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+}
+
+// ================================== S3兼容对象存储 - 开始 ==================================
+
+// S3Struct - S3 兼容对象存储（AWS S3、MinIO、Cloudflare R2、Ceph 等）
+type S3Struct struct {
+	Client *minio.Client
+	// 存储桶是否已检查
+	ready  bool
+	// 上次检查失败的时间 - 失败后间隔一段时间再重试
+	failed time.Time
+	mutex  sync.Mutex
+}
+
+// s3Retry - 存储桶检查失败后的重试间隔
+const s3Retry = time.Minute
+
+// NewS3Client - 根据 storage.toml 中的 [s3] 配置创建客户端
+/**
+ * @return *minio.Client, error
+ * @example：
+ * endpoint = "http://127.0.0.1:9000" 时使用 http，不带协议时默认 https
+ */
+func NewS3Client() (*minio.Client, error) {
+
+	endpoint := cast.ToString(StorageToml.Get("s3.endpoint"))
+	if utils.Is.Empty(endpoint) {
+		endpoint = "https://s3.amazonaws.com"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	item, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := minio.BucketLookupDNS
+	if cast.ToBool(StorageToml.Get("s3.path_style")) {
+		lookup = minio.BucketLookupPath
+	}
+
+	return minio.New(item.Host, &minio.Options{
+		Creds: credentials.NewStaticV4(
+			cast.ToString(StorageToml.Get("s3.access_key")),
+			cast.ToString(StorageToml.Get("s3.secret_key")),
+			"",
+		),
+		Secure:       item.Scheme == "https",
+		Region:       cast.ToString(StorageToml.Get("s3.region")),
+		BucketLookup: lookup,
+	})
+}
+
+// Bucket - 存储桶名称 - 首次调用时检查存储桶是否存在，不存在则创建
+/**
+ * 检查失败（网络抖动、密钥没有存储桶权限等）时不影响本次操作，间隔 s3Retry 后再次检查
+ */
+func (this *S3Struct) Bucket() string {
+
+	bucket := cast.ToString(StorageToml.Get("s3.bucket"))
+
+	if this.Client == nil {
+		return bucket
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.ready || time.Since(this.failed) < s3Retry {
+		return bucket
+	}
+
+	if err := this.check(bucket); err != nil {
+		this.failed = time.Now()
+		Log.Error(map[string]any{
+			"error":     err,
+			"func_name": utils.Caller().FuncName,
+			"file_name": utils.Caller().FileName,
+			"file_line": utils.Caller().Line,
+		}, "S3 Bucket 初始化错误")
+		return bucket
+	}
+
+	this.ready = true
+
+	return bucket
+}
+
+// check - 检查存储桶是否存在，不存在则创建
+func (this *S3Struct) check(bucket string) error {
+
+	exist, err := this.Client.BucketExists(context.Background(), bucket)
+	if err != nil || exist {
+		return err
+	}
+
+	return this.Client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{
+		Region: cast.ToString(StorageToml.Get("s3.region")),
+	})
+}
+
+// bucket - 存储桶名称 - 客户端未初始化时返回错误
+func (this *S3Struct) bucket() (string, error) {
+	if this.Client == nil {
+		return "", errors.New("S3 未初始化")
+	}
+	return this.Bucket(), nil
+}
+
+// Domain - 访问域名 - 未配置时根据 endpoint 和 path_style 拼接
+func (this *S3Struct) Domain() string {
+
+	if !utils.Is.Empty(StorageToml.Get("s3.domain")) {
+		return strings.TrimRight(cast.ToString(StorageToml.Get("s3.domain")), "/")
+	}
+
+	if this.Client == nil {
+		return ""
+	}
+
+	endpoint := this.Client.EndpointURL()
+	bucket   := cast.ToString(StorageToml.Get("s3.bucket"))
+
+	if cast.ToBool(StorageToml.Get("s3.path_style")) {
+		return fmt.Sprintf("%s://%s/%s", endpoint.Scheme, endpoint.Host, bucket)
+	}
+
+	return fmt.Sprintf("%s://%s.%s", endpoint.Scheme, bucket, endpoint.Host)
+}
+
+// Upload - 上传文件
+func (this *S3Struct) Upload(key string, reader io.Reader) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	// 大小未知时 SDK 会自动分片上传
+	size := int64(-1)
+	if item, ok := reader.(interface{ Len() int }); ok {
+		size = int64(item.Len())
+	}
+
+	_, err = this.Client.PutObject(context.Background(), bucket, StorageKey(key), reader, size, minio.PutObjectOptions{
+		ContentType: utils.Mime.Type(strings.TrimPrefix(filepath.Ext(key), ".")),
+		// http 下默认使用 aws-chunked 流式签名，R2 等部分兼容实现不支持，统一使用 UNSIGNED-PAYLOAD
+		DisableContentSha256: true,
+	})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Domain = this.Domain()
+	result.Path   = "/" + StorageKey(key)
+
+	return
+}
+
+// Path - S3存储位置 - 生成文件路径
+func (this *S3Struct) Path() string {
+	// 生成年月日目录 - 如：2023-04/10
+	dir := time.Now().Format("2006-01/02/")
+	// 生成文件名 - 年月日+毫秒时间戳
+	name := cast.ToString(time.Now().UnixNano() / 1e6)
+	return "storage/" + dir + name
+}
+
+// Get - 读取对象
+func (this *S3Struct) Get(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	item, err := this.Client.GetObject(context.Background(), bucket, StorageKey(key), minio.GetObjectOptions{})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	// GetObject 是惰性请求，通过 Stat 提前暴露对象不存在等错误
+	if _, err = item.Stat(); err != nil {
+		_ = item.Close()
+		result.Error = err
+		return
+	}
+
+	result.Reader = item
+
+	return
+}
+
+// Delete - 删除对象
+func (this *S3Struct) Delete(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if err = this.Client.RemoveObject(context.Background(), bucket, StorageKey(key), minio.RemoveObjectOptions{}); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// Exists - 对象是否存在
+func (this *S3Struct) Exists(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if _, err = this.Client.StatObject(context.Background(), bucket, StorageKey(key), minio.StatObjectOptions{}); err != nil {
+		if !this.notFound(err) {
+			result.Error = err
+		}
+		return
+	}
+
+	result.Exist = true
+
+	return
+}
+
+// Stat - 对象信息
+func (this *S3Struct) Stat(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	item, err := this.Client.StatObject(context.Background(), bucket, StorageKey(key), minio.StatObjectOptions{})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Exist  = true
+	result.Object = &StorageObject{
+		Key:     item.Key,
+		Size:    item.Size,
+		Mime:    item.ContentType,
+		ETag:    strings.Trim(item.ETag, `"`),
+		ModTime: item.LastModified.Unix(),
+	}
+
+	return
+}
+
+// List - 按前缀列出对象 - 游标为上一页最后一个 key
+func (this *S3Struct) List(prefix, cursor string, limit int) (result *StorageResponse) {
+
+	result = &StorageResponse{}
+	limit  = storageLimit(limit)
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 多取一条用于判断是否还有下一页
+	for item := range this.Client.ListObjects(ctx, bucket, minio.ListObjectsOptions{
+		Prefix:     StorageKey(prefix) + utils.Ternary(strings.HasSuffix(prefix, "/"), "/", ""),
+		StartAfter: cursor,
+		MaxKeys:    limit + 1,
+		Recursive:  true,
+	}) {
+		if item.Err != nil {
+			result.Error = item.Err
+			return
+		}
+		if len(result.Objects) == limit {
+			result.Cursor = result.Objects[limit-1].Key
+			break
+		}
+		result.Objects = append(result.Objects, StorageObject{
+			Key:     item.Key,
+			Size:    item.Size,
+			Mime:    utils.Mime.Type(strings.TrimPrefix(filepath.Ext(item.Key), ".")),
+			ETag:    strings.Trim(item.ETag, `"`),
+			ModTime: item.LastModified.Unix(),
+		})
+	}
+
+	return
+}
+
+// Copy - 复制对象
+func (this *S3Struct) Copy(src, dst string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(dst)}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	_, err = this.Client.CopyObject(context.Background(),
+		minio.CopyDestOptions{Bucket: bucket, Object: StorageKey(dst)},
+		minio.CopySrcOptions{Bucket: bucket, Object: StorageKey(src)},
+	)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Domain = this.Domain()
+
+	return
+}
+
+// Move - 移动对象 - S3 没有原生移动，复制后删除
+func (this *S3Struct) Move(src, dst string) (result *StorageResponse) {
+
+	if result = this.Copy(src, dst); result.Error != nil {
+		return
+	}
+
+	if item := this.Delete(src); item.Error != nil {
+		result.Error = item.Error
+	}
+
+	return
+}
+
+// SignedURL - 生成带签名的临时访问地址 - 有效期最长 7 天
+func (this *S3Struct) SignedURL(key string, ttl time.Duration, method string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	item, err := this.Client.Presign(context.Background(), storageMethod(method), bucket, StorageKey(key), ttl, nil)
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.URL = item.String()
+
+	return
+}
+
+// core - 分片上传等底层接口
+func (this *S3Struct) core() *minio.Core {
+	return &minio.Core{Client: this.Client}
+}
+
+// InitUpload - 初始化分片上传
+func (this *S3Struct) InitUpload(key string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key)}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	uploadId, err := this.core().NewMultipartUpload(context.Background(), bucket, StorageKey(key), minio.PutObjectOptions{
+		ContentType: utils.Mime.Type(strings.TrimPrefix(filepath.Ext(key), ".")),
+	})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.UploadId = uploadId
+
+	return
+}
+
+// UploadPart - 上传分片
+func (this *S3Struct) UploadPart(key, uploadId string, index int, reader io.Reader, size int64) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	item, err := this.core().PutObjectPart(context.Background(), bucket, StorageKey(key), uploadId, index, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Part = &StoragePart{Index: index, ETag: item.ETag, Size: size}
+
+	return
+}
+
+// CompleteUpload - 合并分片
+func (this *S3Struct) CompleteUpload(key, uploadId string, parts []StoragePart) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	var items []minio.CompletePart
+	for _, part := range parts {
+		items = append(items, minio.CompletePart{PartNumber: part.Index, ETag: part.ETag})
+	}
+
+	_, err = this.core().CompleteMultipartUpload(context.Background(), bucket, StorageKey(key), uploadId, items, minio.PutObjectOptions{})
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	result.Domain = this.Domain()
+
+	return
+}
+
+// AbortUpload - 取消分片上传
+func (this *S3Struct) AbortUpload(key, uploadId string) (result *StorageResponse) {
+
+	result = &StorageResponse{Path: "/" + StorageKey(key), UploadId: uploadId}
+
+	bucket, err := this.bucket()
+	if err != nil {
+		result.Error = err
+		return
+	}
+
+	if err = this.core().AbortMultipartUpload(context.Background(), bucket, StorageKey(key), uploadId); err != nil {
+		result.Error = err
+	}
+
+	return
+}
+
+// notFound - 对象不存在的错误
+func (this *S3Struct) notFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
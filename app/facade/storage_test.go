@@ -0,0 +1,230 @@
+package facade
+
+import (
+	"bytes"
+	"context"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// s3Mock - 本地模拟的 S3 服务
+type s3Mock struct {
+	*httptest.Server
+	// 为 true 时存储桶相关的请求返回 403 - 模拟密钥没有存储桶权限
+	broken atomic.Bool
+}
+
+func newS3Mock(t *testing.T) (*s3Mock, *S3Struct) {
+
+	mock    := &s3Mock{}
+	handler := gofakes3.New(s3mem.New()).Server()
+
+	// 使用 https - http 下分片上传使用 aws-chunked 流式签名，模拟服务不支持
+	mock.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mock.broken.Load() && strings.Count(strings.Trim(r.URL.Path, "/"), "/") == 0 {
+			w.WriteHeader(403)
+			return
+		}
+		// SDK 递归列出时带有空的 delimiter，S3 视为没有分隔符，模拟服务会当作分隔符处理
+		if query := r.URL.Query(); query.Has("delimiter") && query.Get("delimiter") == "" {
+			query.Del("delimiter")
+			r.URL.RawQuery = query.Encode()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(mock.Close)
+
+	item, _ := url.Parse(mock.URL)
+	client, err := minio.New(item.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4("access", "secret", ""),
+		Secure:       true,
+		Transport:    mock.Client().Transport,
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mock, &S3Struct{Client: client}
+}
+
+// s3Read - 读取对象内容
+func s3Read(t *testing.T, store *S3Struct, key string) string {
+
+	item := store.Get(key)
+	if item.Error != nil {
+		t.Fatalf("读取 %s 失败：%v", key, item.Error)
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(item.Reader)
+
+	body, err := io.ReadAll(item.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestS3Nil(t *testing.T) {
+
+	store := &S3Struct{}
+
+	if store.Bucket() == "" || store.Domain() != "" {
+		t.Fatal("未初始化时 Bucket 应当返回配置的名称，Domain 为空")
+	}
+
+	for name, item := range map[string]*StorageResponse{
+		"Upload":         store.Upload("a.txt", strings.NewReader("a")),
+		"Get":            store.Get("a.txt"),
+		"Delete":         store.Delete("a.txt"),
+		"Exists":         store.Exists("a.txt"),
+		"Stat":           store.Stat("a.txt"),
+		"List":           store.List("", "", 10),
+		"Copy":           store.Copy("a.txt", "b.txt"),
+		"Move":           store.Move("a.txt", "b.txt"),
+		"SignedURL":      store.SignedURL("a.txt", time.Minute, "GET"),
+		"InitUpload":     store.InitUpload("a.txt"),
+		"UploadPart":     store.UploadPart("a.txt", "id", 1, strings.NewReader("a"), 1),
+		"CompleteUpload": store.CompleteUpload("a.txt", "id", nil),
+		"AbortUpload":    store.AbortUpload("a.txt", "id"),
+	} {
+		if item.Error == nil {
+			t.Fatalf("未初始化时 %s 应当返回错误", name)
+		}
+	}
+}
+
+func TestS3Bucket(t *testing.T) {
+
+	mock, store := newS3Mock(t)
+
+	// 第一次检查失败 - 不标记为已检查
+	mock.broken.Store(true)
+	store.Bucket()
+	if store.ready {
+		t.Fatal("存储桶检查失败时不应当标记为已检查")
+	}
+
+	// 重试间隔内不再检查
+	mock.broken.Store(false)
+	store.Bucket()
+	if store.ready {
+		t.Fatal("重试间隔内不应当再次检查")
+	}
+
+	// 超过重试间隔后重新检查，并创建存储桶
+	store.failed = time.Now().Add(-s3Retry)
+	bucket := store.Bucket()
+	if !store.ready {
+		t.Fatal("超过重试间隔后应当重新检查")
+	}
+
+	exist, err := store.Client.BucketExists(context.Background(), bucket)
+	if err != nil || !exist {
+		t.Fatalf("存储桶未创建：%v", err)
+	}
+}
+
+func TestS3Object(t *testing.T) {
+
+	_, store := newS3Mock(t)
+
+	for _, key := range []string{"storage/a.txt", "storage/b.txt", "storage/c.txt", "other/d.txt"} {
+		if item := store.Upload(key, bytes.NewReader([]byte(key))); item.Error != nil {
+			t.Fatal(item.Error)
+		}
+	}
+
+	if body := s3Read(t, store, "storage/a.txt"); body != "storage/a.txt" {
+		t.Fatalf("内容错误：%s", body)
+	}
+
+	if item := store.Exists("storage/a.txt"); item.Error != nil || !item.Exist {
+		t.Fatalf("对象应当存在：%v", item.Error)
+	}
+	if item := store.Exists("storage/x.txt"); item.Error != nil || item.Exist {
+		t.Fatalf("对象不存在时 Exists 应当返回 false：%v", item.Error)
+	}
+
+	if item := store.Stat("storage/a.txt"); item.Error != nil || item.Object.Size != int64(len("storage/a.txt")) {
+		t.Fatalf("对象信息错误：%v", item.Error)
+	}
+
+	// 分页
+	first := store.List("storage/", "", 2)
+	if first.Error != nil || len(first.Objects) != 2 || first.Cursor != "storage/b.txt" {
+		t.Fatalf("第一页错误：%v %+v %s", first.Error, first.Objects, first.Cursor)
+	}
+	second := store.List("storage/", first.Cursor, 2)
+	if second.Error != nil || len(second.Objects) != 1 || second.Objects[0].Key != "storage/c.txt" || second.Cursor != "" {
+		t.Fatalf("第二页错误：%v %+v %s", second.Error, second.Objects, second.Cursor)
+	}
+
+	if item := store.Move("storage/a.txt", "storage/e.txt"); item.Error != nil {
+		t.Fatal(item.Error)
+	}
+	if store.Exists("storage/a.txt").Exist || s3Read(t, store, "storage/e.txt") != "storage/a.txt" {
+		t.Fatal("移动后原对象应当删除，新对象内容不变")
+	}
+
+	if item := store.Delete("storage/e.txt"); item.Error != nil || store.Exists("storage/e.txt").Exist {
+		t.Fatalf("删除失败：%v", item.Error)
+	}
+
+	item := store.SignedURL("storage/b.txt", time.Minute, "GET")
+	if item.Error != nil || !strings.Contains(item.URL, "X-Amz-Signature=") {
+		t.Fatalf("签名地址错误：%v %s", item.Error, item.URL)
+	}
+}
+
+func TestS3Multipart(t *testing.T) {
+
+	_, store := newS3Mock(t)
+
+	key  := "storage/big.bin"
+	init := store.InitUpload(key)
+	if init.Error != nil {
+		t.Fatal(init.Error)
+	}
+
+	// 除最后一片外，分片至少 5MB
+	chunks := []string{strings.Repeat("a", 5<<20), "tail"}
+	var parts []StoragePart
+	for index, chunk := range chunks {
+		item := store.UploadPart(key, init.UploadId, index+1, strings.NewReader(chunk), int64(len(chunk)))
+		if item.Error != nil {
+			t.Fatal(item.Error)
+		}
+		parts = append(parts, *item.Part)
+	}
+
+	if item := store.CompleteUpload(key, init.UploadId, parts); item.Error != nil {
+		t.Fatal(item.Error)
+	}
+
+	if body := s3Read(t, store, key); body != strings.Join(chunks, "") {
+		t.Fatalf("合并后的内容错误，长度：%d", len(body))
+	}
+
+	// 取消
+	abort := store.InitUpload("storage/abort.bin")
+	if abort.Error != nil {
+		t.Fatal(abort.Error)
+	}
+	if item := store.AbortUpload("storage/abort.bin", abort.UploadId); item.Error != nil {
+		t.Fatal(item.Error)
+	}
+}
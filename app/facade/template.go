@@ -11,6 +11,54 @@ port        = 8642
 debug       = false
 # 登录token名称（别乱改，别作死）
 token_name  = "UNTI_LOGIN_TOKEN"
+# 受信任的反向代理（IP 或 CIDR），只有来自这些地址的 X-Forwarded-For 才会被用作客户端IP
+# 默认只信任本机，反向代理不在本机时改为代理的地址，没有代理时设为 []
+trusted_proxies = ["127.0.0.1", "::1"]
+
+# 登录防暴力破解，按帐号和IP分别计数，计数保存在缓存中（多实例需使用 redis 缓存）
+[login]
+# 免等待的失败次数，超过后从 delay 秒开始每次翻倍，最长 max_delay 秒
+free         = 3
+delay        = 1
+max_delay    = "15 * 60"
+# 帐号连续失败次数达到后锁定 lock 秒，可通过验证码（comm/unlock）解锁，0 为不锁定
+max_attempts = 5
+lock         = "30 * 60"
+# 同一IP失败次数达到后按 max_delay 等待，0 为不限制
+ip_attempts  = 20
+# 最后一次失败后多久清零（秒）
+window       = "60 * 60"
+
+# 静态文件配置（public 目录）
+[static]
+# 存在 .br、.gz 预压缩文件时按 Accept-Encoding 优先输出
+precompressed = true
+# 默认缓存策略
+cache_control = "no-cache"
+
+# 按路径前缀设置缓存策略，最长匹配优先
+[[static.cache]]
+prefix  = "/assets/"
+control = "public, max-age=604800"
+
+[[static.cache]]
+prefix  = "/storage/"
+control = "public, max-age=86400"
+
+# 静态站点挂载，按域名（可选）和路径前缀匹配，指定了域名的优先，其次是最长前缀
+# /api/、/dev/、/socket/ 为接口路由，不参与匹配；/storage/ 始终使用 public 目录
+# fallback 为 SPA（history 模式）回退文件，路径不带扩展名且文件不存在时输出，为空时不回退
+[[static.mount]]
+host     = ""
+prefix   = "/"
+root     = "public"
+fallback = "index.html"
+
+# [[static.mount]]
+# host     = ""
+# prefix   = "/admin"
+# root     = "public/admin"
+# fallback = "index.html"
 `
 
 // TempDatabase - 数据库配置模板
@@ -48,6 +96,8 @@ const TempCache = `# ======== 缓存配置 ========
 open	   = ${open}
 # 默认缓存驱动
 default    = "${default}"
+# 序列化方式 - json | msgpack | gob（gob 仅对 facade.CacheGet / CacheSet 生效）
+codec      = "${codec}"
 
 # redis配置
 [redis]
@@ -72,11 +122,25 @@ expire     = "${file.expire}"
 path       = "${file.path}"
 # 缓存前缀
 prefix     = "${file.prefix}"
+# 过期清理间隔(秒) - 0为不自动清理，升级前的旧版本缓存文件（重启后已无法读取）也会被删除
+gc_interval = "${file.gc_interval}"
+# 缓存目录最大占用(MB) - 超出后从最旧的文件开始淘汰，0为不限制
+max_size   = ${file.max_size}
+# 缓存文件最大数量 - 0为不限制
+max_files  = ${file.max_files}
 
 # 内存缓存配置
 [ram]
 # 缓存过期时间(秒) - 0为永不过期
 expire     = "${ram.expire}"
+# 最大内存占用(MB) - 所有分片合计，0为不限制
+size       = ${ram.size}
+# 单个缓存最大占用(MB) - 0为最大内存占用的 1/8
+max_item   = ${ram.max_item}
+# 淘汰策略 - lru（最近最少使用） 或 clock（时钟置换）
+eviction   = "${ram.eviction}"
+# 分片数量 - 分片越多锁竞争越小，自动取2的幂
+shards     = ${ram.shards}
 `
 
 // TempLog - 日志配置模板
@@ -185,6 +249,10 @@ default    = "${default}"
 [local]
 # 本地存储域名
 domain     = "${local.domain}"
+# 签名密钥 - 用于生成 /storage 下文件的签名地址，为空时使用 jwt.key
+sign_key   = "${local.sign_key}"
+# 私有模式 - 开启后 /storage 下的文件必须携带有效签名才能访问
+private    = ${local.private}
 
 
 # 阿里OSS配置
@@ -229,6 +297,97 @@ bucket            = "${kodo.bucket}"
 region            = "${kodo.region}"
 # KODO 外网域名 - 用于访问 - 这里必须填写
 domain            = "${kodo.domain}"
+
+
+# S3兼容存储配置（AWS S3、MinIO、Cloudflare R2、Ceph 等）
+[s3]
+# 服务地址 - 如：https://s3.us-east-1.amazonaws.com、http://127.0.0.1:9000，不带协议默认 https
+endpoint          = "${s3.endpoint}"
+# 所在地区 - MinIO 一般为 us-east-1，R2 为 auto
+region            = "${s3.region}"
+# S3 Bucket - 存储桶名称
+bucket            = "${s3.bucket}"
+# AccessKey
+access_key        = "${s3.access_key}"
+# SecretKey
+secret_key        = "${s3.secret_key}"
+# 是否使用路径风格访问（endpoint/bucket/key），MinIO、Ceph 一般需要开启
+path_style        = ${s3.path_style}
+# 外网域名 - 用于访问 - 不填写则根据 endpoint 拼接
+domain            = "${s3.domain}"
+
+
+# 上传配置
+[upload]
+# 分片大小(MB) - 除最后一片外每片必须等于该大小，对象存储要求不小于 5MB
+chunk_size        = ${upload.chunk_size}
+# 分片上传会话有效期(小时) - 超时未合并需重新上传
+expire            = ${upload.expire}
+# 图片去除 EXIF 等元数据并重新编码（jpg、png、gif、bmp、tiff），防止夹带页面代码
+reencode          = ${upload.reencode}
+
+# 游客（未登录）上传策略
+[upload.roles.guest]
+# 单个文件最大大小(MB) - 0 为禁止上传
+max_size          = ${upload.guest.max_size}
+# 允许的扩展名 - "*" 为不限（html、svg、xml、js 等仍需显式列出）
+types             = ${upload.guest.types}
+
+# 登录用户上传策略 - 未单独配置的角色都使用此策略
+[upload.roles.user]
+# 单个文件最大大小(MB) - 0 为禁止上传
+max_size          = ${upload.user.max_size}
+# 允许的扩展名 - "*" 为不限（html、svg、xml、js 等仍需显式列出）
+types             = ${upload.user.types}
+
+
+# 图片处理 - 访问图片时通过 size、mode、gravity、quality、rotate、blur、format 参数生成缩略图
+[image]
+# 缩略图缓存 - runtime：缓存在 runtime/image 目录，storage：缓存在默认存储驱动的 cache/image 目录（请为该目录配置生命周期规则），none：不缓存
+cache             = "${image.cache}"
+# runtime 缓存的最大大小(MB) - 超出后删除最久未访问的缩略图，0 为不限
+cache_size        = ${image.cache_size}
+# 允许的尺寸 - 包含 "*" 为不限制（不建议，会被用来无限生成缩略图）
+sizes             = ${image.sizes}
+# 默认质量 - 1 ~ 100，仅 jpeg、webp 有效
+quality           = ${image.quality}
+# 除默认质量外允许的质量
+qualities         = ${image.qualities}
+# 最大模糊半径 - 只取整数
+max_blur          = ${image.max_blur}
+# 原图最大像素（百万） - 超出的图片不处理，防止解压炸弹
+max_pixels        = ${image.max_pixels}
+
+
+# 水印配置
+[watermark]
+# 是否开启
+enable            = ${watermark.enable}
+# 添加方式 - lazy：访问图片时添加（原图不变），upload：上传时写入图片（此时图片不支持分片上传）
+mode              = "${watermark.mode}"
+# 水印类型 - text：文字，image：图片
+type              = "${watermark.type}"
+# 水印文字
+text              = "${watermark.text}"
+# 字体文件 - 留空使用内置字体（不支持中文）
+font              = ""
+# 文字颜色
+color             = "#ffffff"
+# 水印图片 - 建议使用透明背景的 png
+image             = "${watermark.image}"
+# 位置 - center、top、bottom、left、right、top-left、top-right、bottom-left、bottom-right
+position          = "${watermark.position}"
+# 不透明度 - 0 ~ 1
+opacity           = ${watermark.opacity}
+# 水印宽度占图片宽度的比例
+scale             = ${watermark.scale}
+# 边距占图片短边的比例
+margin            = ${watermark.margin}
+# 宽或高小于该值的图片不添加水印（缩略图同样适用）
+min_width         = ${watermark.min_width}
+min_height        = ${watermark.min_height}
+# 不添加水印的路径前缀
+exclude           = ${watermark.exclude}
 `
 
 const TempCrypt   = `# ======== 加密配置 ========
@@ -239,8 +398,110 @@ const TempCrypt   = `# ======== 加密配置 ========
 key      = "${jwt.key}"
 # 过期时间(秒)
 expire   = "${jwt.expire}"
+# 登录访问令牌的有效期(秒) - 过期后使用刷新令牌换取新的访问令牌
+access   = "${jwt.access}"
+# 刷新令牌的有效期(秒) - 每次使用后轮换，重复使用会吊销整个登录
+refresh  = "${jwt.refresh}"
 # 签发者
 issuer   = "${jwt.issuer}"
 # 主题
 subject  = "${jwt.subject}"
-`
\ No newline at end of file
+# 签名算法 - HS256（使用 key）、RS256、ES256、EdDSA
+# 非对称算法的公钥发布在 /.well-known/jwks.json，其他服务无需共享密钥即可验证令牌
+# 从 HS256 切换到非对称算法后，HS256 签发的令牌立即失效，用户需要重新登录
+algorithm = "${jwt.algorithm}"
+# 非对称密钥目录 - PEM（PKCS8）私钥，文件名即 kid，生成：./unti jwt:generate
+# 多实例部署时所有实例需要共享该目录（或同步其中的文件），否则其他实例无法验证令牌
+# 最新的密钥用于签名，其余只用于验证；轮换：./unti jwt:rotate
+keys     = "${jwt.keys}"
+# 指定签名使用的 kid，为空时使用 algorithm 下最新的密钥
+kid      = ""
+
+# 两步验证（TOTP）配置
+[totp]
+# 认证器应用中显示的名称，为空时使用 jwt.issuer
+issuer   = ""
+# 允许前后误差的时间步数（每步30秒）
+skew     = 1
+
+# 密码哈希配置 - 登录成功时，旧算法或旧参数生成的哈希自动升级
+[password]
+# 算法 - argon2id、bcrypt
+algorithm = "argon2id"
+# bcrypt 的 cost（4-31）
+cost     = 12
+# argon2id 的内存(KiB)、迭代次数、并行度
+memory   = 65536
+time     = 3
+threads  = 2
+
+# API Key 配置 - 服务间调用，请求需使用 HMAC-SHA256 签名
+[apikey]
+# 派生 API Key 密钥的主密钥，为空时使用 jwt.key - 修改后已签发的 API Key 全部失效
+key      = "${apikey.key}"
+# 签名时间戳允许的误差(秒) - 同一 nonce 在该时间内只能使用一次
+window   = 300
+# 请求体最大大小(MB) - 校验签名需要把请求体读入内存
+max_body = 10
+
+# 字段加密配置 - 手机号等敏感字段加密存储（AEAD），查询使用盲索引
+[aead]
+# 算法 - aes-gcm、xchacha20-poly1305，修改后只影响新写入的数据
+algorithm = "aes-gcm"
+# 密钥目录 - 文件名即 kid，没有密钥时自动生成，请务必备份，丢失后数据无法解密
+# 最新的密钥用于加密，其余只用于解密；轮换：./unti aead:rotate
+keys     = "config/aead"
+# 指定加密使用的 kid，为空时使用最新的密钥
+kid      = ""
+# 盲索引密钥，为空时由 jwt.key 派生 - 修改后需执行 ./unti aead:migrate 重建索引
+index_key = "${aead.index_key}"
+`
+
+// TempOAuth - 第三方登录配置模板
+const TempOAuth = `# ======== 第三方登录配置（OAuth2 / OIDC） ========
+
+# 回调地址的域名，为空时使用请求的域名，如：https://example.com
+# 在第三方平台填写的回调地址为：域名/api/comm/oauth-callback
+redirect   = "${redirect}"
+# 第三方帐号的邮箱已验证，且与已有用户的邮箱相同时，自动绑定到该用户
+link_email = ${link_email}
+
+# GitHub - https://github.com/settings/developers
+[github]
+enable        = false
+client_id     = ""
+client_secret = ""
+scope         = "read:user user:email"
+
+# Google - https://console.cloud.google.com/apis/credentials
+[google]
+enable        = false
+client_id     = ""
+client_secret = ""
+issuer        = "https://accounts.google.com"
+scope         = "openid email profile"
+
+# QQ互联 - client_id 为 APP ID，client_secret 为 APP Key
+[qq]
+enable        = false
+client_id     = ""
+client_secret = ""
+scope         = "get_user_info"
+
+# 微信开放平台（网站应用扫码登录） - client_id 为 AppID，client_secret 为 AppSecret
+[wechat]
+enable        = false
+client_id     = ""
+client_secret = ""
+scope         = "snsapi_login"
+
+# 通用 OIDC（Keycloak、Authing、Casdoor 等），通过 issuer/.well-known/openid-configuration 自动发现
+# name 即登录时的 provider 参数，可配置多个
+# [[oidc]]
+# name          = "sso"
+# enable        = true
+# client_id     = ""
+# client_secret = ""
+# issuer        = "https://sso.example.com/realms/main"
+# scope         = "openid email profile"
+`
@@ -0,0 +1,226 @@
+package facade
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleState - 失败计数 - 保存在缓存中，多实例共享
+type ThrottleState struct {
+	// 连续失败次数
+	Fails int   `json:"fails"`
+	// 下次允许尝试的时间
+	Until int64 `json:"until"`
+	// 是否已锁定 - 只有帐号会被锁定，IP 只做退避
+	Lock  bool  `json:"lock"`
+}
+
+// ThrottleStruct - 登录防暴力破解 - 按帐号和IP分别计数，失败后按指数退避，帐号连续失败过多时临时锁定
+/**
+ * 尝试在校验之前预占（先按失败计数），成功后再退还，并发请求不会都通过检查
+ */
+type ThrottleStruct struct {
+	// 进程内的计数锁 - 多实例时另加缓存锁
+	mutex sync.Mutex
+}
+
+// Throttle - 登录防暴力破解实例
+/**
+ * @example：
+ * if wait, lock := facade.Throttle.Acquire(account, ip); wait > 0 { ... }
+ * 校验失败：lock := facade.Throttle.Locked(account)
+ * 校验成功：facade.Throttle.Release(account, ip)
+ */
+var Throttle = &ThrottleStruct{}
+
+// config - 读取 [login] 配置 - 旧版本升级上来的配置没有该节时使用内置值
+func (this *ThrottleStruct) config(key string, def int64) int64 {
+	if !AppToml.Viper.IsSet("login." + key) {
+		return def
+	}
+	return cast.ToInt64(utils.Calc(AppToml.Viper.Get("login." + key)))
+}
+
+// accountKey - 帐号的缓存名称 - 不区分大小写，帐号不存在时同样计数，避免通过锁定与否判断帐号是否存在
+func (this *ThrottleStruct) accountKey(account string) string {
+	return fmt.Sprintf("login[account:%s]", strings.ToLower(strings.TrimSpace(account)))
+}
+
+// ipKey - IP的缓存名称
+func (this *ThrottleStruct) ipKey(ip string) string {
+	return fmt.Sprintf("login[ip:%s]", ip)
+}
+
+// get - 读取计数
+func (this *ThrottleStruct) get(key string) ThrottleState {
+	item, _ := CacheGet[ThrottleState](key)
+	return item
+}
+
+// delay - 第 fails 次失败后的等待时间（秒） - 免等待次数内为0，之后从 login.delay 开始翻倍，最长 login.max_delay
+func (this *ThrottleStruct) delay(fails int) int64 {
+
+	free  := int(this.config("free", 3))
+	base  := this.config("delay", 1)
+	limit := this.config("max_delay", 15*60)
+
+	if fails <= free || base <= 0 {
+		return 0
+	}
+
+	value := base
+	for i := free + 1; i < fails && value < limit; i++ {
+		value *= 2
+	}
+
+	if value > limit {
+		return limit
+	}
+
+	return value
+}
+
+// lock - 计数加锁 - 读写计数不是原子操作，进程内互斥，再用 CacheAdd 加跨实例的锁（Redis 时生效）
+/**
+ * @return unlock 释放锁，ok 为 false 时未拿到锁
+ */
+func (this *ThrottleStruct) lock() (unlock func(), ok bool) {
+
+	this.mutex.Lock()
+
+	for i := 0; i < 100; i++ {
+		if CacheAdd("login[lock]", 1, 5*time.Second) {
+			return func() {
+				Cache.Del("login[lock]")
+				this.mutex.Unlock()
+			}, true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	this.mutex.Unlock()
+
+	return nil, false
+}
+
+// Check - 是否允许尝试 - 只读，不计数
+/**
+ * @param account 提交的帐号（或邮箱、手机号） - 为空时只检查IP
+ * @param ip 客户端IP
+ * @return wait 需要等待的秒数，lock 帐号是否已锁定（wait 为剩余锁定时间）
+ */
+func (this *ThrottleStruct) Check(account, ip string) (wait int64, lock bool) {
+
+	now := time.Now().Unix()
+
+	if account != "" {
+		if item := this.get(this.accountKey(account)); item.Until > now {
+			wait, lock = item.Until-now, item.Lock
+		}
+	}
+
+	if item := this.get(this.ipKey(ip)); item.Until-now > wait {
+		wait = item.Until - now
+	}
+
+	return wait, lock
+}
+
+// Acquire - 检查并预占一次尝试 - 在校验密码、验证码之前调用，预占的尝试先按失败计数
+/**
+ * 检查和计数在同一把锁内完成，并发的请求只有免等待次数内的可以通过
+ * @param account 提交的帐号（或邮箱、手机号） - 为空时只按IP计数
+ * @param ip 客户端IP
+ * @return wait 需要等待的秒数（为0时已预占），lock 帐号是否已锁定
+ */
+func (this *ThrottleStruct) Acquire(account, ip string) (wait int64, lock bool) {
+
+	unlock, ok := this.lock()
+	if !ok {
+		return 1, false
+	}
+	defer unlock()
+
+	if wait, lock = this.Check(account, ip); wait > 0 {
+		return wait, lock
+	}
+
+	this.fail(account, ip)
+
+	return 0, false
+}
+
+// Release - 校验成功，退还预占的尝试 - 清除帐号的失败计数，IP 只退还这一次
+/**
+ * @param account 提交的帐号（或邮箱、手机号） - 为空时只退还IP的计数
+ * @param ip 客户端IP
+ */
+func (this *ThrottleStruct) Release(account, ip string) {
+
+	unlock, ok := this.lock()
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	if account != "" {
+		Cache.Del(this.accountKey(account))
+	}
+
+	key   := this.ipKey(ip)
+	state := this.get(key)
+	if state.Fails == 0 {
+		return
+	}
+
+	now := time.Now().Unix()
+	state.Fails--
+	if until := now + this.delay(state.Fails); until < state.Until {
+		state.Until = until
+	}
+	CacheSet(key, state, time.Duration(this.config("window", 60*60)+state.Until-now)*time.Second)
+}
+
+// Locked - 帐号是否已锁定 - 校验失败后用于提示
+func (this *ThrottleStruct) Locked(account string) bool {
+	item := this.get(this.accountKey(account))
+	return item.Lock && item.Until > time.Now().Unix()
+}
+
+// fail - 记录一次失败 - 调用方持有计数锁
+func (this *ThrottleStruct) fail(account, ip string) {
+
+	now    := time.Now().Unix()
+	window := this.config("window", 60*60)
+
+	// 帐号 - 超过 login.max_attempts 次后锁定 login.lock 秒
+	if account != "" {
+		key  := this.accountKey(account)
+		item := this.get(key)
+		item.Fails++
+		item.Until = now + this.delay(item.Fails)
+		if count := this.config("max_attempts", 5); count > 0 && int64(item.Fails) >= count {
+			item.Until, item.Lock = now+this.config("lock", 30*60), true
+		}
+		CacheSet(key, item, time.Duration(window+item.Until-now)*time.Second)
+	}
+
+	// IP - 一个IP尝试多个帐号时同样退避，超过 login.ip_attempts 次后按最长等待时间
+	key   := this.ipKey(ip)
+	state := this.get(key)
+	state.Fails++
+	state.Until = now + this.delay(state.Fails)
+	if count := this.config("ip_attempts", 20); count > 0 && int64(state.Fails) >= count {
+		state.Until = now + this.config("max_delay", 15*60)
+	}
+	CacheSet(key, state, time.Duration(window+state.Until-now)*time.Second)
+}
+
+// Reset - 清除帐号的失败计数（登录成功、验证码解锁） - IP 计数不清除，避免用一个有效帐号重置对其他帐号的尝试
+func (this *ThrottleStruct) Reset(account string) {
+	Cache.Del(this.accountKey(account))
+}
@@ -0,0 +1,186 @@
+package facade
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testThrottle - 使用内存缓存和指定的 [login] 配置，结束后还原
+func testThrottle(t *testing.T, config map[string]any) {
+
+	before := Cache
+	Cache   = BigCache
+	Cache.Clear()
+
+	for key, value := range config {
+		key := key
+		old := AppToml.Viper.Get("login." + key)
+		AppToml.Viper.Set("login."+key, value)
+		t.Cleanup(func() { AppToml.Viper.Set("login."+key, old) })
+	}
+
+	t.Cleanup(func() {
+		Cache.Clear()
+		Cache = before
+	})
+}
+
+func TestThrottleDelay(t *testing.T) {
+
+	testThrottle(t, map[string]any{"free": 3, "delay": 1, "max_delay": 900})
+
+	for fails, want := range map[int]int64{0: 0, 1: 0, 3: 0, 4: 1, 5: 2, 6: 4, 10: 64, 13: 512, 14: 900, 100: 900} {
+		if value := Throttle.delay(fails); value != want {
+			t.Errorf("第 %d 次失败后等待 %d 秒，应当为 %d 秒", fails, value, want)
+		}
+	}
+
+	// delay 为 0 时不退避
+	AppToml.Viper.Set("login.delay", 0)
+	if value := Throttle.delay(10); value != 0 {
+		t.Errorf("delay 为 0 时应当不退避，得到 %d", value)
+	}
+}
+
+func TestThrottleBackoff(t *testing.T) {
+
+	// 等待时间足够长，避免跨秒时误判
+	testThrottle(t, map[string]any{"free": 3, "delay": 60, "max_attempts": 5, "ip_attempts": 20})
+
+	// 免等待次数内可以连续尝试，之后需要等待
+	for i := 1; i <= 4; i++ {
+		if wait, _ := Throttle.Acquire("admin", "10.0.0.1"); wait != 0 {
+			t.Fatalf("第 %d 次尝试不应当等待", i)
+		}
+	}
+	wait, lock := Throttle.Acquire("Admin ", "10.0.0.2")
+	if wait <= 0 || lock {
+		t.Fatalf("帐号超过免等待次数后应当退避（不区分大小写），得到 %d %v", wait, lock)
+	}
+
+	// 同一IP换帐号同样退避
+	for i, account := range []string{"a", "b", "c"} {
+		if wait, _ = Throttle.Acquire(account, "10.0.0.3"); wait != 0 {
+			t.Fatalf("第 %d 个帐号不应当等待", i+1)
+		}
+	}
+	if wait, _ = Throttle.Acquire("d", "10.0.0.3"); wait != 0 {
+		t.Fatal("IP 第 4 次尝试不应当等待")
+	}
+	if wait, lock = Throttle.Acquire("e", "10.0.0.3"); wait <= 0 || lock {
+		t.Fatalf("IP 超过免等待次数后应当退避，得到 %d %v", wait, lock)
+	}
+
+	// Check 只读，不计数
+	for i := 0; i < 10; i++ {
+		Throttle.Check("other", "10.0.0.4")
+	}
+	if wait, _ = Throttle.Acquire("other", "10.0.0.4"); wait != 0 {
+		t.Fatal("Check 不应当计数")
+	}
+}
+
+func TestThrottleLock(t *testing.T) {
+
+	// 不退避，只测试锁定
+	testThrottle(t, map[string]any{"free": 3, "delay": 0, "max_attempts": 5, "lock": 1800, "ip_attempts": 0})
+
+	for i := 1; i <= 5; i++ {
+		if wait, _ := Throttle.Acquire("admin", "10.0.0.1"); wait != 0 {
+			t.Fatalf("第 %d 次尝试不应当等待", i)
+		}
+	}
+	if !Throttle.Locked("admin") || !Throttle.Locked("ADMIN") {
+		t.Fatal("连续失败达到 max_attempts 后应当锁定")
+	}
+
+	// 锁定的帐号换IP也不能尝试，其他帐号不受影响
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if wait, lock := Throttle.Acquire("admin", ip); wait < 1790 || !lock {
+			t.Fatalf("锁定期间应当拒绝，得到 %d %v", wait, lock)
+		}
+	}
+	if wait, _ := Throttle.Acquire("other", "10.0.0.2"); wait != 0 {
+		t.Fatal("其他帐号不应当受影响")
+	}
+
+	// 解锁后可以继续尝试
+	Throttle.Reset("admin")
+	if Throttle.Locked("admin") {
+		t.Fatal("Reset 后应当解锁")
+	}
+	if wait, _ := Throttle.Acquire("admin", "10.0.0.1"); wait != 0 {
+		t.Fatal("Reset 后应当可以尝试")
+	}
+
+	// max_attempts 为 0 时不锁定
+	AppToml.Viper.Set("login.max_attempts", 0)
+	for i := 0; i < 10; i++ {
+		Throttle.Acquire("nolock", "10.0.0.5")
+	}
+	if Throttle.Locked("nolock") {
+		t.Fatal("max_attempts 为 0 时不应当锁定")
+	}
+}
+
+func TestThrottleRelease(t *testing.T) {
+
+	testThrottle(t, map[string]any{"free": 3, "delay": 1, "max_attempts": 5, "ip_attempts": 20})
+
+	// 登录成功退还预占的尝试 - 连续成功不会累计
+	for i := 0; i < 10; i++ {
+		if wait, _ := Throttle.Acquire("admin", "10.0.0.1"); wait != 0 {
+			t.Fatalf("第 %d 次成功登录前不应当等待", i+1)
+		}
+		Throttle.Release("admin", "10.0.0.1")
+	}
+	if state := Throttle.get(Throttle.ipKey("10.0.0.1")); state.Fails != 0 {
+		t.Fatalf("成功后应当退还IP的计数，剩余 %d", state.Fails)
+	}
+
+	// 帐号的计数全部清除，IP 只退还一次
+	for i := 0; i < 3; i++ {
+		Throttle.Acquire("a", "10.0.0.2")
+	}
+	Throttle.Acquire("b", "10.0.0.2")
+	Throttle.Release("b", "10.0.0.2")
+	if state := Throttle.get(Throttle.accountKey("b")); state.Fails != 0 {
+		t.Fatal("成功后应当清除帐号的计数")
+	}
+	if state := Throttle.get(Throttle.ipKey("10.0.0.2")); state.Fails != 3 {
+		t.Fatalf("IP 只应当退还一次，剩余 %d", state.Fails)
+	}
+	if state := Throttle.get(Throttle.accountKey("a")); state.Fails != 3 {
+		t.Fatal("其他帐号的计数不应当清除")
+	}
+
+	// Reset 不清除IP的计数
+	Throttle.Reset("a")
+	if state := Throttle.get(Throttle.ipKey("10.0.0.2")); state.Fails != 3 {
+		t.Fatal("Reset 不应当清除IP的计数")
+	}
+}
+
+// TestThrottleConcurrent - 并发请求只有免等待次数内的可以通过
+func TestThrottleConcurrent(t *testing.T) {
+
+	testThrottle(t, map[string]any{"free": 3, "delay": 60, "max_attempts": 5, "ip_attempts": 20})
+
+	var pass int32
+	var group sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			if wait, _ := Throttle.Acquire("admin", "10.0.0.1"); wait == 0 {
+				atomic.AddInt32(&pass, 1)
+			}
+		}()
+	}
+	group.Wait()
+
+	if pass != 4 {
+		t.Fatalf("并发时应当只有 4 次通过，实际 %d 次", pass)
+	}
+}
@@ -0,0 +1,135 @@
+package facade_test
+
+import (
+	"errors"
+	"inis/app/model"
+	"testing"
+	"time"
+)
+
+// testSession - 创建会话和第一个刷新令牌
+func testSession(t *testing.T, uid int) (jti, token string) {
+
+	jti, err := model.SessionsCreate(uid, "127.0.0.1", "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err = model.TokensIssue(uid, jti, "127.0.0.1", "test", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	return jti, token
+}
+
+func TestTokensRotate(t *testing.T) {
+
+	db := testDB(t, &model.Sessions{}, &model.Tokens{})
+	jti, token := testSession(t, 1)
+
+	// 数据库中只保存哈希
+	var stored model.Tokens
+	db.Where("family = ?", jti).First(&stored)
+	if stored.Hash != model.TokensHash(token) || stored.Hash == token {
+		t.Fatal("刷新令牌应当只保存哈希")
+	}
+
+	item, next, err := model.TokensRotate(token, "127.0.0.2", "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Uid != 1 || item.Family != jti || next == "" || next == token {
+		t.Fatalf("轮换结果错误：%+v %q", item, next)
+	}
+
+	// 新令牌在同一链路中，可以继续轮换
+	item, third, err := model.TokensRotate(next, "127.0.0.2", "test", time.Hour)
+	if err != nil || item.Family != jti || third == "" {
+		t.Fatalf("新令牌应当可以继续轮换：%v", err)
+	}
+
+	session, _ := model.SessionsFind(jti)
+	if !session.Active() {
+		t.Fatal("正常轮换不应当吊销会话")
+	}
+}
+
+func TestTokensReuse(t *testing.T) {
+
+	testDB(t, &model.Sessions{}, &model.Tokens{})
+	jti, token := testSession(t, 1)
+	other, otherToken := testSession(t, 1)
+
+	_, next, err := model.TokensRotate(token, "127.0.0.1", "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧令牌再次使用 - 判定为泄露，吊销整个会话
+	if _, _, err = model.TokensRotate(token, "127.0.0.1", "test", time.Hour); !errors.Is(err, model.ErrTokenReused) {
+		t.Fatalf("重复使用应当返回 ErrTokenReused：%v", err)
+	}
+
+	session, _ := model.SessionsFind(jti)
+	if session.Active() {
+		t.Fatal("重复使用后会话应当被吊销")
+	}
+
+	// 轮换出的新令牌也随会话一起失效
+	if _, _, err = model.TokensRotate(next, "127.0.0.1", "test", time.Hour); !errors.Is(err, model.ErrTokenInvalid) {
+		t.Fatalf("会话吊销后新令牌应当无效：%v", err)
+	}
+
+	// 同一用户的其他会话不受影响
+	if session, _ = model.SessionsFind(other); !session.Active() {
+		t.Fatal("其他会话不应当被吊销")
+	}
+	if _, _, err = model.TokensRotate(otherToken, "127.0.0.1", "test", time.Hour); err != nil {
+		t.Fatalf("其他会话的令牌应当仍然有效：%v", err)
+	}
+}
+
+func TestTokensInvalid(t *testing.T) {
+
+	db := testDB(t, &model.Sessions{}, &model.Tokens{})
+
+	_, expired := testSession(t, 1)
+	db.Model(&model.Tokens{}).Where("hash = ?", model.TokensHash(expired)).UpdateColumn("expire_time", time.Now().Add(-time.Second).Unix())
+
+	_, revoked := testSession(t, 1)
+	model.TokensRevoke(revoked)
+
+	// 没有会话的旧令牌
+	legacy, err := model.TokensIssue(2, "", "127.0.0.1", "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"不存在", "missing", model.ErrTokenInvalid},
+		{"空令牌", "", model.ErrTokenInvalid},
+		{"已过期", expired, model.ErrTokenInvalid},
+		{"已退出", revoked, model.ErrTokenInvalid},
+	} {
+		if _, next, err := model.TokensRotate(item.token, "127.0.0.1", "test", time.Hour); !errors.Is(err, item.err) || next != "" {
+			t.Errorf("%s：得到 %v %q", item.name, err, next)
+		}
+	}
+
+	// 链路为空时签发新的链路，吊销空链路不影响其他令牌
+	var stored model.Tokens
+	db.Where("hash = ?", model.TokensHash(legacy)).First(&stored)
+	if stored.Family == "" {
+		t.Fatal("family 为空时应当开始新的链路")
+	}
+	model.SessionsRevoke("")
+	model.TokensRevokeFamily("")
+	var count int64
+	db.Model(&model.Tokens{}).Where("revoke_time != 0").Count(&count)
+	if count != 1 {
+		t.Fatalf("吊销空链路不应当影响其他令牌，已吊销 %d 个", count)
+	}
+}
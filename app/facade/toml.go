@@ -15,6 +15,7 @@ const (
 	TomlLog     = "log"
 	TomlApp     = "app"
 	TomlCrypt   = "crypt"
+	TomlOAuth   = "oauth"
 )
 
 // NewToml - 获取配置文件
@@ -43,6 +44,8 @@ func NewToml(mode any) *utils.ViperResponse {
 		return CryptToml
 	case TomlApp:
 		return AppToml
+	case TomlOAuth:
+		return OAuthToml
 	}
 	return nil
 }
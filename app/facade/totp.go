@@ -0,0 +1,162 @@
+package facade
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// totpEncoding - 密钥编码 - 认证器应用通用的无填充 base32
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TotpStruct - TOTP 两步验证（RFC 6238） - SHA1、6位、30秒，兼容常见的认证器应用
+type TotpStruct struct {
+	// 时间步长（秒）
+	Period int64
+	// 验证码位数
+	Digits int
+}
+
+// Totp - TOTP 实例
+/**
+ * @example：
+ * secret := facade.Totp.Secret()
+ * uri    := facade.Totp.URI("admin@example.com", secret)
+ * png, _ := facade.Totp.QRCode(uri, 256)
+ * step, ok := facade.Totp.Verify(secret, "123456")
+ */
+var Totp = &TotpStruct{Period: 30, Digits: 6}
+
+// Issuer - 认证器中显示的名称 - totp.issuer，为空时使用 jwt.issuer
+func (this *TotpStruct) Issuer() string {
+
+	issuer := cast.ToString(CryptToml.Get("totp.issuer"))
+	if utils.Is.Empty(issuer) {
+		issuer = cast.ToString(CryptToml.Get("jwt.issuer", "unti"))
+	}
+
+	return issuer
+}
+
+// Secret - 生成密钥 - 160位随机数的 base32
+func (this *TotpStruct) Secret() string {
+
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		return ""
+	}
+
+	return totpEncoding.EncodeToString(random)
+}
+
+// Code - 指定时间步的验证码
+/**
+ * @param secret 密钥（base32）
+ * @param step 时间步 - Unix 时间 / Period
+ * @return string 密钥无效时为空
+ */
+func (this *TotpStruct) Code(secret string, step int64) string {
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.ReplaceAll(secret, " ", "")))
+	if err != nil || len(key) == 0 {
+		return ""
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// 动态截取
+	offset := sum[len(sum)-1] & 0x0f
+	value  := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < this.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", this.Digits, value%mod)
+}
+
+// Step - 当前时间步
+func (this *TotpStruct) Step() int64 {
+	return time.Now().Unix() / this.Period
+}
+
+// Verify - 校验验证码 - 允许前后 totp.skew（默认1）个时间步的误差
+/**
+ * @param secret 密钥（base32）
+ * @param code 验证码
+ * @return step 匹配的时间步 - 调用方应记录并拒绝不大于它的时间步，防止同一验证码被重复使用
+ */
+func (this *TotpStruct) Verify(secret, code string) (step int64, ok bool) {
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != this.Digits {
+		return 0, false
+	}
+
+	skew := int64(1)
+	if CryptToml.Viper.IsSet("totp.skew") {
+		skew = cast.ToInt64(CryptToml.Viper.Get("totp.skew"))
+	}
+	now  := this.Step()
+
+	for item := now - skew; item <= now+skew; item++ {
+		value := this.Code(secret, item)
+		if value != "" && subtle.ConstantTimeCompare([]byte(value), []byte(code)) == 1 {
+			return item, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI - otpauth 地址 - 认证器应用扫码添加
+/**
+ * @param account 帐号名称，如邮箱
+ * @param secret 密钥（base32）
+ */
+func (this *TotpStruct) URI(account, secret string) string {
+
+	issuer := this.Issuer()
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", cast.ToString(this.Digits))
+	query.Set("period", cast.ToString(this.Period))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// QRCode - 生成二维码 PNG - 在本地生成，密钥不会发送给第三方
+/**
+ * @param content 内容，如 otpauth 地址
+ * @param size 边长（像素）
+ * @return []byte, error
+ */
+func (this *TotpStruct) QRCode(content string, size int) ([]byte, error) {
+
+	if size <= 0 || size > 1024 {
+		size = 256
+	}
+
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
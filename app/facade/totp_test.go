@@ -0,0 +1,120 @@
+package facade
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// totpSecret - RFC 6238 附录 B 的测试密钥 "12345678901234567890"
+const totpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// testTotpStep - 当前时间步 - 临近时间步切换时等待，避免测试过程中跨步
+func testTotpStep() int64 {
+	if time.Now().Unix()%Totp.Period >= Totp.Period-2 {
+		time.Sleep(3 * time.Second)
+	}
+	return Totp.Step()
+}
+
+func TestTotpCode(t *testing.T) {
+
+	// RFC 6238 附录 B - SHA1、8位
+	rfc := &TotpStruct{Period: 30, Digits: 8}
+	for unix, want := range map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	} {
+		if code := rfc.Code(totpSecret, unix/rfc.Period); code != want {
+			t.Errorf("T=%d 得到 %s，应当为 %s", unix, code, want)
+		}
+	}
+
+	// RFC 4226 附录 D - 6位，补零
+	for step, want := range []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"} {
+		if code := Totp.Code(totpSecret, int64(step)); code != want {
+			t.Errorf("计数 %d 得到 %s，应当为 %s", step, code, want)
+		}
+	}
+
+	// 密钥不区分大小写，允许空格
+	if Totp.Code(strings.ToLower(totpSecret), 1) != "287082" || Totp.Code("GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ", 1) != "287082" {
+		t.Error("密钥应当不区分大小写并忽略空格")
+	}
+
+	for _, secret := range []string{"", "not-base32!", "1"} {
+		if code := Totp.Code(secret, 1); code != "" {
+			t.Errorf("无效的密钥 %q 应当返回空，得到 %s", secret, code)
+		}
+	}
+}
+
+func TestTotpSecret(t *testing.T) {
+
+	secret := Totp.Secret()
+	if key, err := totpEncoding.DecodeString(secret); err != nil || len(key) != 20 {
+		t.Fatalf("密钥应当为 160 位的 base32：%q %v", secret, err)
+	}
+	if Totp.Secret() == secret {
+		t.Fatal("每次生成的密钥应当不同")
+	}
+}
+
+func TestTotpVerify(t *testing.T) {
+
+	skew := CryptToml.Viper.Get("totp.skew")
+	defer CryptToml.Viper.Set("totp.skew", skew)
+	CryptToml.Viper.Set("totp.skew", 1)
+
+	now := testTotpStep()
+
+	for _, item := range []struct {
+		name string
+		code string
+		step int64
+		ok   bool
+	}{
+		{"当前", Totp.Code(totpSecret, now), now, true},
+		{"前一步", Totp.Code(totpSecret, now-1), now - 1, true},
+		{"后一步", Totp.Code(totpSecret, now+1), now + 1, true},
+		{"带空格", " " + Totp.Code(totpSecret, now)[:3] + " " + Totp.Code(totpSecret, now)[3:], now, true},
+		{"超出误差", Totp.Code(totpSecret, now-2), 0, false},
+		{"超出误差", Totp.Code(totpSecret, now+2), 0, false},
+		{"位数不对", Totp.Code(totpSecret, now)[:5], 0, false},
+		{"位数过多", Totp.Code(totpSecret, now) + "0", 0, false},
+		{"空", "", 0, false},
+	} {
+		step, ok := Totp.Verify(totpSecret, item.code)
+		if ok != item.ok || step != item.step {
+			t.Errorf("%s：得到 %d %v", item.name, step, ok)
+		}
+	}
+
+	if _, ok := Totp.Verify("not-base32!", "000000"); ok {
+		t.Error("无效的密钥不应当通过")
+	}
+
+	// skew 为 0 时只接受当前时间步
+	CryptToml.Viper.Set("totp.skew", 0)
+	now = testTotpStep()
+	if _, ok := Totp.Verify(totpSecret, Totp.Code(totpSecret, now-1)); ok {
+		t.Error("skew 为 0 时不应当接受前一步")
+	}
+	if _, ok := Totp.Verify(totpSecret, Totp.Code(totpSecret, now)); !ok {
+		t.Error("skew 为 0 时应当接受当前时间步")
+	}
+}
+
+func TestTotpURI(t *testing.T) {
+
+	uri := Totp.URI("admin@example.com", totpSecret)
+	for _, item := range []string{"otpauth://totp/", "secret=" + totpSecret, "algorithm=SHA1", "digits=6", "period=30", ":admin@example.com?"} {
+		if !strings.Contains(uri, item) {
+			t.Errorf("%s 中缺少 %s", uri, item)
+		}
+	}
+}
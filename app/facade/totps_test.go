@@ -0,0 +1,159 @@
+package facade_test
+
+import (
+	"inis/app/facade"
+	"inis/app/model"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testTotps - 开启两步验证，返回恢复码
+func testTotps(t *testing.T, uid int, secret string) (item model.Totps, codes []string) {
+
+	codes, err := model.TotpsEnable(uid, secret, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, ok := model.TotpsFind(uid)
+	if !ok {
+		t.Fatal("开启后应当可以查到")
+	}
+
+	return item, codes
+}
+
+// testStep - 当前时间步 - 临近时间步切换时等待
+func testStep() int64 {
+	if time.Now().Unix()%facade.Totp.Period >= facade.Totp.Period-2 {
+		time.Sleep(3 * time.Second)
+	}
+	return facade.Totp.Step()
+}
+
+func TestTotpsReplay(t *testing.T) {
+
+	db := testDB(t, &model.Totps{})
+	secret := facade.Totp.Secret()
+	item, _ := testTotps(t, 1, secret)
+
+	// 密钥加密存储，读出时解密
+	var raw string
+	db.Model(&model.Totps{}).Where("uid = ?", 1).Pluck("secret", &raw)
+	if !facade.Aead.Encrypted(raw) || strings.Contains(raw, secret) || item.Secret != secret {
+		t.Fatalf("密钥应当加密存储：%q", raw)
+	}
+
+	now  := testStep()
+	code := facade.Totp.Code(secret, now)
+
+	if recovery, ok := item.Verify(code); !ok || recovery {
+		t.Fatal("验证码应当通过")
+	}
+	if item.LastStep != now {
+		t.Fatal("应当记录使用的时间步")
+	}
+
+	// 同一验证码、更早的验证码都不能再用，重新读取的记录也一样
+	fresh, _ := model.TotpsFind(1)
+	for _, value := range []*model.Totps{&item, &fresh} {
+		for _, step := range []int64{now, now - 1} {
+			if _, ok := value.Verify(facade.Totp.Code(secret, step)); ok {
+				t.Errorf("时间步 %d 的验证码不应当重复使用", step)
+			}
+		}
+	}
+
+	// 之后的时间步仍然可以使用
+	if _, ok := fresh.Verify(facade.Totp.Code(secret, now+1)); !ok {
+		t.Fatal("后一个时间步的验证码应当通过")
+	}
+}
+
+func TestTotpsConcurrent(t *testing.T) {
+
+	testDB(t, &model.Totps{})
+	secret := facade.Totp.Secret()
+	testTotps(t, 1, secret)
+
+	code := facade.Totp.Code(secret, testStep())
+
+	var pass int32
+	var group sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		item, _ := model.TotpsFind(1)
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			if _, ok := item.Verify(code); ok {
+				atomic.AddInt32(&pass, 1)
+			}
+		}()
+	}
+	group.Wait()
+
+	if pass != 1 {
+		t.Fatalf("同一验证码并发提交时应当只通过一次，实际 %d 次", pass)
+	}
+}
+
+func TestTotpsRecovery(t *testing.T) {
+
+	testDB(t, &model.Totps{})
+	item, codes := testTotps(t, 1, facade.Totp.Secret())
+
+	if len(codes) != model.TotpsRecoveryCount || item.Remaining() != model.TotpsRecoveryCount {
+		t.Fatalf("应当生成 %d 个恢复码", model.TotpsRecoveryCount)
+	}
+
+	for _, value := range []struct {
+		name     string
+		code     string
+		recovery bool
+		ok       bool
+	}{
+		{"恢复码", codes[0], true, true},
+		{"重复使用", codes[0], false, false},
+		{"小写无分隔符", strings.ToLower(strings.ReplaceAll(codes[1], "-", "")), true, true},
+		{"错误的恢复码", "AAAAA-AAAAA", false, false},
+		{"空", "", false, false},
+	} {
+		recovery, ok := item.Verify(value.code)
+		if recovery != value.recovery || ok != value.ok {
+			t.Errorf("%s：得到 %v %v", value.name, recovery, ok)
+		}
+	}
+	if item.Remaining() != model.TotpsRecoveryCount-2 {
+		t.Fatalf("使用后应当删除恢复码，剩余 %d", item.Remaining())
+	}
+
+	// 过期的记录（另一个请求已使用了恢复码）不能再用同一个恢复码
+	stale, _ := model.TotpsFind(1)
+	fresh, _ := model.TotpsFind(1)
+	if _, ok := fresh.Verify(codes[2]); !ok {
+		t.Fatal("恢复码应当通过")
+	}
+	if _, ok := stale.Verify(codes[2]); ok {
+		t.Fatal("已使用的恢复码不应当再次通过")
+	}
+
+	// 重新生成后旧的恢复码全部失效
+	next, err := model.TotpsRecovery(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, _ = model.TotpsFind(1)
+	if _, ok := item.Verify(codes[3]); ok {
+		t.Fatal("重新生成后旧的恢复码不应当通过")
+	}
+	if _, ok := item.Verify(next[0]); !ok {
+		t.Fatal("新的恢复码应当通过")
+	}
+
+	// 关闭后查不到
+	if err = model.TotpsDisable(1); err != nil || model.TotpsEnabled(1) {
+		t.Fatal("关闭后不应当再开启")
+	}
+}
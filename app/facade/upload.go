@@ -0,0 +1,290 @@
+package facade
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"image/gif"
+	"mime"
+	"path"
+	"strings"
+)
+
+const (
+	// UploadRoleGuest - 游客（未登录）
+	UploadRoleGuest = "guest"
+	// UploadRoleUser  - 普通用户
+	UploadRoleUser  = "user"
+)
+
+// UploadSniffSize - MIME 嗅探需要的字节数
+const UploadSniffSize = 3072
+
+// uploadDanger - 可被浏览器当作页面执行的类型，types 为 "*" 时也不允许，必须显式列出
+var uploadDanger = []string{"html", "htm", "xhtml", "shtml", "svg", "svgz", "xml", "js", "mjs"}
+
+// uploadImage - 可以去除 EXIF 并重新编码的图片类型
+var uploadImage = map[string]imaging.Format{
+	"jpg":  imaging.JPEG,
+	"jpeg": imaging.JPEG,
+	"png":  imaging.PNG,
+	"gif":  imaging.GIF,
+	"bmp":  imaging.BMP,
+	"tif":  imaging.TIFF,
+	"tiff": imaging.TIFF,
+}
+
+// UploadPolicyStruct - 上传策略
+type UploadPolicyStruct struct {
+	// 角色
+	Role    string
+	// 单个文件最大字节数 - 0 表示禁止上传
+	MaxSize int64
+	// 允许的扩展名（小写、不带点） - "*" 表示不限
+	Types   []string
+}
+
+// uploadRoles - 内置的上传策略 - 配置文件中没有 [upload.roles] 时使用（旧版本升级上来的配置）
+var uploadRoles = map[string]UploadPolicyStruct{
+	UploadRoleGuest: {
+		Role:    UploadRoleGuest,
+		MaxSize: 10,
+		Types:   []string{"jpg", "jpeg", "png", "gif", "webp"},
+	},
+	UploadRoleUser: {
+		Role:    UploadRoleUser,
+		MaxSize: 100,
+		Types:   []string{"jpg", "jpeg", "png", "gif", "webp", "bmp", "ico", "mp3", "mp4", "webm", "mov", "pdf", "txt", "md", "csv", "json", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "zip", "rar", "7z", "gz"},
+	},
+}
+
+// UploadPolicy - 获取角色的上传策略 - 读取 storage.toml 中的 [upload.roles.角色]，未配置的角色使用 user
+/**
+ * @param role 角色
+ * @return *UploadPolicyStruct
+ * @example：
+ * policy := facade.UploadPolicy(facade.UploadRoleGuest)
+ */
+func UploadPolicy(role string) *UploadPolicyStruct {
+
+	// 未配置任何策略 - 使用内置策略
+	if !StorageToml.Viper.IsSet("upload.roles") {
+		item, ok := uploadRoles[role]
+		if !ok {
+			item = uploadRoles[UploadRoleUser]
+		}
+		item.MaxSize <<= 20
+		return &item
+	}
+
+	if !StorageToml.Viper.IsSet("upload.roles." + role) {
+		role = UploadRoleUser
+	}
+
+	var types []string
+	for _, item := range cast.ToStringSlice(StorageToml.Viper.Get("upload.roles." + role + ".types")) {
+		types = append(types, strings.ToLower(strings.TrimPrefix(strings.TrimSpace(item), ".")))
+	}
+
+	return &UploadPolicyStruct{
+		Role:    role,
+		MaxSize: cast.ToInt64(StorageToml.Viper.Get("upload.roles."+role+".max_size")) << 20,
+		Types:   types,
+	}
+}
+
+// Allow - 检查文件名和大小
+/**
+ * @param name 原始文件名
+ * @param size 文件大小（字节）
+ * @return ext 小写扩展名（不带点）
+ */
+func (this *UploadPolicyStruct) Allow(name string, size int64) (ext string, err error) {
+
+	if this.MaxSize <= 0 {
+		return "", errors.New("当前账号不允许上传文件")
+	}
+
+	if size > this.MaxSize {
+		return "", fmt.Errorf("文件大小不能超过 %dMB", this.MaxSize>>20)
+	}
+
+	ext = strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	if utils.Is.Empty(ext) {
+		return "", errors.New("文件缺少扩展名")
+	}
+
+	if utils.InArray(ext, this.Types) {
+		return ext, nil
+	}
+
+	if utils.InArray("*", this.Types) && !utils.InArray(ext, uploadDanger) {
+		return ext, nil
+	}
+
+	return "", fmt.Errorf("不允许上传 .%s 文件", ext)
+}
+
+// Sniff - 根据文件内容嗅探 MIME，并检查是否与扩展名一致
+/**
+ * @param ext 小写扩展名（不带点）
+ * @param head 文件开头的内容，至少 UploadSniffSize 字节（文件更小时为全部内容）
+ * @return mime 嗅探到的 MIME
+ */
+func (this *UploadPolicyStruct) Sniff(ext string, head []byte) (mime string, err error) {
+
+	detect := mimetype.Detect(head)
+
+	if !uploadMatch(ext, detect) {
+		return detect.String(), fmt.Errorf("文件内容（%s）与扩展名 .%s 不符", detect.String(), ext)
+	}
+
+	return detect.String(), nil
+}
+
+// uploadMatch - 嗅探结果是否与扩展名一致
+func uploadMatch(ext string, detect *mimetype.MIME) bool {
+
+	expect := strings.Split(mime.TypeByExtension("."+ext), ";")[0]
+
+	// 页面类型只能使用对应的扩展名，防止以 .txt 等名义上传 html
+	for _, item := range []string{"text/html", "image/svg+xml", "text/xml", "application/xml"} {
+		if detect.Is(item) {
+			return utils.InArray(ext, uploadDanger)
+		}
+	}
+
+	for item := detect; item != nil; item = item.Parent() {
+		if item.Extension() == "."+ext || (!utils.Is.Empty(expect) && item.Is(expect)) {
+			return true
+		}
+	}
+
+	// jpg 和 jpeg、tif 和 tiff 等别名
+	alias := map[string]string{"jpeg": "jpg", "tif": "tiff", "htm": "html", "mpeg": "mpg"}
+	if value, ok := alias[ext]; ok && detect.Extension() == "."+value {
+		return true
+	}
+
+	// 纯文本 - 扩展名本身不是二进制格式时放行，如 md、log、csv
+	if detect.Is("text/plain") {
+		return utils.Is.Empty(expect) || strings.HasPrefix(expect, "text/") || strings.HasSuffix(expect, "json")
+	}
+
+	return false
+}
+
+// Reencode - 上传时是否会重新编码 - 开启了 upload.reencode 且为支持的图片类型
+func (this *UploadPolicyStruct) Reencode(ext string) bool {
+	_, ok := uploadImage[ext]
+	return (ok || ext == "webp") && cast.ToBool(StorageToml.Get("upload.reencode"))
+}
+
+// Sanitize - 图片去除 EXIF 等元数据并重新编码（webp 只删除元数据块），未开启 upload.reencode 或非图片时原样返回
+/**
+ * @param ext 小写扩展名（不带点）
+ * @param body 文件内容
+ * @return 处理后的文件内容
+ */
+func (this *UploadPolicyStruct) Sanitize(ext string, body []byte) ([]byte, error) {
+
+	if !this.Reencode(ext) {
+		return body, nil
+	}
+
+	// 与缩略图相同 - 解码前先检查像素
+	config, err := imageConfig(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// webp 没有纯 Go 的编码器 - 直接删除 EXIF、XMP 块，图像数据不变
+	if ext == "webp" {
+		return uploadWebP(body)
+	}
+
+	format := uploadImage[ext]
+	buffer := new(bytes.Buffer)
+
+	// 动图逐帧重新编码，保留动画
+	if format == imaging.GIF {
+		frames, err := gifFrames(body)
+		if err != nil {
+			return nil, errors.New("图片解析失败")
+		}
+		if err = imageFrames(config, frames); err != nil {
+			return nil, err
+		}
+		item, err := gif.DecodeAll(bytes.NewReader(body))
+		if err != nil {
+			return nil, errors.New("图片解析失败")
+		}
+		if err = gif.EncodeAll(buffer, item); err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	}
+
+	// 按 EXIF 方向旋转后再去除 EXIF，避免图片方向错乱
+	item, err := imaging.Decode(bytes.NewReader(body), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, errors.New("图片解析失败")
+	}
+
+	if err = imaging.Encode(buffer, item, format, imaging.JPEGQuality(90)); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// uploadWebP - 删除 webp 中的 EXIF、XMP 块，并清除 VP8X 中对应的标记
+/**
+ * webp 为 RIFF 容器：RIFF + 大小 + WEBP，之后是若干块：4 字节类型 + 4 字节大小（小端） + 内容（奇数长度补 1 字节）
+ */
+func uploadWebP(body []byte) ([]byte, error) {
+
+	if len(body) < 12 || string(body[:4]) != "RIFF" || string(body[8:12]) != "WEBP" {
+		return nil, errors.New("图片解析失败")
+	}
+
+	result := append([]byte{}, body[:12]...)
+
+	for offset := 12; offset < len(body); {
+
+		if offset+8 > len(body) {
+			return nil, errors.New("图片解析失败")
+		}
+
+		kind := string(body[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(body[offset+4 : offset+8]))
+		end  := offset + 8 + size + size%2
+		if end > len(body) || end < offset {
+			return nil, errors.New("图片解析失败")
+		}
+
+		switch kind {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, body[offset:end]...)
+			if size > 0 {
+				// 第 3 位为 EXIF，第 2 位为 XMP
+				chunk[8] &^= 0x08 | 0x04
+			}
+			result = append(result, chunk...)
+		default:
+			result = append(result, body[offset:end]...)
+		}
+
+		offset = end
+	}
+
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+
+	return result, nil
+}
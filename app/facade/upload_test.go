@@ -0,0 +1,157 @@
+package facade
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testPNG - 生成 PNG，extra 为插入到 IHDR 之后的 tEXt 内容（模拟元数据）
+func testPNG(t *testing.T, extra string) []byte {
+
+	buffer := new(bytes.Buffer)
+	if err := png.Encode(buffer, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	body := buffer.Bytes()
+	if extra == "" {
+		return body
+	}
+
+	// 文件头 8 字节 + IHDR 25 字节
+	data  := []byte("Comment\x00" + extra)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk  = append(chunk, "tEXt"...)
+	chunk  = append(chunk, data...)
+	chunk  = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(append([]byte("tEXt"), data...)))
+
+	return append(append(append([]byte{}, body[:33]...), chunk...), body[33:]...)
+}
+
+// testWebP - 带 EXIF、XMP 块的 VP8X 格式 webp（只有文件头，不含图像数据）
+func testWebP() []byte {
+
+	chunk := func(kind string, data []byte) []byte {
+		item := append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+		item  = append(item, data...)
+		if len(data)%2 == 1 {
+			item = append(item, 0)
+		}
+		return item
+	}
+
+	// 标记位：EXIF 0x08、XMP 0x04；宽高 - 1 各 3 字节
+	vp8x := []byte{0x08 | 0x04, 0, 0, 0, 3, 0, 0, 3, 0, 0}
+
+	body := []byte("WEBP")
+	body  = append(body, chunk("VP8X", vp8x)...)
+	body  = append(body, chunk("EXIF", []byte("secret-exif"))...)
+	body  = append(body, chunk("XMP ", []byte("secret-xmp"))...)
+
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func TestUploadAllow(t *testing.T) {
+
+	policy := &UploadPolicyStruct{MaxSize: 1 << 20, Types: []string{"jpg", "png", "txt"}}
+	any    := &UploadPolicyStruct{MaxSize: 1 << 20, Types: []string{"*"}}
+
+	for _, item := range []struct {
+		name   string
+		policy *UploadPolicyStruct
+		file   string
+		size   int64
+		ext    string
+	}{
+		{"允许的类型", policy, "a.PNG", 100, "png"},
+		{"多个点", policy, "a.tar.txt", 100, "txt"},
+		{"不允许的类型", policy, "a.exe", 100, ""},
+		{"没有扩展名", policy, "readme", 100, ""},
+		{"超过大小", policy, "a.png", 1<<20 + 1, ""},
+		{"禁止上传", &UploadPolicyStruct{Types: []string{"*"}}, "a.png", 1, ""},
+		{"不限类型", any, "a.exe", 100, "exe"},
+		{"不限类型时仍禁止页面", any, "a.html", 100, ""},
+		{"不限类型时仍禁止 svg", any, "a.SVG", 100, ""},
+		{"不限类型时仍禁止脚本", any, "a.js", 100, ""},
+	} {
+		ext, err := item.policy.Allow(item.file, item.size)
+		if ext != item.ext || (item.ext == "") != (err != nil) {
+			t.Errorf("%s：%s 得到 %q %v", item.name, item.file, ext, err)
+		}
+	}
+}
+
+func TestUploadSniff(t *testing.T) {
+
+	policy := &UploadPolicyStruct{}
+	image  := testPNG(t, "")
+
+	for _, item := range []struct {
+		name string
+		ext  string
+		body []byte
+		ok   bool
+	}{
+		{"PNG", "png", image, true},
+		{"PNG 改为 jpg", "jpg", image, false},
+		{"PNG 改为 txt", "txt", image, false},
+		{"文本", "txt", []byte("hello world"), true},
+		{"Markdown", "md", []byte("# title"), true},
+		{"JSON", "json", []byte(`{"a":1}`), true},
+		{"文本改为 png", "png", []byte("hello world"), false},
+		{"HTML 改为 txt", "txt", []byte("<!DOCTYPE html><html><script>alert(1)</script></html>"), false},
+		{"SVG 改为 png", "png", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), false},
+		{"HTML", "html", []byte("<!DOCTYPE html><html></html>"), true},
+	} {
+		if _, err := policy.Sniff(item.ext, item.body); (err == nil) != item.ok {
+			t.Errorf("%s：%v", item.name, err)
+		}
+	}
+}
+
+func TestUploadSanitize(t *testing.T) {
+
+	policy := &UploadPolicyStruct{}
+
+	// PNG 重新编码后元数据被去除
+	body, err := policy.Sanitize("png", testPNG(t, "secret-text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(body, []byte("secret-text")) {
+		t.Fatal("重新编码后不应当保留 tEXt 元数据")
+	}
+	if _, err = png.Decode(bytes.NewReader(body)); err != nil {
+		t.Fatalf("重新编码后的图片无法解析：%v", err)
+	}
+
+	// webp 删除 EXIF、XMP 块并清除对应的标记位
+	body, err = policy.Sanitize("webp", testWebP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(body, []byte("secret")) || bytes.Contains(body, []byte("EXIF")) || bytes.Contains(body, []byte("XMP ")) {
+		t.Fatal("webp 不应当保留 EXIF、XMP 块")
+	}
+	if body[20]&(0x08|0x04) != 0 {
+		t.Fatalf("VP8X 的元数据标记位未清除：%08b", body[20])
+	}
+	if int(binary.LittleEndian.Uint32(body[4:8])) != len(body)-8 {
+		t.Fatal("RIFF 大小未更新")
+	}
+
+	// 非图片原样返回
+	text := []byte("hello")
+	if body, err = policy.Sanitize("txt", text); err != nil || !bytes.Equal(body, text) {
+		t.Fatal("非图片应当原样返回")
+	}
+
+	// 内容无法解析
+	if _, err = policy.Sanitize("png", []byte(strings.Repeat("x", 100))); err == nil {
+		t.Fatal("无法解析的图片应当返回错误")
+	}
+}
@@ -0,0 +1,300 @@
+package facade
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// WatermarkModeLazy   - 访问图片时由图片处理服务添加，原图不变
+	WatermarkModeLazy   = "lazy"
+	// WatermarkModeUpload - 上传时直接写入图片
+	WatermarkModeUpload = "upload"
+	// WatermarkOff        - 签名地址中用于去除水印的参数值
+	WatermarkOff        = "off"
+)
+
+// WatermarkStruct - 水印
+type WatermarkStruct struct {
+	// 字体缓存 - 字体文件路径 => 字体
+	fonts sync.Map
+	// 图片水印缓存 - 图片路径 + 修改时间 => 图片
+	images sync.Map
+}
+
+// Watermark - 水印实例
+/**
+ * @example：
+ * position := facade.Watermark.Lazy("storage/2023-04/10/1.png")
+ */
+var Watermark = &WatermarkStruct{}
+
+// Enable - 是否开启了指定模式的水印
+func (this *WatermarkStruct) Enable(mode string) bool {
+	return cast.ToBool(StorageToml.Get("watermark.enable")) &&
+		cast.ToString(StorageToml.Get("watermark.mode", WatermarkModeLazy)) == mode
+}
+
+// Exclude - 路径是否不需要水印 - 匹配 watermark.exclude 中的前缀
+func (this *WatermarkStruct) Exclude(key string) bool {
+	key = strings.TrimPrefix(StorageKey(key), "public/")
+	for _, item := range cast.ToStringSlice(StorageToml.Get("watermark.exclude")) {
+		if strings.HasPrefix(key, strings.TrimPrefix(StorageKey(item), "public/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Position - 配置的水印位置
+func (this *WatermarkStruct) Position() string {
+	return strings.ToLower(cast.ToString(StorageToml.Get("watermark.position", "bottom-right")))
+}
+
+// Lazy - 访问图片时需要添加的水印位置 - 为空表示不需要
+/**
+ * @param key 图片路径
+ * @return string 水印位置
+ */
+func (this *WatermarkStruct) Lazy(key string) string {
+	if !this.Enable(WatermarkModeLazy) || this.Exclude(key) {
+		return ""
+	}
+	return this.Position()
+}
+
+// Override - 签名地址中的水印参数 - off 为去除水印，其他为水印位置
+/**
+ * @param value watermark 参数
+ * @return position 水印位置，为空表示不添加
+ */
+func (this *WatermarkStruct) Override(value string) (position string, err error) {
+
+	value = strings.ToLower(value)
+	if value == WatermarkOff {
+		return "", nil
+	}
+
+	if _, ok := imageGravity[value]; !ok {
+		return "", fmt.Errorf("不支持的水印位置：%s", value)
+	}
+
+	return value, nil
+}
+
+// Key - 水印配置的标识 - 配置或水印图片变化后缩略图缓存自动失效
+func (this *WatermarkStruct) Key() string {
+
+	var keys []string
+	for _, key := range []string{"type", "text", "font", "color", "image", "opacity", "scale", "margin", "min_width", "min_height"} {
+		keys = append(keys, cast.ToString(StorageToml.Get("watermark."+key)))
+	}
+
+	if info, err := os.Stat(cast.ToString(StorageToml.Get("watermark.image"))); err == nil {
+		keys = append(keys, cast.ToString(info.ModTime().UnixNano()))
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(keys, "\n"))))[:16]
+}
+
+// Apply - 添加水印 - 图片小于 min_width 或 min_height 时原样返回
+/**
+ * @param src 图片
+ * @param position 位置 - center、top、bottom、left、right、top-left、top-right、bottom-left、bottom-right
+ * @return image.Image
+ */
+func (this *WatermarkStruct) Apply(src image.Image, position string) (image.Image, error) {
+
+	bounds := src.Bounds()
+	if bounds.Dx() < cast.ToInt(StorageToml.Get("watermark.min_width", 300)) ||
+		bounds.Dy() < cast.ToInt(StorageToml.Get("watermark.min_height", 300)) {
+		return src, nil
+	}
+
+	anchor, ok := imageGravity[position]
+	if !ok {
+		return nil, fmt.Errorf("不支持的水印位置：%s", position)
+	}
+
+	// 水印宽度为图片宽度的 scale 倍
+	width := int(float64(bounds.Dx()) * cast.ToFloat64(StorageToml.Get("watermark.scale", 0.2)))
+	if width < 1 {
+		return src, nil
+	}
+
+	var mark image.Image
+	var err error
+	if cast.ToString(StorageToml.Get("watermark.type", "text")) == "image" {
+		mark, err = this.image(width)
+	} else {
+		mark, err = this.text(width)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+
+	// 边距为短边的 margin 倍
+	margin := int(math.Min(float64(bounds.Dx()), float64(bounds.Dy())) * cast.ToFloat64(StorageToml.Get("watermark.margin", 0.02)))
+	point  := this.point(dst.Bounds(), mark.Bounds(), anchor, margin)
+
+	opacity := math.Max(0, math.Min(1, cast.ToFloat64(StorageToml.Get("watermark.opacity", 0.5))))
+	mask    := image.NewUniform(color.Alpha{A: uint8(opacity * 255)})
+
+	draw.DrawMask(dst, mark.Bounds().Add(point), mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+
+	return dst, nil
+}
+
+// point - 按锚点计算水印左上角的位置
+func (this *WatermarkStruct) point(canvas, mark image.Rectangle, anchor imaging.Anchor, margin int) image.Point {
+
+	x := (canvas.Dx() - mark.Dx()) / 2
+	y := (canvas.Dy() - mark.Dy()) / 2
+
+	switch anchor {
+	case imaging.TopLeft, imaging.Left, imaging.BottomLeft:
+		x = margin
+	case imaging.TopRight, imaging.Right, imaging.BottomRight:
+		x = canvas.Dx() - mark.Dx() - margin
+	}
+
+	switch anchor {
+	case imaging.TopLeft, imaging.Top, imaging.TopRight:
+		y = margin
+	case imaging.BottomLeft, imaging.Bottom, imaging.BottomRight:
+		y = canvas.Dy() - mark.Dy() - margin
+	}
+
+	return image.Pt(x, y)
+}
+
+// image - 图片水印 - 按宽度等比例缩放
+func (this *WatermarkStruct) image(width int) (image.Image, error) {
+
+	name := cast.ToString(StorageToml.Get("watermark.image"))
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key := name + "|" + cast.ToString(info.ModTime().UnixNano())
+	item, ok := this.images.Load(key)
+	if !ok {
+		src, err := imaging.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		item, _ = this.images.LoadOrStore(key, src)
+	}
+
+	return imaging.Resize(item.(image.Image), width, 0, imaging.Lanczos), nil
+}
+
+// text - 文字水印 - 字号按宽度计算
+func (this *WatermarkStruct) text(width int) (image.Image, error) {
+
+	text := cast.ToString(StorageToml.Get("watermark.text"))
+	if utils.Is.Empty(text) {
+		return nil, errors.New("水印文字不能为空")
+	}
+
+	item, err := this.font()
+	if err != nil {
+		return nil, err
+	}
+
+	// 先用固定字号测量，再按比例换算
+	size := 100.0
+	face, err := opentype.NewFace(item, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	measure := font.MeasureString(face, text).Ceil()
+	_ = face.Close()
+	if measure == 0 {
+		return nil, errors.New("水印文字无法显示")
+	}
+
+	size = math.Max(size*float64(width)/float64(measure), 1)
+	if face, err = opentype.NewFace(item, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull}); err != nil {
+		return nil, err
+	}
+	defer func(face font.Face) {
+		_ = face.Close()
+	}(face)
+
+	metrics := face.Metrics()
+	canvas  := image.NewNRGBA(image.Rect(0, 0, font.MeasureString(face, text).Ceil(), (metrics.Ascent + metrics.Descent).Ceil()))
+
+	drawer := &font.Drawer{
+		Dst:  canvas,
+		Src:  image.NewUniform(this.color()),
+		Face: face,
+		Dot:  fixed.Point26_6{Y: metrics.Ascent},
+	}
+	drawer.DrawString(text)
+
+	return canvas, nil
+}
+
+// font - 字体 - 未配置字体文件时使用内置的 Go Regular（不包含中文，中文水印需要配置字体）
+func (this *WatermarkStruct) font() (*opentype.Font, error) {
+
+	name := cast.ToString(StorageToml.Get("watermark.font"))
+
+	if item, ok := this.fonts.Load(name); ok {
+		return item.(*opentype.Font), nil
+	}
+
+	body := goregular.TTF
+	if !utils.Is.Empty(name) {
+		var err error
+		if body, err = os.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	item, err := opentype.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	this.fonts.Store(name, item)
+
+	return item, nil
+}
+
+// color - 文字颜色 - 如：#ffffff
+func (this *WatermarkStruct) color() color.Color {
+
+	value := strings.TrimPrefix(cast.ToString(StorageToml.Get("watermark.color", "#ffffff")), "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil || len(value) != 6 {
+		return color.White
+	}
+
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}
+}
@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"inis/app/facade"
+)
+
+// JWKS - 公钥集 - 其他服务据此验证本服务签发的令牌
+func JWKS(ctx *gin.Context) {
+
+	// 轮换后新密钥需要尽快可见，缓存时间不宜过长
+	ctx.Header("Cache-Control", "public, max-age=300")
+
+	ctx.JSON(200, facade.JwtKeys.JWKS())
+}
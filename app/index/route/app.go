@@ -31,6 +31,7 @@ func Route(Gin *gin.Engine) {
 
 	// 注册路由
 	Gin.GET("/", controller.Index)
+	Gin.GET("/.well-known/jwks.json", controller.JWKS)
 }
 
 // watch - 监听 public/index.html 文件变化
@@ -14,6 +14,9 @@ import (
 	"strings"
 )
 
+// paramsStream - 不拷贝请求体的接口 - 由控制器按上传策略限制大小后再解析，避免超大的请求体全部读入内存
+var paramsStream = []any{"/api/file/upload"}
+
 func Params() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
@@ -27,6 +30,15 @@ func Params() gin.HandlerFunc {
 		method := ctx.Request.Method
 		params := make(map[string]any)
 
+		// 二进制流（如分片上传）、文件上传不拷贝 body，只解析 query，由控制器直接读取
+		if strings.Contains(ctx.GetHeader("Content-Type"), "application/octet-stream") || utils.In.Array(strings.ToLower(ctx.Request.URL.Path), paramsStream) {
+			for key, val := range utils.Parse.Params(utils.Parse.ParamsBefore(ctx.Request.URL.Query())) {
+				params[key] = val
+			}
+			ctx.Set("params", params)
+			return
+		}
+
 		// 拷贝一份 body
 		body, _ := io.ReadAll(ctx.Request.Body)
 
@@ -2,36 +2,41 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/cast"
 	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"inis/app/model"
 )
 
-// Token 简单 token 验证
+// Token 服务间调用验证 - 必须携带有效的 API Key 签名（i-api-key、i-timestamp、i-nonce、i-signature）
 func Token() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		// 检查请求头的 Authorization 字段是否存在
-		auth := ctx.Request.Header.Get("Authorization")
-		// 从请求参数中获取 token
-		if utils.Is.Empty(auth) {
-			auth = ctx.Query("token")
-		}
 
-		if utils.Is.Empty(auth) {
-			ctx.JSON(200, gin.H{"data": nil, "code": 401, "msg": "未授权"})
+		if utils.Is.Empty(ctx.GetHeader("i-api-key")) {
+			ctx.JSON(200, gin.H{"data": nil, "code": 401, "msg": facade.Lang(ctx, "未授权")})
 			ctx.Abort()
 			return
 		}
 
-		// token := cast.ToString(utils.Env().Get("app.token", "0147."))
-		token := cast.ToString("0147.")
+		item, err := model.ApiKeysAuth(ctx.Writer, ctx.Request)
+		if err != nil {
+			ctx.JSON(200, gin.H{"data": nil, "code": 403, "msg": facade.Lang(ctx, err.Error())})
+			ctx.Abort()
+			return
+		}
 
-		// 检查请求头的 Authorization 字段是否正确
-		if auth != token {
-			ctx.JSON(200, gin.H{"data": nil, "code": 403, "msg": "无权限"})
+		user := facade.DB.Model(&model.Users{}).Find(item.Uid)
+		if utils.Is.Empty(user) {
+			ctx.JSON(200, gin.H{"data": nil, "code": 403, "msg": facade.Lang(ctx, "无权限")})
 			ctx.Abort()
 			return
 		}
 
+		go model.ApiKeysTouch(item, ctx.ClientIP())
+
+		ctx.Set("user", user)
+		ctx.Set("api_key", item.KeyId)
+		ctx.Set("scopes", item.Scopes)
+
 		ctx.Next()
 	}
 }
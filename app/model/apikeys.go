@@ -0,0 +1,222 @@
+package model
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ApiKeys - API Key - 以所属用户的身份调用接口，权限为用户权限与 Scopes 的交集
+type ApiKeys struct {
+	Id         int      `gorm:"type:int(32); comment:主键;" json:"id"`
+	Uid        int      `gorm:"type:int(32); comment:所属用户ID; default:0; index;" json:"uid"`
+	Name       string   `gorm:"size:64; comment:名称，如调用方应用;" json:"name"`
+	KeyId      string   `gorm:"size:32; comment:Key ID; uniqueIndex;" json:"key_id"`
+	Salt       string   `gorm:"size:32; comment:派生密钥的随机盐;" json:"-"`
+	Hash       string   `gorm:"size:64; comment:密钥哈希;" json:"-"`
+	Scopes     []string `gorm:"type:text; serializer:json; comment:权限范围，如：files:*;" json:"scopes"`
+	Ip         string   `gorm:"size:64; comment:最后调用的IP; default:Null;" json:"ip"`
+	LastTime   int64    `gorm:"comment:最后调用时间; default:0;" json:"last_time"`
+	ExpireTime int64    `gorm:"comment:过期时间 - 为0时永不过期; default:0;" json:"expire_time"`
+	RevokeTime int64    `gorm:"comment:吊销时间 - 不为0表示已吊销; default:0;" json:"revoke_time"`
+	CreateTime int64    `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+	UpdateTime int64    `gorm:"autoUpdateTime; comment:更新时间;" json:"update_time"`
+}
+
+// ApiKeysTouchInterval - 最后调用时间的更新间隔（秒）
+const ApiKeysTouchInterval = 60
+
+// InitApiKeys - 初始化ApiKeys表
+func InitApiKeys() {
+	// 迁移表
+	err := facade.DB.Drive().AutoMigrate(&ApiKeys{})
+	if err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "ApiKeys表迁移失败")
+		return
+	}
+}
+
+// apiKeysCached - 缓存结构 - Salt、Hash 不输出到 JSON，缓存时需要单独保存
+type apiKeysCached struct {
+	ApiKeys
+	Salt string `json:"salt"`
+	Hash string `json:"hash"`
+}
+
+// apiKeysCache - API Key 的缓存名称
+func apiKeysCache(keyId string) string {
+	return fmt.Sprintf("apikey[%s]", keyId)
+}
+
+// ApiKeysCreate - 签发 API Key
+/**
+ * @param uid 所属用户ID
+ * @param name 名称
+ * @param scopes 权限范围 - 已归一化
+ * @param expire 有效期 - 为0时永不过期
+ * @return item API Key，secret 密钥（只返回这一次）
+ */
+func ApiKeysCreate(uid int, name string, scopes []string, expire time.Duration) (item ApiKeys, secret string, err error) {
+
+	item = ApiKeys{
+		Uid:    uid,
+		Name:   name,
+		KeyId:  "ak_" + utils.Rand.String(24, "0123456789abcdefghijklmnopqrstuvwxyz"),
+		Salt:   utils.Rand.String(32, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"),
+		Scopes: scopes,
+	}
+
+	if expire > 0 {
+		item.ExpireTime = time.Now().Add(expire).Unix()
+	}
+
+	secret    = facade.ApiKey.Secret(item.KeyId, item.Salt)
+	item.Hash = facade.ApiKey.Hash(secret)
+
+	return item, secret, facade.DB.Drive().Create(&item).Error
+}
+
+// ApiKeysFind - 查找 API Key - 开启了缓存时优先从缓存读取
+func ApiKeysFind(keyId string) (item ApiKeys, ok bool) {
+
+	cacheName  := apiKeysCache(keyId)
+	cacheState := cast.ToBool(facade.CacheToml.Get("open"))
+
+	if cacheState {
+		if cache, exist := facade.CacheGet[apiKeysCached](cacheName); exist {
+			item = cache.ApiKeys
+			item.Salt, item.Hash = cache.Salt, cache.Hash
+			return item, item.Id != 0
+		}
+	}
+
+	facade.DB.Drive().Where("key_id = ?", keyId).Limit(1).Find(&item)
+
+	if cacheState && item.Id != 0 {
+		go facade.CacheSet(cacheName, apiKeysCached{ApiKeys: item, Salt: item.Salt, Hash: item.Hash}, time.Duration(facade.JwtAccessExpire())*time.Second)
+	}
+
+	return item, item.Id != 0
+}
+
+// Active - API Key 是否有效
+func (this *ApiKeys) Active() bool {
+	return this.Id != 0 && this.RevokeTime == 0 && (this.ExpireTime == 0 || this.ExpireTime >= time.Now().Unix())
+}
+
+// ApiKeysList - 用户的 API Key - 包括已过期的，不包括已吊销的
+func ApiKeysList(uid any) (result []ApiKeys) {
+	facade.DB.Drive().Where("uid = ? AND revoke_time = 0", uid).Order("id desc").Find(&result)
+	return result
+}
+
+// ApiKeysRevoke - 吊销 API Key
+/**
+ * @param uid 所属用户ID - 为 nil 时不限制（管理员）
+ * @param ids API Key 的主键
+ * @return int 吊销的数量
+ */
+func ApiKeysRevoke(uid any, ids []int) int {
+
+	var keys []string
+	query := facade.DB.Drive().Model(&ApiKeys{}).Where("id IN ? AND revoke_time = 0", ids)
+	if uid != nil {
+		query = query.Where("uid = ?", uid)
+	}
+	query.Pluck("key_id", &keys)
+
+	if len(keys) == 0 {
+		return 0
+	}
+
+	facade.DB.Drive().Model(&ApiKeys{}).Where("key_id IN ?", keys).UpdateColumn("revoke_time", time.Now().Unix())
+	for _, key := range keys {
+		facade.Cache.Del(apiKeysCache(key))
+	}
+
+	return len(keys)
+}
+
+// ApiKeysRevokeUser - 吊销用户的全部 API Key（删除用户时）
+func ApiKeysRevokeUser(uid any) int {
+
+	var ids []int
+	facade.DB.Drive().Model(&ApiKeys{}).Where("uid = ? AND revoke_time = 0", uid).Pluck("id", &ids)
+
+	return ApiKeysRevoke(uid, ids)
+}
+
+// ApiKeysTouch - 更新最后调用时间和IP - 距上次更新不足 ApiKeysTouchInterval 时跳过
+func ApiKeysTouch(item ApiKeys, ip string) {
+
+	now := time.Now().Unix()
+	if now-item.LastTime < ApiKeysTouchInterval && item.Ip == ip {
+		return
+	}
+
+	facade.DB.Drive().Model(&ApiKeys{}).Where("id = ?", item.Id).UpdateColumns(map[string]any{"last_time": now, "ip": ip})
+	facade.Cache.Del(apiKeysCache(item.KeyId))
+}
+
+// ApiKeysAuth - 校验请求的 API Key 签名 - 读取后还原请求体
+/**
+ * @param writer 响应 - 请求体超出 apikey.max_body 时用于关闭连接
+ * @param request 请求 - 需要 i-api-key、i-timestamp、i-nonce、i-signature 请求头
+ * @return ApiKeys, error
+ */
+func ApiKeysAuth(writer http.ResponseWriter, request *http.Request) (item ApiKeys, err error) {
+
+	keyId     := request.Header.Get("i-api-key")
+	timestamp := request.Header.Get("i-timestamp")
+	nonce     := request.Header.Get("i-nonce")
+	signature := request.Header.Get("i-signature")
+
+	if utils.Is.Empty(keyId) || utils.Is.Empty(timestamp) || utils.Is.Empty(nonce) || utils.Is.Empty(signature) {
+		return item, errors.New("签名参数不完整！")
+	}
+
+	if !facade.ApiKey.Fresh(timestamp) {
+		return item, errors.New("签名已过期！")
+	}
+
+	item, ok := ApiKeysFind(keyId)
+	if !ok || !item.Active() {
+		return item, errors.New("API Key 无效或已过期！")
+	}
+
+	// 主密钥变化后派生出的密钥不再匹配
+	secret := facade.ApiKey.Secret(item.KeyId, item.Salt)
+	if subtle.ConstantTimeCompare([]byte(facade.ApiKey.Hash(secret)), []byte(item.Hash)) != 1 {
+		return item, errors.New("API Key 无效或已过期！")
+	}
+
+	var body []byte
+	if request.Body != nil {
+		if body, err = io.ReadAll(http.MaxBytesReader(writer, request.Body, facade.ApiKey.MaxBody())); err != nil {
+			var large *http.MaxBytesError
+			if errors.As(err, &large) {
+				return item, errors.New("请求体过大！")
+			}
+			return item, err
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if !facade.ApiKey.Verify(secret, signature, request.Method, request.URL.RequestURI(), timestamp, nonce, body) {
+		return item, errors.New("签名错误！")
+	}
+
+	// 签名通过后再记录 nonce，避免伪造的请求占用
+	if !facade.ApiKey.Nonce(item.KeyId, nonce) {
+		return item, errors.New("请求已被使用！")
+	}
+
+	return item, nil
+}
@@ -27,6 +27,13 @@ func InitTable() {
 
 	allow := []func(){
 		InitUsers,
+		InitFiles,
+		InitTokens,
+		InitSessions,
+		InitRoles,
+		InitTotps,
+		InitIdentities,
+		InitApiKeys,
 	}
 
 	for _, val := range allow {
@@ -46,7 +53,7 @@ func init() {
 func DomainTemp1() (replace map[string]any) {
 	toml := facade.NewToml(facade.TomlStorage)
 	replace = make(map[string]any)
-	storage := []string{"oss", "cos", "kodo"}
+	storage := []string{"oss", "cos", "kodo", "s3"}
 	// 模板变量替换
 	for _, val := range storage {
 		// 优先使用配置文件中的域名
@@ -70,6 +77,9 @@ func DomainTemp1() (replace map[string]any) {
 				)
 			}
 		}
+		if val == "s3" && facade.S3 != nil && facade.S3.Client != nil {
+			replace["{{"+val+"}}"] = facade.S3.Domain()
+		}
 	}
 	if !utils.Is.Empty(facade.Cache.Get("domain")) {
 		replace["{{localhost}}"] = cast.ToString(facade.Cache.Get("domain"))
@@ -82,7 +92,7 @@ func DomainTemp1() (replace map[string]any) {
 func DomainTemp2() (replace map[string]any) {
 	toml := facade.NewToml(facade.TomlStorage)
 	replace = make(map[string]any)
-	storage := []string{"oss", "cos", "kodo"}
+	storage := []string{"oss", "cos", "kodo", "s3"}
 	// 拼接自定义域名
 	for _, val := range storage {
 		if !utils.Is.Empty(toml.Get(val + ".domain")) {
@@ -107,5 +117,10 @@ func DomainTemp2() (replace map[string]any) {
 	replace[oss] = "{{oss}}"
 	replace[cos] = "{{cos}}"
 
+	// 拼接 s3 域名
+	if facade.S3 != nil && facade.S3.Client != nil {
+		replace[facade.S3.Domain()] = "{{s3}}"
+	}
+
 	return replace
 }
@@ -0,0 +1,245 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"inis/app/facade"
+	"reflect"
+	"strings"
+)
+
+// EncryptModels - 含有加密字段的模型 - 用于 aead:migrate 重新加密
+var EncryptModels = []any{&Users{}, &Totps{}}
+
+func init() {
+	schema.RegisterSerializer("encrypt", EncryptSerializer{})
+}
+
+// EncryptSerializer - 加密字段的序列化器 - 写入时使用 facade.Aead 加密，读取时解密
+/**
+ * 字段声明 `gorm:"serializer:encrypt;"` 即可，只支持 string 类型
+ * 需要查询的字段另加一个盲索引字段，声明 `blind:"字段名"`，在模型的 BeforeSave 中调用 encryptSave
+ * @example：
+ * Phone      string `gorm:"size:255; serializer:encrypt;" json:"phone"`
+ * PhoneIndex string `gorm:"size:64; index;" json:"-" blind:"phone"`
+ */
+type EncryptSerializer struct{}
+
+// Scan - 读取时解密 - 启用加密前写入的明文原样返回
+func (EncryptSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) (err error) {
+
+	var text string
+	switch value := dbValue.(type) {
+	case []byte:
+		text = string(value)
+	case string:
+		text = value
+	case nil:
+	default:
+		return fmt.Errorf("加密字段 %s 的类型不支持：%T", field.Name, dbValue)
+	}
+
+	if facade.Aead.Encrypted(text) {
+		if text, err = facade.Aead.Decrypt(text, encryptAad(field)); err != nil {
+			return fmt.Errorf("加密字段 %s 解密失败：%v", field.Name, err)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(text)
+
+	return nil
+}
+
+// Value - 写入时加密 - 空值不加密
+func (EncryptSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+
+	text := cast.ToString(fieldValue)
+	if text == "" || facade.Aead.Encrypted(text) {
+		return text, nil
+	}
+
+	return facade.Aead.Encrypt(text, encryptAad(field))
+}
+
+// encryptAad - 加密字段的附加数据 - 模型名.字段名，不使用表名，避免修改表前缀后无法解密
+func encryptAad(field *schema.Field) string {
+	return strings.ToLower(field.Schema.Name) + "." + field.DBName
+}
+
+// encryptField - 是否为加密字段
+func encryptField(field *schema.Field) bool {
+	return field.TagSettings["SERIALIZER"] == "encrypt"
+}
+
+// encryptBlind - 加密字段对应的盲索引字段
+func encryptBlind(field *schema.Field) *schema.Field {
+	for _, item := range field.Schema.Fields {
+		if name := item.Tag.Get("blind"); name != "" && field.Schema.LookUpField(name) == field {
+			return item
+		}
+	}
+	return nil
+}
+
+// encryptPlain - 加密字段的明文 - 已经是密文时先解密
+func encryptPlain(field *schema.Field, value any) (string, error) {
+	text := cast.ToString(value)
+	if facade.Aead.Encrypted(text) {
+		return facade.Aead.Decrypt(text, encryptAad(field))
+	}
+	return text, nil
+}
+
+// encryptMap - 加密 map 中的加密字段并填充盲索引 - gorm 使用 map 更新时不经过序列化器
+func encryptMap(table *schema.Schema, values map[string]any) (err error) {
+
+	for _, field := range table.Fields {
+
+		if !encryptField(field) {
+			continue
+		}
+
+		key := field.DBName
+		if _, ok := values[key]; !ok {
+			if _, ok = values[field.Name]; !ok {
+				continue
+			}
+			key = field.Name
+		}
+
+		plain, err := encryptPlain(field, values[key])
+		if err != nil {
+			return err
+		}
+
+		if blind := encryptBlind(field); blind != nil {
+			values[blind.DBName] = facade.Aead.BlindIndex(plain, encryptAad(field))
+		}
+
+		if plain == "" {
+			values[key] = ""
+			continue
+		}
+
+		if values[key], err = facade.Aead.Encrypt(plain, encryptAad(field)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encryptSave - 保存前处理加密字段 - 在模型的 BeforeSave 中调用
+/**
+ * 使用 map 更新时加密并填充盲索引；使用结构体保存时由序列化器加密，这里只计算盲索引
+ * @param tx 钩子的 tx
+ * @param model 钩子的接收者
+ */
+func encryptSave(tx *gorm.DB, model any) (err error) {
+
+	if tx.Statement.Schema == nil {
+		return nil
+	}
+
+	if values, ok := tx.Statement.Dest.(map[string]any); ok {
+		return encryptMap(tx.Statement.Schema, values)
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(model))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, field := range tx.Statement.Schema.Fields {
+
+		if !encryptField(field) {
+			continue
+		}
+
+		blind := encryptBlind(field)
+		if blind == nil {
+			continue
+		}
+
+		plain, err := encryptPlain(field, field.ReflectValueOf(tx.Statement.Context, value).Interface())
+		if err != nil {
+			return err
+		}
+
+		if err = blind.Set(tx.Statement.Context, value, facade.Aead.BlindIndex(plain, encryptAad(field))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EncryptMigrate - 重新加密表中的加密字段，并重建盲索引
+/**
+ * @param model 模型，如：&Users{}
+ * @param all 为 true 时处理全部数据（轮换密钥、修改盲索引密钥后使用），否则只处理还是明文的旧数据
+ * @return count 处理的行数
+ */
+func EncryptMigrate(model any, all bool) (count int64, err error) {
+
+	db   := facade.DB.Drive()
+	stmt := &gorm.Statement{DB: db, Context: context.Background()}
+	if err = stmt.Parse(model); err != nil {
+		return 0, err
+	}
+
+	var fields []*schema.Field
+	var where  []string
+	var args   []any
+	for _, field := range stmt.Schema.Fields {
+		if encryptField(field) {
+			fields = append(fields, field)
+			where  = append(where, fmt.Sprintf("(%s <> '' AND %s NOT LIKE ?)", stmt.Quote(field.DBName), stmt.Quote(field.DBName)))
+			args   = append(args, facade.AeadPrefix+"%")
+		}
+	}
+
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	query := db.Model(model).Unscoped()
+	if !all {
+		query = query.Where(strings.Join(where, " OR "), args...)
+	}
+
+	primary := stmt.Schema.PrioritizedPrimaryField
+	rows    := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+
+	tx := query.FindInBatches(rows.Interface(), 100, func(tx *gorm.DB, batch int) error {
+
+		list := rows.Elem()
+
+		for i := 0; i < list.Len(); i++ {
+
+			item   := list.Index(i)
+			values := make(map[string]any)
+			for _, field := range fields {
+				values[field.DBName] = field.ReflectValueOf(stmt.Context, item).Interface()
+			}
+
+			if err := encryptMap(stmt.Schema, values); err != nil {
+				return err
+			}
+
+			id, _ := primary.ValueOf(stmt.Context, item)
+			if err := db.Model(model).Unscoped().Where(primary.DBName+" = ?", id).UpdateColumns(values).Error; err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	return count, tx.Error
+}
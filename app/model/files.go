@@ -0,0 +1,119 @@
+package model
+
+import (
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"gorm.io/gorm"
+	"gorm.io/plugin/soft_delete"
+	"inis/app/facade"
+)
+
+type Files struct {
+	Id          int    `gorm:"type:int(32); comment:主键;" json:"id"`
+	Uid         int    `gorm:"type:int(32); comment:上传者ID; default:0; index;" json:"uid"`
+	Name        string `gorm:"size:255; comment:原始文件名;" json:"name"`
+	Size        int64  `gorm:"comment:文件大小(字节); default:0;" json:"size"`
+	Mime        string `gorm:"size:128; comment:文件类型;" json:"mime"`
+	Sha256      string `gorm:"size:64; comment:文件哈希; index;" json:"sha256"`
+	Driver      string `gorm:"size:32; comment:存储驱动;" json:"driver"`
+	Key         string `gorm:"size:512; comment:存储路径; index;" json:"key"`
+	Url         string `gorm:"comment:访问地址; default:Null;" json:"url"`
+	Remark      string `gorm:"comment:备注; default:Null;" json:"remark"`
+	// 以下为公共字段
+	Json       any                   `gorm:"type:longtext; comment:用于存储JSON数据;" json:"json"`
+	Text       any                   `gorm:"type:longtext; comment:用于存储文本数据;" json:"text"`
+	Result     any                   `gorm:"type:varchar(256); comment:不存储数据，用于封装返回结果;" json:"result"`
+	CreateTime int64                 `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+	UpdateTime int64                 `gorm:"autoUpdateTime; comment:更新时间;" json:"update_time"`
+	DeleteTime soft_delete.DeletedAt `gorm:"comment:删除时间; default:0;" json:"delete_time"`
+}
+
+// InitFiles - 初始化Files表
+func InitFiles() {
+	// 迁移表
+	err := facade.DB.Drive().AutoMigrate(&Files{})
+	if err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Files表迁移失败")
+		return
+	}
+}
+
+// AfterFind - 查询后的钩子
+func (this *Files) AfterFind(tx *gorm.DB) (err error) {
+
+	// 替换 url 中的域名
+	this.Url = utils.Replace(this.Url, DomainTemp1())
+
+	this.Text = cast.ToString(this.Text)
+	this.Json = utils.Json.Decode(this.Json)
+
+	return
+}
+
+// BeforeSave - 保存前的Hook（包括 create update）
+func (this *Files) BeforeSave(tx *gorm.DB) (err error) {
+
+	// 域名替换为模板变量，切换域名后无需修改数据
+	this.Url = utils.Replace(this.Url, DomainTemp2())
+
+	return
+}
+
+// FilesOwner - 用户是否可以操作存储对象 - 管理员，或者有该对象的上传记录
+/**
+ * @param uid 用户ID
+ * @param key 存储对象 key
+ * @return bool
+ */
+func FilesOwner(uid int, key string) bool {
+	if uid == 0 {
+		return false
+	}
+	if PermissionsAdmin(uid) {
+		return true
+	}
+	return facade.DB.Model(&Files{}).Where("uid", uid).Where("key", key).Exist()
+}
+
+// FilesRemove - 真实删除文件记录 - 同一对象不再被任何记录引用时（秒传会共享对象），同时从存储中删除
+/**
+ * @param files 要删除的记录
+ * @return error
+ */
+func FilesRemove(files []Files) (err error) {
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	ids := make([]any, 0, len(files))
+	for _, item := range files {
+		ids = append(ids, item.Id)
+	}
+
+	if tx := facade.DB.Model(&Files{}).WithTrashed().Force().Delete(ids); tx.Error != nil {
+		return tx.Error
+	}
+
+	// 删除不再被引用的对象
+	for _, item := range files {
+
+		exist := facade.DB.Model(&Files{}).WithTrashed().Where("driver", item.Driver).Where("key", item.Key).Exist()
+		if exist {
+			continue
+		}
+
+		if result := facade.StorageDriver(item.Driver).Delete(item.Key); result.Error != nil {
+			facade.Log.Error(map[string]any{
+				"error":     result.Error,
+				"key":       item.Key,
+				"driver":    item.Driver,
+				"func_name": utils.Caller().FuncName,
+				"file_name": utils.Caller().FileName,
+				"file_line": utils.Caller().Line,
+			}, "存储对象删除失败")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"inis/app/facade"
+)
+
+// Identities - 第三方帐号绑定 - 同一平台的同一帐号只能绑定一个用户，一个用户可以绑定多个平台
+type Identities struct {
+	Id         int    `gorm:"type:int(32); comment:主键;" json:"id"`
+	Uid        int    `gorm:"type:int(32); comment:用户ID; default:0; index;" json:"uid"`
+	Provider   string `gorm:"size:32; comment:平台; uniqueIndex:idx_identities_subject;" json:"provider"`
+	Subject    string `gorm:"size:128; comment:平台内的用户标识; uniqueIndex:idx_identities_subject;" json:"-"`
+	Email      string `gorm:"size:128; comment:平台返回的邮箱; default:Null;" json:"email"`
+	Nickname   string `gorm:"size:64; comment:平台返回的昵称; default:Null;" json:"nickname"`
+	Avatar     string `gorm:"comment:平台返回的头像; default:Null;" json:"avatar"`
+	CreateTime int64  `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+	UpdateTime int64  `gorm:"autoUpdateTime; comment:更新时间;" json:"update_time"`
+}
+
+// InitIdentities - 初始化Identities表
+func InitIdentities() {
+	// 迁移表
+	err := facade.DB.Drive().AutoMigrate(&Identities{})
+	if err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Identities表迁移失败")
+		return
+	}
+}
+
+// IdentitiesFind - 查找绑定 - 未绑定时 ok 为 false
+func IdentitiesFind(provider, subject string) (item Identities, ok bool) {
+	facade.DB.Drive().Where("provider = ? AND subject = ?", provider, subject).Limit(1).Find(&item)
+	return item, item.Id != 0
+}
+
+// IdentitiesBind - 绑定第三方帐号 - 已绑定当前用户时更新资料，已绑定其他用户时返回错误
+/**
+ * @param uid 用户ID
+ * @param profile 第三方用户信息
+ * @return Identities, error
+ */
+func IdentitiesBind(uid int, profile *facade.OAuthProfile) (item Identities, err error) {
+
+	item, ok := IdentitiesFind(profile.Provider, profile.Subject)
+	if ok && item.Uid != uid {
+		return item, errors.New("该第三方帐号已绑定其他用户！")
+	}
+
+	item.Uid, item.Provider, item.Subject = uid, profile.Provider, profile.Subject
+	item.Email, item.Nickname, item.Avatar = profile.Email, profile.Nickname, profile.Avatar
+
+	return item, facade.DB.Drive().Save(&item).Error
+}
+
+// IdentitiesList - 用户绑定的第三方帐号
+func IdentitiesList(uid any) (result []Identities) {
+	facade.DB.Drive().Where("uid = ?", uid).Order("id asc").Find(&result)
+	return result
+}
+
+// IdentitiesUnbind - 解除绑定
+/**
+ * @param uid 用户ID
+ * @param id 绑定ID
+ * @return int 解除的数量
+ */
+func IdentitiesUnbind(uid, id any) int {
+	tx := facade.DB.Drive().Where("uid = ? AND id = ?", uid, id).Delete(&Identities{})
+	return int(tx.RowsAffected)
+}
+
+// IdentitiesRemove - 删除用户的全部绑定（删除用户时）
+func IdentitiesRemove(uid ...any) {
+	facade.DB.Drive().Where("uid IN ?", uid).Delete(&Identities{})
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// permissionsName - 权限格式：控制器:方法，支持通配符，如：users:delete、users:*、*
+var permissionsName = regexp.MustCompile(`^(\*|[a-z0-9_-]+:(\*|[a-z0-9_-]+))$`)
+
+// Permissions - 角色的权限
+type Permissions struct {
+	Id         int    `gorm:"type:int(32); comment:主键;" json:"id"`
+	RoleId     int    `gorm:"type:int(32); comment:角色ID; index;" json:"role_id"`
+	Name       string `gorm:"size:64; comment:权限，如：users:delete;" json:"name"`
+	CreateTime int64  `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+}
+
+// permissionsCache - 用户权限集的缓存名称
+func permissionsCache(uid any) string {
+	return fmt.Sprintf("rbac[%v]", uid)
+}
+
+// PermissionsNormalize - 权限归一化 - 转小写、去重、排序，丢弃格式不正确的
+/**
+ * @param names 权限 - 字符串数组或逗号分隔的字符串
+ */
+func PermissionsNormalize(names any) (result []string) {
+
+	var items []string
+	switch value := names.(type) {
+	case string:
+		items = strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == '，' || r == '\n' || r == ' '
+		})
+	default:
+		items = cast.ToStringSlice(names)
+	}
+
+	exist := map[string]bool{}
+	for _, item := range items {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if !permissionsName.MatchString(item) || exist[item] {
+			continue
+		}
+		exist[item] = true
+		result = append(result, item)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// PermissionsMatch - 权限集是否包含指定权限
+/**
+ * @param grants 权限集
+ * @param name 权限，如：users:delete
+ * @example：
+ * ok := model.PermissionsMatch([]string{"users:*"}, "users:delete")
+ */
+func PermissionsMatch(grants []string, name string) bool {
+
+	controller, _, _ := strings.Cut(name, ":")
+
+	for _, item := range grants {
+		if item == "*" || item == name || item == controller+":*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PermissionsExpire - 权限集缓存的有效期 - 并发时刷新与查询交错，最多在这段时间内使用旧的权限集
+const PermissionsExpire = 5 * time.Minute
+
+// PermissionsOf - 用户的权限集 - 游客角色 + 用户角色（已登录） + 分配的角色
+/**
+ * @param uid 用户ID - 0 表示未登录
+ * @return []string 开启了缓存时优先从缓存读取
+ */
+func PermissionsOf(uid int) (result []string) {
+
+	cacheName  := permissionsCache(uid)
+	cacheState := cast.ToBool(facade.CacheToml.Get("open"))
+
+	if cacheState {
+		if value, ok := facade.CacheGet[[]string](cacheName); ok {
+			return value
+		}
+	}
+
+	keys := []string{RolesGuest}
+	if uid != 0 {
+		keys = append(keys, RolesUser)
+	}
+
+	// 数据库或角色表不可用（未安装、未迁移） - 使用内置角色的默认权限
+	fallback := func() []string {
+		for _, key := range keys {
+			result = append(result, rolesDefault[key]...)
+		}
+		return PermissionsNormalize(result)
+	}
+
+	if facade.DB == nil || facade.DB.Drive() == nil {
+		return fallback()
+	}
+
+	var roles []int
+	db := facade.DB.Drive()
+	tx := db.Model(&Roles{}).Where("`key` IN ?", keys)
+	if uid != 0 {
+		tx = tx.Or("id IN (?)", db.Model(&UsersRoles{}).Select("role_id").Where("uid = ?", uid))
+	}
+
+	if tx.Pluck("id", &roles).Error != nil || len(roles) == 0 {
+		return fallback()
+	}
+
+	db.Model(&Permissions{}).Where("role_id IN ?", roles).Pluck("name", &result)
+
+	result = PermissionsNormalize(result)
+
+	// 同步写入并设置有效期 - 异步写入可能晚于 PermissionsFlush，导致已收回的权限一直留在缓存中
+	if cacheState {
+		facade.CacheSet(cacheName, result, PermissionsExpire)
+	}
+
+	return result
+}
+
+// PermissionsCheck - 用户是否拥有指定权限
+/**
+ * @param uid 用户ID - 0 表示未登录
+ * @param name 权限，如：users:delete
+ */
+func PermissionsCheck(uid int, name string) bool {
+	return PermissionsMatch(PermissionsOf(uid), strings.ToLower(name))
+}
+
+// PermissionsFlush - 清除权限集缓存
+/**
+ * @param uid （可选）用户ID - 为空时清除全部用户（角色的权限发生变化）
+ */
+func PermissionsFlush(uid ...any) {
+
+	if len(uid) == 0 {
+		facade.Cache.DelPrefix("rbac[")
+		return
+	}
+
+	for _, item := range uid {
+		facade.Cache.Del(permissionsCache(item))
+	}
+}
+
+// PermissionsAdmin - 是否拥有全部权限
+func PermissionsAdmin(uid int) bool {
+	return utils.InArray("*", PermissionsOf(uid))
+}
@@ -0,0 +1,215 @@
+package model
+
+import (
+	"errors"
+	"github.com/spf13/cast"
+	"github.com/unti-io/go-utils/utils"
+	"gorm.io/gorm"
+	"inis/app/facade"
+	"strings"
+)
+
+const (
+	// RolesGuest - 游客 - 所有请求（包括未登录）都拥有的权限
+	RolesGuest = "guest"
+	// RolesUser - 用户 - 所有已登录用户都拥有的权限
+	RolesUser  = "user"
+	// RolesAdmin - 管理员
+	RolesAdmin = "admin"
+)
+
+// RolesBuiltin - 内置角色 - 不能删除，也不能修改标识
+var RolesBuiltin = []any{RolesGuest, RolesUser, RolesAdmin}
+
+// rolesDefault - 内置角色的默认权限 - 初始化时写入，角色表为空或不可用时（未安装、迁移失败）直接使用
+var rolesDefault = map[string][]string{
+	RolesGuest: {"comm:*", "file:rand", "roles:mine"},
+	RolesUser:  {"users:one", "users:all", "users:count", "users:column", "users:save", "users:update", "file:*", "files:*", "sessions:*", "apikeys:*", "proxy:*", "users:2fa-status", "users:2fa-setup", "users:2fa-qrcode", "users:2fa-confirm", "users:2fa-disable", "users:2fa-recovery", "users:identities", "users:unbind"},
+	RolesAdmin: {"*"},
+}
+
+// Roles - 角色 - 直接删除，不使用软删除，避免标识的唯一索引冲突
+type Roles struct {
+	Id          int      `gorm:"type:int(32); comment:主键;" json:"id"`
+	Key         string   `gorm:"size:32; comment:标识; uniqueIndex;" json:"key"`
+	Name        string   `gorm:"size:32; comment:名称;" json:"name"`
+	Remark      string   `gorm:"comment:备注; default:Null;" json:"remark"`
+	Permissions []string `gorm:"-" json:"permissions"`
+	// 以下为公共字段
+	CreateTime int64 `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+	UpdateTime int64 `gorm:"autoUpdateTime; comment:更新时间;" json:"update_time"`
+}
+
+// UsersRoles - 用户和角色的关联
+type UsersRoles struct {
+	Id         int   `gorm:"type:int(32); comment:主键;" json:"id"`
+	Uid        int   `gorm:"type:int(32); comment:用户ID; index:idx_users_roles,unique;" json:"uid"`
+	RoleId     int   `gorm:"type:int(32); comment:角色ID; index:idx_users_roles,unique; index;" json:"role_id"`
+	CreateTime int64 `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+}
+
+// InitRoles - 初始化Roles、Permissions、UsersRoles表 - 角色表为空时写入内置角色
+func InitRoles() {
+	// 迁移表
+	err := facade.DB.Drive().AutoMigrate(&Roles{}, &Permissions{}, &UsersRoles{})
+	if err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Roles表迁移失败")
+		return
+	}
+
+	var count int64
+	facade.DB.Drive().Model(&Roles{}).Count(&count)
+	if count != 0 {
+		return
+	}
+
+	names := map[string]string{RolesGuest: "游客", RolesUser: "用户", RolesAdmin: "管理员"}
+
+	for _, key := range []string{RolesGuest, RolesUser, RolesAdmin} {
+		item := Roles{Key: key, Name: names[key], Permissions: rolesDefault[key]}
+		if err := RolesSave(&item); err != nil {
+			facade.Log.Error(map[string]any{"error": err, "key": key}, "内置角色初始化失败")
+			return
+		}
+		// 升级前已有的站点 - 第一个用户（安装时创建的）成为管理员
+		if key == RolesAdmin {
+			var uid int
+			facade.DB.Drive().Model(&Users{}).Select("min(id)").Scan(&uid)
+			if uid != 0 {
+				RolesAssign(uid, []int{item.Id})
+			}
+		}
+	}
+}
+
+// RolesSave - 创建或更新角色，并替换角色的全部权限
+/**
+ * @param item 角色 - Id 为0时创建
+ * @return error
+ */
+func RolesSave(item *Roles) (err error) {
+
+	item.Key = strings.ToLower(strings.TrimSpace(item.Key))
+	if utils.Is.Empty(item.Key) {
+		return errors.New("角色标识不能为空！")
+	}
+
+	err = facade.DB.Drive().Transaction(func(tx *gorm.DB) error {
+
+		var exist int64
+		tx.Model(&Roles{}).Where("`key` = ? AND id != ?", item.Key, item.Id).Count(&exist)
+		if exist != 0 {
+			return errors.New("角色标识已存在！")
+		}
+
+		if err := tx.Save(item).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("role_id = ?", item.Id).Delete(&Permissions{}).Error; err != nil {
+			return err
+		}
+
+		names := PermissionsNormalize(item.Permissions)
+		for _, name := range names {
+			if err := tx.Create(&Permissions{RoleId: item.Id, Name: name}).Error; err != nil {
+				return err
+			}
+		}
+		item.Permissions = names
+
+		return nil
+	})
+
+	PermissionsFlush()
+
+	return err
+}
+
+// RolesDelete - 删除角色及其权限和用户关联 - 内置角色不能删除
+func RolesDelete(ids []int) (err error) {
+
+	var keys []string
+	facade.DB.Drive().Model(&Roles{}).Where("id IN ?", ids).Pluck("key", &keys)
+	for _, key := range keys {
+		if utils.In.Array(key, RolesBuiltin) {
+			return errors.New("内置角色不能删除！")
+		}
+	}
+
+	err = facade.DB.Drive().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id IN ?", ids).Delete(&Permissions{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id IN ?", ids).Delete(&UsersRoles{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&Roles{}).Error
+	})
+
+	PermissionsFlush()
+
+	return err
+}
+
+// RolesAssign - 替换用户的全部角色
+/**
+ * @param uid 用户ID
+ * @param ids 角色ID - 为空时移除全部角色
+ * @return error
+ */
+func RolesAssign(uid int, ids []int) (err error) {
+
+	err = facade.DB.Drive().Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Where("uid = ?", uid).Delete(&UsersRoles{}).Error; err != nil {
+			return err
+		}
+
+		var exist []int
+		tx.Model(&Roles{}).Where("id IN ?", ids).Pluck("id", &exist)
+
+		for _, id := range exist {
+			if err := tx.Create(&UsersRoles{Uid: uid, RoleId: id}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	PermissionsFlush(uid)
+
+	return err
+}
+
+// RolesList - 角色列表（带权限）
+/**
+ * @param ids 角色ID - 为空时返回全部
+ */
+func RolesList(ids ...int) (result []Roles) {
+
+	db := facade.DB.Drive().Model(&Roles{}).Order("id asc")
+	if len(ids) != 0 {
+		db = db.Where("id IN ?", ids)
+	}
+	db.Find(&result)
+
+	for key := range result {
+		facade.DB.Drive().Model(&Permissions{}).Where("role_id = ?", result[key].Id).Order("name asc").Pluck("name", &result[key].Permissions)
+	}
+
+	return result
+}
+
+// RolesOfUser - 用户的角色 - 不含内置的 guest、user
+func RolesOfUser(uid any) (result []Roles) {
+
+	var ids []int
+	facade.DB.Drive().Model(&UsersRoles{}).Where("uid = ?", cast.ToInt(uid)).Pluck("role_id", &ids)
+	if len(ids) == 0 {
+		return make([]Roles, 0)
+	}
+
+	return RolesList(ids...)
+}
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/cast"
+	"gorm.io/plugin/soft_delete"
+	"inis/app/facade"
+	"time"
+)
+
+// Sessions - 登录会话 - 每次登录一条，访问令牌的 jti 和刷新令牌的 family 都是会话的 Jti
+type Sessions struct {
+	Id         int    `gorm:"type:int(32); comment:主键;" json:"id"`
+	Uid        int    `gorm:"type:int(32); comment:用户ID; default:0; index;" json:"uid"`
+	Jti        string `gorm:"size:36; comment:会话ID; uniqueIndex;" json:"jti"`
+	Ip         string `gorm:"size:64; comment:最后访问的IP; default:Null;" json:"ip"`
+	Agent      string `gorm:"size:512; comment:User-Agent; default:Null;" json:"agent"`
+	LastTime   int64  `gorm:"comment:最后访问时间; default:0;" json:"last_time"`
+	ExpireTime int64  `gorm:"comment:过期时间 - 随刷新令牌延长; default:0;" json:"expire_time"`
+	RevokeTime int64  `gorm:"comment:吊销时间 - 不为0表示已退出; default:0;" json:"revoke_time"`
+	// 以下为公共字段
+	CreateTime int64                 `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+	UpdateTime int64                 `gorm:"autoUpdateTime; comment:更新时间;" json:"update_time"`
+	DeleteTime soft_delete.DeletedAt `gorm:"comment:删除时间; default:0;" json:"delete_time"`
+}
+
+// SessionsTouchInterval - 最后访问时间的更新间隔（秒） - 避免每个请求都写数据库
+const SessionsTouchInterval = 60
+
+// InitSessions - 初始化Sessions表
+func InitSessions() {
+	// 迁移表
+	err := facade.DB.Drive().AutoMigrate(&Sessions{})
+	if err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Sessions表迁移失败")
+		return
+	}
+}
+
+// sessionsCache - 会话的缓存名称
+func sessionsCache(jti string) string {
+	return fmt.Sprintf("session[%s]", jti)
+}
+
+// sessionsRevoked - 吊销标记的缓存名称
+func sessionsRevoked(jti string) string {
+	return fmt.Sprintf("session[%s][revoked]", jti)
+}
+
+// sessionsShared - 是否缓存会话 - 只在开启了缓存且为 Redis 时缓存
+/**
+ * 内存、文件缓存只在本实例有效，多实例时其他实例吊销会话后无法清除本实例的缓存，这时每次都查数据库
+ */
+func sessionsShared() bool {
+	if !cast.ToBool(facade.CacheToml.Get("open")) {
+		return false
+	}
+	_, ok := facade.Cache.(*facade.RedisCacheStruct)
+	return ok
+}
+
+// SessionsCreate - 登录时创建会话
+/**
+ * @param uid 用户ID
+ * @param ip 客户端IP
+ * @param agent 客户端 User-Agent
+ * @param expire 有效期 - 与刷新令牌相同
+ * @return jti 会话ID
+ */
+func SessionsCreate(uid int, ip, agent string, expire time.Duration) (jti string, err error) {
+
+	if len(agent) > 512 {
+		agent = agent[:512]
+	}
+
+	jti = uuid.New().String()
+
+	tx := facade.DB.Drive().Create(&Sessions{
+		Uid:        uid,
+		Jti:        jti,
+		Ip:         ip,
+		Agent:      agent,
+		LastTime:   time.Now().Unix(),
+		ExpireTime: time.Now().Add(expire).Unix(),
+	})
+
+	return jti, tx.Error
+}
+
+// SessionsFind - 查找会话 - 缓存为 Redis 时优先从缓存读取，吊销标记优先于缓存的会话
+func SessionsFind(jti string) (item Sessions, ok bool) {
+
+	cacheName := sessionsCache(jti)
+	shared    := sessionsShared()
+
+	if shared {
+		if item, ok = facade.CacheGet[Sessions](cacheName); ok {
+			// 查询数据库和写入缓存之间被吊销时，缓存的是吊销前的会话
+			if revoke, exist := facade.CacheGet[int64](sessionsRevoked(jti)); exist && item.RevokeTime == 0 {
+				item.RevokeTime = revoke
+			}
+			return item, item.Id != 0
+		}
+	}
+
+	facade.DB.Drive().Where("jti = ?", jti).Limit(1).Find(&item)
+
+	if shared && item.Id != 0 {
+		facade.CacheSet(cacheName, item, time.Duration(facade.JwtAccessExpire())*time.Second)
+	}
+
+	return item, item.Id != 0
+}
+
+// Active - 会话是否有效
+func (this *Sessions) Active() bool {
+	return this.Id != 0 && this.RevokeTime == 0 && this.ExpireTime >= time.Now().Unix()
+}
+
+// SessionsTouch - 更新最后访问时间和IP - 距上次更新不足 SessionsTouchInterval 时跳过
+/**
+ * @param item 会话
+ * @param ip 客户端IP
+ * @param expire 新的过期时间 - 为0时不修改
+ */
+func SessionsTouch(item Sessions, ip string, expire int64) {
+
+	now := time.Now().Unix()
+	if expire == 0 && now-item.LastTime < SessionsTouchInterval && item.Ip == ip {
+		return
+	}
+
+	update := map[string]any{"last_time": now, "ip": ip}
+	if expire != 0 {
+		update["expire_time"] = expire
+	}
+
+	facade.DB.Drive().Model(&Sessions{}).Where("id = ?", item.Id).UpdateColumns(update)
+	facade.Cache.Del(sessionsCache(item.Jti))
+}
+
+// SessionsRevoke - 吊销会话 - 访问令牌立即失效，刷新令牌一并吊销
+func SessionsRevoke(jti string) {
+
+	if jti == "" {
+		return
+	}
+
+	now := time.Now().Unix()
+
+	facade.DB.Drive().Model(&Sessions{}).Where("jti = ? AND revoke_time = 0", jti).UpdateColumn("revoke_time", now)
+	TokensRevokeFamily(jti)
+
+	// 吊销标记比会话缓存活得久，并发读取重新写入的旧缓存也会被判定为已吊销
+	if sessionsShared() {
+		facade.CacheSet(sessionsRevoked(jti), now, 2*time.Duration(facade.JwtAccessExpire())*time.Second)
+	}
+
+	facade.Cache.Del(sessionsCache(jti))
+}
+
+// SessionsRevokeUser - 吊销用户的全部会话（修改密码、强制下线等）
+/**
+ * @param uid 用户ID
+ * @param except 保留的会话 - 如当前会话，为空时全部吊销
+ * @return int 吊销的会话数
+ */
+func SessionsRevokeUser(uid any, except string) int {
+
+	var jtis []string
+	facade.DB.Drive().Model(&Sessions{}).Where("uid = ? AND revoke_time = 0 AND jti != ?", uid, except).Pluck("jti", &jtis)
+
+	for _, jti := range jtis {
+		SessionsRevoke(jti)
+	}
+
+	// 没有会话的刷新令牌（升级前签发的）
+	if except == "" {
+		TokensRevokeUser(uid)
+	}
+
+	return len(jtis)
+}
+
+// SessionsList - 用户的有效会话 - 按最后访问时间倒序
+func SessionsList(uid any) (result []Sessions) {
+	facade.DB.Drive().Where("uid = ? AND revoke_time = 0 AND expire_time >= ?", uid, time.Now().Unix()).Order("last_time desc").Find(&result)
+	return result
+}
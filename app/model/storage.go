@@ -0,0 +1,93 @@
+package model
+
+import (
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+	"inis/app/facade"
+)
+
+// storageColumns - 保存了文件地址的字段 - 迁移存储后需要改写
+var storageColumns = []struct {
+	Table  string
+	Model  any
+	Column string
+}{
+	{Table: "users", Model: &Users{}, Column: "avatar"},
+	{Table: "files", Model: &Files{}, Column: "url"},
+}
+
+// StoragePlaceholder - 驱动对应的域名模板变量
+/**
+ * @param mode 驱动模式
+ * @return string
+ * @example：
+ * model.StoragePlaceholder("oss") // {{oss}}
+ */
+func StoragePlaceholder(mode string) string {
+	if mode == facade.StorageModeLocal {
+		return "{{localhost}}"
+	}
+	return "{{" + mode + "}}"
+}
+
+// StorageRewrite - 存储迁移后改写数据库中的文件地址，并更新 Files 表的存储驱动
+/**
+ * @param from 源驱动
+ * @param to 目标驱动
+ * @return result 表名.字段 => 改写的行数
+ * @example：
+ * result, err := model.StorageRewrite("oss", "s3")
+ */
+func StorageRewrite(from, to string) (result map[string]int64, err error) {
+
+	result = make(map[string]int64)
+
+	// 目标为本地存储时使用配置的域名，未配置则为相对路径
+	prefix := StoragePlaceholder(to)
+	if to == facade.StorageModeLocal {
+		prefix = cast.ToString(facade.StorageToml.Get("local.domain"))
+	}
+
+	// 源地址的几种形式
+	sources := []string{StoragePlaceholder(from)}
+	if from == facade.StorageModeLocal {
+		if domain := cast.ToString(facade.StorageToml.Get("local.domain")); domain != "" {
+			sources = append(sources, domain)
+		}
+	}
+
+	err = facade.DB.Drive().Transaction(func(tx *gorm.DB) error {
+
+		for _, item := range storageColumns {
+
+			for _, source := range sources {
+				db := tx.Unscoped().Model(item.Model).Where(item.Column+" LIKE ?", source+"/storage/%").
+					UpdateColumn(item.Column, gorm.Expr("CONCAT(?, SUBSTRING("+item.Column+", ?))", prefix, len(source)+1))
+				if db.Error != nil {
+					return db.Error
+				}
+				result[item.Table+"."+item.Column] += db.RowsAffected
+			}
+
+			// 本地存储未配置域名时保存的是相对路径
+			if from == facade.StorageModeLocal {
+				db := tx.Unscoped().Model(item.Model).Where(item.Column+" LIKE ?", "/storage/%").
+					UpdateColumn(item.Column, gorm.Expr("CONCAT(?, "+item.Column+")", prefix))
+				if db.Error != nil {
+					return db.Error
+				}
+				result[item.Table+"."+item.Column] += db.RowsAffected
+			}
+		}
+
+		db := tx.Unscoped().Model(&Files{}).Where("driver = ?", from).UpdateColumn("driver", to)
+		if db.Error != nil {
+			return db.Error
+		}
+		result["files.driver"] = db.RowsAffected
+
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/plugin/soft_delete"
+	"inis/app/facade"
+	"time"
+)
+
+// ErrTokenReused - 已轮换的刷新令牌被再次使用（可能已泄露），同一登录链路的令牌已全部吊销
+var ErrTokenReused = errors.New("刷新令牌已被使用，请重新登录！")
+
+// ErrTokenInvalid - 刷新令牌不存在、已过期或已吊销
+var ErrTokenInvalid = errors.New("刷新令牌无效或已过期，请重新登录！")
+
+// Tokens - 刷新令牌 - 只保存 sha256，每次使用后轮换
+type Tokens struct {
+	Id         int    `gorm:"type:int(32); comment:主键;" json:"id"`
+	Uid        int    `gorm:"type:int(32); comment:用户ID; default:0; index;" json:"uid"`
+	Family     string `gorm:"size:36; comment:登录链路 - 即会话的 Jti; index;" json:"family"`
+	Hash       string `gorm:"size:64; comment:令牌哈希; uniqueIndex;" json:"-"`
+	Ip         string `gorm:"size:64; comment:IP; default:Null;" json:"ip"`
+	Agent      string `gorm:"size:512; comment:User-Agent; default:Null;" json:"agent"`
+	ExpireTime int64  `gorm:"comment:过期时间; default:0;" json:"expire_time"`
+	UsedTime   int64  `gorm:"comment:轮换时间 - 不为0表示已使用; default:0;" json:"used_time"`
+	RevokeTime int64  `gorm:"comment:吊销时间 - 不为0表示已吊销; default:0;" json:"revoke_time"`
+	// 以下为公共字段
+	CreateTime int64                 `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+	UpdateTime int64                 `gorm:"autoUpdateTime; comment:更新时间;" json:"update_time"`
+	DeleteTime soft_delete.DeletedAt `gorm:"comment:删除时间; default:0;" json:"delete_time"`
+}
+
+// InitTokens - 初始化Tokens表
+func InitTokens() {
+	// 迁移表
+	err := facade.DB.Drive().AutoMigrate(&Tokens{})
+	if err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Tokens表迁移失败")
+		return
+	}
+}
+
+// TokensHash - 刷新令牌的哈希
+func TokensHash(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+// TokensIssue - 签发刷新令牌
+/**
+ * @param uid 用户ID
+ * @param family 登录链路 - 即会话的 Jti，为空时开始新的链路
+ * @param ip 客户端IP
+ * @param agent 客户端 User-Agent
+ * @param expire 有效期
+ * @return token 明文令牌，只返回给客户端一次
+ */
+func TokensIssue(uid int, family, ip, agent string, expire time.Duration) (token string, err error) {
+
+	random := make([]byte, 32)
+	if _, err = rand.Read(random); err != nil {
+		return "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(random)
+
+	if family == "" {
+		family = uuid.New().String()
+	}
+
+	if len(agent) > 512 {
+		agent = agent[:512]
+	}
+
+	tx := facade.DB.Drive().Create(&Tokens{
+		Uid:        uid,
+		Family:     family,
+		Hash:       TokensHash(token),
+		Ip:         ip,
+		Agent:      agent,
+		ExpireTime: time.Now().Add(expire).Unix(),
+	})
+
+	return token, tx.Error
+}
+
+// TokensRotate - 使用刷新令牌 - 标记为已使用并在同一链路中签发新的令牌
+/**
+ * @param token 明文令牌
+ * @param ip 客户端IP
+ * @param agent 客户端 User-Agent
+ * @param expire 新令牌的有效期
+ * @return item 被使用的令牌，next 新的明文令牌
+ */
+func TokensRotate(token, ip, agent string, expire time.Duration) (item Tokens, next string, err error) {
+
+	db := facade.DB.Drive()
+
+	if tx := db.Where("hash = ?", TokensHash(token)).Limit(1).Find(&item); tx.Error != nil || item.Id == 0 {
+		return item, "", ErrTokenInvalid
+	}
+
+	if item.RevokeTime != 0 || item.ExpireTime < time.Now().Unix() {
+		return item, "", ErrTokenInvalid
+	}
+
+	// 条件更新 - 并发请求中只有一个能成功，其余视为重复使用
+	tx := db.Model(&Tokens{}).Where("id = ? AND used_time = 0", item.Id).UpdateColumn("used_time", time.Now().Unix())
+	if tx.Error != nil {
+		return item, "", tx.Error
+	}
+
+	// 重复使用 - 吊销整个会话，持有旧令牌的一方（可能是攻击者）和正常用户都需要重新登录
+	if tx.RowsAffected == 0 {
+		SessionsRevoke(item.Family)
+		return item, "", ErrTokenReused
+	}
+
+	next, err = TokensIssue(item.Uid, item.Family, ip, agent, expire)
+
+	return item, next, err
+}
+
+// TokensRevoke - 吊销刷新令牌所在的会话（退出登录）
+func TokensRevoke(token string) {
+
+	item := Tokens{}
+	if tx := facade.DB.Drive().Where("hash = ?", TokensHash(token)).Limit(1).Find(&item); tx.Error != nil || item.Id == 0 {
+		return
+	}
+
+	SessionsRevoke(item.Family)
+}
+
+// TokensRevokeFamily - 吊销链路中的全部令牌
+func TokensRevokeFamily(family string) {
+	// 没有会话的旧令牌 family 为空，不能按空值批量吊销
+	if family == "" {
+		return
+	}
+	facade.DB.Drive().Model(&Tokens{}).Where("family = ? AND revoke_time = 0", family).UpdateColumn("revoke_time", time.Now().Unix())
+}
+
+// TokensRevokeUser - 吊销用户的全部刷新令牌（修改密码等）
+func TokensRevokeUser(uid any) {
+	facade.DB.Drive().Model(&Tokens{}).Where("uid = ? AND revoke_time = 0", uid).UpdateColumn("revoke_time", time.Now().Unix())
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"fmt"
+	"github.com/unti-io/go-utils/utils"
+	"inis/app/facade"
+	"strings"
+	"time"
+)
+
+// TotpsRecoveryCount - 每次生成的恢复码数量
+const TotpsRecoveryCount = 10
+
+// TotpsExpire - 登录挑战和待确认密钥的有效期
+const TotpsExpire = 5 * time.Minute
+
+// Totps - 两步验证 - 每个用户一条，确认后才写入
+type Totps struct {
+	Id         int    `gorm:"type:int(32); comment:主键;" json:"id"`
+	Uid        int    `gorm:"type:int(32); comment:用户ID; uniqueIndex;" json:"uid"`
+	Secret     string `gorm:"size:255; comment:TOTP密钥 - 加密存储; serializer:encrypt;" json:"-"`
+	Recovery   string `gorm:"type:text; comment:恢复码哈希 - 逗号分隔，使用后删除;" json:"-"`
+	LastStep   int64  `gorm:"comment:最后使用的时间步 - 防止验证码重复使用; default:0;" json:"-"`
+	CreateTime int64  `gorm:"autoCreateTime; comment:创建时间;" json:"create_time"`
+	UpdateTime int64  `gorm:"autoUpdateTime; comment:更新时间;" json:"update_time"`
+}
+
+// InitTotps - 初始化Totps表
+func InitTotps() {
+	// 迁移表
+	err := facade.DB.Drive().AutoMigrate(&Totps{})
+	if err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Totps表迁移失败")
+		return
+	}
+	// 加密启用前的明文数据
+	if _, err = EncryptMigrate(&Totps{}, false); err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Totps表加密字段迁移失败")
+	}
+}
+
+// totpsHash - 恢复码的哈希 - 不区分大小写和分隔符
+func totpsHash(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(code)))
+}
+
+// TotpsFind - 用户的两步验证 - 未开启时 ok 为 false
+func TotpsFind(uid any) (item Totps, ok bool) {
+	facade.DB.Drive().Where("uid = ?", uid).Limit(1).Find(&item)
+	return item, item.Id != 0
+}
+
+// TotpsEnabled - 用户是否开启了两步验证
+func TotpsEnabled(uid any) bool {
+	_, ok := TotpsFind(uid)
+	return ok
+}
+
+// TotpsEnable - 开启两步验证 - 已开启时替换密钥
+/**
+ * @param uid 用户ID
+ * @param secret 已确认的密钥
+ * @param step 确认时使用的时间步
+ * @return codes 恢复码明文，只返回给用户一次
+ */
+func TotpsEnable(uid int, secret string, step int64) (codes []string, err error) {
+
+	codes, hashes, err := totpsRecovery()
+	if err != nil {
+		return nil, err
+	}
+
+	item, _ := TotpsFind(uid)
+	item.Uid, item.Secret, item.Recovery, item.LastStep = uid, secret, strings.Join(hashes, ","), step
+
+	return codes, facade.DB.Drive().Save(&item).Error
+}
+
+// TotpsDisable - 关闭两步验证
+func TotpsDisable(uid any) error {
+	return facade.DB.Drive().Where("uid = ?", uid).Delete(&Totps{}).Error
+}
+
+// TotpsRecovery - 重新生成恢复码 - 旧的恢复码全部失效
+func TotpsRecovery(uid any) (codes []string, err error) {
+
+	codes, hashes, err := totpsRecovery()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := facade.DB.Drive().Model(&Totps{}).Where("uid = ?", uid).UpdateColumn("recovery", strings.Join(hashes, ","))
+
+	return codes, tx.Error
+}
+
+// totpsRecovery - 生成恢复码 - 10位 base32，按 5-5 分组
+func totpsRecovery() (codes, hashes []string, err error) {
+
+	for i := 0; i < TotpsRecoveryCount; i++ {
+
+		random := make([]byte, 10)
+		if _, err = rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+
+		code := base32.StdEncoding.EncodeToString(random)[:10]
+		codes  = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, totpsHash(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// Remaining - 剩余的恢复码数量
+func (this *Totps) Remaining() int {
+	if utils.Is.Empty(this.Recovery) {
+		return 0
+	}
+	return len(strings.Split(this.Recovery, ","))
+}
+
+// Verify - 校验验证码或恢复码 - 验证码的时间步必须大于上次使用的，恢复码使用后删除
+/**
+ * @param code 6位验证码或恢复码
+ * @return recovery 是否使用了恢复码，ok 是否通过
+ */
+func (this *Totps) Verify(code string) (recovery, ok bool) {
+
+	db := facade.DB.Drive()
+
+	if step, pass := facade.Totp.Verify(this.Secret, code); pass {
+		// 条件更新 - 并发提交同一验证码时只有一个能成功
+		tx := db.Model(&Totps{}).Where("id = ? AND last_step < ?", this.Id, step).UpdateColumn("last_step", step)
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			return false, false
+		}
+		this.LastStep = step
+		return false, true
+	}
+
+	hash := totpsHash(code)
+	list := strings.Split(this.Recovery, ",")
+
+	for key, item := range list {
+		if item == "" || subtle.ConstantTimeCompare([]byte(item), []byte(hash)) != 1 {
+			continue
+		}
+		next := strings.Join(append(append([]string{}, list[:key]...), list[key+1:]...), ",")
+		// 条件更新 - 恢复码只能使用一次
+		tx := db.Model(&Totps{}).Where("id = ? AND recovery = ?", this.Id, this.Recovery).UpdateColumn("recovery", next)
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			return true, false
+		}
+		this.Recovery = next
+		return true, true
+	}
+
+	return false, false
+}
@@ -17,13 +17,15 @@ type Users struct {
 	Password    string `gorm:"comment:密码;" json:"password"`
 	Nickname    string `gorm:"size:32; comment:昵称;" json:"nickname"`
 	Email       string `gorm:"size:128; comment:邮箱;" json:"email"`
-	Phone       string `gorm:"size:32; comment:手机号;" json:"phone"`
+	Phone       string `gorm:"size:255; comment:手机号 - 加密存储; serializer:encrypt;" json:"phone"`
+	PhoneIndex  string `gorm:"size:64; comment:手机号的盲索引 - 用于查询; index;" json:"-" blind:"phone"`
 	Avatar      string `gorm:"comment:头像; default:Null;" json:"avatar"`
 	Description string `gorm:"comment:描述; default:Null;" json:"description"`
 	Title       string `gorm:"comment:头衔; default:Null;" json:"title"`
 	Exp  		int    `gorm:"type:int(32); comment:经验值; default:0;" json:"exp"`
 	Pages       string `gorm:"comment:页面权限; default:Null;" json:"pages"`
 	Source      string `gorm:"size:32; default:'default'; comment:注册来源;" json:"source"`
+	Stamp       string `gorm:"size:32; comment:安全戳 - 修改密码时更换，令牌中的安全戳与之不一致时失效;" json:"stamp"`
 	Remark      string `gorm:"comment:备注; default:Null;" json:"remark"`
 	// 以下为公共字段
 	Json       any                   `gorm:"type:longtext; comment:用于存储JSON数据;" json:"json"`
@@ -43,6 +45,94 @@ func InitUsers() {
 		facade.Log.Error(map[string]any{"error": err}, "Users表迁移失败")
 		return
 	}
+	// 加密启用前的明文数据
+	if _, err = EncryptMigrate(&Users{}, false); err != nil {
+		facade.Log.Error(map[string]any{"error": err}, "Users表加密字段迁移失败")
+	}
+}
+
+// UsersSocial - 按帐号、邮箱、手机号查询的条件 - 手机号加密存储，使用盲索引查询
+/**
+ * @param column 字段
+ * @param value 值
+ * @return []any 条件，如：[]any{"phone_index", "=", "..."}
+ */
+func UsersSocial(column string, value any) []any {
+	if column == "phone" {
+		return []any{"phone_index", "=", facade.Aead.BlindIndex(value, "users.phone")}
+	}
+	return []any{column, "=", value}
+}
+
+// UsersStampNew - 生成新的安全戳
+func UsersStampNew() string {
+	return utils.Rand.String(32, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+// UsersStamp - 用户的安全戳 - 升级前的用户为空，首次签发令牌时生成
+func UsersStamp(uid int) string {
+
+	var stamps []string
+	facade.DB.Drive().Model(&Users{}).Where("id = ?", uid).Limit(1).Pluck("stamp", &stamps)
+
+	if len(stamps) == 1 && !utils.Is.Empty(stamps[0]) {
+		return stamps[0]
+	}
+
+	stamp := UsersStampNew()
+	tx := facade.DB.Drive().Model(&Users{}).Where("id = ? AND (stamp = '' OR stamp IS NULL)", uid).UpdateColumn("stamp", stamp)
+
+	// 并发时以先写入的为准
+	if tx.RowsAffected == 0 {
+		stamps = nil
+		facade.DB.Drive().Model(&Users{}).Where("id = ?", uid).Limit(1).Pluck("stamp", &stamps)
+		if len(stamps) == 1 {
+			stamp = stamps[0]
+		}
+	}
+
+	facade.Cache.Del(fmt.Sprintf("user[%v]", uid))
+
+	return stamp
+}
+
+// BeforeCreate - 创建前的Hook
+func (this *Users) BeforeCreate(tx *gorm.DB) (err error) {
+	if utils.Is.Empty(this.Stamp) {
+		this.Stamp = UsersStampNew()
+	}
+	return
+}
+
+// UsersPrivate - 非公开字段 - 只有本人和管理员可以查看
+var UsersPrivate = []string{"email", "phone", "pages", "remark", "login_time"}
+
+// UsersPublic - 用户资料的公开部分 - 返回去除了非公开字段的副本
+func UsersPublic(item map[string]any) (result map[string]any) {
+	result = make(map[string]any, len(item))
+	for key, val := range item {
+		if !utils.InArray(key, UsersPrivate) {
+			result[key] = val
+		}
+	}
+	return result
+}
+
+// UsersDecrypt - 解密直接查询（不经过模型）得到的手机号
+func UsersDecrypt(rows []map[string]any) {
+	for _, row := range rows {
+		if !facade.Aead.Encrypted(row["phone"]) {
+			continue
+		}
+		if plain, err := facade.Aead.Decrypt(row["phone"], "users.phone"); err == nil {
+			row["phone"] = plain
+		}
+	}
+}
+
+// BeforeSave - 保存前的Hook（包括 create update）
+func (this *Users) BeforeSave(tx *gorm.DB) (err error) {
+	return encryptSave(tx, this)
 }
 
 // AfterFind - 查询后的钩子
@@ -110,8 +200,8 @@ func (this *Users) AfterSave(tx *gorm.DB) (err error) {
 	}
 
 	// 手机号 唯一处理
-	if !utils.Is.Empty(this.Phone) {
-		exist := facade.DB.Model(&Users{}).Where("id", "!=", this.Id).Where("phone", this.Phone).Exist()
+	if !utils.Is.Empty(this.PhoneIndex) {
+		exist := facade.DB.Model(&Users{}).Where("id", "!=", this.Id).Where("phone_index", this.PhoneIndex).Exist()
 		if exist {
 			return errors.New("手机号已存在！")
 		}
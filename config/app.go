@@ -1,19 +1,18 @@
 package config
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cast"
 	"github.com/unti-io/go-utils/utils"
-	"image"
 	"inis/app/facade"
 	"inis/app/middleware"
 	"io"
 	"net/http"
-	"regexp"
+	"os"
 	"strings"
+	"time"
 )
 
 // Gin - gin引擎
@@ -65,6 +64,15 @@ func InitApp() {
 	}
 
 	Gin = gin.Default()
+	// 受信任的反向代理 - 默认信任全部代理时 X-Forwarded-For 可以被伪造，登录防暴力破解的IP计数就失效了
+	proxies := []string{"127.0.0.1", "::1"}
+	if AppToml.Viper.IsSet("app.trusted_proxies") {
+		proxies = cast.ToStringSlice(AppToml.Viper.Get("app.trusted_proxies"))
+	}
+	if err := Gin.SetTrustedProxies(proxies); err != nil {
+		fmt.Println("受信任的代理配置错误", err)
+		_ = Gin.SetTrustedProxies([]string{"127.0.0.1", "::1"})
+	}
 	// 处理资源路由 和 404路由
 	notRoute(Gin)
 	// 打印版本信息
@@ -119,157 +127,140 @@ func notRoute(Gin *gin.Engine) {
 			}
 		}()
 
-		ctx.Status(200)
+		// 获取请求的路径 - 规范化后再做签名校验和挂载匹配
+		path := facade.StaticClean(ctx.Request.URL.Path)
+
+		// 存储文件 - 校验签名地址
+		if strings.HasPrefix(path, "/storage/") {
+			if err := facade.LocalStorage.Verify(path, ctx.Request.URL.Query()); err != nil {
+				ctx.JSON(200, gin.H{"code": 403, "msg": err.Error(), "data": nil})
+				return
+			}
+			// 签名地址只允许浏览器缓存到过期为止
+			if ctx.Query("sig") != "" {
+				ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", cast.ToInt64(ctx.Query("expires"))-time.Now().Unix()))
+			}
+		}
+		// 挂载点 - 接口路由不参与
+		mount, name, ok := facade.StaticMount(ctx.Request.Host, path)
+		if !ok {
+			ctx.JSON(200, gin.H{"code": 400, "msg": "路由未定义！", "data": nil})
+			return
+		}
 
-		// 获取请求的路径
-		path := ctx.Request.URL.Path
 		// 页面资源
 		page := []any{"/", "/index.htm", "/index.html", "/index.php", "/index.jsp"}
-		imgs := []any{"jpg", "jpeg", "png", "gif", "tif", "tiff", "bmp"}
+		imgs := []any{"jpg", "jpeg", "png", "gif", "tif", "tiff", "bmp", "webp"}
 
-		// path 以 / 分隔，取最后一个到末尾
-		prefix := path[:strings.LastIndex(path, "/")]
+		// name 以 / 分隔，取最后一个到末尾
+		prefix := name[:strings.LastIndex(name, "/")]
 		// 文件名
-		fileName := path[strings.LastIndex(path, "/"):]
+		fileName := name[strings.LastIndex(name, "/"):]
 		// 文件后缀 - 转小写
 		ext := strings.ToLower(fileName[strings.LastIndex(fileName, ".")+1:])
 
-		// 判断文件是否存在
-		IsExist := func(path string) (check bool) {
-			// 判断 path 是否以 public 开头
-			if !strings.HasPrefix(path, "public") {
-				path = "public/" + path
-			}
-			exist := utils.File().Exist(path)
-			if !exist {
+		// 输出错误信息
+		Error := func(err error) {
+			switch {
+			case errors.Is(err, facade.ErrStaticForbidden):
+				ctx.JSON(200, gin.H{"code": 403, "msg": err.Error(), "data": nil})
+			case os.IsNotExist(err):
 				ctx.JSON(200, gin.H{"code": 400, "msg": "资源不存在！", "data": nil})
+			default:
+				ctx.JSON(200, gin.H{"code": 400, "msg": "文件读取失败！", "data": err.Error()})
+			}
+		}
+		// 输出文件 - 不存在时尝试 SPA 回退
+		Serve := func(name string) {
+			err := mount.Serve(ctx.Writer, ctx.Request, name)
+			if err == nil {
+				return
+			}
+			if os.IsNotExist(err) && mount.SPA(ctx.Writer, ctx.Request, name) {
+				return
 			}
-			return exist
+			Error(err)
 		}
 		// 输出错误图片
 		WriteByte := func(path string) {
-			_, err := ctx.Writer.Write(utils.File().Byte("public/assets/images/gif/" + path).Byte)
-			if err != nil {
-				ctx.JSON(200, gin.H{"code": 400, "msg": "资源不存在！", "data": nil})
+			ctx.Header("Cache-Control", "no-store")
+			if err := facade.Static.Serve(ctx.Writer, ctx.Request, "/assets/images/gif/"+path); err != nil {
+				Error(err)
 			}
 		}
 
 		switch {
 		// 页面文件
 		case utils.In.Array(fileName, page):
-			if check := IsExist("public/" + prefix + "/index.html"); check {
-				ctx.Header("Content-Type", "text/html; charset=utf-8")
-				_, err := ctx.Writer.Write(utils.File().Byte("public" + prefix + "/index.html").Byte)
-				if err != nil {
-					ctx.JSON(200, gin.H{"code": 400, "msg": "资源不存在！", "data": nil})
-					break
-				}
-			}
+			Serve(prefix + "/index.html")
 		// 图片文件 - 条件压缩处理
 		case utils.In.Array(ext, imgs):
 
-			// 设置文件类型
-			ctx.Header("Content-Type", utils.Mime.Type(ext)+"; charset=utf-8")
-			exist := utils.File().Exist("public" + path)
-			if !exist {
+			stat, err := mount.Stat(name)
+			if err != nil {
 				WriteByte("404.gif")
 				break
 			}
 
-			// 正则表达式，匹配图片尺寸
-			reg := regexp.MustCompile(`^(\d+)\D+(\d+)$`)
-			// 从 query 的 size 中获取图片尺寸
-			match := reg.FindStringSubmatch(ctx.Query("size"))
-
-			if match != nil {
-
-				width := cast.ToInt(match[1])
-				height := cast.ToInt(match[2])
-
-				// 图片处理模式
-				var mode string
-				mode = utils.Ternary[string](width == height, "fill", mode)
-				mode = ctx.DefaultQuery("mode", mode)
+			// 图片处理参数 - size、mode、gravity、quality、rotate、blur、format
+			options, err := facade.ImageParse(ctx.Request.URL.Query(), ext, ctx.GetHeader("Accept"))
+			if err != nil {
+				ctx.JSON(200, gin.H{"code": 400, "msg": err.Error(), "data": nil})
+				break
+			}
 
-				src, err := imaging.Open("public" + path)
-				if err != nil {
-					WriteByte("error.gif")
+			// 水印 - 只有签名地址可以覆盖（签名校验时已包含 watermark 参数）
+			watermark := facade.Watermark.Lazy(path)
+			if strings.HasPrefix(path, "/storage/") && ctx.Query("sig") != "" && ctx.Query("watermark") != "" {
+				if watermark, err = facade.Watermark.Override(ctx.Query("watermark")); err != nil {
+					ctx.JSON(200, gin.H{"code": 400, "msg": err.Error(), "data": nil})
 					break
 				}
-
-				// 处理图片
-				var dstImage *image.NRGBA
-				switch mode {
-				case "fill":
-					// 填充
-					dstImage = imaging.Fill(src, width, height, imaging.Center, imaging.Lanczos)
-				case "resize":
-					// 完全自定义大小
-					dstImage = imaging.Resize(src, width, height, imaging.Lanczos)
-				case "fit":
-					// 等比例缩放
-					dstImage = imaging.Fit(src, width, height, imaging.Lanczos)
-				default:
-					// 等比例缩放
-					dstImage = imaging.Fit(src, width, height, imaging.Lanczos)
-				}
-
-				// 压缩的图片格式
-				var format imaging.Format
-				switch ext {
-				case "jpg", "jpeg":
-					format = imaging.JPEG
-				case "png":
-					format = imaging.PNG
-				case "gif":
-					format = imaging.GIF
-				case "tif", "tiff":
-					format = imaging.TIFF
-				case "bmp":
-					format = imaging.BMP
-				default:
-					format = imaging.JPEG
+			}
+			if watermark != "" {
+				if options == nil {
+					options = facade.ImageOriginal(ext)
 				}
-				buffer := new(bytes.Buffer)
-				// 解决GIG压缩之后不会动的问题
-				err = imaging.Encode(buffer, dstImage, format)
+				options.Watermark = watermark
+			}
 
-				if err != nil {
-					WriteByte("error.gif")
-					break
-				}
+			// 原图
+			if options == nil {
+				Serve(name)
+				break
+			}
 
-				_, err = ctx.Writer.Write(buffer.Bytes())
+			// 缩略图 - 按原图 ETag 和参数缓存
+			etag := mount.ETag(stat)
+			body, err := facade.Image(etag, options, func() ([]byte, error) {
+				file, _, err := mount.Open(name)
 				if err != nil {
-					WriteByte("error.gif")
-					break
+					return nil, err
 				}
-
+				defer func(file io.ReadCloser) {
+					_ = file.Close()
+				}(file)
+				return io.ReadAll(file)
+			})
+			if errors.Is(err, facade.ErrImageAnimated) {
+				ctx.JSON(200, gin.H{"code": 400, "msg": err.Error(), "data": nil})
 				break
 			}
-
-			_, err := ctx.Writer.Write(utils.File().Byte("public" + path).Byte)
 			if err != nil {
 				WriteByte("error.gif")
 				break
 			}
-		// 其他文件
-		case strings.Contains(fileName, "."):
-
-			exist := IsExist("public" + path)
-			if !exist {
-				break
-			}
 
-			if check := IsExist(path); check {
-				// 设置文件类型
-				ctx.Header("Content-Type", utils.Mime.Type(ext)+"; charset=utf-8")
-				_, err := ctx.Writer.Write(utils.File().Byte("public" + path).Byte)
-				if err != nil {
-					ctx.JSON(200, gin.H{"code": 400, "msg": "文件读取失败！", "data": err.Error()})
-					break
-				}
+			// 同一地址按 Accept 返回不同格式，缓存需要区分
+			if options.Auto {
+				ctx.Header("Vary", "Accept")
 			}
+			facade.Static.Content(ctx.Writer, ctx.Request, path, options.Mime(), options.ETag(etag), stat.ModTime(), body)
+		// 其他文件
+		case strings.Contains(fileName, "."):
+			Serve(name)
+		// SPA 路由
+		case mount.SPA(ctx.Writer, ctx.Request, name):
 		// 路由未定义
 		default:
 			ctx.JSON(200, gin.H{"code": 400, "msg": "路由未定义！", "data": nil})
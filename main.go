@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	api "inis/app/api/route"
+	"inis/app/command"
 	dev "inis/app/dev/route"
 	index "inis/app/index/route"
 	"inis/app/middleware"
 	socket "inis/app/socket/route"
 	app "inis/config"
+	"os"
 )
 
 /*
@@ -18,6 +20,15 @@ import (
  */
 func main() {
 
+	// 命令行模式 - 如：./unti cache:gc，执行完直接退出，执行失败时退出码为 1
+	if ok, err := command.Run(os.Args[1:]...); ok {
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 监听服务
 	watch()
 	// 运行服务